@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/config"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+// scheme knows the built-in types and the FlexDaemonsets API, for both cluster clients and file decoding.
+var scheme = runtime.NewScheme()
+
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = flexdaemonsetsv1alpha1.AddToScheme(scheme)
+}
+
+// clusterFlags are the connection flags shared by every command that can talk to a cluster.
+type clusterFlags struct {
+	kubeconfig string
+	context    string
+	namespace  string
+}
+
+func (f *clusterFlags) addTo(fs *flag.FlagSet) {
+	fs.StringVar(&f.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file. Defaults to $KUBECONFIG or ~/.kube/config.")
+	fs.StringVar(&f.context, "context", "", "The kubeconfig context to use. Defaults to the current context.")
+}
+
+// addNamespaceTo registers -n and --namespace, for commands working on namespaced objects.
+func (f *clusterFlags) addNamespaceTo(fs *flag.FlagSet) {
+	fs.StringVar(&f.namespace, "n", "", "Namespace. Defaults to the kubeconfig context's namespace.")
+	fs.StringVar(&f.namespace, "namespace", "", "Same as -n.")
+}
+
+// clientConfig resolves the kubeconfig the same way kubectl does.
+func (f *clusterFlags) clientConfig() clientcmd.ClientConfig {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = f.kubeconfig
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: f.context, Context: clientcmdapi.Context{Namespace: f.namespace}})
+}
+
+// newClient builds an uncached client from the kubeconfig.
+func (f *clusterFlags) newClient() (client.Client, error) {
+	restConfig, err := f.clientConfig().ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("creating client: %w", err)
+	}
+	return c, nil
+}
+
+// resolveNamespace returns the -n flag, or the kubeconfig context's namespace, or "default".
+func (f *clusterFlags) resolveNamespace() (string, error) {
+	namespace, _, err := f.clientConfig().Namespace()
+	if err != nil {
+		return "", fmt.Errorf("resolving namespace: %w", err)
+	}
+	return namespace, nil
+}
+
+// templateDefaultsFlag reads the template defaults from the manager's configuration file, so that commands
+// calculating resources fill in unset template minimums exactly like a manager started with that --config.
+type templateDefaultsFlag struct {
+	path string
+}
+
+func (f *templateDefaultsFlag) addTo(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "manager-config", "",
+		"The manager's configuration file (config.yaml of the flexdaemonsets-manager-config ConfigMap). Its templates "+
+			"section fills in the minimums templates leave unset, as the manager does. Without it no defaults are applied.")
+}
+
+// load returns the defaults of the configuration file, or none if no file was given.
+func (f *templateDefaultsFlag) load() (utils.TemplateDefaults, error) {
+	if f.path == "" {
+		return utils.TemplateDefaults{}, nil
+	}
+	cfg, err := config.Load(f.path)
+	if err != nil {
+		return utils.TemplateDefaults{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return utils.TemplateDefaults{}, fmt.Errorf("configuration file %s: %w", f.path, err)
+	}
+	return cfg.Templates, nil
+}
+
+// parseInterspersed parses flags that may appear before, between or after positional arguments, as kubectl
+// allows, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,796 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/config"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+// Defaults match the objects in manifests/.
+const (
+	defaultManagerNamespace = "flexdaemonsets-system"
+	defaultWebhookConfig    = "flexdaemonsets-mutating-webhook-config"
+	defaultDeployment       = "flexdaemonsets-webhook-deployment"
+	defaultServiceAccount   = "flexdaemonsets-webhook-sa"
+	defaultTLSSecret        = "flexdaemonsets-webhook-tls"
+)
+
+// certExpiryWarning is how long before expiry the serving certificate is reported.
+const certExpiryWarning = 30 * 24 * time.Hour
+
+type checkStatus string
+
+const (
+	checkPass checkStatus = "PASS"
+	checkWarn checkStatus = "WARN"
+	checkFail checkStatus = "FAIL"
+)
+
+// checkResult is one item of the doctor's report.
+type checkResult struct {
+	Check   string      `json:"check"`
+	Status  checkStatus `json:"status"`
+	Message string      `json:"message"`
+	// Hint tells the operator how to fix a warning or failure.
+	Hint string `json:"hint,omitempty"`
+}
+
+// expectedCRD is a CustomResourceDefinition the manager needs, with the manifest that installs it.
+type expectedCRD struct {
+	name     string
+	manifest string
+}
+
+var expectedCRDs = []expectedCRD{
+	{name: "flexdaemonsettemplates.flexdaemonsets.xai", manifest: "manifests/crd.yaml"},
+	{name: "flexdaemonsetnodepods.flexdaemonsets.xai", manifest: "manifests/flexdaemonsets.xai_flexdaemonsetnodepods.yaml"},
+	{name: "flexnodeoverrides.flexdaemonsets.xai", manifest: "manifests/flexdaemonsets.xai_flexnodeoverrides.yaml"},
+}
+
+// permission is an access the manager's service account needs.
+type permission struct {
+	group, resource, subresource string
+	verbs                        []string
+	// namespaced permissions are checked in the manager's namespace, the others cluster-wide.
+	namespaced bool
+	// name, if set, limits the permission to the object of that name.
+	name string
+}
+
+// managerPermissions mirrors the kubebuilder:rbac markers in pkg/controller (manifests/role.yaml). Keep them in sync.
+var managerPermissions = []permission{
+	{group: "", resource: "events", verbs: []string{"create", "patch"}},
+	{group: "", resource: "nodes", verbs: []string{"get", "list", "watch"}},
+	{group: "", resource: "pods", verbs: []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+	{group: "apps", resource: "daemonsets", verbs: []string{"get", "list", "watch", "update", "patch"}},
+	{group: flexdaemonsetsv1alpha1.GroupVersion.Group, resource: "flexdaemonsettemplates", verbs: []string{"get", "list", "watch"}},
+	{group: flexdaemonsetsv1alpha1.GroupVersion.Group, resource: "flexdaemonsetnodepods", verbs: []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+	{group: flexdaemonsetsv1alpha1.GroupVersion.Group, resource: "flexdaemonsetnodepods", subresource: "status", verbs: []string{"get", "update", "patch"}},
+	{group: flexdaemonsetsv1alpha1.GroupVersion.Group, resource: "flexnodeoverrides", verbs: []string{"get", "list", "watch"}},
+	{group: flexdaemonsetsv1alpha1.GroupVersion.Group, resource: "flexnodeoverrides", subresource: "status", verbs: []string{"get", "update", "patch"}},
+}
+
+// leaderElectionPermission is needed when the manager runs with --leader-elect.
+var leaderElectionPermission = permission{group: "coordination.k8s.io", resource: "leases", verbs: []string{"get", "create", "update"}, namespaced: true}
+
+// selfManagedCertPermissions are needed when the manager runs with --cert-mode=self-managed. The webhook
+// configuration is limited to the named one, as in manifests/self-managed-certs.yaml.
+func selfManagedCertPermissions(webhookConfig string) []permission {
+	return []permission{
+		{group: "", resource: "secrets", verbs: []string{"get", "create", "update"}, namespaced: true},
+		{group: "admissionregistration.k8s.io", resource: "mutatingwebhookconfigurations", verbs: []string{"get", "update"}, name: webhookConfig},
+	}
+}
+
+// doctorOptions name the installation's objects.
+type doctorOptions struct {
+	namespace      string
+	webhookConfig  string
+	deployment     string
+	serviceAccount string
+	tlsSecret      string
+}
+
+// doctor runs the checks in order; later checks use what earlier ones found.
+type doctor struct {
+	opts       doctorOptions
+	c          client.Client
+	restConfig *rest.Config
+	results    []checkResult
+
+	webhooks   []admissionregistrationv1.MutatingWebhook
+	caBundle   []byte
+	deployment *appsv1.Deployment
+}
+
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: kubectl flexds doctor [flags] [-o table|json]\n\n"+
+			"Checks the FlexDaemonsets installation: CRDs, the webhook configuration and its caBundle, the webhook\n"+
+			"service and serving certificate, the manager's RBAC, template references and the manager's readiness.\n"+
+			"Exits non-zero if any check fails.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	var cluster clusterFlags
+	var opts doctorOptions
+	var output string
+	cluster.addTo(fs)
+	fs.StringVar(&opts.namespace, "manager-namespace", defaultManagerNamespace, "Namespace the manager is installed in.")
+	fs.StringVar(&opts.webhookConfig, "webhook-config", defaultWebhookConfig, "Name of the MutatingWebhookConfiguration.")
+	fs.StringVar(&opts.deployment, "deployment", defaultDeployment, "Name of the manager Deployment.")
+	fs.StringVar(&opts.serviceAccount, "service-account", defaultServiceAccount, "Name of the manager's ServiceAccount.")
+	fs.StringVar(&opts.tlsSecret, "tls-secret", defaultTLSSecret, "Name of the Secret holding the webhook serving certificate.")
+	fs.StringVar(&output, "o", outputTable, "Output format: table or json.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if output != outputTable && output != outputJSON {
+		return fmt.Errorf("unsupported output format %q", output)
+	}
+
+	restConfig, err := cluster.clientConfig().ClientConfig()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return fmt.Errorf("creating client: %w", err)
+	}
+	d := &doctor{opts: opts, c: c, restConfig: restConfig}
+	d.run(context.Background())
+
+	if output == outputJSON {
+		if err := printJSON(os.Stdout, d.results); err != nil {
+			return err
+		}
+	} else {
+		d.print(os.Stdout)
+	}
+	if failed := d.count(checkFail); failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+func (d *doctor) run(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	d.checkCRDs(ctx)
+	d.checkWebhookConfiguration(ctx)
+	d.checkWebhookService(ctx)
+	// The manager check looks up the Deployment, whose args decide what the certificate and RBAC checks expect.
+	d.checkManager(ctx)
+	d.checkServingCertificate(ctx)
+	d.checkRBAC(ctx)
+	d.checkTemplateReferences(ctx)
+}
+
+func (d *doctor) pass(check, format string, args ...interface{}) {
+	d.results = append(d.results, checkResult{Check: check, Status: checkPass, Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *doctor) warn(check, hint, format string, args ...interface{}) {
+	d.results = append(d.results, checkResult{Check: check, Status: checkWarn, Message: fmt.Sprintf(format, args...), Hint: hint})
+}
+
+func (d *doctor) fail(check, hint, format string, args ...interface{}) {
+	d.results = append(d.results, checkResult{Check: check, Status: checkFail, Message: fmt.Sprintf(format, args...), Hint: hint})
+}
+
+// cannotCheck records a check that could not run, typically because the doctor's own user lacks access.
+func (d *doctor) cannotCheck(check string, err error) {
+	hint := ""
+	if apierrors.IsForbidden(err) {
+		hint = "Run the doctor as a user with read access to the installation, e.g. a cluster admin."
+	}
+	d.warn(check, hint, "could not check: %v", err)
+}
+
+func (d *doctor) count(status checkStatus) int {
+	n := 0
+	for _, result := range d.results {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+func (d *doctor) print(w io.Writer) {
+	for _, result := range d.results {
+		fmt.Fprintf(w, "[%s] %s: %s\n", result.Status, result.Check, result.Message)
+		if result.Hint != "" {
+			fmt.Fprintf(w, "       hint: %s\n", result.Hint)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warning(s), %d failed\n", d.count(checkPass), d.count(checkWarn), d.count(checkFail))
+}
+
+// checkCRDs verifies every CRD is installed, established, and serves and stores the version this build uses.
+func (d *doctor) checkCRDs(ctx context.Context) {
+	version := flexdaemonsetsv1alpha1.GroupVersion.Version
+	for _, expected := range expectedCRDs {
+		check := "CRD " + expected.name
+		hint := fmt.Sprintf("kubectl apply -f %s", expected.manifest)
+		crd := &unstructured.Unstructured{}
+		crd.SetAPIVersion("apiextensions.k8s.io/v1")
+		crd.SetKind("CustomResourceDefinition")
+		if err := d.c.Get(ctx, types.NamespacedName{Name: expected.name}, crd); err != nil {
+			if apierrors.IsNotFound(err) {
+				d.fail(check, hint, "not installed")
+			} else {
+				d.cannotCheck(check, err)
+			}
+			continue
+		}
+
+		established := false
+		conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+		for _, raw := range conditions {
+			condition, _ := raw.(map[string]interface{})
+			if condition["type"] == "Established" && condition["status"] == "True" {
+				established = true
+			}
+		}
+		if !established {
+			d.fail(check, "kubectl describe crd "+expected.name+" and fix the reported problem, then re-apply "+expected.manifest, "not established")
+			continue
+		}
+
+		var served, storage bool
+		var versions []string
+		rawVersions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+		for _, raw := range rawVersions {
+			v, _ := raw.(map[string]interface{})
+			name, _ := v["name"].(string)
+			versions = append(versions, name)
+			if name == version {
+				served, _ = v["served"].(bool)
+				storage, _ = v["storage"].(bool)
+			}
+		}
+		switch {
+		case !served:
+			d.fail(check, "The CRD is from a different release; "+hint, "version %s is not served (versions: %s)", version, strings.Join(versions, ", "))
+		case !storage:
+			d.warn(check, "The CRD is from a different release; "+hint, "version %s is served but not the storage version", version)
+		default:
+			d.pass(check, "installed, established, serving and storing %s", version)
+		}
+	}
+}
+
+// checkWebhookConfiguration verifies the caBundle is a real certificate and points out a failurePolicy that lets an
+// unavailable webhook block pod creation.
+func (d *doctor) checkWebhookConfiguration(ctx context.Context) {
+	check := "Webhook configuration"
+	config := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	if err := d.c.Get(ctx, types.NamespacedName{Name: d.opts.webhookConfig}, config); err != nil {
+		if apierrors.IsNotFound(err) {
+			d.fail(check, "kubectl apply -f manifests/webhook.yaml, or pass --webhook-config if it has another name",
+				"MutatingWebhookConfiguration %s not found", d.opts.webhookConfig)
+		} else {
+			d.cannotCheck(check, err)
+		}
+		return
+	}
+	if len(config.Webhooks) == 0 {
+		d.fail(check, "kubectl apply -f manifests/webhook.yaml", "MutatingWebhookConfiguration %s has no webhooks", config.Name)
+		return
+	}
+	d.webhooks = config.Webhooks
+	d.pass(check, "MutatingWebhookConfiguration %s has %d webhook(s)", config.Name, len(config.Webhooks))
+
+	for _, webhook := range config.Webhooks {
+		caCheck := "Webhook " + webhook.Name + " caBundle"
+		caHint := "Set caBundle to the base64-encoded CA that signed the webhook serving certificate (see \"make generate-certs\"), or let cert-manager inject it."
+		bundle := webhook.ClientConfig.CABundle
+		certs := parseCertificates(bundle)
+		switch {
+		case len(bytes.TrimSpace(bundle)) == 0:
+			d.fail(caCheck, caHint, "caBundle is empty or still the \"Cg==\" placeholder from manifests/webhook.yaml")
+		case len(certs) == 0:
+			d.fail(caCheck, caHint, "caBundle holds no PEM certificate")
+		default:
+			if d.caBundle == nil {
+				d.caBundle = bundle
+			}
+			d.pass(caCheck, "%d CA certificate(s), first subject %q", len(certs), certs[0].Subject.String())
+		}
+
+		policyCheck := "Webhook " + webhook.Name + " failurePolicy"
+		// failurePolicy defaults to Fail in admissionregistration.k8s.io/v1.
+		if webhook.FailurePolicy != nil && *webhook.FailurePolicy == admissionregistrationv1.Ignore {
+			d.pass(policyCheck, "Ignore: pods are created unsized while the webhook is unavailable")
+			continue
+		}
+		if webhook.NamespaceSelector == nil || (len(webhook.NamespaceSelector.MatchLabels) == 0 && len(webhook.NamespaceSelector.MatchExpressions) == 0) {
+			d.warn(policyCheck, "Set failurePolicy: Ignore, or add a namespaceSelector excluding kube-system and "+d.opts.namespace+".",
+				"Fail with no namespaceSelector: while the webhook is unavailable, no pod can be created in any namespace, including the manager's own")
+			continue
+		}
+		d.pass(policyCheck, "Fail, restricted by a namespaceSelector")
+	}
+}
+
+// checkWebhookService verifies the service the webhook calls exists, has ready endpoints, and answers through
+// the API server.
+func (d *doctor) checkWebhookService(ctx context.Context) {
+	for _, webhook := range d.webhooks {
+		ref := webhook.ClientConfig.Service
+		check := "Webhook " + webhook.Name + " service"
+		if ref == nil {
+			d.pass(check, "calls URL %s; service checks skipped", valueOr(stringValue(webhook.ClientConfig.URL), "<none>"))
+			continue
+		}
+		port := int32(443)
+		if ref.Port != nil {
+			port = *ref.Port
+		}
+
+		svc := &corev1.Service{}
+		if err := d.c.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, svc); err != nil {
+			if apierrors.IsNotFound(err) {
+				d.fail(check, "kubectl apply -f manifests/deployment.yaml", "service %s/%s not found", ref.Namespace, ref.Name)
+			} else {
+				d.cannotCheck(check, err)
+			}
+			continue
+		}
+		hasPort := false
+		for _, p := range svc.Spec.Ports {
+			if p.Port == port {
+				hasPort = true
+			}
+		}
+		if !hasPort {
+			d.fail(check, "Make the webhook's clientConfig.service.port match a port of the service.", "service %s/%s has no port %d", ref.Namespace, ref.Name, port)
+			continue
+		}
+
+		var slices discoveryv1.EndpointSliceList
+		if err := d.c.List(ctx, &slices, client.InNamespace(ref.Namespace), client.MatchingLabels{discoveryv1.LabelServiceName: ref.Name}); err != nil {
+			d.cannotCheck(check, err)
+			continue
+		}
+		ready := 0
+		for _, slice := range slices.Items {
+			for _, endpoint := range slice.Endpoints {
+				if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+					ready++
+				}
+			}
+		}
+		if ready == 0 {
+			d.fail(check, "Check that the manager pods are running and ready, and that the service selector matches their labels.",
+				"service %s/%s has no ready endpoints", ref.Namespace, ref.Name)
+			continue
+		}
+
+		// A request through the API server's service proxy takes the path the API server takes for admission.
+		// Any HTTP answer from the webhook server, even an error for the GET, proves it is reachable.
+		clientset, err := kubernetes.NewForConfig(d.restConfig)
+		if err != nil {
+			d.cannotCheck(check, err)
+			continue
+		}
+		_, err = clientset.CoreV1().Services(ref.Namespace).ProxyGet("https", ref.Name, fmt.Sprint(port), stringValue(ref.Path), nil).DoRaw(ctx)
+		switch {
+		case err == nil:
+		case apierrors.IsForbidden(err):
+			d.warn(check, "Grant the doctor's user services/proxy to test reachability.", "%d ready endpoint(s); reachability not tested: %v", ready, err)
+			continue
+		case isProxyConnectionError(err):
+			d.fail(check, "Check that the webhook server listens on the service's targetPort (9443 by default) and that no NetworkPolicy blocks the API server.",
+				"%d ready endpoint(s), but the API server cannot reach the webhook: %v", ready, err)
+			continue
+		}
+		d.pass(check, "service %s/%s:%d has %d ready endpoint(s) and answers through the API server", ref.Namespace, ref.Name, port, ready)
+	}
+}
+
+// isProxyConnectionError reports whether the service proxy failed to connect, as opposed to relaying an error
+// answered by the webhook server.
+func isProxyConnectionError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "error trying to reach service") || strings.Contains(msg, "no endpoints available") ||
+		strings.Contains(msg, "connection refused")
+}
+
+// checkServingCertificate verifies the serving certificate is signed by the caBundle, covers the service's DNS
+// name and is not about to expire.
+func (d *doctor) checkServingCertificate(ctx context.Context) {
+	check := "Serving certificate"
+	secret := &corev1.Secret{}
+	if err := d.c.Get(ctx, types.NamespacedName{Namespace: d.opts.namespace, Name: d.opts.tlsSecret}, secret); err != nil {
+		if apierrors.IsNotFound(err) && managerSelfManagesCerts(d.deployment) {
+			d.fail(check, "The leader creates it with --cert-mode=self-managed; check the manager's logs and its access to secrets.",
+				"secret %s/%s not found", d.opts.namespace, d.opts.tlsSecret)
+		} else if apierrors.IsNotFound(err) {
+			d.fail(check, "Create it with \"kubectl create secret tls "+d.opts.tlsSecret+" --cert=tls.crt --key=tls.key -n "+d.opts.namespace+"\", or pass --tls-secret.",
+				"secret %s/%s not found", d.opts.namespace, d.opts.tlsSecret)
+		} else {
+			d.cannotCheck(check, err)
+		}
+		return
+	}
+	chain := parseCertificates(secret.Data[corev1.TLSCertKey])
+	if len(chain) == 0 {
+		d.fail(check, "Recreate the secret from a PEM certificate.", "secret %s/%s has no PEM certificate in %s", d.opts.namespace, d.opts.tlsSecret, corev1.TLSCertKey)
+		return
+	}
+	leaf := chain[0]
+
+	now := time.Now()
+	switch {
+	case now.After(leaf.NotAfter):
+		d.fail(check, "Issue a new serving certificate and update the secret.", "expired at %s", leaf.NotAfter.Format(time.RFC3339))
+	case leaf.NotAfter.Sub(now) < certExpiryWarning:
+		d.warn(check, "Renew the serving certificate before it expires.", "expires at %s", leaf.NotAfter.Format(time.RFC3339))
+	default:
+		d.pass(check, "valid until %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	if d.caBundle == nil {
+		return
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(d.caBundle)
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		d.fail("Webhook CA", "Set the webhook's caBundle to the CA that signed the certificate in secret "+d.opts.tlsSecret+", or reissue the certificate from that CA.",
+			"caBundle does not verify the serving certificate: %v", err)
+	} else {
+		d.pass("Webhook CA", "caBundle verifies the serving certificate")
+	}
+
+	for _, webhook := range d.webhooks {
+		ref := webhook.ClientConfig.Service
+		if ref == nil {
+			continue
+		}
+		host := ref.Name + "." + ref.Namespace + ".svc"
+		sanCheck := "Serving certificate SAN " + host
+		if err := leaf.VerifyHostname(host); err != nil {
+			d.fail(sanCheck, "Reissue the certificate with "+host+" (and "+host+".cluster.local) as a DNS SAN.",
+				"not covered; certificate DNS names: %s", valueOr(strings.Join(leaf.DNSNames, ", "), "<none>"))
+		} else {
+			d.pass(sanCheck, "covered")
+		}
+	}
+}
+
+// checkManager verifies the manager Deployment is ready and not running several active replicas.
+func (d *doctor) checkManager(ctx context.Context) {
+	check := "Manager deployment"
+	deployment := &appsv1.Deployment{}
+	if err := d.c.Get(ctx, types.NamespacedName{Namespace: d.opts.namespace, Name: d.opts.deployment}, deployment); err != nil {
+		if apierrors.IsNotFound(err) {
+			d.fail(check, "kubectl apply -f manifests/deployment.yaml, or pass --deployment and --manager-namespace.",
+				"deployment %s/%s not found", d.opts.namespace, d.opts.deployment)
+		} else {
+			d.cannotCheck(check, err)
+		}
+		return
+	}
+	d.deployment = deployment
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	switch {
+	case deployment.Status.ReadyReplicas == 0:
+		d.fail(check, d.notReadyHint(ctx, deployment), "no ready replicas (%d desired)", replicas)
+	case deployment.Status.ReadyReplicas < replicas:
+		d.warn(check, d.notReadyHint(ctx, deployment), "%d of %d replicas ready", deployment.Status.ReadyReplicas, replicas)
+	default:
+		d.pass(check, "%d of %d replicas ready", deployment.Status.ReadyReplicas, replicas)
+	}
+
+	if replicas > 1 && !managerLeaderElects(deployment) {
+		d.warn("Manager leader election", "Add --leader-elect to the manager's args.",
+			"%d replicas without --leader-elect: every replica runs the controllers and they race on the same objects", replicas)
+	}
+}
+
+// notReadyHint names the manager pods that are not ready and why.
+func (d *doctor) notReadyHint(ctx context.Context, deployment *appsv1.Deployment) string {
+	hint := fmt.Sprintf("kubectl -n %s describe deployment %s and check the manager logs.", deployment.Namespace, deployment.Name)
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return hint
+	}
+	var pods corev1.PodList
+	if err := d.c.List(ctx, &pods, client.InNamespace(deployment.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return hint
+	}
+	var reasons []string
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Ready {
+				continue
+			}
+			reason := string(pod.Status.Phase)
+			if status.State.Waiting != nil {
+				reason = status.State.Waiting.Reason
+			} else if status.State.Terminated != nil {
+				reason = status.State.Terminated.Reason
+			}
+			reasons = append(reasons, fmt.Sprintf("%s: %s", pod.Name, reason))
+		}
+	}
+	if len(reasons) == 0 {
+		return hint
+	}
+	return fmt.Sprintf("Not ready: %s. %s", strings.Join(reasons, ", "), hint)
+}
+
+func managerLeaderElects(deployment *appsv1.Deployment) bool {
+	return hasManagerArg(deployment, "--leader-elect", "--leader-elect=true")
+}
+
+// managerSelfManagesCerts reports whether the manager generates and rotates its own serving certificate.
+func managerSelfManagesCerts(deployment *appsv1.Deployment) bool {
+	return deployment != nil && hasManagerArg(deployment, "--cert-mode=self-managed")
+}
+
+// managerNamespaceRestriction returns the --namespaces and --namespace-selector the manager runs with. Both are
+// empty when it watches every namespace.
+func managerNamespaceRestriction(deployment *appsv1.Deployment) (*config.ManagerConfiguration, error) {
+	cfg := &config.ManagerConfiguration{}
+	if deployment == nil {
+		return cfg, nil
+	}
+	fs := flag.NewFlagSet("manager", flag.ContinueOnError)
+	cfg.BindFlags(fs)
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		for _, arg := range append(append([]string{}, container.Command...), container.Args...) {
+			for _, name := range []string{"namespaces", "namespace-selector"} {
+				if value, ok := strings.CutPrefix(arg, "--"+name+"="); ok {
+					if err := fs.Set(name, value); err != nil {
+						return nil, fmt.Errorf("manager flag --%s: %w", name, err)
+					}
+				}
+			}
+		}
+	}
+	return cfg, nil
+}
+
+func hasManagerArg(deployment *appsv1.Deployment, args ...string) bool {
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		for _, arg := range append(append([]string{}, container.Command...), container.Args...) {
+			for _, want := range args {
+				if arg == want {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// checkRBAC asks the API server, as the manager's service account, whether every permission the manager needs is
+// granted. This catches a ClusterRole that lags behind manifests/role.yaml or a binding to the wrong subject. A
+// namespace-restricted manager only needs its namespaced permissions in the namespaces it watches, so they are
+// checked there instead of cluster-wide.
+func (d *doctor) checkRBAC(ctx context.Context) {
+	subject := fmt.Sprintf("system:serviceaccount:%s:%s", d.opts.namespace, d.opts.serviceAccount)
+	check := "RBAC for " + subject
+
+	impersonated := rest.CopyConfig(d.restConfig)
+	impersonated.Impersonate = rest.ImpersonationConfig{
+		UserName: subject,
+		Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:" + d.opts.namespace, "system:authenticated"},
+	}
+	c, err := client.New(impersonated, client.Options{Scheme: scheme})
+	if err != nil {
+		d.cannotCheck(check, err)
+		return
+	}
+
+	restriction, err := managerNamespaceRestriction(d.deployment)
+	if err != nil {
+		d.cannotCheck(check, err)
+		return
+	}
+	var watched []string
+	if restriction.Restricted() {
+		if watched, err = restriction.ResolveNamespaces(ctx, d.c); err != nil {
+			d.cannotCheck(check, err)
+			return
+		}
+	}
+
+	// Every check is a permission and the namespace it is needed in; "" is cluster-wide.
+	type scopedPermission struct {
+		permission
+		namespace string
+	}
+	var permissions []scopedPermission
+	add := func(p permission) {
+		if p.namespaced {
+			permissions = append(permissions, scopedPermission{p, d.opts.namespace})
+		} else {
+			permissions = append(permissions, scopedPermission{p, ""})
+		}
+	}
+	for _, p := range managerPermissions {
+		if watched == nil || clusterScopedResources[p.resource] {
+			add(p)
+			continue
+		}
+		for _, namespace := range watched {
+			permissions = append(permissions, scopedPermission{p, namespace})
+		}
+	}
+	if restriction.NamespaceSelector != nil {
+		add(namespaceReadPermission)
+	}
+	if d.deployment != nil && managerLeaderElects(d.deployment) {
+		add(leaderElectionPermission)
+	}
+	if managerSelfManagesCerts(d.deployment) {
+		for _, p := range selfManagedCertPermissions(d.opts.webhookConfig) {
+			add(p)
+		}
+	}
+	var missing []string
+	for _, p := range permissions {
+		for _, verb := range p.verbs {
+			attributes := &authorizationv1.ResourceAttributes{Group: p.group, Resource: p.resource, Subresource: p.subresource, Verb: verb, Namespace: p.namespace, Name: p.name}
+			review := &authorizationv1.SelfSubjectAccessReview{Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attributes}}
+			if err := c.Create(ctx, review); err != nil {
+				if apierrors.IsForbidden(err) {
+					d.warn(check, "Run the doctor as a user allowed to impersonate service accounts, e.g. a cluster admin.", "could not impersonate the service account: %v", err)
+				} else {
+					d.cannotCheck(check, err)
+				}
+				return
+			}
+			if !review.Status.Allowed {
+				missing = append(missing, describePermission(p.permission, verb, p.namespace))
+			}
+		}
+	}
+	if len(missing) > 0 {
+		hint := "kubectl apply -f manifests/role.yaml -f manifests/rbac.yaml, and check the ClusterRoleBinding's subject."
+		if watched != nil {
+			hint = "kubectl apply -f manifests/role.yaml, then kubectl flexds restrict with the manager's namespace flags | kubectl apply -f -."
+		}
+		if managerSelfManagesCerts(d.deployment) {
+			hint += " Self-managed certificates also need manifests/self-managed-certs.yaml."
+		}
+		d.fail(check, hint, "missing %s", strings.Join(missing, ", "))
+		return
+	}
+	d.pass(check, "all %d required permissions granted", len(permissions))
+}
+
+func describePermission(p permission, verb, namespace string) string {
+	resource := p.resource
+	if p.subresource != "" {
+		resource += "/" + p.subresource
+	}
+	if p.group != "" {
+		resource += "." + p.group
+	}
+	if p.name != "" {
+		resource += " " + p.name
+	}
+	if namespace != "" {
+		resource += " in " + namespace
+	}
+	return verb + " " + resource
+}
+
+// checkTemplateReferences finds DaemonSets and overrides naming a template that does not exist; their pods are
+// admitted unsized.
+func (d *doctor) checkTemplateReferences(ctx context.Context) {
+	check := "Template references"
+	var templates flexdaemonsetsv1alpha1.FlexDaemonsetTemplateList
+	if err := d.c.List(ctx, &templates); err != nil {
+		d.cannotCheck(check, err)
+		return
+	}
+	existing := map[string]bool{}
+	for _, template := range templates.Items {
+		existing[template.Name] = true
+	}
+
+	var daemonSets appsv1.DaemonSetList
+	if err := d.c.List(ctx, &daemonSets); err != nil {
+		d.cannotCheck(check, err)
+		return
+	}
+	var dangling []string
+	referenced := 0
+	for _, ds := range daemonSets.Items {
+		name, ok := ds.Annotations[utils.FlexDaemonsetTemplateAnnotation]
+		if !ok {
+			continue
+		}
+		referenced++
+		if !existing[name] {
+			dangling = append(dangling, fmt.Sprintf("DaemonSet %s/%s -> %q", ds.Namespace, ds.Name, name))
+		}
+	}
+
+	var overrides flexdaemonsetsv1alpha1.FlexNodeOverrideList
+	if err := d.c.List(ctx, &overrides); err != nil {
+		d.cannotCheck(check, err)
+		return
+	}
+	for _, override := range overrides.Items {
+		if name := override.Spec.TemplateName; name != "" {
+			referenced++
+			if !existing[name] {
+				dangling = append(dangling, fmt.Sprintf("FlexNodeOverride %s -> %q", override.Name, name))
+			}
+		}
+	}
+
+	if len(dangling) > 0 {
+		d.fail(check, "Create the missing FlexDaemonsetTemplates or fix the "+utils.FlexDaemonsetTemplateAnnotation+" annotations and override templateNames.",
+			"%d reference(s) to missing templates: %s", len(dangling), strings.Join(dangling, "; "))
+		return
+	}
+	d.pass(check, "%d reference(s) to %d template(s) all resolve", referenced, len(templates.Items))
+}
+
+// parseCertificates returns the certificates in PEM data, skipping anything else.
+func parseCertificates(data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		certs = append(certs, cert)
+	}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
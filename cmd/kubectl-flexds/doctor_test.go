@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+)
+
+// testCertificate is a PEM certificate signed by parent, or self-signed if parent is nil.
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCertificate(t *testing.T, parent *testCertificate, notAfter time.Time, dnsNames ...string) *testCertificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "flexdaemonsets-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     dnsNames,
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func testCRD(name string, established, served, storage bool) *unstructured.Unstructured {
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"versions": []interface{}{
+			map[string]interface{}{"name": flexdaemonsetsv1alpha1.GroupVersion.Version, "served": served, "storage": storage},
+		}},
+	}}
+	crd.SetAPIVersion("apiextensions.k8s.io/v1")
+	crd.SetKind("CustomResourceDefinition")
+	crd.SetName(name)
+	if established {
+		crd.Object["status"] = map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "Established", "status": "True"},
+		}}
+	}
+	return crd
+}
+
+func testWebhookConfiguration(caBundle []byte, policy admissionregistrationv1.FailurePolicyType, selector *metav1.LabelSelector) *admissionregistrationv1.MutatingWebhookConfiguration {
+	port := int32(443)
+	return &admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultWebhookConfig},
+		Webhooks: []admissionregistrationv1.MutatingWebhook{{
+			Name:              "pods.flexdaemonsets.xai",
+			FailurePolicy:     &policy,
+			NamespaceSelector: selector,
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				CABundle: caBundle,
+				Service:  &admissionregistrationv1.ServiceReference{Namespace: defaultManagerNamespace, Name: "flexdaemonsets-webhook", Port: &port},
+			},
+		}},
+	}
+}
+
+func testManagerDeployment(replicas, ready int32, args ...string) *appsv1.Deployment {
+	labels := map[string]string{"app": "flexdaemonsets"}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: defaultManagerNamespace, Name: defaultDeployment},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "manager", Args: args}}},
+			},
+		},
+		Status: appsv1.DeploymentStatus{ReadyReplicas: ready},
+	}
+}
+
+func newTestDoctor(t *testing.T, objs ...client.Object) *doctor {
+	return &doctor{
+		opts: doctorOptions{
+			namespace:      defaultManagerNamespace,
+			webhookConfig:  defaultWebhookConfig,
+			deployment:     defaultDeployment,
+			serviceAccount: defaultServiceAccount,
+			tlsSecret:      defaultTLSSecret,
+		},
+		c: newFakeClient(t, objs...),
+	}
+}
+
+// statuses returns the doctor's results as "STATUS check" lines.
+func (d *doctor) statuses() []string {
+	var lines []string
+	for _, result := range d.results {
+		lines = append(lines, string(result.Status)+" "+result.Check)
+	}
+	return lines
+}
+
+func TestDoctorCheckCRDs(t *testing.T) {
+	name := expectedCRDs[0].name
+	tests := []struct {
+		name string
+		crd  *unstructured.Unstructured
+		want checkStatus
+	}{
+		{name: "missing", want: checkFail},
+		{name: "not established", crd: testCRD(name, false, true, true), want: checkFail},
+		{name: "version not served", crd: testCRD(name, true, false, false), want: checkFail},
+		{name: "not the storage version", crd: testCRD(name, true, true, false), want: checkWarn},
+		{name: "healthy", crd: testCRD(name, true, true, true), want: checkPass},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objs []client.Object
+			if tt.crd != nil {
+				objs = append(objs, tt.crd)
+			}
+			d := newTestDoctor(t, objs...)
+			d.checkCRDs(context.Background())
+			if got := d.results[0]; got.Check != "CRD "+name || got.Status != tt.want {
+				t.Errorf("result = %+v, want %s for CRD %s", got, tt.want, name)
+			}
+		})
+	}
+}
+
+func TestDoctorCheckWebhookConfiguration(t *testing.T) {
+	ca := newTestCertificate(t, nil, time.Now().Add(time.Hour))
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"flexdaemonsets": "enabled"}}
+	tests := []struct {
+		name   string
+		config *admissionregistrationv1.MutatingWebhookConfiguration
+		want   []string
+	}{
+		{name: "missing", want: []string{"FAIL Webhook configuration"}},
+		{
+			name:   "placeholder caBundle and unrestricted Fail",
+			config: testWebhookConfiguration([]byte("\n"), admissionregistrationv1.Fail, nil),
+			want: []string{"PASS Webhook configuration", "FAIL Webhook pods.flexdaemonsets.xai caBundle",
+				"WARN Webhook pods.flexdaemonsets.xai failurePolicy"},
+		},
+		{
+			name:   "caBundle without a certificate",
+			config: testWebhookConfiguration([]byte("not a certificate"), admissionregistrationv1.Ignore, nil),
+			want: []string{"PASS Webhook configuration", "FAIL Webhook pods.flexdaemonsets.xai caBundle",
+				"PASS Webhook pods.flexdaemonsets.xai failurePolicy"},
+		},
+		{
+			name:   "healthy",
+			config: testWebhookConfiguration(ca.pem, admissionregistrationv1.Fail, selector),
+			want: []string{"PASS Webhook configuration", "PASS Webhook pods.flexdaemonsets.xai caBundle",
+				"PASS Webhook pods.flexdaemonsets.xai failurePolicy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objs []client.Object
+			if tt.config != nil {
+				objs = append(objs, tt.config)
+			}
+			d := newTestDoctor(t, objs...)
+			d.checkWebhookConfiguration(context.Background())
+			if got := d.statuses(); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("results = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDoctorCheckWebhookService(t *testing.T) {
+	webhooks := testWebhookConfiguration(nil, admissionregistrationv1.Fail, nil).Webhooks
+	service := func(port int32) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: defaultManagerNamespace, Name: "flexdaemonsets-webhook"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: port}}},
+		}
+	}
+	tests := []struct {
+		name        string
+		objs        []client.Object
+		wantMessage string
+	}{
+		{name: "service missing", wantMessage: "not found"},
+		{name: "wrong port", objs: []client.Object{service(8443)}, wantMessage: "has no port 443"},
+		{name: "no endpoints", objs: []client.Object{service(443)}, wantMessage: "no ready endpoints"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDoctor(t, tt.objs...)
+			d.webhooks = webhooks
+			d.checkWebhookService(context.Background())
+			if len(d.results) != 1 || d.results[0].Status != checkFail || !strings.Contains(d.results[0].Message, tt.wantMessage) {
+				t.Errorf("results = %+v, want a failure containing %q", d.results, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestDoctorCheckServingCertificate(t *testing.T) {
+	host := "flexdaemonsets-webhook." + defaultManagerNamespace + ".svc"
+	ca := newTestCertificate(t, nil, time.Now().Add(365*24*time.Hour))
+	otherCA := newTestCertificate(t, nil, time.Now().Add(365*24*time.Hour))
+	valid := newTestCertificate(t, ca, time.Now().Add(90*24*time.Hour), host)
+	secret := func(cert *testCertificate) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: defaultManagerNamespace, Name: defaultTLSSecret},
+			Data:       map[string][]byte{corev1.TLSCertKey: cert.pem},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		cert     *testCertificate
+		caBundle []byte
+		want     []string
+	}{
+		{name: "secret missing", want: []string{"FAIL Serving certificate"}},
+		{name: "valid", cert: valid, caBundle: ca.pem,
+			want: []string{"PASS Serving certificate", "PASS Webhook CA", "PASS Serving certificate SAN " + host}},
+		{name: "expiring soon", cert: newTestCertificate(t, ca, time.Now().Add(7*24*time.Hour), host), caBundle: ca.pem,
+			want: []string{"WARN Serving certificate", "PASS Webhook CA", "PASS Serving certificate SAN " + host}},
+		{name: "signed by another CA", cert: valid, caBundle: otherCA.pem,
+			want: []string{"PASS Serving certificate", "FAIL Webhook CA", "PASS Serving certificate SAN " + host}},
+		{name: "wrong DNS name", cert: newTestCertificate(t, ca, time.Now().Add(90*24*time.Hour), "webhook.example.com"), caBundle: ca.pem,
+			want: []string{"PASS Serving certificate", "PASS Webhook CA", "FAIL Serving certificate SAN " + host}},
+		{name: "no caBundle to verify against", cert: valid, want: []string{"PASS Serving certificate"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objs []client.Object
+			if tt.cert != nil {
+				objs = append(objs, secret(tt.cert))
+			}
+			d := newTestDoctor(t, objs...)
+			d.webhooks = testWebhookConfiguration(tt.caBundle, admissionregistrationv1.Fail, nil).Webhooks
+			d.caBundle = tt.caBundle
+			d.checkServingCertificate(context.Background())
+			if got := d.statuses(); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("results = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDoctorCheckManager(t *testing.T) {
+	crashing := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: defaultManagerNamespace, Name: "manager-1", Labels: map[string]string{"app": "flexdaemonsets"}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{{
+			Name: "manager", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		}}},
+	}
+	tests := []struct {
+		name     string
+		objs     []client.Object
+		want     []string
+		wantHint string
+	}{
+		{name: "missing", want: []string{"FAIL Manager deployment"}},
+		{name: "ready", objs: []client.Object{testManagerDeployment(1, 1)}, want: []string{"PASS Manager deployment"}},
+		{name: "not ready names the pod", objs: []client.Object{testManagerDeployment(1, 0), crashing},
+			want: []string{"FAIL Manager deployment"}, wantHint: "manager-1: CrashLoopBackOff"},
+		{name: "partly ready", objs: []client.Object{testManagerDeployment(2, 1, "--leader-elect")}, want: []string{"WARN Manager deployment"}},
+		{name: "replicas without leader election", objs: []client.Object{testManagerDeployment(2, 2)},
+			want: []string{"PASS Manager deployment", "WARN Manager leader election"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDoctor(t, tt.objs...)
+			d.checkManager(context.Background())
+			if got := d.statuses(); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("results = %q, want %q", got, tt.want)
+			}
+			if tt.wantHint != "" && !strings.Contains(d.results[0].Hint, tt.wantHint) {
+				t.Errorf("hint = %q, want it to contain %q", d.results[0].Hint, tt.wantHint)
+			}
+		})
+	}
+}
+
+func TestDoctorCheckTemplateReferences(t *testing.T) {
+	override := &flexdaemonsetsv1alpha1.FlexNodeOverride{
+		ObjectMeta: metav1.ObjectMeta{Name: "gpu-nodes"},
+		Spec:       flexdaemonsetsv1alpha1.FlexNodeOverrideSpec{TemplateName: "large"},
+	}
+	tests := []struct {
+		name        string
+		objs        []client.Object
+		want        checkStatus
+		wantMessage string
+	}{
+		{name: "all resolve", objs: []client.Object{testTemplateObject(nil), testDaemonSetObject("100m", "64Mi")}, want: checkPass,
+			wantMessage: "1 reference(s) to 1 template(s)"},
+		{name: "DaemonSet names a missing template", objs: []client.Object{testDaemonSetObject("100m", "64Mi")}, want: checkFail,
+			wantMessage: "DaemonSet monitoring/node-exporter -> \"small\""},
+		{name: "override names a missing template", objs: []client.Object{testTemplateObject(nil), override}, want: checkFail,
+			wantMessage: "FlexNodeOverride gpu-nodes -> \"large\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDoctor(t, tt.objs...)
+			d.checkTemplateReferences(context.Background())
+			if got := d.results[0]; got.Status != tt.want || !strings.Contains(got.Message, tt.wantMessage) {
+				t.Errorf("result = %+v, want %s containing %q", got, tt.want, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestManagerNamespaceRestriction(t *testing.T) {
+	tests := []struct {
+		name           string
+		deployment     *appsv1.Deployment
+		wantNamespaces []string
+		wantSelector   bool
+		wantErr        bool
+	}{
+		{name: "no deployment"},
+		{name: "every namespace", deployment: testManagerDeployment(1, 1, "--leader-elect")},
+		{name: "namespaces", deployment: testManagerDeployment(1, 1, "--namespaces=monitoring,logging"), wantNamespaces: []string{"monitoring", "logging"}},
+		{name: "selector", deployment: testManagerDeployment(1, 1, "--namespace-selector=team=platform"), wantSelector: true},
+		{name: "invalid selector", deployment: testManagerDeployment(1, 1, "--namespace-selector=team in"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := managerNamespaceRestriction(tt.deployment)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("managerNamespaceRestriction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if strings.Join(cfg.Namespaces, ",") != strings.Join(tt.wantNamespaces, ",") || (cfg.NamespaceSelector != nil) != tt.wantSelector {
+				t.Errorf("namespaces %v, selector %v; want %v, selector %v", cfg.Namespaces, cfg.NamespaceSelector, tt.wantNamespaces, tt.wantSelector)
+			}
+		})
+	}
+}
+
+func TestDescribePermission(t *testing.T) {
+	tests := []struct {
+		p         permission
+		namespace string
+		want      string
+	}{
+		{p: permission{resource: "pods"}, want: "list pods"},
+		{p: permission{group: "flexdaemonsets.xai", resource: "flexdaemonsetnodepods", subresource: "status"}, namespace: "monitoring",
+			want: "list flexdaemonsetnodepods/status.flexdaemonsets.xai in monitoring"},
+		{p: permission{group: "admissionregistration.k8s.io", resource: "mutatingwebhookconfigurations", name: defaultWebhookConfig},
+			want: "list mutatingwebhookconfigurations.admissionregistration.k8s.io " + defaultWebhookConfig},
+	}
+	for _, tt := range tests {
+		if got := describePermission(tt.p, "list", tt.namespace); got != tt.want {
+			t.Errorf("describePermission() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/audit"
+	flexcontroller "github.com/prakarsh-dt/FlexDaemonsets/pkg/controller"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+// podTrace is everything that determines a pod's resources, as resolved from the cluster.
+type podTrace struct {
+	pod       *corev1.Pod
+	ds        *appsv1.DaemonSet
+	fdnp      *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod
+	template  *flexdaemonsetsv1alpha1.FlexDaemonsetTemplate
+	node      *corev1.Node
+	decision  *audit.Decision
+	notes     []string
+	overrides []flexdaemonsetsv1alpha1.FlexNodeOverride
+	// defaults are the manager's template defaults, applied to the template before calculating.
+	defaults utils.TemplateDefaults
+}
+
+func runExplain(args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: kubectl flexds explain pod <name> [-n <namespace>]\n\n"+
+			"Resolves the pod's DaemonSet, template, node and overrides, reruns the calculation step by step and\n"+
+			"flags any difference between the pod's live resources and what the calculator produces now.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	var cluster clusterFlags
+	cluster.addTo(fs)
+	cluster.addNamespaceTo(fs)
+	var defaults templateDefaultsFlag
+	defaults.addTo(fs)
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 1 && strings.HasPrefix(positional[0], "pod/") {
+		positional = []string{"pod", strings.TrimPrefix(positional[0], "pod/")}
+	}
+	if len(positional) != 2 || (positional[0] != "pod" && positional[0] != "pods" && positional[0] != "po") {
+		fs.Usage()
+		return errors.New("expected \"pod <name>\"")
+	}
+
+	namespace, err := cluster.resolveNamespace()
+	if err != nil {
+		return err
+	}
+	c, err := cluster.newClient()
+	if err != nil {
+		return err
+	}
+	trace, err := resolvePodTrace(context.Background(), c, types.NamespacedName{Namespace: namespace, Name: positional[1]})
+	if err != nil {
+		return err
+	}
+	if trace.defaults, err = defaults.load(); err != nil {
+		return err
+	}
+	mismatches := trace.print(os.Stdout)
+	if mismatches > 0 {
+		return fmt.Errorf("%d mismatch(es) between the pod's live resources and the current calculation", mismatches)
+	}
+	return nil
+}
+
+// resolvePodTrace reads the pod and everything its sizing depends on. Missing pieces are recorded as notes, so
+// the trace explains as much as it can.
+func resolvePodTrace(ctx context.Context, c client.Reader, key types.NamespacedName) (*podTrace, error) {
+	trace := &podTrace{pod: &corev1.Pod{}}
+	if err := c.Get(ctx, key, trace.pod); err != nil {
+		return nil, fmt.Errorf("getting pod %s: %w", key, err)
+	}
+	pod := trace.pod
+
+	if value, ok := pod.Annotations[audit.DecisionAnnotation]; ok {
+		decision, err := audit.DecodeDecision(value)
+		if err != nil {
+			trace.notes = append(trace.notes, fmt.Sprintf("the %s annotation is malformed: %v", audit.DecisionAnnotation, err))
+		} else {
+			trace.decision = decision
+		}
+	}
+
+	// DaemonSet pods are controlled by their DaemonSet; pods standing in for them are controlled by a
+	// FlexDaemonSetNodePod that names the DaemonSet.
+	dsKey := types.NamespacedName{Namespace: pod.Namespace}
+	owner := metav1.GetControllerOf(pod)
+	switch {
+	case owner != nil && owner.Kind == "DaemonSet":
+		dsKey.Name = owner.Name
+	case owner != nil && owner.Kind == "FlexDaemonSetNodePod":
+		trace.fdnp = &flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: owner.Name}, trace.fdnp); err != nil {
+			return nil, fmt.Errorf("getting FlexDaemonSetNodePod %s/%s: %w", pod.Namespace, owner.Name, err)
+		}
+		dsKey = types.NamespacedName{Namespace: trace.fdnp.Spec.DaemonSetNamespace, Name: trace.fdnp.Spec.DaemonSetName}
+	default:
+		return nil, fmt.Errorf("pod %s is neither controlled by a DaemonSet nor by a FlexDaemonSetNodePod", key)
+	}
+	trace.ds = &appsv1.DaemonSet{}
+	if err := c.Get(ctx, dsKey, trace.ds); err != nil {
+		return nil, fmt.Errorf("getting DaemonSet %s: %w", dsKey, err)
+	}
+
+	templateName := trace.ds.Annotations[utils.FlexDaemonsetTemplateAnnotation]
+	if templateName == "" && trace.decision != nil {
+		templateName = trace.decision.Template
+		trace.notes = append(trace.notes, "the DaemonSet no longer carries the template annotation; using the template recorded on the pod")
+	}
+	if templateName != "" {
+		trace.template = &flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{}
+		if err := c.Get(ctx, types.NamespacedName{Name: templateName}, trace.template); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("getting FlexDaemonsetTemplate %s: %w", templateName, err)
+			}
+			trace.notes = append(trace.notes, fmt.Sprintf("FlexDaemonsetTemplate %s does not exist", templateName))
+			trace.template = nil
+		}
+	}
+
+	if pod.Spec.NodeName == "" {
+		trace.notes = append(trace.notes, "the pod is not scheduled yet, so it has not been sized")
+		return trace, nil
+	}
+	trace.node = &corev1.Node{}
+	if err := c.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, trace.node); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("getting node %s: %w", pod.Spec.NodeName, err)
+		}
+		trace.notes = append(trace.notes, fmt.Sprintf("node %s no longer exists", pod.Spec.NodeName))
+		trace.node = nil
+	}
+
+	var overrides flexdaemonsetsv1alpha1.FlexNodeOverrideList
+	if err := c.List(ctx, &overrides); err != nil {
+		// Overrides are optional; a cluster without the CRD or without access to it simply has none.
+		trace.notes = append(trace.notes, fmt.Sprintf("could not list FlexNodeOverrides: %v", err))
+	} else {
+		trace.overrides = overrides.Items
+	}
+	return trace, nil
+}
+
+// print writes the decision trace and returns the number of mismatches found.
+func (t *podTrace) print(w io.Writer) int {
+	pod := t.pod
+	fmt.Fprintf(w, "Pod:        %s/%s\n", pod.Namespace, pod.Name)
+	fmt.Fprintf(w, "Node:       %s\n", valueOr(pod.Spec.NodeName, "<not scheduled>"))
+	fmt.Fprintf(w, "DaemonSet:  %s/%s\n", t.ds.Namespace, t.ds.Name)
+	if t.fdnp != nil {
+		fmt.Fprintf(w, "Managed by: FlexDaemonSetNodePod %s (phase %s)\n", t.fdnp.Name, valueOr(t.fdnp.Status.Phase, "<none>"))
+	}
+	templateName := t.ds.Annotations[utils.FlexDaemonsetTemplateAnnotation]
+	fmt.Fprintf(w, "Annotation: %s=%s\n", utils.FlexDaemonsetTemplateAnnotation, valueOr(templateName, "<not set>"))
+	// The manager fills in the minimums the template leaves unset before calculating; so does the trace.
+	var templateSpec *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec
+	if t.template != nil {
+		templateSpec = t.defaults.Apply(&t.template.Spec)
+		fmt.Fprintf(w, "Template:   %s (generation %d): cpu %d%% min %s max %s, memory %d%% min %s max %s, ephemeral-storage %d%% min %s max %s\n",
+			t.template.Name, t.template.Generation,
+			templateSpec.CPUPercentage, valueOr(templateSpec.MinCPU, "-"), valueOr(templateSpec.MaxCPU, "-"),
+			templateSpec.MemoryPercentage, valueOr(templateSpec.MinMemory, "-"), valueOr(templateSpec.MaxMemory, "-"),
+			templateSpec.StoragePercentage, valueOr(templateSpec.MinStorage, "-"), valueOr(templateSpec.MaxStorage, "-"))
+		if defaulted := defaultedMinimums(&t.template.Spec, templateSpec); len(defaulted) > 0 {
+			fmt.Fprintf(w, "Defaults:   %s from the manager configuration\n", strings.Join(defaulted, ", "))
+		}
+	}
+	if reason := utils.PauseReason(t.ds, t.template); reason != "" {
+		fmt.Fprintf(w, "Paused:     %s; new pods are not resized\n", reason)
+	}
+	if _, releasing := t.ds.Annotations[utils.ReleaseAnnotation]; releasing || templateName == "" {
+		fmt.Fprintf(w, "Released:   the DaemonSet is released from flex management; its pods are restored to the DaemonSet template's resources\n")
+	}
+	if _, pending := pod.Annotations[flexcontroller.PodApplyTemplateAnnotation]; pending {
+		fmt.Fprintf(w, "Pending:    the pod still carries %s and has not been sized yet\n", flexcontroller.PodApplyTemplateAnnotation)
+	}
+	for _, note := range t.notes {
+		fmt.Fprintf(w, "Note:       %s\n", note)
+	}
+
+	if t.node == nil || t.template == nil {
+		fmt.Fprintln(w, "\nThe calculation cannot be rerun without the node and the template.")
+		return 0
+	}
+
+	allocatable, adjustErrs := utils.EffectiveAllocatable(t.node)
+	fmt.Fprintf(w, "\nNode allocatable:      %s\n", utils.DescribeResources(t.node.Status.Allocatable))
+	if utils.HasAllocatableAdjustments(t.node) {
+		fmt.Fprintf(w, "Adjustments:           %s\n", strings.Join(nodeAdjustmentSources(t.node), ", "))
+		for _, err := range adjustErrs {
+			fmt.Fprintf(w, "                       ignored: %v\n", err)
+		}
+		fmt.Fprintf(w, "Effective allocatable: %s\n", utils.DescribeResources(allocatable))
+	}
+
+	fmt.Fprintf(w, "\nCalculation (calculator %s):\n", utils.CalculatorVersion)
+	result, err := utils.CalculatePodResourcesWithBounds(templateSpec, allocatable)
+	if err != nil {
+		fmt.Fprintf(w, "  failed: %v\n", err)
+		return 0
+	}
+	_, skipped := utils.ApplyNodeOverrides(result, t.overrides, t.node, allocatable, t.ds, t.template.Name, time.Now())
+	for _, s := range skipped {
+		fmt.Fprintf(w, "  skipped override %s: %v\n", s.Override.Name, s.Err)
+	}
+	for _, step := range result.Steps {
+		fmt.Fprintf(w, "  %-18s %s\n", string(step.Resource)+":", describeStep(step))
+	}
+	fmt.Fprintf(w, "  %-18s %s\n", "result:", result.Describe())
+
+	expected := result.Resources
+	if t.fdnp != nil {
+		fmt.Fprintf(w, "\nFlexDaemonSetNodePod spec: requests %s, limits %s\n",
+			utils.DescribeResources(t.fdnp.Spec.Resources.Requests), utils.DescribeResources(t.fdnp.Spec.Resources.Limits))
+	}
+	if d := t.decision; d != nil {
+		fmt.Fprintf(w, "\nRecorded decision (%s, template %s generation %d, calculator %s):\n",
+			d.Time.UTC().Format(time.RFC3339), d.Template, d.TemplateGeneration, d.CalculatorVersion)
+		fmt.Fprintf(w, "  node allocatable: %s\n", utils.DescribeResources(d.NodeAllocatable))
+		if d.EffectiveAllocatable != nil {
+			fmt.Fprintf(w, "  effective:        %s\n", utils.DescribeResources(d.EffectiveAllocatable))
+		}
+		fmt.Fprintf(w, "  resources:        %s\n", utils.DescribeResources(d.Resources))
+		if d.Override != "" {
+			fmt.Fprintf(w, "  override:         %s\n", d.Override)
+		}
+		for _, name := range sortedKeys(d.OriginalContainers) {
+			original := d.OriginalContainers[name]
+			fmt.Fprintf(w, "  original %s: requests %s, limits %s\n", name, utils.DescribeResources(original.Requests), utils.DescribeResources(original.Limits))
+		}
+		if !resourceListsEqual(d.Resources, expected) {
+			fmt.Fprintf(w, "  ! the calculation now differs from the recorded decision (template, node or override changed since)\n")
+		}
+	}
+
+	fmt.Fprintln(w, "\nLive resources:")
+	mismatches := 0
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			problems := containerMismatches(container.Resources, expected)
+			mismatches += len(problems)
+			status := "ok"
+			if len(problems) > 0 {
+				status = "MISMATCH: " + strings.Join(problems, "; ")
+			}
+			fmt.Fprintf(w, "  %s: requests %s, limits %s: %s\n", container.Name,
+				utils.DescribeResources(container.Resources.Requests), utils.DescribeResources(container.Resources.Limits), status)
+		}
+	}
+	return mismatches
+}
+
+// defaultedMinimums lists the minimums of effective that were filled in from the manager's template defaults.
+func defaultedMinimums(template, effective *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) []string {
+	var defaulted []string
+	for _, minimum := range []struct{ name, own, effective string }{
+		{"cpu min", template.MinCPU, effective.MinCPU},
+		{"memory min", template.MinMemory, effective.MinMemory},
+		{"ephemeral-storage min", template.MinStorage, effective.MinStorage},
+	} {
+		if minimum.own == "" && minimum.effective != "" {
+			defaulted = append(defaulted, minimum.name+" "+minimum.effective)
+		}
+	}
+	return defaulted
+}
+
+// nodeAdjustmentSources lists the node's flex-base and reserved annotations and labels as key=value.
+func nodeAdjustmentSources(node *corev1.Node) []string {
+	var sources []string
+	for _, key := range []string{utils.FlexBaseAnnotation, utils.ReservedAnnotation} {
+		if value, ok := node.Annotations[key]; ok {
+			sources = append(sources, fmt.Sprintf("%s=%q", key, value))
+		}
+	}
+	for _, key := range sortedKeys(node.Labels) {
+		if strings.HasPrefix(key, utils.FlexBaseLabelPrefix) || strings.HasPrefix(key, utils.ReservedLabelPrefix) {
+			sources = append(sources, fmt.Sprintf("%s=%q", key, node.Labels[key]))
+		}
+	}
+	return sources
+}
+
+// describeStep renders one calculation step, e.g. "10% of 28 = 2800m, minimum 100m, maximum 2 -> 2 (cap)".
+func describeStep(step utils.CalculationStep) string {
+	var b strings.Builder
+	if step.Allocatable != nil {
+		fmt.Fprintf(&b, "%d%% of %s = %s", step.Percentage, step.Allocatable.String(), step.FromPercentage.String())
+	} else if step.Override == "" || step.Minimum != nil {
+		b.WriteString("node reports no allocatable")
+	}
+	if step.Minimum != nil {
+		fmt.Fprintf(&b, ", minimum %s", step.Minimum.String())
+	}
+	if step.Maximum != nil {
+		fmt.Fprintf(&b, ", maximum %s", step.Maximum.String())
+	}
+	switch {
+	case step.Override != "":
+		fmt.Fprintf(&b, " -> overridden by FlexNodeOverride %s: %s (override)", step.Override, step.Result.String())
+	case step.Result == nil:
+		b.WriteString(" -> not requested")
+	default:
+		fmt.Fprintf(&b, " -> %s (%s)", step.Result.String(), step.Bound)
+	}
+	return strings.TrimPrefix(b.String(), " ")
+}
+
+// containerMismatches lists every expected resource whose live request or limit differs.
+func containerMismatches(live corev1.ResourceRequirements, expected corev1.ResourceList) []string {
+	var problems []string
+	for _, name := range sortedKeys(expected) {
+		want := expected[name]
+		for _, side := range []struct {
+			kind string
+			list corev1.ResourceList
+		}{{"request", live.Requests}, {"limit", live.Limits}} {
+			got, ok := side.list[name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s %s missing, expected %s", name, side.kind, want.String()))
+			} else if got.Cmp(want) != 0 {
+				problems = append(problems, fmt.Sprintf("%s %s is %s, expected %s", name, side.kind, got.String(), want.String()))
+			}
+		}
+	}
+	return problems
+}
+
+func resourceListsEqual(a, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, qa := range a {
+		qb, ok := b[name]
+		if !ok || qa.Cmp(qb) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+)
+
+func TestExplain(t *testing.T) {
+	// 10% of a 1-CPU, 1Gi, 100Gi node.
+	sized := resources("100m", "107374182", "10Gi")
+	noMinimum := func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) {}
+	ownMinimum := func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) { s.MinCPU = "150m" }
+
+	tests := []struct {
+		name           string
+		template       func(*flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec)
+		live           corev1.ResourceList
+		config         string
+		wantMismatches int
+		wantOutput     []string
+	}{
+		{name: "sized pod", template: noMinimum, live: sized, wantOutput: []string{"result:", "exporter: requests cpu=100m", ": ok"}},
+		{
+			name:           "default minimum applied like the manager",
+			template:       noMinimum,
+			live:           sized,
+			config:         "templates:\n  minCPU: 200m\n",
+			wantMismatches: 2,
+			wantOutput:     []string{"cpu 10% min 200m", "Defaults:   cpu min 200m from the manager configuration", "MISMATCH"},
+		},
+		{
+			name:       "template minimum wins over the default",
+			template:   ownMinimum,
+			live:       resources("150m", "107374182", "10Gi"),
+			config:     "templates:\n  minCPU: 200m\n",
+			wantOutput: []string{"cpu 10% min 150m"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := testDaemonSetObject("50m", "64Mi")
+			pod := testDaemonSetPod(ds, "node-a", tt.live)
+			c := newFakeClient(t, ds, pod, testTemplateObject(tt.template), testNode("node-a", "1", "1Gi"))
+
+			trace, err := resolvePodTrace(context.Background(), c, client.ObjectKeyFromObject(pod))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.config != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				content := "apiVersion: config.flexdaemonsets.xai/v1alpha1\nkind: ManagerConfiguration\n" + tt.config
+				if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				if trace.defaults, err = (&templateDefaultsFlag{path: path}).load(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var out bytes.Buffer
+			if got := trace.print(&out); got != tt.wantMismatches {
+				t.Errorf("mismatches = %d, want %d\n%s", got, tt.wantMismatches, out.String())
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output does not contain %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+)
+
+// readTemplateFile reads a FlexDaemonsetTemplate from a YAML or JSON file.
+func readTemplateFile(path string) (*flexdaemonsetsv1alpha1.FlexDaemonsetTemplate, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	template := &flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{}
+	if err := yaml.UnmarshalStrict(raw, template); err != nil {
+		return nil, fmt.Errorf("decoding FlexDaemonsetTemplate from %s: %w", path, err)
+	}
+	if template.Kind != "" && template.Kind != "FlexDaemonsetTemplate" {
+		return nil, fmt.Errorf("%s contains a %s, expected a FlexDaemonsetTemplate", path, template.Kind)
+	}
+	return template, nil
+}
+
+// readNodesFile reads nodes from a YAML or JSON file holding a NodeList, a List of Nodes (as written by
+// "kubectl get nodes -o yaml") or a single Node.
+func readNodesFile(path string) ([]corev1.Node, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	switch typeMeta.Kind {
+	case "Node":
+		node := corev1.Node{}
+		if err := yaml.Unmarshal(raw, &node); err != nil {
+			return nil, fmt.Errorf("decoding Node from %s: %w", path, err)
+		}
+		return []corev1.Node{node}, nil
+	case "NodeList", "List":
+		list := corev1.NodeList{}
+		if err := yaml.Unmarshal(raw, &list); err != nil {
+			return nil, fmt.Errorf("decoding nodes from %s: %w", path, err)
+		}
+		for _, node := range list.Items {
+			if node.Kind != "" && node.Kind != "Node" {
+				return nil, fmt.Errorf("%s contains a %s, expected only Nodes", path, node.Kind)
+			}
+		}
+		return list.Items, nil
+	default:
+		return nil, fmt.Errorf("%s contains a %q, expected a Node, NodeList or List", path, typeMeta.Kind)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+const (
+	testNamespace = "monitoring"
+	testDaemonSet = "node-exporter"
+	testTemplate  = "small"
+)
+
+// testNode returns a node with the given allocatable cpu and memory and 100Gi of ephemeral storage.
+func testNode(name, cpu, memory string) *corev1.Node {
+	return &corev1.Node{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Node"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"kubernetes.io/hostname": name}},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU:              resource.MustParse(cpu),
+			corev1.ResourceMemory:           resource.MustParse(memory),
+			corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+		}},
+	}
+}
+
+// testTemplateObject returns a template asking for 10% of every resource, changed by mutate if set.
+func testTemplateObject(mutate func(*flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec)) *flexdaemonsetsv1alpha1.FlexDaemonsetTemplate {
+	template := &flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{
+		TypeMeta:   metav1.TypeMeta{APIVersion: flexdaemonsetsv1alpha1.GroupVersion.String(), Kind: "FlexDaemonsetTemplate"},
+		ObjectMeta: metav1.ObjectMeta{Name: testTemplate},
+		Spec:       flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec{CPUPercentage: 10, MemoryPercentage: 10, StoragePercentage: 10},
+	}
+	if mutate != nil {
+		mutate(&template.Spec)
+	}
+	return template
+}
+
+// testDaemonSetObject returns testDaemonSet, annotated with testTemplate, whose container requests cpu and memory.
+func testDaemonSetObject(cpu, memory string) *appsv1.DaemonSet {
+	labels := map[string]string{"app": testDaemonSet}
+	resources := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu), corev1.ResourceMemory: resource.MustParse(memory)}
+	return &appsv1.DaemonSet{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "DaemonSet"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        testDaemonSet,
+			Namespace:   testNamespace,
+			UID:         "ds-uid",
+			Annotations: map[string]string{utils.FlexDaemonsetTemplateAnnotation: testTemplate},
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name:      "exporter",
+					Image:     "exporter:v1",
+					Resources: corev1.ResourceRequirements{Requests: resources, Limits: resources},
+				}}},
+			},
+		},
+	}
+}
+
+// testDaemonSetPod returns a running pod of ds on the node whose container has the given requests and limits.
+func testDaemonSetPod(ds *appsv1.DaemonSet, nodeName string, resources corev1.ResourceList) *corev1.Pod {
+	isController := true
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ds.Name + "-" + nodeName,
+			Namespace: ds.Namespace,
+			UID:       types.UID(ds.Name + "-" + nodeName + "-uid"),
+			Labels:    ds.Spec.Template.Labels,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1", Kind: "DaemonSet", Name: ds.Name, UID: ds.UID, Controller: &isController,
+			}},
+		},
+		Spec:   *ds.Spec.Template.Spec.DeepCopy(),
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	pod.Spec.NodeName = nodeName
+	pod.Spec.Containers[0].Resources = corev1.ResourceRequirements{Requests: resources, Limits: resources}
+	return pod
+}
+
+func newFakeClient(t *testing.T, objs ...client.Object) client.Client {
+	t.Helper()
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func resources(cpu, memory, storage string) corev1.ResourceList {
+	return corev1.ResourceList{
+		corev1.ResourceCPU:              resource.MustParse(cpu),
+		corev1.ResourceMemory:           resource.MustParse(memory),
+		corev1.ResourceEphemeralStorage: resource.MustParse(storage),
+	}
+}
+
+// writeYAMLFile writes obj as YAML to a file named name in a temporary directory and returns its path.
+func writeYAMLFile(t *testing.T, name string, obj interface{}) string {
+	t.Helper()
+	raw, err := yaml.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,68 @@
+// Command kubectl-flexds is a kubectl plugin for planning and inspecting FlexDaemonsets sizing.
+//
+// Installed on the PATH it is invoked as "kubectl flexds <command>". Every command that is given its inputs as
+// files works offline, without cluster access.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// command is one kubectl-flexds subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{name: "preview", summary: "Show what every node would get from a template, without applying it.", run: runPreview},
+	{name: "migrate", summary: "Propose templates for DaemonSets with fixed resources and compare per-node sizes.", run: runMigrate},
+	{name: "explain", summary: "Trace why a pod got its resources and flag drift from the current calculation.", run: runExplain},
+	{name: "simulate", summary: "Run the controllers offline against a snapshot directory and report the steady state.", run: runSimulate},
+	{name: "report", summary: "Aggregate dry-run sizing decisions recorded on pods by DaemonSet and node class.", run: runReport},
+	{name: "doctor", summary: "Check the installation and print pass/fail items with remediation hints.", run: runDoctor},
+	{name: "restrict", summary: "Render namespaced RBAC and a webhook namespaceSelector for namespace-restricted mode.", run: runRestrict},
+}
+
+func main() {
+	// The calculator logs every step through controller-runtime; a CLI only reports the outcome.
+	ctrllog.SetLogger(logr.Discard())
+
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		usage()
+		if len(os.Args) < 2 {
+			os.Exit(2)
+		}
+		return
+	}
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		}
+		if err := cmd.run(os.Args[2:]); err != nil {
+			if err == flag.ErrHelp {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "error: unknown command %q\n\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: kubectl flexds <command> [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun \"kubectl flexds <command> -h\" for the flags of a command.\n")
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+// migrationResources are the resources a template sizes, in display order.
+var migrationResources = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage}
+
+// migrationPlan is the proposed template for one DaemonSet and what it would give every node.
+type migrationPlan struct {
+	ds       *appsv1.DaemonSet
+	template *flexdaemonsetsv1alpha1.FlexDaemonsetTemplate
+	// current is what every container of the DaemonSet requests today, per resource (the largest container request).
+	current corev1.ResourceList
+	// median is the median allocatable of the DaemonSet's nodes the percentages were derived from.
+	median   corev1.ResourceList
+	nodes    []corev1.Node
+	warnings []string
+}
+
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: kubectl flexds migrate (daemonset <name> | --all) [-n <namespace>] [flags]\n"+
+			"       kubectl flexds migrate -f <daemonsets file> --nodes <nodes file> [flags]\n\n"+
+			"Proposes a FlexDaemonsetTemplate per DaemonSet that reproduces its current fixed requests on the median\n"+
+			"node, with minimums and maximums that keep every node within --min-ratio and --max-ratio of today's size,\n"+
+			"and prints the old and new size on every node. With -f and --nodes, no cluster access is needed.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	var cluster clusterFlags
+	var all bool
+	var daemonSetsPath, nodesPath, outputDir string
+	var minRatio, maxRatio float64
+	cluster.addTo(fs)
+	cluster.addNamespaceTo(fs)
+	fs.BoolVar(&all, "all", false, "Migrate every DaemonSet in the namespace that is not flex-managed yet.")
+	fs.StringVar(&daemonSetsPath, "f", "", "DaemonSet or List of DaemonSets YAML or JSON file, instead of reading the cluster.")
+	fs.StringVar(&nodesPath, "nodes", "", "Node, NodeList or List YAML or JSON file, instead of reading the cluster.")
+	fs.Float64Var(&minRatio, "min-ratio", 0.5, "Template minimums are set to this fraction of today's requests, bounding how small a pod gets on small nodes.")
+	fs.Float64Var(&maxRatio, "max-ratio", 2, "Template maximums are set to this multiple of today's requests, bounding how large a pod gets on large nodes. 0 sets no maximum.")
+	fs.StringVar(&outputDir, "output-dir", "", "Write the proposed template and the annotated DaemonSet of every migration to this directory.")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if minRatio < 0 || minRatio > 1 {
+		return errors.New("--min-ratio must be between 0 and 1")
+	}
+	if maxRatio != 0 && maxRatio < 1 {
+		return errors.New("--max-ratio must be 0 or at least 1")
+	}
+
+	ctx := context.Background()
+	var c client.Client
+	needCluster := daemonSetsPath == "" || nodesPath == ""
+	if needCluster {
+		if c, err = cluster.newClient(); err != nil {
+			return err
+		}
+	}
+
+	var daemonSets []appsv1.DaemonSet
+	switch {
+	case daemonSetsPath != "":
+		if len(positional) != 0 || all {
+			return errors.New("-f cannot be combined with a DaemonSet name or --all")
+		}
+		if daemonSets, err = readDaemonSetsFile(daemonSetsPath); err != nil {
+			return err
+		}
+	case all || len(positional) == 2:
+		namespace, err := cluster.resolveNamespace()
+		if err != nil {
+			return err
+		}
+		if daemonSets, err = readClusterDaemonSets(ctx, c, namespace, all, positional); err != nil {
+			return err
+		}
+	default:
+		fs.Usage()
+		return errors.New("expected \"daemonset <name>\", --all or -f")
+	}
+
+	var nodes []corev1.Node
+	if nodesPath != "" {
+		nodes, err = readNodesFile(nodesPath)
+	} else {
+		nodes, err = listNodes(ctx, c, "")
+	}
+	if err != nil {
+		return err
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	migrated := 0
+	for i := range daemonSets {
+		ds := &daemonSets[i]
+		if templateName, managed := ds.Annotations[utils.FlexDaemonsetTemplateAnnotation]; managed {
+			fmt.Fprintf(os.Stderr, "Skipping DaemonSet %s/%s: already sized by template %s\n", ds.Namespace, ds.Name, templateName)
+			continue
+		}
+		plan, err := planMigration(ds, nodes, minRatio, maxRatio)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping DaemonSet %s/%s: %v\n", ds.Namespace, ds.Name, err)
+			continue
+		}
+		if migrated > 0 {
+			fmt.Println()
+		}
+		if err := plan.print(os.Stdout); err != nil {
+			return err
+		}
+		if outputDir != "" {
+			if err := plan.write(outputDir); err != nil {
+				return err
+			}
+		}
+		migrated++
+	}
+	if migrated == 0 {
+		return errors.New("no DaemonSet to migrate")
+	}
+	return nil
+}
+
+// readClusterDaemonSets reads one named DaemonSet, or all of them in the namespace.
+func readClusterDaemonSets(ctx context.Context, c client.Reader, namespace string, all bool, positional []string) ([]appsv1.DaemonSet, error) {
+	if all {
+		var list appsv1.DaemonSetList
+		if err := c.List(ctx, &list, client.InNamespace(namespace)); err != nil {
+			return nil, fmt.Errorf("listing DaemonSets in %s: %w", namespace, err)
+		}
+		return list.Items, nil
+	}
+	switch positional[0] {
+	case "daemonset", "daemonsets", "ds":
+	default:
+		return nil, fmt.Errorf("unsupported resource %q, expected daemonset", positional[0])
+	}
+	ds := appsv1.DaemonSet{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: positional[1]}, &ds); err != nil {
+		return nil, fmt.Errorf("getting DaemonSet %s/%s: %w", namespace, positional[1], err)
+	}
+	return []appsv1.DaemonSet{ds}, nil
+}
+
+// planMigration derives a template from the DaemonSet's current requests and the shape of its nodes. A maxRatio
+// of 0 leaves the template without maximums.
+func planMigration(ds *appsv1.DaemonSet, allNodes []corev1.Node, minRatio, maxRatio float64) (*migrationPlan, error) {
+	plan := &migrationPlan{ds: ds, current: corev1.ResourceList{}, median: corev1.ResourceList{}}
+
+	// The manager gives every container the same quantity, so the largest request is what has to be reproduced.
+	containers := append(append([]corev1.Container{}, ds.Spec.Template.Spec.InitContainers...), ds.Spec.Template.Spec.Containers...)
+	for _, name := range migrationResources {
+		distinct := map[string]bool{}
+		for _, container := range containers {
+			quantity, ok := container.Resources.Requests[name]
+			if !ok {
+				continue
+			}
+			distinct[quantity.String()] = true
+			if current, ok := plan.current[name]; !ok || quantity.Cmp(current) > 0 {
+				plan.current[name] = quantity
+			}
+		}
+		if len(distinct) > 1 {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("containers request different %s; every container will get the same size, based on the largest request", name))
+		}
+	}
+	if len(plan.current) == 0 {
+		return nil, errors.New("no container requests cpu, memory or ephemeral-storage")
+	}
+
+	// Only nodes the DaemonSet's node selector admits count towards the median.
+	selector := labels.SelectorFromSet(ds.Spec.Template.Spec.NodeSelector)
+	for _, node := range allNodes {
+		if selector.Matches(labels.Set(node.Labels)) {
+			plan.nodes = append(plan.nodes, node)
+		}
+	}
+	if len(plan.nodes) == 0 {
+		return nil, errors.New("no node matches the DaemonSet's node selector")
+	}
+
+	spec := flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec{}
+	for _, name := range migrationResources {
+		median, ok := medianAllocatable(plan.nodes, name)
+		if !ok {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("no node reports allocatable %s; the template minimum is used everywhere", name))
+		} else {
+			plan.median[name] = median
+		}
+
+		percentage := int32(1)
+		minimum, maximum := "", ""
+		if current, requested := plan.current[name]; requested {
+			if ok && median.Sign() > 0 {
+				percentage = clampPercentage(math.Round(quantityFloat(current, name) / quantityFloat(median, name) * 100))
+			}
+			minimum = scaleQuantity(current, name, minRatio)
+			if !ok {
+				minimum = current.String()
+			}
+			if maxRatio != 0 {
+				maximum = scaleQuantity(current, name, maxRatio)
+			}
+		} else {
+			// Every percentage is required, so a resource the DaemonSet never requested gets the smallest one.
+			plan.warnings = append(plan.warnings, fmt.Sprintf("the DaemonSet requests no %s today; the template requests 1%% of it", name))
+		}
+		switch name {
+		case corev1.ResourceCPU:
+			spec.CPUPercentage, spec.MinCPU, spec.MaxCPU = percentage, minimum, maximum
+		case corev1.ResourceMemory:
+			spec.MemoryPercentage, spec.MinMemory, spec.MaxMemory = percentage, minimum, maximum
+		case corev1.ResourceEphemeralStorage:
+			spec.StoragePercentage, spec.MinStorage, spec.MaxStorage = percentage, minimum, maximum
+		}
+	}
+
+	plan.template = &flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{
+		TypeMeta:   metav1.TypeMeta{APIVersion: flexdaemonsetsv1alpha1.GroupVersion.String(), Kind: "FlexDaemonsetTemplate"},
+		ObjectMeta: metav1.ObjectMeta{Name: ds.Namespace + "-" + ds.Name},
+		Spec:       spec,
+	}
+	return plan, nil
+}
+
+// print writes the proposed template and the per-node comparison.
+func (p *migrationPlan) print(w io.Writer) error {
+	fmt.Fprintf(w, "DaemonSet %s/%s: requests %s today on %d node(s), median node allocatable %s\n",
+		p.ds.Namespace, p.ds.Name, utils.DescribeResources(p.current), len(p.nodes), utils.DescribeResources(p.median))
+	for _, warning := range p.warnings {
+		fmt.Fprintf(w, "  warning: %s\n", warning)
+	}
+	raw, err := yaml.Marshal(p.template)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nProposed template:\n%s\n", indent(string(raw), "  "))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	header := []string{"NODE"}
+	for _, name := range migrationResources {
+		header = append(header, strings.ToUpper(string(name))+" (OLD -> NEW)")
+	}
+	fmt.Fprintln(tw, strings.Join(append(header, "NOTES"), "\t"))
+	for i := range p.nodes {
+		node := &p.nodes[i]
+		allocatable, _ := utils.EffectiveAllocatable(node)
+		result, err := utils.CalculatePodResourcesWithBounds(&p.template.Spec, allocatable)
+		if err != nil {
+			return fmt.Errorf("calculating node %s: %w", node.Name, err)
+		}
+		row := []string{node.Name}
+		var notes []string
+		for _, name := range migrationResources {
+			old, hadOld := p.current[name]
+			proposed, hasNew := result.Resources[name]
+			cell := fmt.Sprintf("%s -> %s", quantityOrDash(old, hadOld), quantityOrDash(proposed, hasNew))
+			if hadOld && hasNew && old.Sign() > 0 {
+				ratio := quantityFloat(proposed, name) / quantityFloat(old, name)
+				cell += fmt.Sprintf(" (%+.0f%%)", (ratio-1)*100)
+			}
+			switch result.Bounds[name] {
+			case utils.BoundFloor:
+				notes = append(notes, fmt.Sprintf("%s at minimum", name))
+			case utils.BoundCap:
+				notes = append(notes, fmt.Sprintf("%s at maximum", name))
+			}
+			row = append(row, cell)
+		}
+		fmt.Fprintln(tw, strings.Join(append(row, valueOr(strings.Join(notes, ", "), "-")), "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\nPercentages are whole numbers, so the median node is matched to the nearest percent.")
+	fmt.Fprintln(w, "Limits are set equal to requests once the DaemonSet is flex-managed.")
+	return nil
+}
+
+// write stores the template and the DaemonSet annotated with it in dir.
+func (p *migrationPlan) write(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	ds := p.ds.DeepCopy()
+	ds.TypeMeta = metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "DaemonSet"}
+	// Only what a user would apply is kept; server-populated fields would make the manifest fail or conflict.
+	ds.ObjectMeta = metav1.ObjectMeta{
+		Name:        ds.Name,
+		Namespace:   ds.Namespace,
+		Labels:      ds.Labels,
+		Annotations: ds.Annotations,
+	}
+	delete(ds.Annotations, corev1.LastAppliedConfigAnnotation)
+	delete(ds.Annotations, "deprecated.daemonset.template.generation")
+	if ds.Annotations == nil {
+		ds.Annotations = map[string]string{}
+	}
+	ds.Annotations[utils.FlexDaemonsetTemplateAnnotation] = p.template.Name
+	ds.Status = appsv1.DaemonSetStatus{}
+
+	for _, file := range []struct {
+		name string
+		obj  interface{}
+	}{
+		{p.template.Name + "-template.yaml", p.template},
+		{ds.Namespace + "-" + ds.Name + "-daemonset.yaml", ds},
+	} {
+		raw, err := yaml.Marshal(file.obj)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, file.name)
+		if err := os.WriteFile(path, raw, 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+	}
+	return nil
+}
+
+// readDaemonSetsFile reads a DaemonSet or a List of DaemonSets from a YAML or JSON file.
+func readDaemonSetsFile(path string) ([]appsv1.DaemonSet, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	switch typeMeta.Kind {
+	case "DaemonSet":
+		ds := appsv1.DaemonSet{}
+		if err := yaml.Unmarshal(raw, &ds); err != nil {
+			return nil, fmt.Errorf("decoding DaemonSet from %s: %w", path, err)
+		}
+		return []appsv1.DaemonSet{ds}, nil
+	case "DaemonSetList", "List":
+		list := appsv1.DaemonSetList{}
+		if err := yaml.Unmarshal(raw, &list); err != nil {
+			return nil, fmt.Errorf("decoding DaemonSets from %s: %w", path, err)
+		}
+		return list.Items, nil
+	default:
+		return nil, fmt.Errorf("%s contains a %q, expected a DaemonSet, DaemonSetList or List", path, typeMeta.Kind)
+	}
+}
+
+// medianAllocatable returns the median effective allocatable of the resource over the nodes reporting it.
+func medianAllocatable(nodes []corev1.Node, name corev1.ResourceName) (resource.Quantity, bool) {
+	var values []resource.Quantity
+	for i := range nodes {
+		allocatable, _ := utils.EffectiveAllocatable(&nodes[i])
+		if quantity, ok := allocatable[name]; ok {
+			values = append(values, quantity)
+		}
+	}
+	if len(values) == 0 {
+		return resource.Quantity{}, false
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Cmp(values[j]) < 0 })
+	return values[(len(values)-1)/2], true
+}
+
+// quantityFloat returns the quantity in the unit the calculator works in: millicores for CPU, units otherwise.
+func quantityFloat(q resource.Quantity, name corev1.ResourceName) float64 {
+	if name == corev1.ResourceCPU {
+		return float64(q.MilliValue())
+	}
+	return float64(q.Value())
+}
+
+// scaleQuantity returns q*ratio as a template minimum or maximum string, or "" if it rounds to zero.
+func scaleQuantity(q resource.Quantity, name corev1.ResourceName, ratio float64) string {
+	scaled := quantityFloat(q, name) * ratio
+	var result *resource.Quantity
+	if name == corev1.ResourceCPU {
+		result = resource.NewMilliQuantity(int64(scaled), resource.DecimalSI)
+	} else {
+		result = resource.NewQuantity(int64(scaled), q.Format)
+	}
+	if result.IsZero() {
+		return ""
+	}
+	return result.String()
+}
+
+func clampPercentage(p float64) int32 {
+	return int32(math.Max(1, math.Min(100, p)))
+}
+
+func quantityOrDash(q resource.Quantity, ok bool) string {
+	if !ok {
+		return "-"
+	}
+	return q.String()
+}
+
+func indent(text, prefix string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i := range lines {
+		lines[i] = prefix + lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPlanMigration(t *testing.T) {
+	// The median node has 4 CPUs, so today's 200m becomes 5%: 100m on the small node and 800m on the large one.
+	nodes := []corev1.Node{*testNode("node-large", "16", "64Gi"), *testNode("node-median", "4", "16Gi"), *testNode("node-small", "2", "8Gi")}
+
+	tests := []struct {
+		name       string
+		minRatio   float64
+		maxRatio   float64
+		wantMinCPU string
+		wantMaxCPU string
+		wantOutput []string
+	}{
+		{
+			name:       "default ratios",
+			minRatio:   0.5,
+			maxRatio:   2,
+			wantMinCPU: "100m",
+			wantMaxCPU: "400m",
+			wantOutput: []string{"200m -> 400m (+100%)", "cpu at maximum"},
+		},
+		{
+			name:       "minimum raises the small node",
+			minRatio:   0.75,
+			maxRatio:   2,
+			wantMinCPU: "150m",
+			wantMaxCPU: "400m",
+			wantOutput: []string{"200m -> 150m (-25%)", "cpu at minimum"},
+		},
+		{
+			name:       "no maximum",
+			minRatio:   0.5,
+			wantMinCPU: "100m",
+			wantOutput: []string{"200m -> 800m (+300%)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			daemonSets, err := readDaemonSetsFile(writeYAMLFile(t, "daemonset.yaml", testDaemonSetObject("200m", "256Mi")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			plan, err := planMigration(&daemonSets[0], nodes, tt.minRatio, tt.maxRatio)
+			if err != nil {
+				t.Fatal(err)
+			}
+			spec := plan.template.Spec
+			if spec.CPUPercentage != 5 {
+				t.Errorf("cpuPercentage = %d, want 5", spec.CPUPercentage)
+			}
+			if spec.MinCPU != tt.wantMinCPU || spec.MaxCPU != tt.wantMaxCPU {
+				t.Errorf("minCPU, maxCPU = %q, %q, want %q, %q", spec.MinCPU, spec.MaxCPU, tt.wantMinCPU, tt.wantMaxCPU)
+			}
+			var out bytes.Buffer
+			if err := plan.print(&out); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output does not contain %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestPlanMigrationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		nodes   []corev1.Node
+		cpu     string
+		wantErr string
+	}{
+		{name: "no matching node", cpu: "200m", wantErr: "no node matches"},
+		{name: "no requests", nodes: []corev1.Node{*testNode("node-a", "4", "16Gi")}, wantErr: "no container requests"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := testDaemonSetObject("200m", "256Mi")
+			if tt.cpu == "" {
+				ds.Spec.Template.Spec.Containers[0].Resources = corev1.ResourceRequirements{}
+			}
+			if _, err := planMigration(ds, tt.nodes, 0.5, 2); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("planMigration() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
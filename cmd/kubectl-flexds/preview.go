@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// previewRow is the sizing preview for one node.
+type previewRow struct {
+	Node string `json:"node"`
+	// Allocatable is what the template is applied to, after the node's flex-base and reserved adjustments.
+	Allocatable corev1.ResourceList                 `json:"allocatable"`
+	Requests    corev1.ResourceList                 `json:"requests,omitempty"`
+	Limits      corev1.ResourceList                 `json:"limits,omitempty"`
+	Bounds      map[corev1.ResourceName]utils.Bound `json:"bounds,omitempty"`
+	// Steps carry the template minimum and maximum each resource was checked against.
+	Steps    []utils.CalculationStep `json:"steps,omitempty"`
+	Warnings []string                `json:"warnings,omitempty"`
+	Error    string                  `json:"error,omitempty"`
+}
+
+func runPreview(args []string) error {
+	fs := flag.NewFlagSet("preview", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: kubectl flexds preview --template <file> [--nodes <file> | --selector <labels>] [-o table|json]\n\n"+
+			"Calculates the resources every node would get from the template, the same way the manager does.\n"+
+			"With --nodes, no cluster access is needed.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	var templatePath, nodesPath, selector, output string
+	var cluster clusterFlags
+	fs.StringVar(&templatePath, "template", "", "FlexDaemonsetTemplate YAML or JSON file. Required.")
+	fs.StringVar(&nodesPath, "nodes", "", "Node, NodeList or List YAML or JSON file (e.g. from \"kubectl get nodes -o yaml\"). Reads the live cluster when empty.")
+	fs.StringVar(&selector, "selector", "", "Label selector restricting the live cluster's nodes. Ignored with --nodes.")
+	fs.StringVar(&output, "o", outputTable, "Output format: table or json.")
+	cluster.addTo(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if templatePath == "" {
+		fs.Usage()
+		return errors.New("--template is required")
+	}
+	if output != outputTable && output != outputJSON {
+		return fmt.Errorf("unsupported output format %q", output)
+	}
+
+	template, err := readTemplateFile(templatePath)
+	if err != nil {
+		return err
+	}
+
+	var nodes []corev1.Node
+	if nodesPath != "" {
+		if nodes, err = readNodesFile(nodesPath); err != nil {
+			return err
+		}
+	} else {
+		c, err := cluster.newClient()
+		if err != nil {
+			return err
+		}
+		if nodes, err = listNodes(context.Background(), c, selector); err != nil {
+			return err
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	rows := make([]previewRow, 0, len(nodes))
+	for i := range nodes {
+		rows = append(rows, previewNode(&template.Spec, &nodes[i]))
+	}
+	if output == outputJSON {
+		return printJSON(os.Stdout, rows)
+	}
+	return printPreviewTable(os.Stdout, rows)
+}
+
+// listNodes lists the cluster's nodes matching the label selector.
+func listNodes(ctx context.Context, c client.Reader, selector string) ([]corev1.Node, error) {
+	opts := []client.ListOption{}
+	if selector != "" {
+		sel, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --selector: %w", err)
+		}
+		opts = append(opts, client.MatchingLabelsSelector{Selector: sel})
+	}
+	var nodes corev1.NodeList
+	if err := c.List(ctx, &nodes, opts...); err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+	return nodes.Items, nil
+}
+
+// previewNode sizes the template for one node.
+func previewNode(templateSpec *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec, node *corev1.Node) previewRow {
+	allocatable, adjustErrs := utils.EffectiveAllocatable(node)
+	row := previewRow{Node: node.Name, Allocatable: allocatable}
+	for _, err := range adjustErrs {
+		row.Warnings = append(row.Warnings, err.Error())
+	}
+	result, err := utils.CalculatePodResourcesWithBounds(templateSpec, allocatable)
+	if err != nil {
+		row.Error = err.Error()
+		return row
+	}
+	row.Requests = result.Resources
+	row.Limits = result.Resources.DeepCopy() // The manager sets limits equal to requests.
+	row.Bounds = result.Bounds
+	row.Steps = result.Steps
+	return row
+}
+
+func printPreviewTable(w io.Writer, rows []previewRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tALLOCATABLE\tREQUESTS\tLIMITS\tBOUNDS")
+	var warnings []error
+	for _, row := range rows {
+		if row.Error != "" {
+			fmt.Fprintf(tw, "%s\t%s\t<error: %s>\t\t\n", row.Node, utils.DescribeResources(row.Allocatable), row.Error)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", row.Node, utils.DescribeResources(row.Allocatable),
+			utils.DescribeResources(row.Requests), utils.DescribeResources(row.Limits), describeBounds(row.Steps))
+		for _, warning := range row.Warnings {
+			warnings = append(warnings, fmt.Errorf("node %s: %s", row.Node, warning))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if err := utilerrors.NewAggregate(warnings); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: ignored malformed allocatable adjustments: %v\n", err)
+	}
+	return nil
+}
+
+// describeBounds renders the bound of every requested resource as "cpu=cap (max 2), memory=percentage", sorted by
+// resource name. A floor or cap names the template minimum or maximum that decided it.
+func describeBounds(steps []utils.CalculationStep) string {
+	var parts []string
+	for _, step := range steps {
+		if step.Result == nil {
+			continue
+		}
+		part := fmt.Sprintf("%s=%s", step.Resource, step.Bound)
+		switch {
+		case step.Bound == utils.BoundFloor && step.Minimum != nil:
+			part += fmt.Sprintf(" (min %s)", step.Minimum.String())
+		case step.Bound == utils.BoundCap && step.Maximum != nil:
+			part += fmt.Sprintf(" (max %s)", step.Maximum.String())
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+func TestPreview(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   func(*flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec)
+		node       *corev1.Node
+		wantCPU    utils.Bound
+		wantBounds string
+	}{
+		{
+			name:       "percentage",
+			node:       testNode("node-a", "4", "8Gi"),
+			wantCPU:    utils.BoundPercentage,
+			wantBounds: "cpu=percentage, ephemeral-storage=percentage, memory=percentage",
+		},
+		{
+			name:       "floor on a small node",
+			template:   func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) { s.MinCPU = "500m" },
+			node:       testNode("node-a", "1", "8Gi"),
+			wantCPU:    utils.BoundFloor,
+			wantBounds: "cpu=floor (min 500m)",
+		},
+		{
+			name:       "cap on a large node",
+			template:   func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) { s.MaxCPU = "2" },
+			node:       testNode("node-a", "64", "8Gi"),
+			wantCPU:    utils.BoundCap,
+			wantBounds: "cpu=cap (max 2)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templatePath := writeYAMLFile(t, "template.yaml", testTemplateObject(tt.template))
+			nodesPath := writeYAMLFile(t, "nodes.yaml", &corev1.NodeList{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "NodeList"},
+				Items:    []corev1.Node{*tt.node},
+			})
+			template, err := readTemplateFile(templatePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodes, err := readNodesFile(nodesPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			row := previewNode(&template.Spec, &nodes[0])
+			if row.Error != "" {
+				t.Fatalf("previewNode() error = %s", row.Error)
+			}
+			if got := row.Bounds[corev1.ResourceCPU]; got != tt.wantCPU {
+				t.Errorf("cpu bound = %q, want %q", got, tt.wantCPU)
+			}
+			var out bytes.Buffer
+			if err := printPreviewTable(&out, []previewRow{row}); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), tt.wantBounds) {
+				t.Errorf("table does not contain %q:\n%s", tt.wantBounds, out.String())
+			}
+		})
+	}
+}
+
+func TestPreviewInvalidTemplate(t *testing.T) {
+	template := testTemplateObject(func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) { s.MinCPU, s.MaxCPU = "2", "1" })
+	row := previewNode(&template.Spec, testNode("node-a", "4", "8Gi"))
+	if !strings.Contains(row.Error, "MaxCPU") {
+		t.Errorf("error = %q, want one naming MaxCPU", row.Error)
+	}
+}
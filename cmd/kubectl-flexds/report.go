@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/audit"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+// defaultNodeClassLabel groups nodes by their instance type, which fixes their allocatable on most providers.
+const defaultNodeClassLabel = corev1.LabelInstanceTypeStable
+
+// reportRow aggregates the dry-run decisions recorded on the pods of one DaemonSet on one class of nodes.
+type reportRow struct {
+	Namespace string `json:"namespace"`
+	DaemonSet string `json:"daemonSet"`
+	Template  string `json:"template"`
+	NodeClass string `json:"nodeClass"`
+	Pods      int    `json:"pods"`
+	// CurrentRequests and DryRunRequests are summed over the pods' containers; init containers do not run
+	// alongside them and are left out.
+	CurrentRequests corev1.ResourceList `json:"currentRequests"`
+	DryRunRequests  corev1.ResourceList `json:"dryRunRequests"`
+	// Change is DryRunRequests minus CurrentRequests.
+	Change corev1.ResourceList `json:"change"`
+}
+
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: kubectl flexds report [-n <namespace> | -A] [--snapshot <dir>] [--node-class-label <label>] [-o table|json]\n\n"+
+			"Aggregates the sizing decisions recorded on pods admitted in dry-run mode (the manager's --dry-run or a\n"+
+			"template's spec.dryRun) by DaemonSet and node class, with the requests the pods have today and would get.\n"+
+			"With --snapshot, the pods and nodes are read from a directory as for simulate.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	var cluster clusterFlags
+	var snapshotDir, nodeClassLabel, output string
+	var allNamespaces bool
+	cluster.addTo(fs)
+	cluster.addNamespaceTo(fs)
+	fs.BoolVar(&allNamespaces, "A", false, "Report on every namespace.")
+	fs.BoolVar(&allNamespaces, "all-namespaces", false, "Same as -A.")
+	fs.StringVar(&snapshotDir, "snapshot", "", "Directory of YAML or JSON files holding pods and nodes. Reads the live cluster when empty.")
+	fs.StringVar(&nodeClassLabel, "node-class-label", defaultNodeClassLabel, "Node label naming the node class. Nodes without it are classed by allocatable CPU and memory.")
+	fs.StringVar(&output, "o", outputTable, "Output format: table or json.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if output != outputTable && output != outputJSON {
+		return fmt.Errorf("unsupported output format %q", output)
+	}
+
+	var pods []corev1.Pod
+	var nodes []corev1.Node
+	if snapshotDir != "" {
+		snap, err := loadSnapshot(snapshotDir)
+		if err != nil {
+			return err
+		}
+		for _, obj := range snap.objects {
+			switch obj := obj.(type) {
+			case *corev1.Pod:
+				if cluster.namespace == "" || obj.Namespace == cluster.namespace {
+					pods = append(pods, *obj)
+				}
+			case *corev1.Node:
+				nodes = append(nodes, *obj)
+			}
+		}
+	} else {
+		c, err := cluster.newClient()
+		if err != nil {
+			return err
+		}
+		namespace := ""
+		if !allNamespaces {
+			if namespace, err = cluster.resolveNamespace(); err != nil {
+				return err
+			}
+		}
+		if pods, nodes, err = listReportObjects(context.Background(), c, namespace); err != nil {
+			return err
+		}
+	}
+
+	rows, malformed := aggregateDryRunDecisions(pods, nodes, nodeClassLabel)
+	for _, problem := range malformed {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", problem)
+	}
+	if output == outputJSON {
+		return printJSON(os.Stdout, rows)
+	}
+	if len(rows) == 0 {
+		fmt.Fprintf(os.Stderr, "No pods carry the %s annotation.\n", audit.DryRunAnnotation)
+		return nil
+	}
+	return printReportTable(os.Stdout, rows)
+}
+
+// listReportObjects lists the pods in namespace (every namespace if empty) and the cluster's nodes.
+func listReportObjects(ctx context.Context, c client.Reader, namespace string) ([]corev1.Pod, []corev1.Node, error) {
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.InNamespace(namespace)); err != nil {
+		return nil, nil, fmt.Errorf("listing pods: %w", err)
+	}
+	var nodes corev1.NodeList
+	if err := c.List(ctx, &nodes); err != nil {
+		return nil, nil, fmt.Errorf("listing nodes: %w", err)
+	}
+	return pods.Items, nodes.Items, nil
+}
+
+// aggregateDryRunDecisions groups the pods carrying a dry-run decision by DaemonSet and node class. It also returns
+// a description of every annotation it could not decode.
+func aggregateDryRunDecisions(pods []corev1.Pod, nodes []corev1.Node, nodeClassLabel string) ([]reportRow, []string) {
+	nodesByName := make(map[string]*corev1.Node, len(nodes))
+	for i := range nodes {
+		nodesByName[nodes[i].Name] = &nodes[i]
+	}
+	type groupKey struct{ namespace, daemonSet, template, nodeClass string }
+	groups := map[groupKey]*reportRow{}
+	var malformed []string
+	for i := range pods {
+		pod := &pods[i]
+		value, ok := pod.Annotations[audit.DryRunAnnotation]
+		if !ok {
+			continue
+		}
+		decision, err := audit.DecodeDecision(value)
+		if err != nil {
+			malformed = append(malformed, fmt.Sprintf("pod %s/%s: %v", pod.Namespace, pod.Name, err))
+			continue
+		}
+		daemonSet := "-"
+		if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "DaemonSet" {
+			daemonSet = owner.Name
+		}
+		key := groupKey{pod.Namespace, daemonSet, decision.Template, nodeClass(nodesByName[decision.NodeName], decision, nodeClassLabel)}
+		row, ok := groups[key]
+		if !ok {
+			row = &reportRow{
+				Namespace:       key.namespace,
+				DaemonSet:       key.daemonSet,
+				Template:        key.template,
+				NodeClass:       key.nodeClass,
+				CurrentRequests: corev1.ResourceList{},
+				DryRunRequests:  corev1.ResourceList{},
+			}
+			groups[key] = row
+		}
+		row.Pods++
+		for _, requirements := range decision.OriginalContainers {
+			sumResources(row.CurrentRequests, requirements.Requests)
+			sumResources(row.DryRunRequests, decision.Resources)
+		}
+	}
+
+	rows := make([]reportRow, 0, len(groups))
+	for _, row := range groups {
+		row.Change = subtractResources(row.DryRunRequests, row.CurrentRequests)
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.DaemonSet != b.DaemonSet {
+			return a.DaemonSet < b.DaemonSet
+		}
+		if a.Template != b.Template {
+			return a.Template < b.Template
+		}
+		return a.NodeClass < b.NodeClass
+	})
+	return rows, malformed
+}
+
+// nodeClass returns the node's class label, or its allocatable CPU and memory as recorded in the decision when the
+// node is gone or unlabelled.
+func nodeClass(node *corev1.Node, decision *audit.Decision, label string) string {
+	if node != nil && node.Labels[label] != "" {
+		return node.Labels[label]
+	}
+	shape := corev1.ResourceList{}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if quantity, ok := decision.NodeAllocatable[name]; ok {
+			shape[name] = quantity
+		}
+	}
+	return utils.DescribeResources(shape)
+}
+
+// sumResources adds resources to total.
+func sumResources(total, resources corev1.ResourceList) {
+	for name, quantity := range resources {
+		sum := total[name]
+		sum.Add(quantity)
+		total[name] = sum
+	}
+}
+
+// subtractResources returns a - b for every resource in either list.
+func subtractResources(a, b corev1.ResourceList) corev1.ResourceList {
+	difference := a.DeepCopy()
+	for name, quantity := range b {
+		value, ok := difference[name]
+		if !ok {
+			value = *resource.NewQuantity(0, quantity.Format)
+		}
+		value.Sub(quantity)
+		difference[name] = value
+	}
+	return difference
+}
+
+func printReportTable(w io.Writer, rows []reportRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tDAEMONSET\tTEMPLATE\tNODE CLASS\tPODS\tCURRENT REQUESTS\tDRY-RUN REQUESTS\tCHANGE")
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", row.Namespace, row.DaemonSet, row.Template, row.NodeClass, row.Pods,
+			utils.DescribeResources(row.CurrentRequests), utils.DescribeResources(row.DryRunRequests), describeChange(row.Change))
+	}
+	return tw.Flush()
+}
+
+// describeChange renders a resource difference as "cpu=+250m, memory=-64Mi", sorted by resource name.
+func describeChange(change corev1.ResourceList) string {
+	if len(change) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(change))
+	for name := range change {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		quantity := change[corev1.ResourceName(name)]
+		sign := ""
+		if quantity.Sign() > 0 {
+			sign = "+"
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s%s", name, sign, quantity.String()))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/audit"
+)
+
+// testDryRunPod returns a pod of testDaemonSet on the node carrying a dry-run decision that would size its container
+// from current to sized.
+func testDryRunPod(t *testing.T, nodeName, allocatableCPU string, current, sized corev1.ResourceList) *corev1.Pod {
+	t.Helper()
+	pod := testDaemonSetPod(testDaemonSetObject("100m", "64Mi"), nodeName, current)
+	decision := &audit.Decision{
+		NodeName:           nodeName,
+		Template:           testTemplate,
+		NodeAllocatable:    corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(allocatableCPU), corev1.ResourceMemory: resource.MustParse("8Gi")},
+		Resources:          sized,
+		OriginalContainers: map[string]corev1.ResourceRequirements{"exporter": {Requests: current}},
+	}
+	value, err := decision.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod.Annotations = map[string]string{audit.DryRunAnnotation: value}
+	return pod
+}
+
+func TestAggregateDryRunDecisions(t *testing.T) {
+	current := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("64Mi")}
+	sized := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m"), corev1.ResourceMemory: resource.MustParse("32Mi")}
+
+	labelled := func(name, class string) corev1.Node {
+		node := testNode(name, "2", "8Gi")
+		node.Labels[defaultNodeClassLabel] = class
+		return *node
+	}
+	nodes := []corev1.Node{labelled("node-a", "m5.large"), labelled("node-b", "m5.large"), labelled("node-c", "m5.xlarge"), *testNode("node-d", "4", "8Gi")}
+
+	unsized := testDaemonSetPod(testDaemonSetObject("100m", "64Mi"), "node-a", current)
+	malformed := testDryRunPod(t, "node-a", "2", current, sized)
+	malformed.Name, malformed.Annotations[audit.DryRunAnnotation] = "malformed", "{"
+	standalone := testDryRunPod(t, "node-a", "2", current, sized)
+	standalone.Name, standalone.OwnerReferences = "standalone", nil
+
+	pods := []corev1.Pod{
+		*testDryRunPod(t, "node-a", "2", current, sized),
+		*testDryRunPod(t, "node-b", "2", current, sized),
+		*testDryRunPod(t, "node-c", "4", current, sized),
+		// node-d has no class label; node-gone was deleted since admission.
+		*testDryRunPod(t, "node-d", "4", current, sized),
+		*testDryRunPod(t, "node-gone", "4", current, sized),
+		*unsized, *malformed, *standalone,
+	}
+	rows, problems := aggregateDryRunDecisions(pods, nodes, defaultNodeClassLabel)
+
+	if len(problems) != 1 || !strings.Contains(problems[0], "monitoring/malformed") {
+		t.Errorf("problems = %q, want one naming the malformed pod", problems)
+	}
+	want := []struct {
+		daemonSet, nodeClass string
+		pods                 int
+		change               string
+	}{
+		{"-", "m5.large", 1, "cpu=+150m, memory=-32Mi"},
+		{testDaemonSet, "cpu=4, memory=8Gi", 2, "cpu=+300m, memory=-64Mi"},
+		{testDaemonSet, "m5.large", 2, "cpu=+300m, memory=-64Mi"},
+		{testDaemonSet, "m5.xlarge", 1, "cpu=+150m, memory=-32Mi"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("rows = %+v, want %d", rows, len(want))
+	}
+	for i, w := range want {
+		row := rows[i]
+		if row.DaemonSet != w.daemonSet || row.NodeClass != w.nodeClass || row.Pods != w.pods || row.Template != testTemplate {
+			t.Errorf("row %d = %s on %q, %d pods, template %s; want %s on %q, %d pods", i, row.DaemonSet, row.NodeClass, row.Pods, row.Template, w.daemonSet, w.nodeClass, w.pods)
+		}
+		if got := describeChange(row.Change); got != w.change {
+			t.Errorf("row %d change = %s, want %s", i, got, w.change)
+		}
+	}
+}
+
+func TestDescribeChange(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b corev1.ResourceList
+		want string
+	}{
+		{name: "no resources", want: "none"},
+		{name: "unchanged", a: resources("100m", "64Mi", "1Gi"), b: resources("100m", "64Mi", "1Gi"), want: "cpu=0, ephemeral-storage=0, memory=0"},
+		{name: "resource only requested today", a: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m")},
+			b: resources("100m", "64Mi", "1Gi"), want: "cpu=+100m, ephemeral-storage=-1Gi, memory=-64Mi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeChange(subtractResources(tt.a, tt.b)); got != tt.want {
+				t.Errorf("describeChange() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintReportTable(t *testing.T) {
+	current := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}
+	rows, _ := aggregateDryRunDecisions([]corev1.Pod{*testDryRunPod(t, "node-a", "2", current, resources("50m", "0", "0"))}, nil, defaultNodeClassLabel)
+	var out bytes.Buffer
+	if err := printReportTable(&out, rows); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"NODE CLASS", testDaemonSet, "cpu=2, memory=8Gi", "cpu=-50m"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("table lacks %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/config"
+)
+
+// restrictedRoleName names the rendered ClusterRole, Roles and bindings.
+const restrictedRoleName = "flexdaemonsets-restricted"
+
+// namespaceNameLabel is set on every namespace by the API server, so a webhook can select namespaces by name.
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// clusterScopedResources are the resources of managerPermissions that are not namespaced. In namespace-restricted
+// mode they are the only cluster-wide grants; nodes are read for their allocatable, templates and overrides hold
+// the sizing rules.
+var clusterScopedResources = map[string]bool{
+	"nodes":                  true,
+	"flexdaemonsettemplates": true,
+	"flexnodeoverrides":      true,
+}
+
+// namespaceReadPermission lets the manager resolve --namespace-selector.
+var namespaceReadPermission = permission{group: "", resource: "namespaces", verbs: []string{"get", "list"}}
+
+type restrictOptions struct {
+	namespaces        []string
+	selector          *metav1.LabelSelector
+	managerNamespace  string
+	serviceAccount    string
+	webhookConfig     string
+	selfManagedCerts  bool
+	resolvedNamespace []string
+}
+
+func runRestrict(args []string) error {
+	fs := flag.NewFlagSet("restrict", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: kubectl flexds restrict (--namespaces a,b | --namespace-selector key=value) [flags] | kubectl apply -f -\n\n"+
+			"Renders what a manager started with the same --namespaces and --namespace-selector needs: a Role and\n"+
+			"RoleBinding in every watched namespace, a ClusterRole limited to nodes, templates and overrides, and the\n"+
+			"MutatingWebhookConfiguration with a matching namespaceSelector. Namespaces matching the selector are read\n"+
+			"from the cluster; re-run after adding one. Apply it instead of the manager-role ClusterRoleBinding in\n"+
+			"manifests/rbac.yaml.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	var cluster clusterFlags
+	var opts restrictOptions
+	var namespaces, selector string
+	cluster.addTo(fs)
+	fs.StringVar(&namespaces, "namespaces", "", "Comma-separated namespaces the manager watches.")
+	fs.StringVar(&selector, "namespace-selector", "", "Label selector of further namespaces the manager watches.")
+	fs.StringVar(&opts.managerNamespace, "manager-namespace", defaultManagerNamespace, "Namespace the manager is installed in.")
+	fs.StringVar(&opts.serviceAccount, "service-account", defaultServiceAccount, "Name of the manager's ServiceAccount.")
+	fs.StringVar(&opts.webhookConfig, "webhook-config", defaultWebhookConfig, "Name of the MutatingWebhookConfiguration to restrict. Empty skips it.")
+	fs.BoolVar(&opts.selfManagedCerts, "self-managed-certs", false, "Also grant what --cert-mode=self-managed needs cluster-wide.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	for _, namespace := range strings.Split(namespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			opts.namespaces = append(opts.namespaces, namespace)
+		}
+	}
+	if selector != "" {
+		parsed, err := metav1.ParseToLabelSelector(selector)
+		if err != nil {
+			return fmt.Errorf("invalid --namespace-selector: %w", err)
+		}
+		opts.selector = parsed
+	}
+	if len(opts.namespaces) == 0 && opts.selector == nil {
+		return fmt.Errorf("pass --namespaces, --namespace-selector or both")
+	}
+
+	c, err := cluster.newClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cfg := &config.ManagerConfiguration{Namespaces: opts.namespaces, NamespaceSelector: opts.selector}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if opts.resolvedNamespace, err = cfg.ResolveNamespaces(ctx, c); err != nil {
+		return err
+	}
+	if len(opts.resolvedNamespace) == 0 {
+		return fmt.Errorf("namespace selector %s matches no namespaces", selector)
+	}
+
+	objects := renderRestrictedRBAC(opts)
+	if opts.webhookConfig != "" {
+		webhookConfig, err := restrictWebhookConfiguration(ctx, c, opts)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, webhookConfig)
+	}
+	return printYAMLDocuments(os.Stdout, objects)
+}
+
+// renderRestrictedRBAC returns the ClusterRole for cluster-scoped reads and a Role in every watched namespace,
+// with their bindings to the manager's service account.
+func renderRestrictedRBAC(opts restrictOptions) []client.Object {
+	clusterPermissions, namespacedPermissions := splitRestrictedPermissions(managerPermissions)
+	if opts.selector != nil {
+		clusterPermissions = append(clusterPermissions, namespaceReadPermission)
+	}
+	if opts.selfManagedCerts {
+		webhookConfig := opts.webhookConfig
+		if webhookConfig == "" {
+			webhookConfig = defaultWebhookConfig
+		}
+		for _, p := range selfManagedCertPermissions(webhookConfig) {
+			if !p.namespaced {
+				clusterPermissions = append(clusterPermissions, p)
+			}
+		}
+	}
+	subject := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: opts.serviceAccount, Namespace: opts.managerNamespace}
+	labels := map[string]string{"app.kubernetes.io/name": "flexdaemonsets"}
+
+	objects := []client.Object{
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: restrictedRoleName, Labels: labels},
+			Rules:      policyRules(clusterPermissions),
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: restrictedRoleName, Labels: labels},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: restrictedRoleName},
+			Subjects:   []rbacv1.Subject{subject},
+		},
+	}
+	for _, namespace := range opts.resolvedNamespace {
+		objects = append(objects,
+			&rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Name: restrictedRoleName, Namespace: namespace, Labels: labels},
+				Rules:      policyRules(namespacedPermissions),
+			},
+			&rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: restrictedRoleName, Namespace: namespace, Labels: labels},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: restrictedRoleName},
+				Subjects:   []rbacv1.Subject{subject},
+			})
+	}
+	return objects
+}
+
+// splitRestrictedPermissions separates the permissions granted cluster-wide in namespace-restricted mode from those
+// granted in each watched namespace.
+func splitRestrictedPermissions(permissions []permission) (cluster, namespaced []permission) {
+	for _, p := range permissions {
+		if clusterScopedResources[p.resource] {
+			cluster = append(cluster, p)
+		} else {
+			namespaced = append(namespaced, p)
+		}
+	}
+	return cluster, namespaced
+}
+
+func policyRules(permissions []permission) []rbacv1.PolicyRule {
+	rules := make([]rbacv1.PolicyRule, 0, len(permissions))
+	for _, p := range permissions {
+		resource := p.resource
+		if p.subresource != "" {
+			resource += "/" + p.subresource
+		}
+		rule := rbacv1.PolicyRule{APIGroups: []string{p.group}, Resources: []string{resource}, Verbs: p.verbs}
+		if p.name != "" {
+			rule.ResourceNames = []string{p.name}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// restrictWebhookConfiguration returns the live MutatingWebhookConfiguration with every webhook's namespaceSelector
+// matching the watched namespaces. A selector alone is used as is, so namespaces labelled later are covered;
+// otherwise the namespaces are matched by name.
+func restrictWebhookConfiguration(ctx context.Context, c client.Client, opts restrictOptions) (client.Object, error) {
+	webhookConfig := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	if err := c.Get(ctx, types.NamespacedName{Name: opts.webhookConfig}, webhookConfig); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("MutatingWebhookConfiguration %s not found; apply manifests/webhook.yaml first or pass --webhook-config", opts.webhookConfig)
+		}
+		return nil, err
+	}
+	selector := opts.selector
+	if len(opts.namespaces) > 0 {
+		selector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      namespaceNameLabel,
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   opts.resolvedNamespace,
+		}}}
+	}
+	for i := range webhookConfig.Webhooks {
+		webhookConfig.Webhooks[i].NamespaceSelector = selector.DeepCopy()
+	}
+	webhookConfig.TypeMeta = metav1.TypeMeta{APIVersion: admissionregistrationv1.SchemeGroupVersion.String(), Kind: "MutatingWebhookConfiguration"}
+	webhookConfig.ObjectMeta = metav1.ObjectMeta{Name: webhookConfig.Name, Labels: webhookConfig.Labels, Annotations: webhookConfig.Annotations}
+	delete(webhookConfig.Annotations, corev1.LastAppliedConfigAnnotation)
+	return webhookConfig, nil
+}
+
+// printYAMLDocuments writes the objects as a multi-document YAML stream.
+func printYAMLDocuments(w io.Writer, objects []client.Object) error {
+	for i, obj := range objects {
+		raw, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(w, "---")
+		}
+		if _, err := w.Write(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
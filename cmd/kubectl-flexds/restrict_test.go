@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// grantedResources returns the resources of rules as "resource[ name]" strings.
+func grantedResources(rules []rbacv1.PolicyRule) []string {
+	var granted []string
+	for _, rule := range rules {
+		for _, resource := range rule.Resources {
+			granted = append(granted, strings.TrimSpace(resource+" "+strings.Join(rule.ResourceNames, ",")))
+		}
+	}
+	return granted
+}
+
+func TestRenderRestrictedRBAC(t *testing.T) {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}}
+	tests := []struct {
+		name             string
+		opts             restrictOptions
+		wantCluster      []string
+		wantRoles        []string
+		wantNotInCluster []string
+	}{
+		{
+			name:             "namespaces",
+			opts:             restrictOptions{namespaces: []string{"monitoring", "logging"}, resolvedNamespace: []string{"monitoring", "logging"}},
+			wantCluster:      []string{"nodes", "flexdaemonsettemplates", "flexnodeoverrides", "flexnodeoverrides/status"},
+			wantRoles:        []string{"monitoring", "logging"},
+			wantNotInCluster: []string{"pods", "daemonsets", "namespaces", "mutatingwebhookconfigurations " + defaultWebhookConfig},
+		},
+		{
+			name:        "selector reads namespaces",
+			opts:        restrictOptions{selector: selector, resolvedNamespace: []string{"platform-a"}},
+			wantCluster: []string{"namespaces"},
+			wantRoles:   []string{"platform-a"},
+		},
+		{
+			name:             "self-managed certificates",
+			opts:             restrictOptions{selfManagedCerts: true, webhookConfig: "custom", resolvedNamespace: []string{"monitoring"}},
+			wantCluster:      []string{"mutatingwebhookconfigurations custom"},
+			wantRoles:        []string{"monitoring"},
+			wantNotInCluster: []string{"secrets"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.managerNamespace, tt.opts.serviceAccount = defaultManagerNamespace, defaultServiceAccount
+			objects := renderRestrictedRBAC(tt.opts)
+			if len(objects) != 2+2*len(tt.wantRoles) {
+				t.Fatalf("rendered %d objects, want a ClusterRole, its binding and a Role and binding in %v", len(objects), tt.wantRoles)
+			}
+
+			granted := strings.Join(grantedResources(objects[0].(*rbacv1.ClusterRole).Rules), "\n") + "\n"
+			for _, want := range tt.wantCluster {
+				if !strings.Contains(granted, want+"\n") {
+					t.Errorf("ClusterRole grants %q, want %s", granted, want)
+				}
+			}
+			for _, unwanted := range tt.wantNotInCluster {
+				if strings.Contains(granted, unwanted+"\n") {
+					t.Errorf("ClusterRole grants %s cluster-wide", unwanted)
+				}
+			}
+
+			subject := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: defaultServiceAccount, Namespace: defaultManagerNamespace}
+			if binding := objects[1].(*rbacv1.ClusterRoleBinding); binding.Subjects[0] != subject {
+				t.Errorf("ClusterRoleBinding subject = %+v, want %+v", binding.Subjects[0], subject)
+			}
+			for i, namespace := range tt.wantRoles {
+				role := objects[2+2*i].(*rbacv1.Role)
+				binding := objects[3+2*i].(*rbacv1.RoleBinding)
+				if role.Namespace != namespace || binding.Namespace != namespace || binding.RoleRef.Name != role.Name {
+					t.Errorf("Role %s/%s bound by %s/%s, want both in %s", role.Namespace, role.Name, binding.Namespace, binding.Name, namespace)
+				}
+				if roleGrants := strings.Join(grantedResources(role.Rules), ","); !strings.Contains(roleGrants, "pods") || strings.Contains(roleGrants, "nodes") {
+					t.Errorf("Role in %s grants %s, want the namespaced permissions only", namespace, roleGrants)
+				}
+			}
+		})
+	}
+}
+
+func TestRestrictWebhookConfiguration(t *testing.T) {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}}
+	live := testWebhookConfiguration([]byte("ca"), admissionregistrationv1.Fail, nil)
+	live.ResourceVersion = "7"
+	live.Annotations = map[string]string{corev1.LastAppliedConfigAnnotation: "{}", "example.com/owner": "platform"}
+
+	namespace := func(name string, extra map[string]string) labels.Set {
+		set := labels.Set{namespaceNameLabel: name}
+		for key, value := range extra {
+			set[key] = value
+		}
+		return set
+	}
+	tests := []struct {
+		name          string
+		opts          restrictOptions
+		wantMatches   []labels.Set
+		wantNoMatches []labels.Set
+	}{
+		{
+			name:          "namespaces are matched by name",
+			opts:          restrictOptions{namespaces: []string{"monitoring"}, resolvedNamespace: []string{"monitoring"}},
+			wantMatches:   []labels.Set{namespace("monitoring", nil)},
+			wantNoMatches: []labels.Set{namespace("logging", nil)},
+		},
+		{
+			name:          "selector alone covers namespaces labelled later",
+			opts:          restrictOptions{selector: selector, resolvedNamespace: []string{"platform-a"}},
+			wantMatches:   []labels.Set{namespace("platform-b", map[string]string{"team": "platform"})},
+			wantNoMatches: []labels.Set{namespace("platform-a", nil)},
+		},
+		{
+			name:          "namespaces and selector are matched by the resolved names",
+			opts:          restrictOptions{namespaces: []string{"monitoring"}, selector: selector, resolvedNamespace: []string{"monitoring", "platform-a"}},
+			wantMatches:   []labels.Set{namespace("monitoring", nil), namespace("platform-a", nil)},
+			wantNoMatches: []labels.Set{namespace("logging", nil)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.webhookConfig = defaultWebhookConfig
+			obj, err := restrictWebhookConfiguration(context.Background(), newFakeClient(t, live.DeepCopy()), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			restricted := obj.(*admissionregistrationv1.MutatingWebhookConfiguration)
+			if restricted.ResourceVersion != "" || restricted.Annotations[corev1.LastAppliedConfigAnnotation] != "" || restricted.Annotations["example.com/owner"] == "" {
+				t.Errorf("metadata = %+v, want it stripped to name, labels and annotations other than last-applied", restricted.ObjectMeta)
+			}
+			selector, err := metav1.LabelSelectorAsSelector(restricted.Webhooks[0].NamespaceSelector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, ns := range tt.wantMatches {
+				if !selector.Matches(ns) {
+					t.Errorf("namespaceSelector %s does not match %v", selector, ns)
+				}
+			}
+			for _, ns := range tt.wantNoMatches {
+				if selector.Matches(ns) {
+					t.Errorf("namespaceSelector %s matches %v", selector, ns)
+				}
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := restrictWebhookConfiguration(context.Background(), newFakeClient(t), restrictOptions{webhookConfig: "absent"})
+		if err == nil || !strings.Contains(err.Error(), "--webhook-config") {
+			t.Errorf("error = %v, want one pointing at --webhook-config", err)
+		}
+	})
+}
+
+func TestPrintYAMLDocuments(t *testing.T) {
+	objects := renderRestrictedRBAC(restrictOptions{resolvedNamespace: []string{"monitoring"}})
+	var out bytes.Buffer
+	if err := printYAMLDocuments(&out, objects); err != nil {
+		t.Fatal(err)
+	}
+	documents := strings.Split(out.String(), "---\n")
+	if len(documents) != len(objects) {
+		t.Fatalf("%d documents, want %d:\n%s", len(documents), len(objects), out.String())
+	}
+	for i, kind := range []string{"ClusterRole", "ClusterRoleBinding", "Role", "RoleBinding"} {
+		if !strings.Contains(documents[i], "kind: "+kind+"\n") {
+			t.Errorf("document %d lacks kind %s:\n%s", i, kind, documents[i])
+		}
+	}
+}
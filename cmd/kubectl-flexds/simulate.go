@@ -0,0 +1,585 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	flexcontroller "github.com/prakarsh-dt/FlexDaemonsets/pkg/controller"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+// defaultSimulationRounds bounds the reconcile loop. Coverage, FDNP and pod sizing settle in two or three rounds;
+// a snapshot that keeps changing after that points at a controller fighting itself.
+const defaultSimulationRounds = 10
+
+// simulatedResources are the resources whose fit and overhead are reported.
+var simulatedResources = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage}
+
+// simulationFDNP is a FlexDaemonSetNodePod the controllers created or kept.
+type simulationFDNP struct {
+	Namespace string              `json:"namespace"`
+	Name      string              `json:"name"`
+	DaemonSet string              `json:"daemonSet"`
+	Node      string              `json:"node"`
+	Resources corev1.ResourceList `json:"resources,omitempty"`
+	Override  string              `json:"override,omitempty"`
+	Phase     string              `json:"phase,omitempty"`
+}
+
+// simulationPod is the size one flex-managed pod ends up with.
+type simulationPod struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	DaemonSet string `json:"daemonSet"`
+	Node      string `json:"node"`
+	// ManagedBy is "DaemonSet" for pods sized by the webhook and pod controller, "FlexDaemonSetNodePod" for pods
+	// created in place of a missing DaemonSet pod.
+	ManagedBy string              `json:"managedBy"`
+	Requests  corev1.ResourceList `json:"requests,omitempty"`
+	Override  string              `json:"override,omitempty"`
+}
+
+// simulationNode sums up one node after the simulation.
+type simulationNode struct {
+	Name        string              `json:"name"`
+	Allocatable corev1.ResourceList `json:"allocatable"`
+	// Requested is the sum of the requests of all non-terminated pods on the node, with flex pods at their new size.
+	Requested corev1.ResourceList `json:"requested"`
+	// Overhead is the part of Requested that comes from flex-managed pods.
+	Overhead corev1.ResourceList `json:"overhead,omitempty"`
+	// OverheadPercent is Overhead as a percentage of Allocatable, per resource.
+	OverheadPercent map[corev1.ResourceName]float64 `json:"overheadPercent,omitempty"`
+	// Overcommitted lists the resources whose requests exceed the allocatable; new flex pods would not fit.
+	Overcommitted []corev1.ResourceName `json:"overcommitted,omitempty"`
+}
+
+// simulationResult is the steady state the controllers reach on the snapshot.
+type simulationResult struct {
+	Rounds    int              `json:"rounds"`
+	Converged bool             `json:"converged"`
+	FDNPs     []simulationFDNP `json:"flexDaemonSetNodePods"`
+	Pods      []simulationPod  `json:"pods"`
+	Nodes     []simulationNode `json:"nodes"`
+	Warnings  []string         `json:"warnings,omitempty"`
+}
+
+func runSimulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: kubectl flexds simulate <snapshot-dir> [--rounds N] [-o table|json]\n\n"+
+			"Loads the nodes, pods, DaemonSets, FlexDaemonsetTemplates, FlexNodeOverrides and FlexDaemonSetNodePods\n"+
+			"found in the YAML and JSON files of a directory, runs the manager's controllers against them until nothing\n"+
+			"changes, and reports the resulting FlexDaemonSetNodePods, pod sizes, nodes the new pods do not fit on and\n"+
+			"the flex overhead per node. It never contacts a cluster.\n\n"+
+			"A snapshot can be taken with:\n"+
+			"  kubectl get nodes,pods,daemonsets,flexdaemonsettemplates,flexnodeoverrides,flexdaemonsetnodepods -A -o yaml > snapshot/cluster.yaml\n\n"+
+			"Pods of flex-managed DaemonSets are sized as if they were recreated, which is what a rollout of a newly\n"+
+			"annotated DaemonSet or a changed template does.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	var output string
+	var rounds int
+	fs.StringVar(&output, "o", outputTable, "Output format: table or json.")
+	fs.IntVar(&rounds, "rounds", defaultSimulationRounds, "Maximum number of reconcile rounds before giving up on a steady state.")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return errors.New("exactly one snapshot directory is required")
+	}
+	if output != outputTable && output != outputJSON {
+		return fmt.Errorf("unsupported output format %q", output)
+	}
+	if rounds < 1 {
+		return errors.New("--rounds must be at least 1")
+	}
+
+	snap, err := loadSnapshot(positional[0])
+	if err != nil {
+		return err
+	}
+	if len(snap.skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "Ignored objects not used by the simulation: %s\n", snap.skippedSummary())
+	}
+	if snap.duplicates > 0 {
+		fmt.Fprintf(os.Stderr, "Ignored %d object(s) found more than once in the snapshot\n", snap.duplicates)
+	}
+
+	ctx := context.Background()
+	c, err := newSimulationClient(ctx, snap.objects)
+	if err != nil {
+		return err
+	}
+	result, err := simulate(ctx, c, rounds)
+	if err != nil {
+		return err
+	}
+	if output == outputJSON {
+		return printJSON(os.Stdout, result)
+	}
+	return result.print(os.Stdout)
+}
+
+// fieldIndexer registers the controllers' field indexes on a fake client builder.
+type fieldIndexer struct {
+	builder *fake.ClientBuilder
+}
+
+func (i fieldIndexer) IndexField(_ context.Context, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	i.builder.WithIndex(obj, field, extractValue)
+	return nil
+}
+
+// newSimulationClient builds an in-memory client holding the snapshot, with the indexes the controllers rely on.
+func newSimulationClient(ctx context.Context, objects []client.Object) (client.Client, error) {
+	prepareSnapshotObjects(objects)
+
+	builder := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(&flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}, &flexdaemonsetsv1alpha1.FlexNodeOverride{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			// The API server assigns UIDs on create; owner references and the controller UID index need them.
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if obj.GetUID() == "" {
+					obj.SetUID(uuid.NewUUID())
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+			// The manager's cache ignores the namespace of keys for cluster-scoped objects, and the controllers
+			// rely on that; the in-memory client does not.
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				if isClusterScoped(obj) {
+					key.Namespace = ""
+				}
+				return c.Get(ctx, key, obj, opts...)
+			},
+		})
+	if err := flexcontroller.SetupIndexes(ctx, fieldIndexer{builder: builder}); err != nil {
+		return nil, fmt.Errorf("registering indexes: %w", err)
+	}
+	return builder.Build(), nil
+}
+
+func isClusterScoped(obj client.Object) bool {
+	switch obj.(type) {
+	case *corev1.Node, *flexdaemonsetsv1alpha1.FlexDaemonsetTemplate, *flexdaemonsetsv1alpha1.FlexNodeOverride:
+		return true
+	}
+	return false
+}
+
+// prepareSnapshotObjects fills in what hand-written snapshots tend to leave out and marks the pods of flex-managed
+// DaemonSets for sizing, as the webhook would when the DaemonSet recreates them.
+func prepareSnapshotObjects(objects []client.Object) {
+	daemonSets := map[types.NamespacedName]*appsv1.DaemonSet{}
+	for _, obj := range objects {
+		if obj.GetUID() == "" {
+			obj.SetUID(uuid.NewUUID())
+		}
+		if ds, ok := obj.(*appsv1.DaemonSet); ok {
+			daemonSets[client.ObjectKeyFromObject(ds)] = ds
+		}
+	}
+
+	for _, obj := range objects {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok {
+			continue
+		}
+		var ds *appsv1.DaemonSet
+		for i := range pod.OwnerReferences {
+			ref := &pod.OwnerReferences[i]
+			if ref.Kind != "DaemonSet" || ref.Controller == nil || !*ref.Controller {
+				continue
+			}
+			ds = daemonSets[types.NamespacedName{Namespace: pod.Namespace, Name: ref.Name}]
+			if ds != nil && ref.UID == "" {
+				ref.UID = ds.UID
+			}
+		}
+		if ds == nil || utils.IsDaemonSetPaused(ds) {
+			continue
+		}
+		templateName := ds.Annotations[utils.FlexDaemonsetTemplateAnnotation]
+		if templateName == "" || ds.Annotations[utils.ReleaseAnnotation] != "" {
+			continue
+		}
+		annotations := pod.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[flexcontroller.PodApplyTemplateAnnotation] = templateName
+		pod.SetAnnotations(annotations)
+	}
+}
+
+// simulate runs the coverage, FDNP and pod reconcilers in rounds until a round leaves every object unchanged.
+// Requeues are not honoured: a requeue without a change would not change anything in a later round either.
+func simulate(ctx context.Context, c client.Client, maxRounds int) (*simulationResult, error) {
+	coverage := &flexcontroller.NodeCoverageReconciler{Client: c, Scheme: scheme}
+	fdnps := &flexcontroller.FlexDaemonSetNodePodReconciler{Client: c, Scheme: scheme, SchedulingMode: flexcontroller.SchedulingModeNodeName}
+	pods := &flexcontroller.PodReconciler{Client: c, Scheme: scheme}
+
+	result := &simulationResult{}
+	warned := map[string]bool{}
+	warn := func(format string, args ...interface{}) {
+		msg := fmt.Sprintf(format, args...)
+		if !warned[msg] {
+			warned[msg] = true
+			result.Warnings = append(result.Warnings, msg)
+		}
+	}
+
+	before, err := snapshotState(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	for result.Rounds < maxRounds && !result.Converged {
+		result.Rounds++
+
+		var dsList appsv1.DaemonSetList
+		if err := c.List(ctx, &dsList, client.MatchingFields{flexcontroller.DaemonSetTemplateIndex: flexcontroller.AnyTemplateIndexValue}); err != nil {
+			return nil, err
+		}
+		for i := range dsList.Items {
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&dsList.Items[i])}
+			if _, err := coverage.Reconcile(ctx, req); err != nil {
+				warn("coverage of DaemonSet %s: %v", req.NamespacedName, err)
+			}
+		}
+
+		var fdnpList flexdaemonsetsv1alpha1.FlexDaemonSetNodePodList
+		if err := c.List(ctx, &fdnpList); err != nil {
+			return nil, err
+		}
+		for i := range fdnpList.Items {
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&fdnpList.Items[i])}
+			if _, err := fdnps.Reconcile(ctx, req); err != nil {
+				warn("FlexDaemonSetNodePod %s: %v", req.NamespacedName, err)
+			}
+		}
+		if err := collectOrphanedPods(ctx, c); err != nil {
+			return nil, err
+		}
+
+		var podList corev1.PodList
+		if err := c.List(ctx, &podList); err != nil {
+			return nil, err
+		}
+		for i := range podList.Items {
+			pod := &podList.Items[i]
+			if _, ok := pod.Annotations[flexcontroller.PodApplyTemplateAnnotation]; !ok {
+				continue
+			}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pod)}
+			if _, err := pods.Reconcile(ctx, req); err != nil {
+				warn("sizing pod %s: %v", req.NamespacedName, err)
+			}
+		}
+
+		after, err := snapshotState(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		result.Converged = after == before
+		before = after
+	}
+	if !result.Converged {
+		warn("no steady state after %d rounds; the results show the last round", result.Rounds)
+	}
+
+	if err := result.collect(ctx, c); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// collectOrphanedPods deletes pods whose controlling FlexDaemonSetNodePod is gone, standing in for the garbage
+// collector the in-memory client does not have.
+func collectOrphanedPods(ctx context.Context, c client.Client) error {
+	var fdnpList flexdaemonsetsv1alpha1.FlexDaemonSetNodePodList
+	if err := c.List(ctx, &fdnpList); err != nil {
+		return err
+	}
+	existing := map[types.UID]bool{}
+	for i := range fdnpList.Items {
+		existing[fdnpList.Items[i].UID] = true
+	}
+	var podList corev1.PodList
+	if err := c.List(ctx, &podList); err != nil {
+		return err
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		owner := metav1.GetControllerOf(pod)
+		if owner == nil || owner.Kind != "FlexDaemonSetNodePod" || existing[owner.UID] {
+			continue
+		}
+		if err := c.Delete(ctx, pod); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotState fingerprints the objects the controllers write, so a round that changed nothing can be detected.
+// Contents are compared rather than resource versions: unlike the API server, the in-memory client bumps the
+// version on updates that change nothing.
+func snapshotState(ctx context.Context, c client.Reader) (string, error) {
+	var state []string
+	for _, list := range []client.ObjectList{&corev1.PodList{}, &flexdaemonsetsv1alpha1.FlexDaemonSetNodePodList{}, &appsv1.DaemonSetList{}} {
+		if err := c.List(ctx, list); err != nil {
+			return "", err
+		}
+		if err := meta.EachListItem(list, func(obj runtime.Object) error {
+			obj.(client.Object).SetResourceVersion("")
+			encoded, err := json.Marshal(obj)
+			if err != nil {
+				return err
+			}
+			state = append(state, string(encoded))
+			return nil
+		}); err != nil {
+			return "", err
+		}
+	}
+	sort.Strings(state)
+	return strings.Join(state, "\n"), nil
+}
+
+// collect reads the steady state back from the client.
+func (r *simulationResult) collect(ctx context.Context, c client.Reader) error {
+	var fdnpList flexdaemonsetsv1alpha1.FlexDaemonSetNodePodList
+	if err := c.List(ctx, &fdnpList); err != nil {
+		return err
+	}
+	fdnpsByUID := map[types.UID]*flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}
+	for i := range fdnpList.Items {
+		fdnp := &fdnpList.Items[i]
+		fdnpsByUID[fdnp.UID] = fdnp
+		r.FDNPs = append(r.FDNPs, simulationFDNP{
+			Namespace: fdnp.Namespace,
+			Name:      fdnp.Name,
+			DaemonSet: fdnp.Spec.DaemonSetName,
+			Node:      fdnp.Spec.NodeName,
+			Resources: fdnp.Spec.Resources.Requests,
+			Override:  fdnp.Annotations[utils.NodeOverrideAnnotation],
+			Phase:     fdnp.Status.Phase,
+		})
+	}
+	sort.Slice(r.FDNPs, func(i, j int) bool {
+		if r.FDNPs[i].Node != r.FDNPs[j].Node {
+			return r.FDNPs[i].Node < r.FDNPs[j].Node
+		}
+		return r.FDNPs[i].Namespace+"/"+r.FDNPs[i].Name < r.FDNPs[j].Namespace+"/"+r.FDNPs[j].Name
+	})
+
+	var dsList appsv1.DaemonSetList
+	if err := c.List(ctx, &dsList, client.MatchingFields{flexcontroller.DaemonSetTemplateIndex: flexcontroller.AnyTemplateIndexValue}); err != nil {
+		return err
+	}
+	flexDaemonSets := map[types.UID]*appsv1.DaemonSet{}
+	for i := range dsList.Items {
+		flexDaemonSets[dsList.Items[i].UID] = &dsList.Items[i]
+	}
+
+	var podList corev1.PodList
+	if err := c.List(ctx, &podList); err != nil {
+		return err
+	}
+	requestedByNode := map[string]corev1.ResourceList{}
+	overheadByNode := map[string]corev1.ResourceList{}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		requests := podRequests(pod)
+		addResources(requestedByNode, pod.Spec.NodeName, requests)
+
+		owner := metav1.GetControllerOf(pod)
+		if owner == nil {
+			continue
+		}
+		entry := simulationPod{Namespace: pod.Namespace, Name: pod.Name, Node: pod.Spec.NodeName, Requests: requests, Override: pod.Annotations[utils.NodeOverrideAnnotation]}
+		if ds, ok := flexDaemonSets[owner.UID]; ok {
+			entry.DaemonSet, entry.ManagedBy = ds.Name, "DaemonSet"
+		} else if fdnp, ok := fdnpsByUID[owner.UID]; ok {
+			entry.DaemonSet, entry.ManagedBy = fdnp.Spec.DaemonSetName, "FlexDaemonSetNodePod"
+		} else {
+			continue
+		}
+		r.Pods = append(r.Pods, entry)
+		addResources(overheadByNode, pod.Spec.NodeName, requests)
+	}
+	sort.Slice(r.Pods, func(i, j int) bool {
+		if r.Pods[i].Node != r.Pods[j].Node {
+			return r.Pods[i].Node < r.Pods[j].Node
+		}
+		return r.Pods[i].Namespace+"/"+r.Pods[i].Name < r.Pods[j].Namespace+"/"+r.Pods[j].Name
+	})
+
+	var nodeList corev1.NodeList
+	if err := c.List(ctx, &nodeList); err != nil {
+		return err
+	}
+	for i := range nodeList.Items {
+		node := &nodeList.Items[i]
+		summary := simulationNode{
+			Name:            node.Name,
+			Allocatable:     node.Status.Allocatable,
+			Requested:       requestedByNode[node.Name],
+			Overhead:        overheadByNode[node.Name],
+			OverheadPercent: map[corev1.ResourceName]float64{},
+		}
+		for _, name := range simulatedResources {
+			allocatable, ok := node.Status.Allocatable[name]
+			if !ok {
+				continue
+			}
+			if requested, ok := summary.Requested[name]; ok && requested.Cmp(allocatable) > 0 {
+				summary.Overcommitted = append(summary.Overcommitted, name)
+			}
+			if overhead, ok := summary.Overhead[name]; ok && allocatable.Sign() > 0 {
+				summary.OverheadPercent[name] = 100 * quantityFloat(overhead, name) / quantityFloat(allocatable, name)
+			}
+		}
+		r.Nodes = append(r.Nodes, summary)
+	}
+	sort.Slice(r.Nodes, func(i, j int) bool { return r.Nodes[i].Name < r.Nodes[j].Name })
+	return nil
+}
+
+// podRequests returns what the scheduler accounts for the pod: the larger of the summed container requests and
+// each init container's requests, plus the pod overhead.
+func podRequests(pod *corev1.Pod) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		for name, quantity := range container.Resources.Requests {
+			sum := requests[name]
+			sum.Add(quantity)
+			requests[name] = sum
+		}
+	}
+	for _, container := range pod.Spec.InitContainers {
+		for name, quantity := range container.Resources.Requests {
+			if current, ok := requests[name]; !ok || quantity.Cmp(current) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	for name, quantity := range pod.Spec.Overhead {
+		sum := requests[name]
+		sum.Add(quantity)
+		requests[name] = sum
+	}
+	return requests
+}
+
+func addResources(byNode map[string]corev1.ResourceList, nodeName string, resources corev1.ResourceList) {
+	total, ok := byNode[nodeName]
+	if !ok {
+		total = corev1.ResourceList{}
+		byNode[nodeName] = total
+	}
+	for name, quantity := range resources {
+		sum := total[name]
+		sum.Add(quantity)
+		total[name] = sum
+	}
+}
+
+func (r *simulationResult) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	state := "steady state"
+	if !r.Converged {
+		state = "no steady state"
+	}
+	fmt.Fprintf(w, "Simulated %d round(s), %s.\n\n", r.Rounds, state)
+
+	fmt.Fprintln(w, "FlexDaemonSetNodePods:")
+	if len(r.FDNPs) == 0 {
+		fmt.Fprintln(w, "  none")
+	} else {
+		fmt.Fprintln(tw, "  NODE\tNAMESPACE\tNAME\tDAEMONSET\tRESOURCES\tOVERRIDE\tPHASE")
+		for _, f := range r.FDNPs {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n", f.Node, f.Namespace, f.Name, f.DaemonSet, utils.DescribeResources(f.Resources), valueOr(f.Override, "-"), valueOr(f.Phase, "-"))
+		}
+		tw.Flush()
+	}
+
+	fmt.Fprintln(w, "\nPod sizes:")
+	if len(r.Pods) == 0 {
+		fmt.Fprintln(w, "  none")
+	} else {
+		fmt.Fprintln(tw, "  NODE\tNAMESPACE\tPOD\tDAEMONSET\tMANAGED BY\tREQUESTS\tOVERRIDE")
+		for _, p := range r.Pods {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n", p.Node, p.Namespace, p.Name, p.DaemonSet, p.ManagedBy, utils.DescribeResources(p.Requests), valueOr(p.Override, "-"))
+		}
+		tw.Flush()
+	}
+
+	fmt.Fprintln(w, "\nNodes where the pods do not fit:")
+	fits := true
+	for _, n := range r.Nodes {
+		for _, name := range n.Overcommitted {
+			fits = false
+			requested, allocatable := n.Requested[name], n.Allocatable[name]
+			fmt.Fprintf(w, "  %s: %s requested %s > allocatable %s\n", n.Name, name, requested.String(), allocatable.String())
+		}
+	}
+	if fits {
+		fmt.Fprintln(w, "  none")
+	}
+
+	fmt.Fprintln(w, "\nFlex overhead per node:")
+	fmt.Fprintln(tw, "  NODE\tALLOCATABLE\tFLEX REQUESTS\tSHARE")
+	for _, n := range r.Nodes {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", n.Name, utils.DescribeResources(n.Allocatable), utils.DescribeResources(n.Overhead), describeShares(n.OverheadPercent))
+	}
+	tw.Flush()
+
+	if len(r.Warnings) > 0 {
+		fmt.Fprintln(w, "\nWarnings:")
+		for _, warning := range r.Warnings {
+			fmt.Fprintf(w, "  %s\n", warning)
+		}
+	}
+	return nil
+}
+
+// describeShares renders percentages as "cpu=12.5%, memory=3.1%".
+func describeShares(shares map[corev1.ResourceName]float64) string {
+	if len(shares) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(shares))
+	for _, name := range sortedKeys(shares) {
+		parts = append(parts, fmt.Sprintf("%s=%.1f%%", name, shares[name]))
+	}
+	return strings.Join(parts, ", ")
+}
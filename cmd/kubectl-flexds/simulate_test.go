@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// writeSnapshot writes every object to its own file of a new snapshot directory.
+func writeSnapshot(t *testing.T, objs ...interface{}) string {
+	t.Helper()
+	dir := t.TempDir()
+	for i, obj := range objs {
+		raw, err := yaml.Marshal(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, string(rune('a'+i))+".yaml"), raw, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadSnapshotDuplicates(t *testing.T) {
+	ds := testDaemonSetObject("50m", "64Mi")
+	changed := ds.DeepCopy()
+	changed.Labels = map[string]string{"edited": "true"}
+	node := testNode("node-a", "4", "8Gi")
+
+	tests := []struct {
+		name           string
+		objs           []interface{}
+		wantObjects    int
+		wantDuplicates int
+		wantErr        string
+	}{
+		{name: "distinct objects", objs: []interface{}{ds, node}, wantObjects: 2},
+		{name: "same object in two files", objs: []interface{}{ds, node, ds}, wantObjects: 2, wantDuplicates: 1},
+		{name: "same name, different contents", objs: []interface{}{ds, changed}, wantErr: "DaemonSet monitoring/node-exporter appears more than once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snap, err := loadSnapshot(writeSnapshot(t, tt.objs...))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadSnapshot() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(snap.objects) != tt.wantObjects || snap.duplicates != tt.wantDuplicates {
+				t.Errorf("objects, duplicates = %d, %d, want %d, %d", len(snap.objects), snap.duplicates, tt.wantObjects, tt.wantDuplicates)
+			}
+			// Building the in-memory client must not trip over what was loaded.
+			if _, err := newSimulationClient(context.Background(), snap.objects); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	ds := testDaemonSetObject("50m", "64Mi")
+	pod := testDaemonSetPod(ds, "node-a", ds.Spec.Template.Spec.Containers[0].Resources.Requests)
+	snap, err := loadSnapshot(writeSnapshot(t, ds, pod, testTemplateObject(nil), testNode("node-a", "4", "8Gi")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	c, err := newSimulationClient(ctx, snap.objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := simulate(ctx, c, defaultSimulationRounds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Converged {
+		t.Errorf("simulation did not converge: %v", result.Warnings)
+	}
+	if len(result.Pods) != 1 {
+		t.Fatalf("pods = %+v, want the DaemonSet pod", result.Pods)
+	}
+	if cpu := result.Pods[0].Requests[corev1.ResourceCPU]; cpu.Cmp(resource.MustParse("400m")) != 0 {
+		t.Errorf("pod cpu = %s, want 10%% of the node", cpu.String())
+	}
+	if len(result.Nodes) != 1 || len(result.Nodes[0].Overcommitted) != 0 {
+		t.Errorf("nodes = %+v, want node-a with room for the pod", result.Nodes)
+	}
+}
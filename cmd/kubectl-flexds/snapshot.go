@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// simulatedKinds are the kinds the simulator loads; everything else in a snapshot is counted and ignored.
+var simulatedKinds = map[string]bool{
+	"Node":                  true,
+	"Pod":                   true,
+	"DaemonSet":             true,
+	"FlexDaemonsetTemplate": true,
+	"FlexDaemonSetNodePod":  true,
+	"FlexNodeOverride":      true,
+}
+
+// snapshot is a set of cluster objects loaded from manifests.
+type snapshot struct {
+	objects []client.Object
+	// skipped counts documents of kinds the simulator does not use, by kind.
+	skipped map[string]int
+	// loaded indexes objects by kind, namespace and name, so an object found in several files is loaded once.
+	loaded map[snapshotKey]client.Object
+	// duplicates counts documents dropped because an identical object was already loaded.
+	duplicates int
+}
+
+// snapshotKey identifies an object across the files of a snapshot. Versions of the same kind are one object.
+type snapshotKey struct {
+	kind      schema.GroupKind
+	namespace string
+	name      string
+}
+
+// loadSnapshot reads every .yaml, .yml and .json file in dir. Files may hold several YAML documents and
+// List objects, as written by "kubectl get nodes,pods,daemonsets,flexdaemonsettemplates -A -o yaml".
+func loadSnapshot(dir string) (*snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	snap := &snapshot{skipped: map[string]int{}, loaded: map[snapshotKey]client.Object{}}
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		err = snap.addDocuments(decoder, utilyaml.NewYAMLOrJSONDecoder(bufio.NewReader(f), 4096))
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", path, err)
+		}
+	}
+	if len(snap.objects) == 0 {
+		return nil, fmt.Errorf("no objects found in %s", dir)
+	}
+	return snap, nil
+}
+
+func (s *snapshot) addDocuments(decoder runtime.Decoder, docs *utilyaml.YAMLOrJSONDecoder) error {
+	for {
+		var raw json.RawMessage
+		if err := docs.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+			continue
+		}
+		if err := s.addDocument(decoder, raw); err != nil {
+			return err
+		}
+	}
+}
+
+// addDocument decodes one JSON document, expanding Lists.
+func (s *snapshot) addDocument(decoder runtime.Decoder, raw []byte) error {
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return err
+	}
+	if strings.HasSuffix(typeMeta.Kind, "List") {
+		var list struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return err
+		}
+		for _, item := range list.Items {
+			if err := s.addDocument(decoder, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !simulatedKinds[typeMeta.Kind] {
+		s.skipped[valueOr(typeMeta.Kind, "<unknown>")]++
+		return nil
+	}
+	obj, _, err := decoder.Decode(raw, nil, nil)
+	if err != nil {
+		return fmt.Errorf("decoding %s: %w", typeMeta.Kind, err)
+	}
+	clientObj, ok := obj.(client.Object)
+	if !ok {
+		s.skipped[typeMeta.Kind]++
+		return nil
+	}
+	// The fake client assigns its own resource versions.
+	clientObj.SetResourceVersion("")
+	return s.add(clientObj)
+}
+
+// add loads obj once. The same object saved twice, e.g. by overlapping "kubectl get" commands, is dropped; two
+// different objects with the same kind, namespace and name cannot both be simulated and are an error.
+func (s *snapshot) add(obj client.Object) error {
+	key := snapshotKey{kind: obj.GetObjectKind().GroupVersionKind().GroupKind(), namespace: obj.GetNamespace(), name: obj.GetName()}
+	if previous, ok := s.loaded[key]; ok {
+		if !apiequality.Semantic.DeepEqual(previous, obj) {
+			return fmt.Errorf("%s %s appears more than once with different contents", key.kind.Kind, describeKey(key))
+		}
+		s.duplicates++
+		return nil
+	}
+	s.loaded[key] = obj
+	s.objects = append(s.objects, obj)
+	return nil
+}
+
+func describeKey(key snapshotKey) string {
+	if key.namespace == "" {
+		return key.name
+	}
+	return key.namespace + "/" + key.name
+}
+
+// skippedSummary renders the skipped kinds as "Service=3, ConfigMap=1".
+func (s *snapshot) skippedSummary() string {
+	parts := make([]string, 0, len(s.skipped))
+	for kind, n := range s.skipped {
+		parts = append(parts, fmt.Sprintf("%s=%d", kind, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
@@ -1,19 +1,37 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp" // For GCP auth
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"           // Ensure webhook is imported if directly used, though often implicitly handled by manager
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission" // Added for admission.NewDecoder
 
 	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
-	flexcontroller "github.com/prakarsh-dt/FlexDaemonsets/pkg/controller"    // Import the new controller package
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/audit"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/certs"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/config"
+	flexcontroller "github.com/prakarsh-dt/FlexDaemonsets/pkg/controller" // Import the new controller package
+	flexevents "github.com/prakarsh-dt/FlexDaemonsets/pkg/events"
+	flexmetrics "github.com/prakarsh-dt/FlexDaemonsets/pkg/metrics"
 	flexdaemonsetwebhook "github.com/prakarsh-dt/FlexDaemonsets/pkg/webhook" // Import the webhook package
 	// +kubebuilder:scaffold:imports
 )
@@ -30,19 +48,12 @@ func init() {
 }
 
 func main() {
-	var metricsAddr string
-	var enableLeaderElection bool
-	var probeAddr string
-	var certDir string // Added variable for cert directory
-
-	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
-	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
-	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
-		"Enable leader election for controller manager. "+
-			"Enabling this will ensure there is only one active controller manager.")
-	// Added flag for cert directory. The controller-runtime manager will automatically use this directory
-	// to find tls.crt and tls.key files for the webhook server.
-	flag.StringVar(&certDir, "cert-dir", "/tmp/k8s-webhook-server/serving-certs", "Directory where the TLS certs (tls.crt, tls.key) are located. Defaults to /tmp/k8s-webhook-server/serving-certs if not provided, or if empty.")
+	var configFile string
+	flag.StringVar(&configFile, "config", "",
+		"Path to a "+config.Kind+" file ("+config.GroupVersion.String()+"). Flags given explicitly override its settings.")
+
+	cfg := config.Default()
+	cfg.BindFlags(flag.CommandLine)
 
 	opts := zap.Options{
 		Development: true,
@@ -52,7 +63,65 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	setupLog.Info("Initializing manager", "certDir", certDir)
+	if configFile != "" {
+		loaded, err := config.Load(configFile)
+		if err != nil {
+			setupLog.Error(err, "unable to load configuration file")
+			os.Exit(1)
+		}
+		if err := loaded.Override(flag.CommandLine); err != nil {
+			setupLog.Error(err, "unable to apply command-line flags over the configuration file")
+			os.Exit(1)
+		}
+		cfg = loaded
+	}
+	if cfg.Certificates.Namespace == "" {
+		cfg.Certificates.Namespace = managerNamespace()
+	}
+	if err := cfg.Validate(); err != nil {
+		setupLog.Error(err, "invalid configuration", "config", configFile)
+		os.Exit(1)
+	}
+
+	// In self-managed mode the webhook server gets its certificate from the loader instead of the cert dir.
+	webhookOptions := webhook.Options{Host: cfg.Webhook.Host, Port: cfg.Webhook.Port, CertDir: cfg.Webhook.CertDir}
+	var certLoader *certs.Loader
+	if cfg.Webhook.Enabled && cfg.Certificates.Mode == config.CertModeSelfManaged {
+		certLoader = &certs.Loader{Namespace: cfg.Certificates.Namespace, SecretName: cfg.Certificates.SecretName}
+		webhookOptions.TLSOpts = append(webhookOptions.TLSOpts, func(tlsConfig *tls.Config) {
+			tlsConfig.GetCertificate = certLoader.GetCertificate
+		})
+	}
+
+	// Pods and nodes are cached in bulk; trim the fields no controller reads to keep memory down.
+	cacheOptions := cache.Options{}
+	if cfg.Enabled(config.CacheTransforms) {
+		cacheOptions.ByObject = map[client.Object]cache.ByObject{
+			&corev1.Pod{}:  {Transform: flexcontroller.TransformPodForCache},
+			&corev1.Node{}: {Transform: flexcontroller.TransformNodeForCache},
+		}
+	}
+	// In namespace-restricted mode only the watched namespaces' pods, DaemonSets and FDNPs are cached, which is
+	// what lets the manager run with namespaced RBAC. Cluster-scoped objects (nodes, templates, overrides) are
+	// cached regardless.
+	restConfig := ctrl.GetConfigOrDie()
+	var namespaces, deniedNamespaces []string
+	if cfg.Restricted() {
+		var err error
+		if namespaces, deniedNamespaces, err = resolveNamespaces(restConfig, cfg); err != nil {
+			setupLog.Error(err, "unable to resolve watched namespaces")
+			os.Exit(1)
+		}
+		if len(deniedNamespaces) > 0 {
+			setupLog.Error(nil, "Skipping namespaces the manager has no RBAC for; grant it access there to have them watched", "namespaces", deniedNamespaces)
+		}
+		cacheOptions.DefaultNamespaces = map[string]cache.Config{}
+		for _, namespace := range namespaces {
+			cacheOptions.DefaultNamespaces[namespace] = cache.Config{}
+		}
+	}
+
+	setupLog.Info("Initializing manager", "config", configFile, "certDir", cfg.Webhook.CertDir, "certMode", cfg.Certificates.Mode, "namespaces", namespaces, "dryRun", cfg.DryRun)
 	// The manager's webhook server will be started locally on Port (default 9443 for controller-runtime v0.11+)
 	// and will use the CertDir to serve TLS.
 	// Certificates (tls.crt and tls.key) must be present in CertDir.
@@ -63,68 +132,190 @@ func main() {
 	// openssl x509 -req -days 365 -in tls.csr -signkey tls.key -out tls.crt
 	// Then place tls.crt and tls.key into the certDir.
 	// In a cluster, cert-manager is a common way to provision and manage TLS certificates for webhooks.
-	// Removing MetricsBindAddress temporarily to try and move past "unknown field" error.
-	// Port & CertDir are not direct fields; webhook server uses defaults.
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme: scheme,
-		// MetricsBindAddress:     metricsAddr, // Removing again due to "unknown field" error
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "flexdaemonsets.xai",
+	// Port & CertDir are not direct fields; they are set on the webhook server passed in Options.WebhookServer.
+	// Since controller-runtime v0.16 the metrics endpoint is configured through Options.Metrics rather than
+	// the old MetricsBindAddress field. Setting the address to "0" disables it.
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+		Scheme:                  scheme,
+		Metrics:                 metricsserver.Options{BindAddress: cfg.Metrics.BindAddress},
+		HealthProbeBindAddress:  cfg.Health.ProbeBindAddress,
+		WebhookServer:           webhook.NewServer(webhookOptions),
+		LeaderElection:          cfg.LeaderElection.LeaderElect,
+		LeaderElectionID:        cfg.LeaderElection.ResourceName,
+		LeaderElectionNamespace: cfg.LeaderElection.ResourceNamespace,
+		LeaseDuration:           &cfg.LeaderElection.LeaseDuration.Duration,
+		RenewDeadline:           &cfg.LeaderElection.RenewDeadline.Duration,
+		RetryPeriod:             &cfg.LeaderElection.RetryPeriod.Duration,
+		Cache:                   cacheOptions,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	// Setup webhooks
-	setupLog.Info("Setting up webhook server and registering webhooks")
-	// Get the webhook server from the manager.
-	hookServer := mgr.GetWebhookServer()
+	// FDNP and pause gauges are derived from the cache at scrape time.
+	ctrlmetrics.Registry.MustRegister(flexmetrics.NewFDNPCollector(mgr.GetCache()), flexmetrics.NewPauseCollector(mgr.GetCache()))
+
+	if cfg.NamespaceSelector != nil || len(deniedNamespaces) > 0 {
+		watcher := &config.NamespaceWatcher{Reader: mgr.GetAPIReader(), Client: mgr.GetClient(), Config: cfg, Resolved: namespaces, Denied: deniedNamespaces}
+		if err := mgr.Add(watcher); err != nil {
+			setupLog.Error(err, "unable to set up namespace watcher")
+			os.Exit(1)
+		}
+	}
+
+	if certLoader != nil {
+		// Secrets and webhook configurations are read straight from the API server rather than through a
+		// cluster-wide cache of every Secret.
+		certLoader.Reader = mgr.GetAPIReader()
+		rotator := &certs.Rotator{
+			Client: mgr.GetClient(),
+			Reader: mgr.GetAPIReader(),
+			Options: certs.Options{
+				Namespace:                       cfg.Certificates.Namespace,
+				SecretName:                      cfg.Certificates.SecretName,
+				ServiceName:                     cfg.Certificates.ServiceName,
+				MutatingWebhookConfigurations:   cfg.Certificates.MutatingWebhookConfigurations,
+				ValidatingWebhookConfigurations: cfg.Certificates.ValidatingWebhookConfigurations,
+				ConversionCRDs:                  cfg.Certificates.ConversionCRDs,
+				Validity:                        cfg.Certificates.Validity.Duration,
+				RotateBefore:                    cfg.Certificates.RotateBefore.Duration,
+			},
+			Loader: certLoader,
+		}
+		if err := mgr.Add(certLoader); err != nil {
+			setupLog.Error(err, "unable to set up certificate loader")
+			os.Exit(1)
+		}
+		if err := mgr.Add(rotator); err != nil {
+			setupLog.Error(err, "unable to set up certificate rotator")
+			os.Exit(1)
+		}
+		if err := mgr.AddReadyzCheck("webhook-certificate", certLoader.Checker); err != nil {
+			setupLog.Error(err, "unable to set up certificate ready check")
+			os.Exit(1)
+		}
+	}
 
-	// Register the PodMutator webhook.
-	// PodMutator.Decoder is *admission.Decoder (pointer to struct)
-	decoder := admission.NewDecoder(mgr.GetScheme())
-	hookServer.Register(
-		"/mutate-v1-pod",
-		&webhook.Admission{Handler: &flexdaemonsetwebhook.PodMutator{Client: mgr.GetClient(), Decoder: decoder}},
-	)
+	// Without the webhook the server is never started, so the probes only report that the manager is up.
+	startedChecker := healthz.Ping
+	if cfg.Webhook.Enabled {
+		// Setup webhooks
+		setupLog.Info("Setting up webhook server and registering webhooks")
+		// Get the webhook server from the manager.
+		hookServer := mgr.GetWebhookServer()
+
+		// Register the PodMutator webhook.
+		// PodMutator.Decoder is *admission.Decoder (pointer to struct)
+		decoder := admission.NewDecoder(mgr.GetScheme())
+		hookServer.Register(
+			"/mutate-v1-pod",
+			&webhook.Admission{Handler: &flexdaemonsetwebhook.PodMutator{
+				Client:           mgr.GetClient(),
+				Decoder:          decoder,
+				Recorder:         flexevents.NewRateLimitedRecorder(mgr.GetEventRecorderFor(flexdaemonsetwebhook.PodMutatorName), flexevents.DefaultInterval),
+				Namespaces:       namespaces,
+				DryRun:           cfg.DryRun,
+				TemplateDefaults: cfg.Templates,
+				FailurePolicy:    flexdaemonsetsv1alpha1.FailurePolicy(cfg.Webhook.FailurePolicy),
+				LookupTimeout:    cfg.Webhook.LookupTimeout.Duration,
+			}},
+		)
+		startedChecker = hookServer.StartedChecker()
+	} else {
+		setupLog.Info("Webhook disabled by configuration; pods are not annotated for sizing")
+	}
 
 	// +kubebuilder:scaffold:builder
 
-	setupLog.Info("Setting up NodeCoverageReconciler")
-	if err = (&flexcontroller.NodeCoverageReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "NodeCoverageReconciler")
+	// Field indexes are shared by several controllers, so they are registered once up front.
+	if err = flexcontroller.SetupIndexes(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		setupLog.Error(err, "unable to set up field indexes")
 		os.Exit(1)
 	}
 
-	setupLog.Info("Setting up FlexDaemonSetNodePodReconciler")
-	if err = (&flexcontroller.FlexDaemonSetNodePodReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "FlexDaemonSetNodePodReconciler")
-		os.Exit(1)
+	controllers := cfg.Controllers
+	if controllers.NodeCoverage.Enabled {
+		setupLog.Info("Setting up NodeCoverageReconciler")
+		if err = (&flexcontroller.NodeCoverageReconciler{
+			Client:                  mgr.GetClient(),
+			Scheme:                  mgr.GetScheme(),
+			Recorder:                flexevents.NewRateLimitedRecorder(mgr.GetEventRecorderFor(flexcontroller.NodeCoverageControllerName), flexevents.DefaultInterval),
+			TemplateDefaults:        cfg.Templates,
+			DryRun:                  cfg.DryRun,
+			MaxConcurrentReconciles: controllers.NodeCoverage.MaxConcurrentReconciles,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "NodeCoverageReconciler")
+			os.Exit(1)
+		}
 	}
 
-	setupLog.Info("Setting up Pod controller") // Existing PodReconciler
-	if err = (&flexcontroller.PodReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Pod")
-		os.Exit(1)
+	if fdnp := controllers.FlexDaemonSetNodePod; fdnp.Enabled {
+		setupLog.Info("Setting up FlexDaemonSetNodePodReconciler")
+		if err = (&flexcontroller.FlexDaemonSetNodePodReconciler{
+			Client:                  mgr.GetClient(),
+			Scheme:                  mgr.GetScheme(),
+			SchedulingMode:          flexcontroller.PodSchedulingMode(fdnp.SchedulingMode),
+			APIReader:               mgr.GetAPIReader(),
+			Recorder:                flexevents.NewRateLimitedRecorder(mgr.GetEventRecorderFor(flexcontroller.FlexDaemonSetNodePodControllerName), flexevents.DefaultInterval),
+			MaxPodRecreations:       int32(fdnp.MaxPodRecreations),
+			BackoffBase:             fdnp.BackoffBase.Duration,
+			BackoffMax:              fdnp.BackoffMax.Duration,
+			BackoffResetAfter:       fdnp.BackoffResetAfter.Duration,
+			DryRun:                  cfg.DryRun,
+			MaxConcurrentReconciles: fdnp.MaxConcurrentReconciles,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "FlexDaemonSetNodePodReconciler")
+			os.Exit(1)
+		}
+	}
+
+	if controllers.FlexNodeOverride.Enabled {
+		setupLog.Info("Setting up FlexNodeOverrideReconciler")
+		if err = (&flexcontroller.FlexNodeOverrideReconciler{
+			Client:                  mgr.GetClient(),
+			Scheme:                  mgr.GetScheme(),
+			Recorder:                flexevents.NewRateLimitedRecorder(mgr.GetEventRecorderFor(flexcontroller.FlexNodeOverrideControllerName), flexevents.DefaultInterval),
+			MaxConcurrentReconciles: controllers.FlexNodeOverride.MaxConcurrentReconciles,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "FlexNodeOverrideReconciler")
+			os.Exit(1)
+		}
+	}
+
+	if controllers.Pod.Enabled {
+		var auditSink audit.Sink
+		if cfg.AuditLog != "" {
+			sink, err := audit.OpenSink(cfg.AuditLog)
+			if err != nil {
+				setupLog.Error(err, "unable to open audit sink", "path", cfg.AuditLog)
+				os.Exit(1)
+			}
+			defer sink.Close()
+			auditSink = sink
+		}
+
+		setupLog.Info("Setting up Pod controller") // Existing PodReconciler
+		if err = (&flexcontroller.PodReconciler{
+			Client:                  mgr.GetClient(),
+			Scheme:                  mgr.GetScheme(),
+			Recorder:                flexevents.NewRateLimitedRecorder(mgr.GetEventRecorderFor(flexcontroller.PodControllerName), flexevents.DefaultInterval),
+			AuditSink:               auditSink,
+			TemplateDefaults:        cfg.Templates,
+			DryRun:                  cfg.DryRun,
+			MaxConcurrentReconciles: controllers.Pod.MaxConcurrentReconciles,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Pod")
+			os.Exit(1)
+		}
 	}
 
 	// Add health and readiness checks using StartedChecker
-	if err := mgr.AddHealthzCheck("healthz", hookServer.StartedChecker()); err != nil {
+	if err := mgr.AddHealthzCheck("healthz", startedChecker); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", hookServer.StartedChecker()); err != nil {
+	if err := mgr.AddReadyzCheck("readyz", startedChecker); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
@@ -135,3 +326,43 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// serviceAccountNamespaceFile holds the namespace of the pod's service account.
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// managerNamespace returns the namespace the manager runs in, or the default install namespace when run
+// outside a cluster.
+func managerNamespace() string {
+	if data, err := os.ReadFile(serviceAccountNamespaceFile); err == nil {
+		if namespace := strings.TrimSpace(string(data)); namespace != "" {
+			return namespace
+		}
+	}
+	return "flexdaemonsets-system"
+}
+
+// resolveNamespaces lists the namespaces the manager is restricted to, before the manager and its cache exist, and
+// splits off the ones it has no RBAC for.
+func resolveNamespaces(restConfig *rest.Config, cfg *config.ManagerConfiguration) (accessible, denied []string, err error) {
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	namespaces, err := cfg.ResolveNamespaces(ctx, c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(namespaces) == 0 {
+		return nil, nil, fmt.Errorf("namespace selector %s matches no namespaces", metav1.FormatLabelSelector(cfg.NamespaceSelector))
+	}
+	if accessible, denied, err = config.AccessibleNamespaces(ctx, c, namespaces); err != nil {
+		return nil, nil, err
+	}
+	// An empty set would make the cache watch every namespace.
+	if len(accessible) == 0 {
+		return nil, nil, fmt.Errorf("the manager has no RBAC in any of the watched namespaces %v", denied)
+	}
+	return accessible, denied, nil
+}
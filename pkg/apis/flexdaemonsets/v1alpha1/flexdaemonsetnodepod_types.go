@@ -40,13 +40,81 @@ type FlexDaemonSetNodePodStatus struct {
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 
 	// Conditions represent the latest available observations of an object's state.
+	// Known condition types are "PodScheduled", "PodReady", "Progressing" and "Degraded".
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// PodName is the name of the pod currently managed for this FlexDaemonSetNodePod.
+	// +optional
+	PodName string `json:"podName,omitempty"`
+
+	// PodPhase mirrors the status.phase of the managed pod.
+	// +optional
+	PodPhase corev1.PodPhase `json:"podPhase,omitempty"`
+
+	// Ready is true when the managed pod reports the Ready condition.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// RestartCount is the total number of container restarts of the managed pod.
+	// +optional
+	RestartCount int32 `json:"restartCount,omitempty"`
+
+	// LastTerminationReason is the reason of the most recent container termination
+	// of the managed pod (e.g. "OOMKilled", "Error").
+	// +optional
+	LastTerminationReason string `json:"lastTerminationReason,omitempty"`
+
+	// Image is the image of the managed pod's first container.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// FailureCount is the number of consecutive times the managed pod failed, was evicted or disappeared.
+	// It is reset once a recreated pod stays Ready, or when the FlexDaemonSetNodePod spec changes.
+	// +optional
+	FailureCount int32 `json:"failureCount,omitempty"`
+
+	// LastFailureReason is the reason recorded for the most recent managed pod failure (e.g. "Evicted", "PodDeleted").
+	// +optional
+	LastFailureReason string `json:"lastFailureReason,omitempty"`
+
+	// LastFailureTime is when the most recent managed pod failure was observed.
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+
+	// NextRetryTime is the earliest time at which the managed pod will be recreated after a failure.
+	// +optional
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
 }
 
+// Condition types reported in FlexDaemonSetNodePodStatus.Conditions.
+const (
+	// FlexDaemonSetNodePodPodScheduled is True once the managed pod has been bound to the target node.
+	FlexDaemonSetNodePodPodScheduled = "PodScheduled"
+	// FlexDaemonSetNodePodPodReady is True while the managed pod is Ready.
+	FlexDaemonSetNodePodPodReady = "PodReady"
+	// FlexDaemonSetNodePodProgressing is True while the controller is creating the pod or waiting for it to become Ready.
+	FlexDaemonSetNodePodProgressing = "Progressing"
+	// FlexDaemonSetNodePodDegraded is True when the managed pod cannot run as desired and needs attention.
+	FlexDaemonSetNodePodDegraded = "Degraded"
+	// FlexDaemonSetNodePodPaused is True while sizing is frozen because the DaemonSet is paused or its template
+	// is suspended. It is removed once sizing resumes.
+	FlexDaemonSetNodePodPaused = "Paused"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
-// +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:resource:scope=Namespaced,shortName=fdnp
+// +kubebuilder:printcolumn:name="DaemonSet",type=string,JSONPath=`.spec.daemonSetName`
+// +kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.spec.nodeName`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Restarts",type=integer,JSONPath=`.status.restartCount`
+// +kubebuilder:printcolumn:name="Failures",type=integer,JSONPath=`.status.failureCount`,priority=1
+// +kubebuilder:printcolumn:name="Pod",type=string,JSONPath=`.status.podName`,priority=1
+// +kubebuilder:printcolumn:name="Last Termination",type=string,JSONPath=`.status.lastTerminationReason`,priority=1
+// +kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.status.image`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // FlexDaemonSetNodePod is the Schema for the flexdaemonsetnodepods API
 type FlexDaemonSetNodePod struct {
@@ -0,0 +1,107 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FlexNodeOverrideSpec defines the desired state of FlexNodeOverride
+// +kubebuilder:validation:XValidation:rule="has(self.nodeName) != has(self.nodeSelector)",message="exactly one of nodeName and nodeSelector must be set"
+// +kubebuilder:validation:XValidation:rule="has(self.daemonSet) != has(self.templateName)",message="exactly one of daemonSet and templateName must be set"
+type FlexNodeOverrideSpec struct {
+	// NodeName targets a single node by name.
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+
+	// NodeSelector targets every node whose labels match.
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+
+	// DaemonSet targets the pods of a single DaemonSet.
+	// +optional
+	DaemonSet *DaemonSetReference `json:"daemonSet,omitempty"`
+
+	// TemplateName targets the pods of every DaemonSet using the named FlexDaemonsetTemplate.
+	// +optional
+	TemplateName string `json:"templateName,omitempty"`
+
+	// Resources replace the quantities calculated from the template. Resources that are not listed keep the
+	// template's result.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=resource
+	Resources []ResourceOverride `json:"resources"`
+
+	// ExpiresAt is the time after which the override no longer applies. Without it, the override applies
+	// until it is deleted.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// DaemonSetReference identifies a DaemonSet.
+type DaemonSetReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// ResourceOverride sets one resource either to an absolute quantity or to a percentage of the node's allocatable.
+// +kubebuilder:validation:XValidation:rule="has(self.quantity) != has(self.percentage)",message="exactly one of quantity and percentage must be set"
+type ResourceOverride struct {
+	// Resource is the overridden resource.
+	// +kubebuilder:validation:Enum=cpu;memory;ephemeral-storage
+	Resource corev1.ResourceName `json:"resource"`
+
+	// Quantity is the absolute quantity to use (e.g., "500m", "256Mi").
+	// +optional
+	Quantity string `json:"quantity,omitempty"`
+
+	// Percentage is the percentage of the node's allocatable to use. Unlike the template, no minimum applies.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	Percentage *int32 `json:"percentage,omitempty"`
+}
+
+// FlexNodeOverrideStatus defines the observed state of FlexNodeOverride
+type FlexNodeOverrideStatus struct {
+	// Conditions represent the latest available observations of the override's state.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Condition types reported in FlexNodeOverrideStatus.Conditions.
+const (
+	// FlexNodeOverrideActive is True while the override is valid and not expired.
+	FlexNodeOverrideActive = "Active"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=fno
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.spec.nodeName`
+// +kubebuilder:printcolumn:name="Template",type=string,JSONPath=`.spec.templateName`
+// +kubebuilder:printcolumn:name="DaemonSet",type=string,JSONPath=`.spec.daemonSet.name`
+// +kubebuilder:printcolumn:name="Expires",type=date,JSONPath=`.spec.expiresAt`
+// +kubebuilder:printcolumn:name="Active",type=string,JSONPath=`.status.conditions[?(@.type=="Active")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// FlexNodeOverride hand-tunes the resources FlexDaemonsets calculates for a DaemonSet on specific nodes.
+// It is applied after the template.
+type FlexNodeOverride struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   FlexNodeOverrideSpec   `json:"spec,omitempty"`
+	Status FlexNodeOverrideStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// FlexNodeOverrideList contains a list of FlexNodeOverride
+type FlexNodeOverrideList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []FlexNodeOverride `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&FlexNodeOverride{}, &FlexNodeOverrideList{})
+}
@@ -1,9 +1,24 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// FailurePolicy is what the pod webhook does with a pod it cannot mark for sizing.
+// +kubebuilder:validation:Enum=Allow;Fallback;Deny
+type FailurePolicy string
+
+const (
+	// FailurePolicyAllow admits the pod with the DaemonSet template's resources.
+	FailurePolicyAllow FailurePolicy = "Allow"
+	// FailurePolicyFallback admits the pod with the template's FallbackResources on every container. Without
+	// fallback resources it behaves like Allow.
+	FailurePolicyFallback FailurePolicy = "Fallback"
+	// FailurePolicyDeny rejects the pod; the DaemonSet controller retries creating it.
+	FailurePolicyDeny FailurePolicy = "Deny"
+)
+
 // FlexDaemonsetTemplateSpec defines the desired state of FlexDaemonsetTemplate
 type FlexDaemonsetTemplateSpec struct {
 	// CPUPercentage is the percentage of CPU to allocate from the node's allocatable CPU.
@@ -32,6 +47,40 @@ type FlexDaemonsetTemplateSpec struct {
 	// MinStorage specifies the minimum absolute ephemeral-storage request (e.g., "1Gi").
 	// +optional
 	MinStorage string `json:"minStorage,omitempty"`
+
+	// MaxCPU caps the CPU request in milliCPU (e.g., "2"). It must not be below MinCPU.
+	// +optional
+	MaxCPU string `json:"maxCPU,omitempty"`
+
+	// MaxMemory caps the memory request (e.g., "2Gi"). It must not be below MinMemory.
+	// +optional
+	MaxMemory string `json:"maxMemory,omitempty"`
+
+	// MaxStorage caps the ephemeral-storage request (e.g., "10Gi"). It must not be below MinStorage.
+	// +optional
+	MaxStorage string `json:"maxStorage,omitempty"`
+
+	// Suspend freezes sizing for every DaemonSet using this template. While set, the webhook passes pods
+	// through unchanged, no FlexDaemonSetNodePods are created or updated and pods are not resized.
+	// Existing pods and FlexDaemonSetNodePods keep their current resources.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// DryRun computes sizing decisions for every DaemonSet using this template without applying them. The
+	// webhook records the resources a pod would get in an annotation and an admission warning instead of
+	// marking it for sizing, and no FlexDaemonSetNodePods are created, updated or deleted.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// FailurePolicy is what the webhook does with a pod of a DaemonSet using this template when it cannot mark
+	// the pod for sizing, for example because the template's minimums do not parse. Defaults to the manager's
+	// webhook failure policy, which also applies when the DaemonSet or the template cannot be read.
+	// +optional
+	FailurePolicy FailurePolicy `json:"failurePolicy,omitempty"`
+
+	// FallbackResources are applied as every container's requests and limits under the Fallback failure policy.
+	// +optional
+	FallbackResources *corev1.ResourceRequirements `json:"fallbackResources,omitempty"`
 }
 
 // FlexDaemonsetTemplateStatus defines the observed state of FlexDaemonsetTemplate
@@ -45,6 +94,12 @@ type FlexDaemonsetTemplateStatus struct {
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:scope=Cluster,shortName=fdt
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="CPU%",type=integer,JSONPath=`.spec.cpuPercentage`
+// +kubebuilder:printcolumn:name="Memory%",type=integer,JSONPath=`.spec.memoryPercentage`
+// +kubebuilder:printcolumn:name="Storage%",type=integer,JSONPath=`.spec.storagePercentage`
+// +kubebuilder:printcolumn:name="Suspended",type=boolean,JSONPath=`.spec.suspend`
+// +kubebuilder:printcolumn:name="DryRun",type=boolean,JSONPath=`.spec.dryRun`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 // FlexDaemonsetTemplate is the Schema for the flexdaemonsettemplates API
 type FlexDaemonsetTemplate struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -5,10 +5,26 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaemonSetReference) DeepCopyInto(out *DaemonSetReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaemonSetReference.
+func (in *DaemonSetReference) DeepCopy() *DaemonSetReference {
+	if in == nil {
+		return nil
+	}
+	out := new(DaemonSetReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FlexDaemonSetNodePod) DeepCopyInto(out *FlexDaemonSetNodePod) {
 	*out = *in
@@ -94,6 +110,14 @@ func (in *FlexDaemonSetNodePodStatus) DeepCopyInto(out *FlexDaemonSetNodePodStat
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.LastFailureTime != nil {
+		in, out := &in.LastFailureTime, &out.LastFailureTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextRetryTime != nil {
+		in, out := &in.NextRetryTime, &out.NextRetryTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlexDaemonSetNodePodStatus.
@@ -111,7 +135,7 @@ func (in *FlexDaemonsetTemplate) DeepCopyInto(out *FlexDaemonsetTemplate) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -168,6 +192,11 @@ func (in *FlexDaemonsetTemplateList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FlexDaemonsetTemplateSpec) DeepCopyInto(out *FlexDaemonsetTemplateSpec) {
 	*out = *in
+	if in.FallbackResources != nil {
+		in, out := &in.FallbackResources, &out.FallbackResources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlexDaemonsetTemplateSpec.
@@ -201,3 +230,140 @@ func (in *FlexDaemonsetTemplateStatus) DeepCopy() *FlexDaemonsetTemplateStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlexNodeOverride) DeepCopyInto(out *FlexNodeOverride) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlexNodeOverride.
+func (in *FlexNodeOverride) DeepCopy() *FlexNodeOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(FlexNodeOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FlexNodeOverride) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlexNodeOverrideList) DeepCopyInto(out *FlexNodeOverrideList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]FlexNodeOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlexNodeOverrideList.
+func (in *FlexNodeOverrideList) DeepCopy() *FlexNodeOverrideList {
+	if in == nil {
+		return nil
+	}
+	out := new(FlexNodeOverrideList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FlexNodeOverrideList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlexNodeOverrideSpec) DeepCopyInto(out *FlexNodeOverrideSpec) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DaemonSet != nil {
+		in, out := &in.DaemonSet, &out.DaemonSet
+		*out = new(DaemonSetReference)
+		**out = **in
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]ResourceOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlexNodeOverrideSpec.
+func (in *FlexNodeOverrideSpec) DeepCopy() *FlexNodeOverrideSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FlexNodeOverrideSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FlexNodeOverrideStatus) DeepCopyInto(out *FlexNodeOverrideStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FlexNodeOverrideStatus.
+func (in *FlexNodeOverrideStatus) DeepCopy() *FlexNodeOverrideStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FlexNodeOverrideStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceOverride) DeepCopyInto(out *ResourceOverride) {
+	*out = *in
+	if in.Percentage != nil {
+		in, out := &in.Percentage, &out.Percentage
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceOverride.
+func (in *ResourceOverride) DeepCopy() *ResourceOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceOverride)
+	in.DeepCopyInto(out)
+	return out
+}
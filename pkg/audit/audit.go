@@ -0,0 +1,163 @@
+// Package audit records how FlexDaemonsets sized a pod, so the decision can be reviewed, reproduced and reversed.
+//
+// Every pod resized by the pod controller carries a DecisionAnnotation with a JSON-encoded Decision. The same
+// record can additionally be written as one JSON line per decision to an audit sink (a file or stdout). In dry-run
+// mode the webhook records the Decision it would have applied in DryRunAnnotation instead.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+// DecisionAnnotation holds the JSON-encoded Decision on a sized pod.
+const DecisionAnnotation = "flexdaemonsets.xai/sizing-decision"
+
+// DryRunAnnotation holds the JSON-encoded Decision a pod admitted in dry-run mode would have been sized with. The
+// pod keeps its original resources.
+const DryRunAnnotation = "flexdaemonsets.xai/dry-run-decision"
+
+// StdoutSinkPath selects stdout as the audit sink.
+const StdoutSinkPath = "-"
+
+// Decision describes one sizing decision: what the pod asked for, what it was given and why.
+type Decision struct {
+	// Time is when the decision was made.
+	Time metav1.Time `json:"time"`
+	// CalculatorVersion identifies the calculation rules that produced Resources.
+	CalculatorVersion string `json:"calculatorVersion"`
+
+	PodNamespace string `json:"podNamespace,omitempty"`
+	PodName      string `json:"podName,omitempty"`
+	PodUID       string `json:"podUID,omitempty"`
+	NodeName     string `json:"nodeName"`
+
+	Template           string `json:"template"`
+	TemplateGeneration int64  `json:"templateGeneration"`
+
+	// NodeAllocatable is the node's allocatable resources at the time of the decision.
+	NodeAllocatable corev1.ResourceList `json:"nodeAllocatable,omitempty"`
+	// EffectiveAllocatable is what the template was applied to, if the node adjusts its allocatable with the
+	// flex-base or reserved annotations or labels.
+	EffectiveAllocatable corev1.ResourceList `json:"effectiveAllocatable,omitempty"`
+	// Resources are the requests and limits applied to every container.
+	Resources corev1.ResourceList `json:"resources,omitempty"`
+	// Bounds records which template rule produced each resource.
+	Bounds map[corev1.ResourceName]utils.Bound `json:"bounds,omitempty"`
+	// Override names the FlexNodeOverride applied on top of the template, if any.
+	Override string `json:"override,omitempty"`
+
+	// OriginalContainers and OriginalInitContainers hold each container's resources before sizing, by container name.
+	OriginalContainers     map[string]corev1.ResourceRequirements `json:"originalContainers,omitempty"`
+	OriginalInitContainers map[string]corev1.ResourceRequirements `json:"originalInitContainers,omitempty"`
+}
+
+// NewDecision builds the audit record for sizing pod on node with the given template and calculation result.
+// The pod must still carry its original resources.
+func NewDecision(pod *corev1.Pod, node *corev1.Node, templateName string, templateGeneration int64, result *utils.CalculationResult) *Decision {
+	decision := &Decision{
+		Time:                   metav1.Now(),
+		CalculatorVersion:      utils.CalculatorVersion,
+		PodNamespace:           pod.Namespace,
+		PodName:                pod.Name,
+		PodUID:                 string(pod.UID),
+		NodeName:               node.Name,
+		Template:               templateName,
+		TemplateGeneration:     templateGeneration,
+		NodeAllocatable:        node.Status.Allocatable.DeepCopy(),
+		Resources:              result.Resources.DeepCopy(),
+		Bounds:                 result.Bounds,
+		Override:               result.Override,
+		OriginalContainers:     containerResources(pod.Spec.Containers),
+		OriginalInitContainers: containerResources(pod.Spec.InitContainers),
+	}
+	if utils.HasAllocatableAdjustments(node) {
+		decision.EffectiveAllocatable, _ = utils.EffectiveAllocatable(node)
+	}
+	return decision
+}
+
+func containerResources(containers []corev1.Container) map[string]corev1.ResourceRequirements {
+	if len(containers) == 0 {
+		return nil
+	}
+	out := make(map[string]corev1.ResourceRequirements, len(containers))
+	for _, c := range containers {
+		out[c.Name] = *c.Resources.DeepCopy()
+	}
+	return out
+}
+
+// Encode returns the decision as compact JSON, suitable for the DecisionAnnotation value.
+func (d *Decision) Encode() (string, error) {
+	raw, err := json.Marshal(d)
+	if err != nil {
+		return "", fmt.Errorf("encoding sizing decision: %w", err)
+	}
+	return string(raw), nil
+}
+
+// DecodeDecision parses a DecisionAnnotation value.
+func DecodeDecision(value string) (*Decision, error) {
+	d := &Decision{}
+	if err := json.Unmarshal([]byte(value), d); err != nil {
+		return nil, fmt.Errorf("decoding sizing decision: %w", err)
+	}
+	return d, nil
+}
+
+// Sink receives every sizing decision.
+type Sink interface {
+	Record(d *Decision) error
+}
+
+// JSONLinesSink writes one JSON object per line to an io.Writer.
+type JSONLinesSink struct {
+	mu      sync.Mutex
+	closer  io.Closer
+	encoder *json.Encoder
+}
+
+var _ Sink = &JSONLinesSink{}
+
+// NewJSONLinesSink returns a sink writing to w.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{encoder: json.NewEncoder(w)}
+}
+
+// OpenSink opens the JSON-lines sink at path, appending to it. StdoutSinkPath writes to stdout.
+func OpenSink(path string) (*JSONLinesSink, error) {
+	if path == StdoutSinkPath {
+		return NewJSONLinesSink(os.Stdout), nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit sink %s: %w", path, err)
+	}
+	sink := NewJSONLinesSink(f)
+	sink.closer = f
+	return sink, nil
+}
+
+// Record implements Sink. json.Encoder terminates every value with a newline.
+func (s *JSONLinesSink) Record(d *Decision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encoder.Encode(d)
+}
+
+// Close closes the underlying file, if the sink owns one.
+func (s *JSONLinesSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
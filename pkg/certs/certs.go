@@ -0,0 +1,174 @@
+// Package certs implements the manager's self-managed webhook certificates: a CA and a serving certificate are
+// generated and stored in a Secret, the CA is injected into the webhook configurations, both are rotated before
+// they expire, and every replica serves the current certificate without a restart.
+package certs
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Keys of the certificate Secret. tls.crt and tls.key are the usual kubernetes.io/tls keys, so the Secret can also be
+// mounted as a --cert-dir. ca-bundle.crt holds the current CA and, during a CA rotation, the previous one; it is what
+// gets injected as caBundle.
+const (
+	CACertKey      = "ca.crt"
+	CAKeyKey       = "ca.key"
+	CABundleKey    = "ca-bundle.crt"
+	ServingCertKey = corev1.TLSCertKey
+	ServingKeyKey  = corev1.TLSPrivateKeyKey
+)
+
+// CAValidity is how long a generated CA is valid. The CA is rotated like the serving certificate, so this only
+// bounds how long an abandoned Secret stays trusted.
+const CAValidity = 5 * 365 * 24 * time.Hour
+
+// clockSkew backdates certificates so nodes with a slightly late clock accept them.
+const clockSkew = 5 * time.Minute
+
+// KeyPair is a certificate with its private key, in parsed and PEM form.
+type KeyPair struct {
+	Cert    *x509.Certificate
+	Key     crypto.Signer
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// GenerateCA creates a self-signed CA valid for validity from now.
+func GenerateCA(commonName string, now time.Time, validity time.Duration) (*KeyPair, error) {
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              now.Add(validity),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	return newKeyPair(template, nil)
+}
+
+// GenerateServingCert creates a server certificate for the DNS names, signed by ca and valid for validity from now.
+// It never outlives the CA.
+func GenerateServingCert(ca *KeyPair, dnsNames []string, now time.Time, validity time.Duration) (*KeyPair, error) {
+	notAfter := now.Add(validity)
+	if notAfter.After(ca.Cert.NotAfter) {
+		notAfter = ca.Cert.NotAfter
+	}
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:    dnsNames,
+		NotBefore:   now.Add(-clockSkew),
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	return newKeyPair(template, ca)
+}
+
+func newKeyPair(template *x509.Certificate, parent *KeyPair) (*KeyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generating serial number: %w", err)
+	}
+	template.SerialNumber = serial
+
+	parentCert, parentKey := template, crypto.Signer(key)
+	if parent != nil {
+		parentCert, parentKey = parent.Cert, parent.Key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, key.Public(), parentKey)
+	if err != nil {
+		return nil, fmt.Errorf("creating certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{
+		Cert:    cert,
+		Key:     key,
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
+
+// ParseKeyPair parses a PEM certificate and private key and checks that they belong together.
+func ParseKeyPair(certPEM, keyPEM []byte) (*KeyPair, error) {
+	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(tlsCert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := tlsCert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot sign")
+	}
+	return &KeyPair{Cert: cert, Key: signer, CertPEM: certPEM, KeyPEM: keyPEM}, nil
+}
+
+// ParseCertificates returns the certificates in PEM data, skipping anything that is not a valid certificate.
+func ParseCertificates(data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+}
+
+// ServiceDNSNames returns the names the API server may use to reach a webhook service.
+func ServiceDNSNames(service, namespace string) []string {
+	return []string{
+		service + "." + namespace + ".svc",
+		service + "." + namespace + ".svc.cluster.local",
+		service + "." + namespace,
+		service,
+	}
+}
+
+// bundle concatenates the distinct PEM certificates that have not expired at now.
+func bundle(now time.Time, certs ...[]byte) []byte {
+	var out bytes.Buffer
+	seen := map[string]bool{}
+	for _, certPEM := range certs {
+		for _, cert := range ParseCertificates(certPEM) {
+			if now.Before(cert.NotAfter) && !seen[string(cert.Raw)] {
+				seen[string(cert.Raw)] = true
+				_ = pem.Encode(&out, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+			}
+		}
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,115 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/metrics"
+)
+
+// DefaultReloadInterval is how often every replica rereads the certificate Secret. Rotation happens long before
+// expiry and the previous CA stays trusted meanwhile, so a replica a little behind still serves a valid certificate.
+const DefaultReloadInterval = time.Minute
+
+// Loader serves the webhook certificate from the Secret and swaps it in place when the Secret changes, so a
+// rotated certificate takes effect without restarting the manager. It runs on every replica.
+type Loader struct {
+	// Reader reads the Secret directly from the API server.
+	Reader     client.Reader
+	Namespace  string
+	SecretName string
+	// Interval is how often the Secret is reread. Defaults to DefaultReloadInterval.
+	Interval time.Duration
+
+	current atomic.Pointer[tls.Certificate]
+	raw     atomic.Pointer[[]byte]
+}
+
+// errNotLoaded is returned until a certificate has been loaded.
+var errNotLoaded = errors.New("webhook serving certificate not loaded yet")
+
+// NeedLeaderElection makes the manager run the loader on every replica, not only on the leader.
+func (l *Loader) NeedLeaderElection() bool {
+	return false
+}
+
+// Start loads the certificate immediately and then every Interval until ctx is done. The Secret may not exist yet
+// while the leader is being elected; loading is retried until it does.
+func (l *Loader) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("cert-loader")
+	interval := l.Interval
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := l.load(ctx); err != nil {
+			logger.Error(err, "Failed to load webhook serving certificate, retrying", "retryIn", interval)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *Loader) load(ctx context.Context) error {
+	secret := &corev1.Secret{}
+	if err := l.Reader.Get(ctx, types.NamespacedName{Namespace: l.Namespace, Name: l.SecretName}, secret); err != nil {
+		return fmt.Errorf("getting certificate secret: %w", err)
+	}
+	kp, err := ParseKeyPair(secret.Data[ServingCertKey], secret.Data[ServingKeyKey])
+	if err != nil {
+		return fmt.Errorf("parsing serving certificate from secret %s/%s: %w", l.Namespace, l.SecretName, err)
+	}
+	if l.set(kp) {
+		log.FromContext(ctx).Info("Loaded webhook serving certificate", "notAfter", kp.Cert.NotAfter)
+	}
+	return nil
+}
+
+// set swaps in the key pair and reports whether it differs from the one being served.
+func (l *Loader) set(kp *KeyPair) bool {
+	raw := append(append([]byte{}, kp.CertPEM...), kp.KeyPEM...)
+	if previous := l.raw.Load(); previous != nil && string(*previous) == string(raw) {
+		return false
+	}
+	cert, err := tls.X509KeyPair(kp.CertPEM, kp.KeyPEM)
+	if err != nil {
+		return false
+	}
+	l.current.Store(&cert)
+	l.raw.Store(&raw)
+	metrics.WebhookCertificateExpiry.WithLabelValues("serving").Set(float64(kp.Cert.NotAfter.Unix()))
+	return true
+}
+
+// GetCertificate is used as the webhook server's tls.Config.GetCertificate.
+func (l *Loader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := l.current.Load()
+	if cert == nil {
+		return nil, errNotLoaded
+	}
+	return cert, nil
+}
+
+// Checker is a readiness check that fails until a certificate has been loaded, so the Service does not route
+// admission requests to a replica that cannot complete a TLS handshake.
+func (l *Loader) Checker(_ *http.Request) error {
+	if l.current.Load() == nil {
+		return errNotLoaded
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/metrics"
+)
+
+// Defaults for Options.
+const (
+	DefaultValidity     = 90 * 24 * time.Hour
+	DefaultRotateBefore = 30 * 24 * time.Hour
+	// DefaultCheckInterval is how often the rotator checks the certificates and caBundles. Checking every minute
+	// also restores a caBundle wiped by re-applying manifests/webhook.yaml within a minute.
+	DefaultCheckInterval = time.Minute
+)
+
+// Options describe where the certificates live and where the CA must be injected.
+type Options struct {
+	// Namespace and SecretName locate the certificate Secret.
+	Namespace  string
+	SecretName string
+	// ServiceName is the webhook Service in Namespace; the serving certificate covers its DNS names.
+	ServiceName string
+
+	// MutatingWebhookConfigurations, ValidatingWebhookConfigurations and ConversionCRDs name the objects whose
+	// caBundle is kept in sync with the CA. Every webhook of a named configuration is updated.
+	MutatingWebhookConfigurations   []string
+	ValidatingWebhookConfigurations []string
+	ConversionCRDs                  []string
+
+	// Validity is how long a serving certificate is issued for, RotateBefore how long before expiry it (or the CA)
+	// is replaced, and CheckInterval how often that is checked.
+	Validity      time.Duration
+	RotateBefore  time.Duration
+	CheckInterval time.Duration
+}
+
+// Validate checks the options for values that would make the rotator spin or never rotate.
+func (o *Options) Validate() error {
+	switch {
+	case o.Namespace == "" || o.SecretName == "" || o.ServiceName == "":
+		return fmt.Errorf("certificate namespace, secret name and service name are required")
+	case o.Validity <= 0 || o.RotateBefore <= 0:
+		return fmt.Errorf("certificate validity and rotate-before must be positive")
+	case o.RotateBefore >= o.Validity:
+		return fmt.Errorf("certificate rotate-before (%s) must be shorter than the validity (%s)", o.RotateBefore, o.Validity)
+	}
+	return nil
+}
+
+// Rotator keeps the certificate Secret valid and the caBundles in sync. It needs leader election, so with several
+// replicas only the leader writes; without leader election concurrent writers are resolved by the Secret's
+// resourceVersion and the losers pick up the winner's certificates on their next check.
+type Rotator struct {
+	// Client writes the Secret and the webhook configurations.
+	Client client.Client
+	// Reader reads them directly from the API server. Secrets and webhook configurations are not cached.
+	Reader client.Reader
+	Options
+
+	// Loader, if set, is handed every certificate the rotator writes, so the leader serves it immediately.
+	Loader *Loader
+}
+
+// The Secret lives in the manager's namespace; these rules are generated into a namespaced Role.
+//+kubebuilder:rbac:groups="",namespace=flexdaemonsets-system,resources=secrets,verbs=get;create;update
+// Injecting the caBundle needs get and update on the named webhook configurations and CRDs only. Those rules are
+// not generated: manifests/self-managed-certs.yaml grants them by resourceNames, and only when certificates are
+// self-managed.
+// Leader election coordinates the rotator, and the controllers, across replicas.
+//+kubebuilder:rbac:groups=coordination.k8s.io,namespace=flexdaemonsets-system,resources=leases,verbs=get;create;update
+
+// NeedLeaderElection makes the manager start the rotator on the leader only.
+func (r *Rotator) NeedLeaderElection() bool {
+	return true
+}
+
+// Start checks the certificates immediately and then every CheckInterval until ctx is done. Failures are logged
+// and retried on the next check.
+func (r *Rotator) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("cert-rotator")
+	ctx = log.IntoContext(ctx, logger)
+	interval := r.CheckInterval
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Reconcile(ctx, time.Now()); err != nil {
+			logger.Error(err, "Failed to reconcile webhook certificates, retrying", "retryIn", interval)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile makes one pass: it renews whatever is missing, invalid or about to expire, stores the certificates and
+// only then injects the CA bundle. A CA is never injected before it is persisted, so a failed Secret write cannot
+// leave the API server trusting a CA that is lost; if the injection fails, the next pass injects the same bundle
+// from the Secret.
+func (r *Rotator) Reconcile(ctx context.Context, now time.Time) error {
+	logger := log.FromContext(ctx)
+
+	secret := &corev1.Secret{}
+	exists := true
+	if err := r.Reader.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: r.SecretName}, secret); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("getting certificate secret: %w", err)
+		}
+		exists = false
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: r.Namespace, Name: r.SecretName},
+			Type:       corev1.SecretTypeTLS,
+		}
+	}
+	data := secret.Data
+	if data == nil {
+		data = map[string][]byte{}
+	}
+
+	ca, err := ParseKeyPair(data[CACertKey], data[CAKeyKey])
+	switch {
+	case err != nil:
+		logger.Info("Generating webhook CA", "reason", fmt.Sprintf("no valid CA in secret: %v", err))
+		ca, err = r.rotate("ca", func() (*KeyPair, error) { return GenerateCA(r.ServiceName+"-ca", now, CAValidity) })
+	case r.expiring(ca.Cert, now):
+		logger.Info("Rotating webhook CA", "notAfter", ca.Cert.NotAfter)
+		ca, err = r.rotate("ca", func() (*KeyPair, error) { return GenerateCA(r.ServiceName+"-ca", now, CAValidity) })
+	}
+	if err != nil {
+		return err
+	}
+	metrics.WebhookCertificateExpiry.WithLabelValues("ca").Set(float64(ca.Cert.NotAfter.Unix()))
+
+	// The previous CA stays in the bundle until it expires, so certificates it signed remain trusted while
+	// replicas pick up the new serving certificate.
+	caBundle := bundle(now, ca.CertPEM, data[CABundleKey])
+
+	dnsNames := ServiceDNSNames(r.ServiceName, r.Namespace)
+	serving, err := ParseKeyPair(data[ServingCertKey], data[ServingKeyKey])
+	if reason := r.servingRenewalReason(serving, err, ca, dnsNames, now); reason != "" {
+		logger.Info("Issuing webhook serving certificate", "reason", reason)
+		serving, err = r.rotate("serving", func() (*KeyPair, error) { return GenerateServingCert(ca, dnsNames, now, r.Validity) })
+		if err != nil {
+			return err
+		}
+	}
+
+	updated := map[string][]byte{
+		CACertKey:      ca.CertPEM,
+		CAKeyKey:       ca.KeyPEM,
+		CABundleKey:    caBundle,
+		ServingCertKey: serving.CertPEM,
+		ServingKeyKey:  serving.KeyPEM,
+	}
+	if !exists {
+		secret.Data = updated
+		if err := r.Client.Create(ctx, secret); err != nil {
+			// Another replica created it first; its certificates are used from the next check on.
+			return fmt.Errorf("creating certificate secret: %w", err)
+		}
+		logger.Info("Created webhook certificate secret", "secret", client.ObjectKeyFromObject(secret))
+	} else if !secretDataEqual(data, updated) {
+		secret.Data = updated
+		if err := r.Client.Update(ctx, secret); err != nil {
+			return fmt.Errorf("updating certificate secret: %w", err)
+		}
+		logger.Info("Updated webhook certificate secret", "secret", client.ObjectKeyFromObject(secret), "servingNotAfter", serving.Cert.NotAfter)
+	}
+
+	if err := r.injectCABundle(ctx, caBundle); err != nil {
+		return err
+	}
+	if r.Loader != nil {
+		r.Loader.set(serving)
+	}
+	return nil
+}
+
+func (r *Rotator) rotate(certificate string, generate func() (*KeyPair, error)) (*KeyPair, error) {
+	kp, err := generate()
+	if err != nil {
+		return nil, fmt.Errorf("generating %s certificate: %w", certificate, err)
+	}
+	metrics.WebhookCertificateRotations.WithLabelValues(certificate).Inc()
+	return kp, nil
+}
+
+func (r *Rotator) expiring(cert *x509.Certificate, now time.Time) bool {
+	return !now.Add(r.RotateBefore).Before(cert.NotAfter)
+}
+
+// servingRenewalReason returns why the serving certificate must be reissued, or "" if it is still good.
+func (r *Rotator) servingRenewalReason(serving *KeyPair, parseErr error, ca *KeyPair, dnsNames []string, now time.Time) string {
+	if parseErr != nil {
+		return fmt.Sprintf("no valid serving certificate in secret: %v", parseErr)
+	}
+	if r.expiring(serving.Cert, now) {
+		return fmt.Sprintf("expires at %s", serving.Cert.NotAfter.Format(time.RFC3339))
+	}
+	if err := serving.Cert.CheckSignatureFrom(ca.Cert); err != nil {
+		return "not signed by the current CA"
+	}
+	for _, name := range dnsNames {
+		if err := serving.Cert.VerifyHostname(name); err != nil {
+			return fmt.Sprintf("does not cover %s", name)
+		}
+	}
+	return ""
+}
+
+// injectCABundle sets the caBundle of every configured webhook and conversion webhook. Objects that do not exist
+// yet are skipped; they are picked up on a later check once applied.
+func (r *Rotator) injectCABundle(ctx context.Context, caBundle []byte) error {
+	logger := log.FromContext(ctx)
+
+	for _, name := range r.MutatingWebhookConfigurations {
+		config := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		if err := r.Reader.Get(ctx, types.NamespacedName{Name: name}, config); err != nil {
+			if errors.IsNotFound(err) {
+				logger.Info("MutatingWebhookConfiguration not found, not injecting caBundle", "name", name)
+				continue
+			}
+			return fmt.Errorf("getting MutatingWebhookConfiguration %s: %w", name, err)
+		}
+		changed := false
+		for i := range config.Webhooks {
+			if !bytes.Equal(config.Webhooks[i].ClientConfig.CABundle, caBundle) {
+				config.Webhooks[i].ClientConfig.CABundle = caBundle
+				changed = true
+			}
+		}
+		if changed {
+			if err := r.Client.Update(ctx, config); err != nil {
+				return fmt.Errorf("injecting caBundle into MutatingWebhookConfiguration %s: %w", name, err)
+			}
+			logger.Info("Injected caBundle", "mutatingWebhookConfiguration", name)
+		}
+	}
+
+	for _, name := range r.ValidatingWebhookConfigurations {
+		config := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		if err := r.Reader.Get(ctx, types.NamespacedName{Name: name}, config); err != nil {
+			if errors.IsNotFound(err) {
+				logger.Info("ValidatingWebhookConfiguration not found, not injecting caBundle", "name", name)
+				continue
+			}
+			return fmt.Errorf("getting ValidatingWebhookConfiguration %s: %w", name, err)
+		}
+		changed := false
+		for i := range config.Webhooks {
+			if !bytes.Equal(config.Webhooks[i].ClientConfig.CABundle, caBundle) {
+				config.Webhooks[i].ClientConfig.CABundle = caBundle
+				changed = true
+			}
+		}
+		if changed {
+			if err := r.Client.Update(ctx, config); err != nil {
+				return fmt.Errorf("injecting caBundle into ValidatingWebhookConfiguration %s: %w", name, err)
+			}
+			logger.Info("Injected caBundle", "validatingWebhookConfiguration", name)
+		}
+	}
+
+	// CRDs are handled as unstructured objects so the apiextensions types need not be in the manager's scheme.
+	encoded := base64.StdEncoding.EncodeToString(caBundle)
+	for _, name := range r.ConversionCRDs {
+		crd := &unstructured.Unstructured{}
+		crd.SetAPIVersion("apiextensions.k8s.io/v1")
+		crd.SetKind("CustomResourceDefinition")
+		if err := r.Reader.Get(ctx, types.NamespacedName{Name: name}, crd); err != nil {
+			if errors.IsNotFound(err) {
+				logger.Info("CustomResourceDefinition not found, not injecting caBundle", "name", name)
+				continue
+			}
+			return fmt.Errorf("getting CustomResourceDefinition %s: %w", name, err)
+		}
+		if strategy, _, _ := unstructured.NestedString(crd.Object, "spec", "conversion", "strategy"); strategy != "Webhook" {
+			logger.Info("CustomResourceDefinition has no conversion webhook, not injecting caBundle", "name", name)
+			continue
+		}
+		current, _, _ := unstructured.NestedString(crd.Object, "spec", "conversion", "webhook", "clientConfig", "caBundle")
+		if current == encoded {
+			continue
+		}
+		if err := unstructured.SetNestedField(crd.Object, encoded, "spec", "conversion", "webhook", "clientConfig", "caBundle"); err != nil {
+			return err
+		}
+		if err := r.Client.Update(ctx, crd); err != nil {
+			return fmt.Errorf("injecting caBundle into CustomResourceDefinition %s: %w", name, err)
+		}
+		logger.Info("Injected caBundle", "customResourceDefinition", name)
+	}
+	return nil
+}
+
+func secretDataEqual(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if !bytes.Equal(value, b[key]) {
+			return false
+		}
+	}
+	return true
+}
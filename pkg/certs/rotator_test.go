@@ -0,0 +1,241 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+const (
+	testNamespace     = "flexdaemonsets-system"
+	testSecret        = "flexdaemonsets-webhook-tls"
+	testService       = "flexdaemonsets-webhook-svc"
+	testWebhookConfig = "flexdaemonsets-mutating-webhook-config"
+)
+
+var testNow = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func testWebhookConfiguration() *admissionregistrationv1.MutatingWebhookConfiguration {
+	return &admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: testWebhookConfig},
+		Webhooks:   []admissionregistrationv1.MutatingWebhook{{Name: "flexdaemonsets.xai.webhook"}},
+	}
+}
+
+// testRotator returns a rotator over a fake client holding objs. Every write is appended to writes as
+// "<verb> <kind>"; failWrite, if set, can fail a write before it reaches the client.
+func testRotator(t *testing.T, writes *[]string, failWrite func(verb string, obj client.Object) error, objs ...client.Object) (*Rotator, client.Client) {
+	t.Helper()
+	record := func(verb string, obj client.Object) error {
+		if failWrite != nil {
+			if err := failWrite(verb, obj); err != nil {
+				return err
+			}
+		}
+		kind := fmt.Sprintf("%T", obj)
+		switch obj.(type) {
+		case *corev1.Secret:
+			kind = "Secret"
+		case *admissionregistrationv1.MutatingWebhookConfiguration:
+			kind = "MutatingWebhookConfiguration"
+		}
+		*writes = append(*writes, verb+" "+kind)
+		return nil
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(clientgoscheme.Scheme).
+		WithObjects(objs...).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if err := record("create", obj); err != nil {
+					return err
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				if err := record("update", obj); err != nil {
+					return err
+				}
+				return c.Update(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	return &Rotator{
+		Client: c,
+		Reader: c,
+		Options: Options{
+			Namespace:                     testNamespace,
+			SecretName:                    testSecret,
+			ServiceName:                   testService,
+			MutatingWebhookConfigurations: []string{testWebhookConfig},
+			Validity:                      DefaultValidity,
+			RotateBefore:                  DefaultRotateBefore,
+		},
+		Loader: &Loader{},
+	}, c
+}
+
+func getSecretAndBundle(t *testing.T, c client.Client) (*corev1.Secret, []byte) {
+	t.Helper()
+	ctx := context.Background()
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: testNamespace, Name: testSecret}, secret); err != nil {
+		t.Fatal(err)
+	}
+	config := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	if err := c.Get(ctx, types.NamespacedName{Name: testWebhookConfig}, config); err != nil {
+		t.Fatal(err)
+	}
+	return secret, config.Webhooks[0].ClientConfig.CABundle
+}
+
+// expiringSecret returns a Secret holding a CA and serving certificate that are due for rotation at testNow.
+func expiringSecret(t *testing.T) *corev1.Secret {
+	t.Helper()
+	issued := testNow.Add(-DefaultValidity + time.Hour)
+	ca, err := GenerateCA(testService+"-ca", issued, DefaultValidity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serving, err := GenerateServingCert(ca, ServiceDNSNames(testService, testNamespace), issued, DefaultValidity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testSecret},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			CACertKey:      ca.CertPEM,
+			CAKeyKey:       ca.KeyPEM,
+			CABundleKey:    ca.CertPEM,
+			ServingCertKey: serving.CertPEM,
+			ServingKeyKey:  serving.KeyPEM,
+		},
+	}
+}
+
+func TestReconcileStoresSecretBeforeInjecting(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret func(t *testing.T) *corev1.Secret
+		want   []string
+	}{
+		{name: "new secret", want: []string{"create Secret", "update MutatingWebhookConfiguration"}},
+		{name: "rotated CA", secret: expiringSecret, want: []string{"update Secret", "update MutatingWebhookConfiguration"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []client.Object{testWebhookConfiguration()}
+			if tt.secret != nil {
+				objs = append(objs, tt.secret(t))
+			}
+			var writes []string
+			r, c := testRotator(t, &writes, nil, objs...)
+			if err := r.Reconcile(context.Background(), testNow); err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(writes) != fmt.Sprint(tt.want) {
+				t.Errorf("writes = %v, want %v", writes, tt.want)
+			}
+			secret, caBundle := getSecretAndBundle(t, c)
+			if !bytes.Equal(caBundle, secret.Data[CABundleKey]) {
+				t.Error("injected caBundle differs from the stored bundle")
+			}
+			if _, err := r.Loader.GetCertificate(nil); err != nil {
+				t.Errorf("loader: %v", err)
+			}
+
+			// A second pass finds everything in place and writes nothing.
+			writes = nil
+			if err := r.Reconcile(context.Background(), testNow); err != nil {
+				t.Fatal(err)
+			}
+			if len(writes) != 0 {
+				t.Errorf("second pass wrote %v", writes)
+			}
+		})
+	}
+}
+
+// A CA that could not be stored must not be injected: the next pass would generate another one, and the API server
+// would trust a CA whose key is lost.
+func TestReconcileDoesNotInjectUnstoredCA(t *testing.T) {
+	failSecret := true
+	failWrite := func(verb string, obj client.Object) error {
+		if _, ok := obj.(*corev1.Secret); ok && failSecret {
+			return fmt.Errorf("injected %s failure", verb)
+		}
+		return nil
+	}
+	var writes []string
+	r, c := testRotator(t, &writes, failWrite, testWebhookConfiguration())
+
+	if err := r.Reconcile(context.Background(), testNow); err == nil {
+		t.Fatal("expected the Secret write to fail")
+	}
+	config := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	if err := c.Get(context.Background(), types.NamespacedName{Name: testWebhookConfig}, config); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Webhooks[0].ClientConfig.CABundle) != 0 {
+		t.Error("caBundle injected although the Secret was not stored")
+	}
+	if _, err := r.Loader.GetCertificate(nil); err == nil {
+		t.Error("loader serves a certificate that was not stored")
+	}
+
+	failSecret = false
+	if err := r.Reconcile(context.Background(), testNow); err != nil {
+		t.Fatal(err)
+	}
+	secret, caBundle := getSecretAndBundle(t, c)
+	if !bytes.Equal(caBundle, secret.Data[CABundleKey]) {
+		t.Error("injected caBundle differs from the stored bundle")
+	}
+}
+
+// If the injection fails after the Secret is stored, the next pass injects the stored bundle without generating a
+// new CA.
+func TestReconcileRetriesInjectionFromSecret(t *testing.T) {
+	failInject := true
+	failWrite := func(verb string, obj client.Object) error {
+		if _, ok := obj.(*admissionregistrationv1.MutatingWebhookConfiguration); ok && failInject {
+			return fmt.Errorf("injected %s failure", verb)
+		}
+		return nil
+	}
+	var writes []string
+	r, c := testRotator(t, &writes, failWrite, testWebhookConfiguration())
+
+	if err := r.Reconcile(context.Background(), testNow); err == nil {
+		t.Fatal("expected the injection to fail")
+	}
+	stored, _ := getSecretAndBundle(t, c)
+
+	failInject = false
+	writes = nil
+	if err := r.Reconcile(context.Background(), testNow); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(writes) != "[update MutatingWebhookConfiguration]" {
+		t.Errorf("writes = %v, want only the injection", writes)
+	}
+	secret, caBundle := getSecretAndBundle(t, c)
+	if !bytes.Equal(secret.Data[CACertKey], stored.Data[CACertKey]) {
+		t.Error("CA regenerated on retry")
+	}
+	if !bytes.Equal(caBundle, stored.Data[CABundleKey]) {
+		t.Error("injected caBundle differs from the stored bundle")
+	}
+}
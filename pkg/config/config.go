@@ -0,0 +1,218 @@
+// Package config defines the manager's versioned configuration file, loaded with --config. The most common
+// settings also have command-line flags (see BindFlags); flags given explicitly take precedence over the file.
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/certs"
+	flexcontroller "github.com/prakarsh-dt/FlexDaemonsets/pkg/controller"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+	flexwebhook "github.com/prakarsh-dt/FlexDaemonsets/pkg/webhook"
+)
+
+// GroupVersion is the apiVersion of the configuration file.
+var GroupVersion = schema.GroupVersion{Group: "config.flexdaemonsets.xai", Version: "v1alpha1"}
+
+// Kind is the kind of the configuration file.
+const Kind = "ManagerConfiguration"
+
+// Certificate modes.
+const (
+	// CertModeManual serves tls.crt and tls.key from the webhook's certDir, provisioned by someone else.
+	CertModeManual = "manual"
+	// CertModeSelfManaged has the manager generate, inject and rotate its own certificate (see package certs).
+	CertModeSelfManaged = "self-managed"
+)
+
+// ManagerConfiguration configures the manager process.
+type ManagerConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	Metrics        MetricsConfiguration        `json:"metrics,omitempty"`
+	Health         HealthConfiguration         `json:"health,omitempty"`
+	Webhook        WebhookConfiguration        `json:"webhook,omitempty"`
+	Certificates   CertificatesConfiguration   `json:"certificates,omitempty"`
+	LeaderElection LeaderElectionConfiguration `json:"leaderElection,omitempty"`
+	Controllers    ControllersConfiguration    `json:"controllers,omitempty"`
+
+	// Namespaces and NamespaceSelector restrict the namespaced objects the manager watches and sizes to the named
+	// namespaces plus those whose labels match the selector. The selector is resolved at startup. Leaving both
+	// empty watches every namespace.
+	Namespaces        []string              `json:"namespaces,omitempty"`
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Templates holds defaults for fields a FlexDaemonsetTemplate leaves unset.
+	Templates utils.TemplateDefaults `json:"templates,omitempty"`
+
+	// DryRun records every sizing decision without applying it, as if every template set spec.dryRun: the webhook
+	// annotates pods with the decision instead of marking them for sizing and the controllers skip their writes.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// FeatureGates turns experimental behaviour on or off by name; see KnownFeatureGates.
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+
+	// AuditLog appends every pod sizing decision as a JSON line to this file; "-" is stdout, empty disables it.
+	AuditLog string `json:"auditLog,omitempty"`
+}
+
+type MetricsConfiguration struct {
+	// BindAddress of the metrics endpoint. "0" disables it.
+	BindAddress string `json:"bindAddress,omitempty"`
+}
+
+type HealthConfiguration struct {
+	// ProbeBindAddress of the /healthz and /readyz endpoints.
+	ProbeBindAddress string `json:"probeBindAddress,omitempty"`
+}
+
+type WebhookConfiguration struct {
+	// Enabled registers the pod mutating webhook. Without it DaemonSet pods are admitted without the sizing
+	// annotation, so the Pod controller leaves their resources alone.
+	Enabled bool `json:"enabled"`
+	// Host and Port the webhook server listens on.
+	Host string `json:"host,omitempty"`
+	Port int    `json:"port,omitempty"`
+	// CertDir holds tls.crt and tls.key in the manual certificate mode.
+	CertDir string `json:"certDir,omitempty"`
+	// FailurePolicy is Allow, Fallback or Deny: what the webhook does with a pod it cannot mark for sizing, unless
+	// the pod's template sets its own.
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+	// LookupTimeout bounds the reads the webhook makes for one pod, so it answers within the API server's
+	// timeoutSeconds and the failure policy applies instead of the webhook configuration's.
+	LookupTimeout metav1.Duration `json:"lookupTimeout,omitempty"`
+}
+
+type CertificatesConfiguration struct {
+	// Mode is CertModeManual or CertModeSelfManaged.
+	Mode string `json:"mode,omitempty"`
+	// SecretName and Namespace of the self-managed certificate Secret. Namespace defaults to the manager's.
+	SecretName string `json:"secretName,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	// ServiceName is the Service the API server calls the webhook through.
+	ServiceName string `json:"serviceName,omitempty"`
+	// Webhook configurations and CRDs whose caBundle is kept in sync with the self-managed CA.
+	MutatingWebhookConfigurations   []string `json:"mutatingWebhookConfigurations,omitempty"`
+	ValidatingWebhookConfigurations []string `json:"validatingWebhookConfigurations,omitempty"`
+	ConversionCRDs                  []string `json:"conversionCRDs,omitempty"`
+	// Validity of a self-managed serving certificate, and how long before expiry it is replaced.
+	Validity     metav1.Duration `json:"validity,omitempty"`
+	RotateBefore metav1.Duration `json:"rotateBefore,omitempty"`
+}
+
+type LeaderElectionConfiguration struct {
+	// LeaderElect runs the controllers and certificate rotation on a single elected replica.
+	LeaderElect bool `json:"leaderElect"`
+	// ResourceName and ResourceNamespace of the Lease. The namespace defaults to the manager's.
+	ResourceName      string `json:"resourceName,omitempty"`
+	ResourceNamespace string `json:"resourceNamespace,omitempty"`
+	// LeaseDuration is how long non-leaders wait before taking over a lease that is not renewed, RenewDeadline
+	// how long the leader keeps retrying to renew before giving up, and RetryPeriod the interval between attempts.
+	LeaseDuration metav1.Duration `json:"leaseDuration,omitempty"`
+	RenewDeadline metav1.Duration `json:"renewDeadline,omitempty"`
+	RetryPeriod   metav1.Duration `json:"retryPeriod,omitempty"`
+}
+
+type ControllersConfiguration struct {
+	Pod                  ControllerConfiguration                     `json:"pod,omitempty"`
+	NodeCoverage         ControllerConfiguration                     `json:"nodeCoverage,omitempty"`
+	FlexDaemonSetNodePod FlexDaemonSetNodePodControllerConfiguration `json:"flexDaemonSetNodePod,omitempty"`
+	FlexNodeOverride     ControllerConfiguration                     `json:"flexNodeOverride,omitempty"`
+}
+
+type ControllerConfiguration struct {
+	// Enabled starts the controller.
+	Enabled bool `json:"enabled"`
+	// MaxConcurrentReconciles is the number of objects the controller reconciles in parallel.
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty"`
+}
+
+type FlexDaemonSetNodePodControllerConfiguration struct {
+	ControllerConfiguration `json:",inline"`
+
+	// SchedulingMode is NodeName or NodeAffinity; see flexcontroller.PodSchedulingMode.
+	SchedulingMode string `json:"schedulingMode,omitempty"`
+	// MaxPodRecreations is the number of consecutive pod failures after which an FDNP is Failed. 0 disables the limit.
+	MaxPodRecreations int `json:"maxPodRecreations"`
+	// BackoffBase and BackoffMax bound the delay before a failed pod is recreated; the failure count is reset once
+	// a recreated pod has been Ready for BackoffResetAfter.
+	BackoffBase       metav1.Duration `json:"backoffBase,omitempty"`
+	BackoffMax        metav1.Duration `json:"backoffMax,omitempty"`
+	BackoffResetAfter metav1.Duration `json:"backoffResetAfter,omitempty"`
+}
+
+// Default returns the configuration used for everything the file and the flags leave unset. It matches the
+// manager's historical behaviour.
+func Default() *ManagerConfiguration {
+	return &ManagerConfiguration{
+		TypeMeta: metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: Kind},
+		Metrics:  MetricsConfiguration{BindAddress: ":8080"},
+		Health:   HealthConfiguration{ProbeBindAddress: ":8081"},
+		Webhook: WebhookConfiguration{
+			Enabled:       true,
+			Port:          9443,
+			CertDir:       "/tmp/k8s-webhook-server/serving-certs",
+			FailurePolicy: string(flexdaemonsetsv1alpha1.FailurePolicyAllow),
+			LookupTimeout: metav1.Duration{Duration: flexwebhook.DefaultLookupTimeout},
+		},
+		Certificates: CertificatesConfiguration{
+			Mode:                          CertModeManual,
+			SecretName:                    "flexdaemonsets-webhook-tls",
+			ServiceName:                   "flexdaemonsets-webhook-svc",
+			MutatingWebhookConfigurations: []string{"flexdaemonsets-mutating-webhook-config"},
+			Validity:                      metav1.Duration{Duration: certs.DefaultValidity},
+			RotateBefore:                  metav1.Duration{Duration: certs.DefaultRotateBefore},
+		},
+		LeaderElection: LeaderElectionConfiguration{
+			ResourceName:  "flexdaemonsets.xai",
+			LeaseDuration: metav1.Duration{Duration: 15 * time.Second},
+			RenewDeadline: metav1.Duration{Duration: 10 * time.Second},
+			RetryPeriod:   metav1.Duration{Duration: 2 * time.Second},
+		},
+		Controllers: ControllersConfiguration{
+			Pod:          ControllerConfiguration{Enabled: true, MaxConcurrentReconciles: 1},
+			NodeCoverage: ControllerConfiguration{Enabled: true, MaxConcurrentReconciles: 1},
+			FlexDaemonSetNodePod: FlexDaemonSetNodePodControllerConfiguration{
+				ControllerConfiguration: ControllerConfiguration{Enabled: true, MaxConcurrentReconciles: 1},
+				SchedulingMode:          string(flexcontroller.SchedulingModeNodeName),
+				MaxPodRecreations:       10,
+				BackoffBase:             metav1.Duration{Duration: flexcontroller.DefaultPodRecreationBackoffBase},
+				BackoffMax:              metav1.Duration{Duration: flexcontroller.DefaultPodRecreationBackoffMax},
+				BackoffResetAfter:       metav1.Duration{Duration: flexcontroller.DefaultBackoffResetAfter},
+			},
+			FlexNodeOverride: ControllerConfiguration{Enabled: true, MaxConcurrentReconciles: 1},
+		},
+	}
+}
+
+// Restricted reports whether the manager is limited to a set of namespaces.
+func (c *ManagerConfiguration) Restricted() bool {
+	return len(c.Namespaces) > 0 || c.NamespaceSelector != nil
+}
+
+// Load reads the configuration file at path on top of Default. Unknown fields are rejected so a misspelt setting
+// does not silently fall back to its default. The result is not validated; call Validate once flags are applied.
+func Load(path string) (*ManagerConfiguration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading configuration file: %w", err)
+	}
+	cfg := Default()
+	// The file has to name its own apiVersion and kind; the defaults' would let a file without them through.
+	cfg.TypeMeta = metav1.TypeMeta{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing configuration file %s: %w", path, err)
+	}
+	if cfg.APIVersion != GroupVersion.String() || cfg.Kind != Kind {
+		return nil, fmt.Errorf("configuration file %s: apiVersion %q, kind %q is not supported, expected apiVersion %q, kind %q",
+			path, cfg.APIVersion, cfg.Kind, GroupVersion.String(), Kind)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const fileHeader = "apiVersion: config.flexdaemonsets.xai/v1alpha1\nkind: ManagerConfiguration\n"
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		check   func(*testing.T, *ManagerConfiguration)
+		wantErr string
+	}{
+		{
+			name:    "header only keeps the defaults",
+			content: fileHeader,
+			check: func(t *testing.T, cfg *ManagerConfiguration) {
+				if cfg.Metrics.BindAddress != ":8080" || !cfg.Controllers.Pod.Enabled {
+					t.Errorf("defaults lost: %+v", cfg)
+				}
+			},
+		},
+		{
+			name:    "settings on top of the defaults",
+			content: fileHeader + "dryRun: true\ncontrollers:\n  pod:\n    enabled: false\n    maxConcurrentReconciles: 4\n",
+			check: func(t *testing.T, cfg *ManagerConfiguration) {
+				if !cfg.DryRun || cfg.Controllers.Pod.Enabled || cfg.Controllers.Pod.MaxConcurrentReconciles != 4 {
+					t.Errorf("settings not applied: dryRun %v, pod %+v", cfg.DryRun, cfg.Controllers.Pod)
+				}
+				if !cfg.Controllers.NodeCoverage.Enabled {
+					t.Error("nodeCoverage lost its default")
+				}
+			},
+		},
+		{name: "misspelt field", content: fileHeader + "dryRn: true\n", wantErr: "unknown field"},
+		{name: "wrong kind", content: "apiVersion: config.flexdaemonsets.xai/v1alpha1\nkind: Other\n", wantErr: "is not supported"},
+		{name: "missing header", content: "dryRun: true\n", wantErr: "is not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(*ManagerConfiguration)
+		wantErrs []string
+	}{
+		{name: "defaults", mutate: func(*ManagerConfiguration) {}},
+		{name: "metrics disabled", mutate: func(c *ManagerConfiguration) { c.Metrics.BindAddress = "0" }},
+		{name: "bad address", mutate: func(c *ManagerConfiguration) { c.Health.ProbeBindAddress = "localhost" },
+			wantErrs: []string{"health.probeBindAddress"}},
+		{name: "bad port", mutate: func(c *ManagerConfiguration) { c.Metrics.BindAddress = ":99999" },
+			wantErrs: []string{"metrics.bindAddress"}},
+		{name: "unknown failure policy", mutate: func(c *ManagerConfiguration) { c.Webhook.FailurePolicy = "Ignore" },
+			wantErrs: []string{"webhook.failurePolicy"}},
+		{name: "self-managed certificates without the webhook", mutate: func(c *ManagerConfiguration) {
+			c.Certificates.Mode, c.Webhook.Enabled = CertModeSelfManaged, false
+		}, wantErrs: []string{"certificates.mode"}},
+		{name: "rotation longer than validity", mutate: func(c *ManagerConfiguration) {
+			c.Certificates.Mode = CertModeSelfManaged
+			c.Certificates.RotateBefore = metav1.Duration{Duration: 2 * c.Certificates.Validity.Duration}
+		}, wantErrs: []string{"certificates.rotateBefore"}},
+		{name: "renew deadline beyond the lease", mutate: func(c *ManagerConfiguration) {
+			c.LeaderElection.LeaderElect = true
+			c.LeaderElection.RenewDeadline = metav1.Duration{Duration: time.Minute}
+		}, wantErrs: []string{"leaderElection.leaseDuration"}},
+		{name: "leader election timings ignored when off", mutate: func(c *ManagerConfiguration) {
+			c.LeaderElection.RenewDeadline = metav1.Duration{Duration: time.Minute}
+		}},
+		{name: "controller without workers", mutate: func(c *ManagerConfiguration) { c.Controllers.NodeCoverage.MaxConcurrentReconciles = 0 },
+			wantErrs: []string{"controllers.nodeCoverage.maxConcurrentReconciles"}},
+		{name: "backoff max below base", mutate: func(c *ManagerConfiguration) {
+			c.Controllers.FlexDaemonSetNodePod.BackoffMax = metav1.Duration{Duration: time.Millisecond}
+		}, wantErrs: []string{"controllers.flexDaemonSetNodePod.backoffMax"}},
+		{name: "invalid and duplicate namespaces", mutate: func(c *ManagerConfiguration) {
+			c.Namespaces = []string{"Monitoring", "logging", "logging"}
+		}, wantErrs: []string{"namespaces[0]", "namespaces[2]"}},
+		{name: "selector matching everything", mutate: func(c *ManagerConfiguration) { c.NamespaceSelector = &metav1.LabelSelector{} },
+			wantErrs: []string{"namespaceSelector"}},
+		{name: "negative template minimum", mutate: func(c *ManagerConfiguration) { c.Templates.MinCPU = "-1" },
+			wantErrs: []string{"templates.minCPU"}},
+		{name: "unknown feature gate", mutate: func(c *ManagerConfiguration) { c.FeatureGates = map[string]bool{"Teleport": true} },
+			wantErrs: []string{"featureGates[Teleport]"}},
+		{name: "every problem at once", mutate: func(c *ManagerConfiguration) {
+			c.Webhook.Port = 0
+			c.Templates.MinMemory = "lots"
+		}, wantErrs: []string{"webhook.port", "templates.minMemory"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.mutate(cfg)
+			err := cfg.Validate()
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want none", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() returned no error, want %v", tt.wantErrs)
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %v, want it to name %s", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOverride(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		check func(*testing.T, *ManagerConfiguration)
+	}{
+		{
+			name: "no flags keeps the file",
+			check: func(t *testing.T, cfg *ManagerConfiguration) {
+				if !cfg.DryRun || cfg.Metrics.BindAddress != ":9090" || len(cfg.Namespaces) != 1 {
+					t.Errorf("file settings lost: dryRun %v, metrics %q, namespaces %v", cfg.DryRun, cfg.Metrics.BindAddress, cfg.Namespaces)
+				}
+			},
+		},
+		{
+			name: "explicit flags win",
+			args: []string{"--dry-run=false", "--metrics-bind-address=:7070", "--namespaces=a, b"},
+			check: func(t *testing.T, cfg *ManagerConfiguration) {
+				if cfg.DryRun || cfg.Metrics.BindAddress != ":7070" || strings.Join(cfg.Namespaces, ",") != "a,b" {
+					t.Errorf("flags not applied: dryRun %v, metrics %q, namespaces %v", cfg.DryRun, cfg.Metrics.BindAddress, cfg.Namespaces)
+				}
+			},
+		},
+		{
+			name: "feature gates merge into the file's",
+			args: []string{"--feature-gates=CacheTransforms=false"},
+			check: func(t *testing.T, cfg *ManagerConfiguration) {
+				if cfg.Enabled(CacheTransforms) || !cfg.FeatureGates["Other"] {
+					t.Errorf("feature gates = %v", cfg.FeatureGates)
+				}
+			},
+		},
+		{
+			name: "namespace selector",
+			args: []string{"--namespace-selector=team=platform"},
+			check: func(t *testing.T, cfg *ManagerConfiguration) {
+				if cfg.NamespaceSelector == nil || cfg.NamespaceSelector.MatchLabels["team"] != "platform" {
+					t.Errorf("namespace selector = %v", cfg.NamespaceSelector)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Flags are parsed before the file is read, as the manager does.
+			fs := flag.NewFlagSet("manager", flag.ContinueOnError)
+			fs.String("config", "", "")
+			Default().BindFlags(fs)
+			if err := fs.Parse(append([]string{"--config=config.yaml"}, tt.args...)); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := Default()
+			cfg.DryRun = true
+			cfg.Metrics.BindAddress = ":9090"
+			cfg.Namespaces = []string{"monitoring"}
+			cfg.FeatureGates = map[string]bool{"Other": true}
+			if err := cfg.Override(fs); err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestParseFeatureGates(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]bool
+		wantErr bool
+	}{
+		{value: "", want: map[string]bool{}},
+		{value: "A=true, B=False", want: map[string]bool{"A": true, "B": false}},
+		{value: "A", wantErr: true},
+		{value: "A=yes", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got := map[string]bool{}
+			err := ParseFeatureGates(tt.value, got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFeatureGates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("gates = %v, want %v", got, tt.want)
+			}
+			for name, enabled := range tt.want {
+				if got[name] != enabled {
+					t.Errorf("gate %s = %v, want %v", name, got[name], enabled)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FeatureSpec describes a feature gate.
+type FeatureSpec struct {
+	// Default is used when the configuration does not set the gate.
+	Default bool
+	// PreRelease is Alpha (off by default, may change or go away) or Beta (on by default, can still be turned off).
+	PreRelease string
+}
+
+// Feature gates.
+const (
+	// CacheTransforms strips fields no controller reads from cached pods and nodes. Turning it off keeps the full
+	// objects in memory, which helps when debugging a controller against what the cache holds.
+	CacheTransforms = "CacheTransforms"
+)
+
+// KnownFeatureGates lists every feature gate the manager understands. Setting any other name is an error.
+var KnownFeatureGates = map[string]FeatureSpec{
+	CacheTransforms: {Default: true, PreRelease: "Beta"},
+}
+
+// Enabled reports whether the feature gate is on, falling back to its default.
+func (c *ManagerConfiguration) Enabled(gate string) bool {
+	if enabled, ok := c.FeatureGates[gate]; ok {
+		return enabled
+	}
+	return KnownFeatureGates[gate].Default
+}
+
+// ParseFeatureGates parses the --feature-gates flag format, "Name=true,Other=false", into gates.
+func ParseFeatureGates(value string, gates map[string]bool) error {
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, enabled, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("feature gate %q: expected Name=true or Name=false", pair)
+		}
+		switch strings.ToLower(strings.TrimSpace(enabled)) {
+		case "true":
+			gates[strings.TrimSpace(name)] = true
+		case "false":
+			gates[strings.TrimSpace(name)] = false
+		default:
+			return fmt.Errorf("feature gate %q: value must be true or false", pair)
+		}
+	}
+	return nil
+}
+
+// knownFeatureGateNames returns the known gates in a stable order for error messages.
+func knownFeatureGateNames() []string {
+	names := make([]string, 0, len(KnownFeatureGates))
+	for name := range KnownFeatureGates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"flag"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BindFlags registers the manager's command-line flags on fs, bound to the fields of c they override. The flags'
+// defaults are c's current values.
+func (c *ManagerConfiguration) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Metrics.BindAddress, "metrics-bind-address", c.Metrics.BindAddress, "The address the metric endpoint binds to.")
+	fs.StringVar(&c.Health.ProbeBindAddress, "health-probe-bind-address", c.Health.ProbeBindAddress, "The address the probe endpoint binds to.")
+	fs.BoolVar(&c.LeaderElection.LeaderElect, "leader-elect", c.LeaderElection.LeaderElect,
+		"Enable leader election for controller manager. "+
+			"Enabling this will ensure there is only one active controller manager.")
+	fs.StringVar(&c.Webhook.CertDir, "cert-dir", c.Webhook.CertDir,
+		"Directory where the TLS certs (tls.crt, tls.key) are located. Only used with --cert-mode=manual.")
+	fs.StringVar(&c.Webhook.FailurePolicy, "webhook-failure-policy", c.Webhook.FailurePolicy,
+		"What the webhook does with a pod it cannot mark for sizing, unless its template sets spec.failurePolicy: "+
+			"Allow (admit unchanged), Fallback (apply the template's spec.fallbackResources) or Deny (reject the pod).")
+	fs.DurationVar(&c.Webhook.LookupTimeout.Duration, "webhook-lookup-timeout", c.Webhook.LookupTimeout.Duration,
+		"Upper bound on the reads the webhook makes for one pod. Keep it below the webhook configuration's timeoutSeconds.")
+
+	// NodeName keeps the historical behaviour of binding FDNP pods directly; NodeAffinity routes them through the scheduler.
+	fdnp := &c.Controllers.FlexDaemonSetNodePod
+	fs.StringVar(&fdnp.SchedulingMode, "fdnp-scheduling-mode", fdnp.SchedulingMode,
+		"How pods created for FlexDaemonSetNodePods are placed on their node: NodeName (bind spec.nodeName directly) "+
+			"or NodeAffinity (required node affinity on metadata.name, scheduled by the default scheduler).")
+	fs.IntVar(&fdnp.MaxPodRecreations, "fdnp-max-pod-recreations", fdnp.MaxPodRecreations,
+		"Consecutive managed pod failures after which a FlexDaemonSetNodePod moves to Failed and stops recreating its pod. 0 disables the limit.")
+	fs.DurationVar(&fdnp.BackoffBase.Duration, "fdnp-backoff-base", fdnp.BackoffBase.Duration,
+		"Initial delay before recreating a failed FlexDaemonSetNodePod pod. Doubles with every consecutive failure.")
+	fs.DurationVar(&fdnp.BackoffMax.Duration, "fdnp-backoff-max", fdnp.BackoffMax.Duration,
+		"Maximum delay before recreating a failed FlexDaemonSetNodePod pod.")
+	fs.DurationVar(&fdnp.BackoffResetAfter.Duration, "fdnp-backoff-reset-after", fdnp.BackoffResetAfter.Duration,
+		"How long a recreated FlexDaemonSetNodePod pod has to stay Ready before its failure count is reset.")
+
+	fs.BoolVar(&c.DryRun, "dry-run", c.DryRun,
+		"Record every sizing decision on pods (annotation and admission warning) and in events without applying it. "+
+			"Templates can also opt in individually with spec.dryRun.")
+	fs.StringVar(&c.AuditLog, "audit-log", c.AuditLog,
+		"Append every pod sizing decision as a JSON line to this file. Use \"-\" for stdout. Disabled when empty.")
+
+	certificates := &c.Certificates
+	fs.StringVar(&certificates.Mode, "cert-mode", certificates.Mode,
+		"How the webhook serving certificate is provided: manual (tls.crt and tls.key in --cert-dir) or self-managed "+
+			"(generated, stored in --cert-secret, injected as caBundle and rotated by the manager).")
+	fs.StringVar(&certificates.SecretName, "cert-secret", certificates.SecretName,
+		"Secret holding the self-managed CA and serving certificate. Only used with --cert-mode=self-managed.")
+	fs.StringVar(&certificates.Namespace, "cert-namespace", certificates.Namespace,
+		"Namespace of --cert-secret and --webhook-service. Defaults to the namespace the manager runs in.")
+	fs.StringVar(&certificates.ServiceName, "webhook-service", certificates.ServiceName,
+		"Service the API server calls the webhook through; the self-managed serving certificate covers its DNS names.")
+	fs.Var((*listValue)(&certificates.MutatingWebhookConfigurations), "mutating-webhook-configurations",
+		"Comma-separated MutatingWebhookConfigurations whose caBundle is kept in sync with the self-managed CA.")
+	fs.Var((*listValue)(&certificates.ValidatingWebhookConfigurations), "validating-webhook-configurations",
+		"Comma-separated ValidatingWebhookConfigurations whose caBundle is kept in sync with the self-managed CA.")
+	fs.Var((*listValue)(&certificates.ConversionCRDs), "conversion-crds",
+		"Comma-separated CustomResourceDefinitions whose conversion webhook caBundle is kept in sync with the self-managed CA.")
+	fs.DurationVar(&certificates.Validity.Duration, "cert-validity", certificates.Validity.Duration,
+		"How long a self-managed serving certificate is valid.")
+	fs.DurationVar(&certificates.RotateBefore.Duration, "cert-rotate-before", certificates.RotateBefore.Duration,
+		"How long before expiry the self-managed serving certificate and CA are replaced.")
+
+	fs.Var((*listValue)(&c.Namespaces), "namespaces",
+		"Comma-separated namespaces the manager watches and sizes DaemonSets in. Empty watches every namespace.")
+	fs.Var(&labelSelectorValue{selector: &c.NamespaceSelector}, "namespace-selector",
+		"Label selector (e.g. team=platform) of further namespaces to watch, resolved at startup.")
+	fs.Var(&featureGatesValue{gates: &c.FeatureGates}, "feature-gates",
+		"Comma-separated Name=true|false pairs turning feature gates on or off. Known gates: "+strings.Join(knownFeatureGateNames(), ", ")+".")
+}
+
+// Override sets on c every flag given explicitly on parsed, a flag set bound to another configuration with
+// BindFlags. Flags parsed only by parsed, like --config itself, are ignored.
+func (c *ManagerConfiguration) Override(parsed *flag.FlagSet) error {
+	fs := flag.NewFlagSet("overrides", flag.ContinueOnError)
+	c.BindFlags(fs)
+	var err error
+	parsed.Visit(func(f *flag.Flag) {
+		if err == nil && fs.Lookup(f.Name) != nil {
+			err = fs.Set(f.Name, f.Value.String())
+		}
+	})
+	return err
+}
+
+// listValue is a comma-separated flag. Setting it replaces the list.
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// labelSelectorValue is a label selector flag in kubectl's -l syntax.
+type labelSelectorValue struct {
+	selector **metav1.LabelSelector
+}
+
+func (l *labelSelectorValue) String() string {
+	if l.selector == nil || *l.selector == nil {
+		return ""
+	}
+	return metav1.FormatLabelSelector(*l.selector)
+}
+
+func (l *labelSelectorValue) Set(value string) error {
+	if value == "" {
+		*l.selector = nil
+		return nil
+	}
+	selector, err := metav1.ParseToLabelSelector(value)
+	if err != nil {
+		return err
+	}
+	*l.selector = selector
+	return nil
+}
+
+// featureGatesValue is the --feature-gates flag. Setting it merges into the gates already configured.
+type featureGatesValue struct {
+	gates *map[string]bool
+}
+
+func (f *featureGatesValue) String() string {
+	if f.gates == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*f.gates))
+	for name, enabled := range *f.gates {
+		pairs = append(pairs, name+"="+strconv.FormatBool(enabled))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f *featureGatesValue) Set(value string) error {
+	if *f.gates == nil {
+		*f.gates = map[string]bool{}
+	}
+	return ParseFeatureGates(value, *f.gates)
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DefaultNamespaceCheckInterval is how often NamespaceWatcher re-resolves the namespace selector.
+const DefaultNamespaceCheckInterval = time.Minute
+
+// ResolveNamespaces returns Namespaces plus the namespaces matching NamespaceSelector, sorted and without
+// duplicates. It returns nil when the manager is not restricted.
+func (c *ManagerConfiguration) ResolveNamespaces(ctx context.Context, reader client.Reader) ([]string, error) {
+	namespaces := append([]string{}, c.Namespaces...)
+	if c.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(c.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+		var list corev1.NamespaceList
+		if err := reader.List(ctx, &list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, fmt.Errorf("listing namespaces matching %s: %w", selector, err)
+		}
+		for _, namespace := range list.Items {
+			namespaces = append(namespaces, namespace.Name)
+		}
+	}
+	slices.Sort(namespaces)
+	return slices.Compact(namespaces), nil
+}
+
+// namespacedAccess are the permissions the manager's cache needs in every watched namespace.
+var namespacedAccess = []authorizationv1.ResourceAttributes{
+	{Resource: "pods", Verb: "list"},
+	{Resource: "pods", Verb: "watch"},
+	{Group: "apps", Resource: "daemonsets", Verb: "list"},
+	{Group: "apps", Resource: "daemonsets", Verb: "watch"},
+	{Group: "flexdaemonsets.xai", Resource: "flexdaemonsetnodepods", Verb: "list"},
+	{Group: "flexdaemonsets.xai", Resource: "flexdaemonsetnodepods", Verb: "watch"},
+}
+
+// AccessibleNamespaces splits namespaces into those the manager may list and watch its namespaced resources in and
+// those it may not, asking the API server with SelfSubjectAccessReviews. A namespace the manager has no RBAC for
+// must not be cached: its informers would never sync and the manager would never start.
+func AccessibleNamespaces(ctx context.Context, c client.Client, namespaces []string) (accessible, denied []string, err error) {
+	for _, namespace := range namespaces {
+		allowed, err := namespaceAccessible(ctx, c, namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		if allowed {
+			accessible = append(accessible, namespace)
+		} else {
+			denied = append(denied, namespace)
+		}
+	}
+	return accessible, denied, nil
+}
+
+func namespaceAccessible(ctx context.Context, c client.Client, namespace string) (bool, error) {
+	for _, attributes := range namespacedAccess {
+		attributes.Namespace = namespace
+		review := &authorizationv1.SelfSubjectAccessReview{Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes}}
+		if err := c.Create(ctx, review); err != nil {
+			return false, fmt.Errorf("checking access to %s in namespace %s: %w", attributes.Resource, namespace, err)
+		}
+		if !review.Status.Allowed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// NamespaceWatcher stops the manager when the namespaces it can watch change: when the namespaces matching the
+// selector change, or when a namespace skipped for lack of RBAC becomes accessible. The cache and the webhook are set
+// up for the namespaces resolved at startup, so the restarted manager is what picks up the new set. A namespace
+// without RBAC is reported and skipped rather than restarting into a manager whose cache cannot sync.
+type NamespaceWatcher struct {
+	// Reader lists namespaces directly from the API server.
+	Reader client.Reader
+	// Client creates the SelfSubjectAccessReviews checking access to each namespace.
+	Client   client.Client
+	Config   *ManagerConfiguration
+	Resolved []string
+	// Denied are the namespaces skipped at startup for lack of RBAC.
+	Denied []string
+	// Interval defaults to DefaultNamespaceCheckInterval.
+	Interval time.Duration
+}
+
+// NeedLeaderElection makes the manager run the watcher on every replica; each has its own cache.
+func (w *NamespaceWatcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Start re-resolves the namespaces every Interval and returns an error, stopping the manager, once the accessible
+// ones differ from Resolved. Newly denied namespaces are logged. Failures to list namespaces or review access are
+// logged and retried.
+func (w *NamespaceWatcher) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("namespace-watcher")
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultNamespaceCheckInterval
+	}
+	reported := w.Denied
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		namespaces, err := w.Config.ResolveNamespaces(ctx, w.Reader)
+		if err != nil {
+			logger.Error(err, "Failed to resolve watched namespaces, retrying", "retryIn", interval)
+			continue
+		}
+		accessible, denied, err := AccessibleNamespaces(ctx, w.Client, namespaces)
+		if err != nil {
+			logger.Error(err, "Failed to check access to watched namespaces, retrying", "retryIn", interval)
+			continue
+		}
+		if !slices.Equal(denied, reported) {
+			if len(denied) > 0 {
+				logger.Error(nil, "Skipping namespaces the manager has no RBAC for; grant it access there to have them watched", "namespaces", denied)
+			}
+			reported = denied
+		}
+		if !slices.Equal(accessible, w.Resolved) {
+			return fmt.Errorf("watched namespaces changed from %v to %v; restarting to watch the new set", w.Resolved, accessible)
+		}
+	}
+}
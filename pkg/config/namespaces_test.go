@@ -0,0 +1,116 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// rbac answers SelfSubjectAccessReviews: a namespace is accessible if it maps to true, and the review fails if it
+// maps to an error.
+type rbac struct {
+	mu      sync.Mutex
+	allowed map[string]bool
+	err     error
+}
+
+func (r *rbac) set(namespace string, allowed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.allowed[namespace] = allowed
+}
+
+func (r *rbac) funcs() interceptor.Funcs {
+	return interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			review, ok := obj.(*authorizationv1.SelfSubjectAccessReview)
+			if !ok {
+				return c.Create(ctx, obj, opts...)
+			}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.err != nil {
+				return r.err
+			}
+			review.Status.Allowed = r.allowed[review.Spec.ResourceAttributes.Namespace]
+			return nil
+		},
+	}
+}
+
+func testNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestAccessibleNamespaces(t *testing.T) {
+	tests := []struct {
+		name           string
+		allowed        map[string]bool
+		err            error
+		wantAccessible []string
+		wantDenied     []string
+		wantErr        bool
+	}{
+		{name: "all accessible", allowed: map[string]bool{"team-a": true, "team-b": true}, wantAccessible: []string{"team-a", "team-b"}},
+		{name: "one without RBAC", allowed: map[string]bool{"team-a": true}, wantAccessible: []string{"team-a"}, wantDenied: []string{"team-b"}},
+		{name: "none accessible", wantDenied: []string{"team-a", "team-b"}},
+		{name: "review fails", err: fmt.Errorf("connection refused"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access := &rbac{allowed: tt.allowed, err: tt.err}
+			c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithInterceptorFuncs(access.funcs()).Build()
+			accessible, denied, err := AccessibleNamespaces(context.Background(), c, []string{"team-a", "team-b"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if fmt.Sprint(accessible) != fmt.Sprint(tt.wantAccessible) || fmt.Sprint(denied) != fmt.Sprint(tt.wantDenied) {
+				t.Errorf("accessible %v, denied %v; want %v, %v", accessible, denied, tt.wantAccessible, tt.wantDenied)
+			}
+		})
+	}
+}
+
+// A namespace matching the selector without RBAC is skipped rather than restarting the manager; it triggers a
+// restart once it becomes accessible.
+func TestNamespaceWatcherSkipsNamespacesWithoutAccess(t *testing.T) {
+	selected := map[string]string{"flexdaemonsets": "enabled"}
+	access := &rbac{allowed: map[string]bool{"team-a": true}}
+	c := fake.NewClientBuilder().
+		WithScheme(clientgoscheme.Scheme).
+		WithObjects(testNamespace("team-a", selected), testNamespace("team-b", selected)).
+		WithInterceptorFuncs(access.funcs()).
+		Build()
+	w := &NamespaceWatcher{
+		Reader:   c,
+		Client:   c,
+		Config:   &ManagerConfiguration{NamespaceSelector: &metav1.LabelSelector{MatchLabels: selected}},
+		Resolved: []string{"team-a"},
+		Interval: time.Millisecond,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := w.Start(ctx); err != nil {
+		t.Fatalf("watcher stopped the manager for a namespace without RBAC: %v", err)
+	}
+
+	access.set("team-b", true)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := w.Start(ctx)
+	if err == nil || !strings.Contains(err.Error(), "[team-a team-b]") {
+		t.Errorf("err = %v, want a restart to watch team-a and team-b", err)
+	}
+}
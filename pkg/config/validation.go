@@ -0,0 +1,215 @@
+package config
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	flexcontroller "github.com/prakarsh-dt/FlexDaemonsets/pkg/controller"
+)
+
+// leaderElectionJitter mirrors client-go's leader election JitterFactor: RenewDeadline must exceed RetryPeriod
+// times this factor.
+const leaderElectionJitter = 1.2
+
+// Validate checks the whole configuration and returns every problem at once, each named by its path in the
+// configuration file.
+func (c *ManagerConfiguration) Validate() error {
+	var errs field.ErrorList
+	errs = append(errs, validateAddress(c.Metrics.BindAddress, field.NewPath("metrics", "bindAddress"))...)
+	errs = append(errs, validateAddress(c.Health.ProbeBindAddress, field.NewPath("health", "probeBindAddress"))...)
+	errs = append(errs, c.validateWebhook(field.NewPath("webhook"))...)
+	errs = append(errs, c.validateCertificates(field.NewPath("certificates"))...)
+	errs = append(errs, c.validateLeaderElection(field.NewPath("leaderElection"))...)
+	errs = append(errs, c.validateControllers(field.NewPath("controllers"))...)
+	errs = append(errs, validateNamespaces(c.Namespaces, field.NewPath("namespaces"))...)
+	if c.NamespaceSelector != nil {
+		if selector, err := metav1.LabelSelectorAsSelector(c.NamespaceSelector); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("namespaceSelector"), metav1.FormatLabelSelector(c.NamespaceSelector), err.Error()))
+		} else if selector.Empty() {
+			errs = append(errs, field.Invalid(field.NewPath("namespaceSelector"), "{}", "matches every namespace; leave it out to watch the whole cluster"))
+		}
+	}
+	errs = append(errs, c.validateTemplates(field.NewPath("templates"))...)
+	gates := make([]string, 0, len(c.FeatureGates))
+	for name := range c.FeatureGates {
+		gates = append(gates, name)
+	}
+	sort.Strings(gates)
+	for _, name := range gates {
+		if _, ok := KnownFeatureGates[name]; !ok {
+			errs = append(errs, field.NotSupported(field.NewPath("featureGates").Key(name), name, knownFeatureGateNames()))
+		}
+	}
+	return errs.ToAggregate()
+}
+
+// validateAddress accepts "0" (disabled) or a host:port with a valid port.
+func validateAddress(address string, path *field.Path) field.ErrorList {
+	if address == "0" {
+		return nil
+	}
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, address, "must be host:port, :port, or \"0\" to disable")}
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return field.ErrorList{field.Invalid(path, address, "port must be a number between 0 and 65535")}
+	}
+	return nil
+}
+
+func (c *ManagerConfiguration) validateWebhook(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if c.Webhook.Port < 1 || c.Webhook.Port > 65535 {
+		errs = append(errs, field.Invalid(path.Child("port"), c.Webhook.Port, "must be between 1 and 65535"))
+	}
+	if c.Webhook.Enabled && c.Certificates.Mode == CertModeManual && c.Webhook.CertDir == "" {
+		errs = append(errs, field.Required(path.Child("certDir"), "the manual certificate mode serves tls.crt and tls.key from this directory"))
+	}
+	switch flexdaemonsetsv1alpha1.FailurePolicy(c.Webhook.FailurePolicy) {
+	case flexdaemonsetsv1alpha1.FailurePolicyAllow, flexdaemonsetsv1alpha1.FailurePolicyFallback, flexdaemonsetsv1alpha1.FailurePolicyDeny:
+	default:
+		errs = append(errs, field.NotSupported(path.Child("failurePolicy"), c.Webhook.FailurePolicy, []string{
+			string(flexdaemonsetsv1alpha1.FailurePolicyAllow), string(flexdaemonsetsv1alpha1.FailurePolicyFallback), string(flexdaemonsetsv1alpha1.FailurePolicyDeny)}))
+	}
+	if c.Webhook.LookupTimeout.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("lookupTimeout"), c.Webhook.LookupTimeout.Duration.String(), "must be positive"))
+	}
+	return errs
+}
+
+func (c *ManagerConfiguration) validateCertificates(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	certificates := c.Certificates
+	switch certificates.Mode {
+	case CertModeManual:
+		return nil
+	case CertModeSelfManaged:
+	default:
+		return field.ErrorList{field.NotSupported(path.Child("mode"), certificates.Mode, []string{CertModeManual, CertModeSelfManaged})}
+	}
+	if !c.Webhook.Enabled {
+		errs = append(errs, field.Invalid(path.Child("mode"), certificates.Mode, "self-managed certificates need webhook.enabled"))
+	}
+	if certificates.SecretName == "" {
+		errs = append(errs, field.Required(path.Child("secretName"), ""))
+	}
+	if certificates.ServiceName == "" {
+		errs = append(errs, field.Required(path.Child("serviceName"), ""))
+	}
+	if certificates.Validity.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("validity"), certificates.Validity.Duration.String(), "must be positive"))
+	}
+	if certificates.RotateBefore.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("rotateBefore"), certificates.RotateBefore.Duration.String(), "must be positive"))
+	} else if certificates.RotateBefore.Duration >= certificates.Validity.Duration {
+		errs = append(errs, field.Invalid(path.Child("rotateBefore"), certificates.RotateBefore.Duration.String(),
+			"must be shorter than validity, or the certificate is replaced on every check"))
+	}
+	return errs
+}
+
+func (c *ManagerConfiguration) validateLeaderElection(path *field.Path) field.ErrorList {
+	election := c.LeaderElection
+	if !election.LeaderElect {
+		return nil
+	}
+	var errs field.ErrorList
+	if election.ResourceName == "" {
+		errs = append(errs, field.Required(path.Child("resourceName"), ""))
+	}
+	if election.RetryPeriod.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("retryPeriod"), election.RetryPeriod.Duration.String(), "must be positive"))
+	}
+	if election.RenewDeadline.Duration <= time.Duration(leaderElectionJitter*float64(election.RetryPeriod.Duration)) {
+		errs = append(errs, field.Invalid(path.Child("renewDeadline"), election.RenewDeadline.Duration.String(),
+			"must be longer than 1.2 times retryPeriod"))
+	}
+	if election.LeaseDuration.Duration <= election.RenewDeadline.Duration {
+		errs = append(errs, field.Invalid(path.Child("leaseDuration"), election.LeaseDuration.Duration.String(),
+			"must be longer than renewDeadline"))
+	}
+	return errs
+}
+
+func (c *ManagerConfiguration) validateControllers(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, controller := range []struct {
+		name string
+		ControllerConfiguration
+	}{
+		{"pod", c.Controllers.Pod},
+		{"nodeCoverage", c.Controllers.NodeCoverage},
+		{"flexDaemonSetNodePod", c.Controllers.FlexDaemonSetNodePod.ControllerConfiguration},
+		{"flexNodeOverride", c.Controllers.FlexNodeOverride},
+	} {
+		if controller.MaxConcurrentReconciles < 1 {
+			errs = append(errs, field.Invalid(path.Child(controller.name, "maxConcurrentReconciles"), controller.MaxConcurrentReconciles, "must be at least 1"))
+		}
+	}
+
+	fdnp := c.Controllers.FlexDaemonSetNodePod
+	fdnpPath := path.Child("flexDaemonSetNodePod")
+	switch flexcontroller.PodSchedulingMode(fdnp.SchedulingMode) {
+	case flexcontroller.SchedulingModeNodeName, flexcontroller.SchedulingModeNodeAffinity:
+	default:
+		errs = append(errs, field.NotSupported(fdnpPath.Child("schedulingMode"), fdnp.SchedulingMode,
+			[]string{string(flexcontroller.SchedulingModeNodeName), string(flexcontroller.SchedulingModeNodeAffinity)}))
+	}
+	if fdnp.MaxPodRecreations < 0 {
+		errs = append(errs, field.Invalid(fdnpPath.Child("maxPodRecreations"), fdnp.MaxPodRecreations, "must not be negative; 0 disables the limit"))
+	}
+	if fdnp.BackoffBase.Duration <= 0 {
+		errs = append(errs, field.Invalid(fdnpPath.Child("backoffBase"), fdnp.BackoffBase.Duration.String(), "must be positive"))
+	}
+	if fdnp.BackoffMax.Duration < fdnp.BackoffBase.Duration {
+		errs = append(errs, field.Invalid(fdnpPath.Child("backoffMax"), fdnp.BackoffMax.Duration.String(), "must not be shorter than backoffBase"))
+	}
+	if fdnp.BackoffResetAfter.Duration <= 0 {
+		errs = append(errs, field.Invalid(fdnpPath.Child("backoffResetAfter"), fdnp.BackoffResetAfter.Duration.String(), "must be positive"))
+	}
+	return errs
+}
+
+func validateNamespaces(namespaces []string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	seen := map[string]bool{}
+	for i, namespace := range namespaces {
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			errs = append(errs, field.Invalid(path.Index(i), namespace, msg))
+		}
+		if seen[namespace] {
+			errs = append(errs, field.Duplicate(path.Index(i), namespace))
+		}
+		seen[namespace] = true
+	}
+	return errs
+}
+
+func (c *ManagerConfiguration) validateTemplates(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, minimum := range []struct{ name, value string }{
+		{"minCPU", c.Templates.MinCPU},
+		{"minMemory", c.Templates.MinMemory},
+		{"minStorage", c.Templates.MinStorage},
+	} {
+		if minimum.value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(minimum.value)
+		if err != nil {
+			errs = append(errs, field.Invalid(path.Child(minimum.name), minimum.value, err.Error()))
+		} else if quantity.Sign() < 0 {
+			errs = append(errs, field.Invalid(path.Child(minimum.name), minimum.value, "must not be negative"))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+const ReasonInvalidAllocatableAdjustment = "InvalidAllocatableAdjustment"
+
+// effectiveAllocatable returns the node's allocatable after the flex-base and reserved adjustments. Malformed
+// adjustments are skipped and reported as a warning event on the node, so the operator can fix the annotation
+// or label while sizing carries on with the valid entries.
+func effectiveAllocatable(ctx context.Context, recorder record.EventRecorder, node *corev1.Node) corev1.ResourceList {
+	allocatable, errs := utils.EffectiveAllocatable(node)
+	if len(errs) > 0 {
+		err := utilerrors.NewAggregate(errs)
+		log.FromContext(ctx).Error(err, "Ignoring malformed allocatable adjustments on node", "nodeName", node.Name)
+		if recorder != nil {
+			recorder.Eventf(node, corev1.EventTypeWarning, ReasonInvalidAllocatableAdjustment, "Ignoring malformed allocatable adjustments: %v", err)
+		}
+	}
+	return allocatable
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// lastAppliedConfigAnnotation is written by kubectl apply and holds a full copy of the object.
+const lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// Compile-time checks that the transforms can be used as informer transforms.
+var (
+	_ toolscache.TransformFunc = TransformPodForCache
+	_ toolscache.TransformFunc = TransformNodeForCache
+)
+
+// TransformPodForCache trims pods before they are stored in the manager's cache.
+//
+// Every pod in the cluster is cached, but only a few are ever read in full: pods carrying the apply-template
+// annotation, pods controlled by a DaemonSet and pods managed by a FlexDaemonSetNodePod. Those keep their spec
+// and status, because the pod controller builds merge patches from the containers and the FDNP controller mirrors
+// their status. All other pods are reduced to what the controllers select on: metadata, node name and phase.
+// Managed fields and kubectl's last-applied configuration are dropped from every pod; they are never read, and
+// merge patches only include fields that differ between the original and modified copy, so they are not lost.
+//
+// The transform runs on every informer event with the object fresh from the API server, so a pod that becomes
+// relevant later (for example by being annotated) is cached in full from that event on.
+func TransformPodForCache(obj interface{}) (interface{}, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return obj, nil
+	}
+	stripCommonMetadata(&pod.ObjectMeta)
+
+	if isPodOfInterest(pod) {
+		return pod, nil
+	}
+	pod.Spec = corev1.PodSpec{NodeName: pod.Spec.NodeName}
+	pod.Status = corev1.PodStatus{Phase: pod.Status.Phase}
+	return pod, nil
+}
+
+// TransformNodeForCache trims nodes before they are stored in the manager's cache. The image list and volume
+// attachments are usually the bulk of a Node object and are not used by any controller.
+func TransformNodeForCache(obj interface{}) (interface{}, error) {
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		return obj, nil
+	}
+	stripCommonMetadata(&node.ObjectMeta)
+	node.Status.Images = nil
+	node.Status.VolumesInUse = nil
+	node.Status.VolumesAttached = nil
+	return node, nil
+}
+
+// isPodOfInterest reports whether any controller may read the pod's spec or status.
+func isPodOfInterest(pod *corev1.Pod) bool {
+	if _, ok := pod.Annotations[PodApplyTemplateAnnotation]; ok {
+		return true
+	}
+	if pod.Labels[LabelManagedBy] == FlexDaemonSetNodePodControllerName {
+		return true
+	}
+	owner := metav1.GetControllerOf(pod)
+	return owner != nil && owner.Kind == "DaemonSet"
+}
+
+func stripCommonMetadata(objMeta *metav1.ObjectMeta) {
+	objMeta.ManagedFields = nil
+	delete(objMeta.Annotations, lastAppliedConfigAnnotation)
+}
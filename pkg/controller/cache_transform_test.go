@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// benchmarkPodCount is the size of the synthetic cache, a large cluster's worth of pods.
+const benchmarkPodCount = 50000
+
+// BenchmarkPodCache fills an informer store with benchmarkPodCount synthetic pods, with and without
+// TransformPodForCache, and reports the heap retained by the store as heap-bytes/op alongside the allocations made
+// while filling it. The pods are copied outside the timer before each fill, so neither figure includes the copies.
+// One pod in twenty is a DaemonSet pod and is kept in full.
+func BenchmarkPodCache(b *testing.B) {
+	pods := make([]*corev1.Pod, benchmarkPodCount)
+	for i := range pods {
+		pods[i] = syntheticPod(i)
+	}
+
+	for _, bc := range []struct {
+		name      string
+		transform toolscache.TransformFunc
+	}{
+		{name: "untransformed"},
+		{name: "transformed", transform: TransformPodForCache},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var retained uint64
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				before := heapAlloc()
+				// The informer hands the transform a freshly decoded object; copy so every iteration starts full.
+				copies := make([]*corev1.Pod, len(pods))
+				for j, pod := range pods {
+					copies[j] = pod.DeepCopy()
+				}
+				b.StartTimer()
+
+				store := toolscache.NewIndexer(toolscache.MetaNamespaceKeyFunc, toolscache.Indexers{})
+				for _, pod := range copies {
+					var obj interface{} = pod
+					if bc.transform != nil {
+						var err error
+						if obj, err = bc.transform(obj); err != nil {
+							b.Fatal(err)
+						}
+					}
+					if err := store.Add(obj); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				b.StopTimer()
+				copies = nil
+				if after := heapAlloc(); after > before {
+					retained += after - before
+				}
+				runtime.KeepAlive(store)
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "heap-bytes/op")
+		})
+	}
+}
+
+// heapAlloc returns the live heap after a full collection.
+func heapAlloc() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// syntheticPod returns a pod shaped like a typical Deployment pod: kubectl-applied, with managed fields, a few
+// containers with environment and volumes, and a running status. Every twentieth pod is owned by a DaemonSet.
+func syntheticPod(i int) *corev1.Pod {
+	name := fmt.Sprintf("app-%d", i)
+	isController := true
+	owner := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "app-rs", UID: "rs-uid", Controller: &isController}
+	if i%20 == 0 {
+		owner = metav1.OwnerReference{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "agent", UID: "ds-uid", Controller: &isController}
+	}
+
+	var containers []corev1.Container
+	var statuses []corev1.ContainerStatus
+	for c := 0; c < 3; c++ {
+		containerName := fmt.Sprintf("container-%d", c)
+		var env []corev1.EnvVar
+		for e := 0; e < 10; e++ {
+			env = append(env, corev1.EnvVar{Name: fmt.Sprintf("ENV_%d", e), Value: strings.Repeat("v", 32)})
+		}
+		containers = append(containers, corev1.Container{
+			Name:  containerName,
+			Image: "registry.example.com/team/app:v1.2.3",
+			Args:  []string{"--config=/etc/app/config.yaml", "--verbose"},
+			Env:   env,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("128Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("512Mi")},
+			},
+			VolumeMounts: []corev1.VolumeMount{{Name: "config", MountPath: "/etc/app"}},
+		})
+		statuses = append(statuses, corev1.ContainerStatus{
+			Name:    containerName,
+			Ready:   true,
+			Image:   "registry.example.com/team/app:v1.2.3",
+			ImageID: "registry.example.com/team/app@sha256:" + strings.Repeat("0", 64),
+			State:   corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+		})
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       fmt.Sprintf("team-%d", i%50),
+			UID:             types.UID("uid-" + name),
+			Labels:          map[string]string{"app": "app", "pod-template-hash": "abcdef"},
+			Annotations:     map[string]string{lastAppliedConfigAnnotation: strings.Repeat("x", 2048)},
+			OwnerReferences: []metav1.OwnerReference{owner},
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kube-controller-manager", Operation: metav1.ManagedFieldsOperationUpdate, FieldsType: "FieldsV1",
+					FieldsV1: &metav1.FieldsV1{Raw: []byte(strings.Repeat("f", 1024))}},
+				{Manager: "kubelet", Operation: metav1.ManagedFieldsOperationUpdate, FieldsType: "FieldsV1", Subresource: "status",
+					FieldsV1: &metav1.FieldsV1{Raw: []byte(strings.Repeat("s", 1024))}},
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:   fmt.Sprintf("node-%d", i%1000),
+			Containers: containers,
+			Volumes: []corev1.Volume{{Name: "config", VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"}}}}},
+			Tolerations: []corev1.Toleration{{Key: "node.kubernetes.io/not-ready", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute}},
+		},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			PodIP:             "10.0.0.1",
+			HostIP:            "192.168.0.1",
+			Conditions:        []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			ContainerStatuses: statuses,
+		},
+	}
+}
@@ -0,0 +1,31 @@
+package controller
+
+import "sync"
+
+// dryRunActions remembers the last write each object was planned to get in dry-run mode, so that a decision repeated
+// on every resync is counted and reported once rather than on every pass.
+type dryRunActions struct {
+	mu   sync.Mutex
+	last map[string]string
+}
+
+// changed records action as the one planned for key and reports whether it differs from the previous one.
+func (a *dryRunActions) changed(key, action string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.last == nil {
+		a.last = make(map[string]string)
+	}
+	if a.last[key] == action {
+		return false
+	}
+	a.last[key] = action
+	return true
+}
+
+// forget drops the action planned for key, once nothing is planned for it any more.
+func (a *dryRunActions) forget(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.last, key)
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/metrics"
+)
+
+const (
+	// DefaultPodRecreationBackoffBase is the delay before the first recreation of a failed managed pod.
+	DefaultPodRecreationBackoffBase = 10 * time.Second
+	// DefaultPodRecreationBackoffMax caps the exponential recreation delay.
+	DefaultPodRecreationBackoffMax = 5 * time.Minute
+	// DefaultBackoffResetAfter is how long a recreated pod has to stay Ready before its failure count is reset.
+	DefaultBackoffResetAfter = 2 * time.Minute
+
+	ReasonPodDeleted             = "PodDeleted"
+	ReasonPodEvicted             = "Evicted"
+	ReasonBackOff                = "BackOff"
+	ReasonRecreationLimitReached = "RecreationLimitReached"
+	ReasonBackoffReset           = "BackoffReset"
+	ReasonManagedPodFailed       = "ManagedPodFailed"
+)
+
+// podFailureReason returns a non-empty reason if the managed pod has terminated and will not run again
+// on its own (Failed, Evicted or, with a non-Always restart policy, Succeeded).
+func podFailureReason(pod *corev1.Pod) string {
+	switch pod.Status.Phase {
+	case corev1.PodFailed:
+		if pod.Status.Reason == ReasonPodEvicted {
+			return ReasonPodEvicted
+		}
+		if pod.Status.Reason != "" {
+			return pod.Status.Reason
+		}
+		return ReasonPodFailed
+	case corev1.PodSucceeded:
+		return ReasonPodCompleted
+	}
+	return ""
+}
+
+func (r *FlexDaemonSetNodePodReconciler) backoffBase() time.Duration {
+	if r.BackoffBase > 0 {
+		return r.BackoffBase
+	}
+	return DefaultPodRecreationBackoffBase
+}
+
+func (r *FlexDaemonSetNodePodReconciler) backoffMax() time.Duration {
+	if r.BackoffMax > 0 {
+		return r.BackoffMax
+	}
+	return DefaultPodRecreationBackoffMax
+}
+
+func (r *FlexDaemonSetNodePodReconciler) backoffResetAfter() time.Duration {
+	if r.BackoffResetAfter > 0 {
+		return r.BackoffResetAfter
+	}
+	return DefaultBackoffResetAfter
+}
+
+// backoffDelay returns base * 2^(failures-1), capped at the configured maximum.
+func (r *FlexDaemonSetNodePodReconciler) backoffDelay(failures int32) time.Duration {
+	delay := r.backoffBase()
+	for i := int32(1); i < failures; i++ {
+		delay *= 2
+		if delay >= r.backoffMax() {
+			return r.backoffMax()
+		}
+	}
+	return delay
+}
+
+// recreationLimitReached reports whether the FDNP has used up its pod recreation budget.
+func (r *FlexDaemonSetNodePodReconciler) recreationLimitReached(fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod) bool {
+	return r.MaxPodRecreations > 0 && fdnp.Status.FailureCount > r.MaxPodRecreations
+}
+
+// recordPodFailure counts a managed pod failure, schedules the next recreation attempt and moves the FDNP
+// to Failed once the recreation limit is exceeded.
+func (r *FlexDaemonSetNodePodReconciler) recordPodFailure(fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, now time.Time, reason, message string) {
+	fdnp.Status.FailureCount++
+	fdnp.Status.LastFailureReason = reason
+	fdnp.Status.LastFailureTime = &metav1.Time{Time: now}
+	metrics.FDNPPodFailures.WithLabelValues(fdnp.Spec.DaemonSetNamespace, fdnp.Spec.DaemonSetName, reason).Inc()
+	r.eventf(fdnp, corev1.EventTypeWarning, ReasonManagedPodFailed, "Managed pod failure %d on node %s: %s", fdnp.Status.FailureCount, fdnp.Spec.NodeName, message)
+
+	if r.recreationLimitReached(fdnp) {
+		fdnp.Status.NextRetryTime = nil
+		msg := fmt.Sprintf("Managed pod failed %d times on node %s (limit %d), last failure: %s. Manual attention required; update or delete this FlexDaemonSetNodePod to retry.",
+			fdnp.Status.FailureCount, fdnp.Spec.NodeName, r.MaxPodRecreations, message)
+		markFDNPDegraded(fdnp, PhaseFailed, ReasonRecreationLimitReached, msg)
+		metrics.FDNPRecreationLimitReached.WithLabelValues(fdnp.Spec.DaemonSetNamespace, fdnp.Spec.DaemonSetName).Inc()
+		r.eventf(fdnp, corev1.EventTypeWarning, ReasonRecreationLimitReached, "%s", msg)
+		return
+	}
+
+	delay := r.backoffDelay(fdnp.Status.FailureCount)
+	fdnp.Status.NextRetryTime = &metav1.Time{Time: now.Add(delay)}
+	msg := fmt.Sprintf("Managed pod failed on node %s (%s); recreating in %s", fdnp.Spec.NodeName, message, delay)
+	markFDNPDegraded(fdnp, PhaseBackOff, ReasonBackOff, msg)
+	r.eventf(fdnp, corev1.EventTypeWarning, ReasonBackOff, "%s", msg)
+}
+
+// remainingBackoff returns how long to wait before the managed pod may be recreated.
+func remainingBackoff(fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, now time.Time) time.Duration {
+	if fdnp.Status.NextRetryTime == nil {
+		return 0
+	}
+	if wait := fdnp.Status.NextRetryTime.Time.Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// resetFailureTracking clears the failure count and retry schedule.
+func resetFailureTracking(fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod) {
+	fdnp.Status.FailureCount = 0
+	fdnp.Status.LastFailureReason = ""
+	fdnp.Status.LastFailureTime = nil
+	fdnp.Status.NextRetryTime = nil
+}
+
+// maybeResetBackoff resets failure tracking once the managed pod has been Ready for BackoffResetAfter.
+// It returns how long to wait before checking again, or zero if there is nothing left to wait for.
+func (r *FlexDaemonSetNodePodReconciler) maybeResetBackoff(fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, pod *corev1.Pod, now time.Time) time.Duration {
+	if fdnp.Status.FailureCount == 0 || !isPodReady(pod) {
+		return 0
+	}
+	var readySince time.Time
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			readySince = cond.LastTransitionTime.Time
+		}
+	}
+	if stableFor := now.Sub(readySince); stableFor < r.backoffResetAfter() {
+		return r.backoffResetAfter() - stableFor
+	}
+	r.eventf(fdnp, corev1.EventTypeNormal, ReasonBackoffReset, "Managed pod %s has been ready for %s, resetting failure count of %d", pod.Name, r.backoffResetAfter(), fdnp.Status.FailureCount)
+	resetFailureTracking(fdnp)
+	return 0
+}
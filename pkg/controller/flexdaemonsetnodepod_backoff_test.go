@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestPodFailureReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		status corev1.PodStatus
+		want   string
+	}{
+		{name: "running", status: corev1.PodStatus{Phase: corev1.PodRunning}, want: ""},
+		{name: "pending", status: corev1.PodStatus{Phase: corev1.PodPending}, want: ""},
+		{name: "evicted", status: corev1.PodStatus{Phase: corev1.PodFailed, Reason: ReasonPodEvicted}, want: ReasonPodEvicted},
+		{name: "rejected by kubelet", status: corev1.PodStatus{Phase: corev1.PodFailed, Reason: "OutOfcpu"}, want: "OutOfcpu"},
+		{name: "failed without reason", status: corev1.PodStatus{Phase: corev1.PodFailed}, want: ReasonPodFailed},
+		{name: "completed", status: corev1.PodStatus{Phase: corev1.PodSucceeded}, want: ReasonPodCompleted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := podFailureReason(&corev1.Pod{Status: tt.status}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	r := &FlexDaemonSetNodePodReconciler{BackoffBase: 10 * time.Second, BackoffMax: time.Minute}
+	tests := []struct {
+		failures int32
+		want     time.Duration
+	}{
+		{failures: 1, want: 10 * time.Second},
+		{failures: 2, want: 20 * time.Second},
+		{failures: 3, want: 40 * time.Second},
+		{failures: 4, want: time.Minute},
+		{failures: 20, want: time.Minute},
+	}
+	for _, tt := range tests {
+		if got := r.backoffDelay(tt.failures); got != tt.want {
+			t.Errorf("backoffDelay(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestRecordPodFailure(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		maxRecreate  int32
+		failureCount int32
+		wantPhase    string
+		wantRetry    time.Duration
+	}{
+		{name: "first failure backs off", maxRecreate: 3, failureCount: 0, wantPhase: PhaseBackOff, wantRetry: 10 * time.Second},
+		{name: "third failure doubles twice", maxRecreate: 3, failureCount: 2, wantPhase: PhaseBackOff, wantRetry: 40 * time.Second},
+		{name: "limit exceeded", maxRecreate: 3, failureCount: 3, wantPhase: PhaseFailed},
+		{name: "no limit", maxRecreate: 0, failureCount: 10, wantPhase: PhaseBackOff, wantRetry: DefaultPodRecreationBackoffMax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &FlexDaemonSetNodePodReconciler{MaxPodRecreations: tt.maxRecreate, BackoffBase: 10 * time.Second}
+			fdnp := testFDNPObject()
+			fdnp.Status.FailureCount = tt.failureCount
+			r.recordPodFailure(fdnp, now, ReasonPodEvicted, "evicted")
+
+			if fdnp.Status.FailureCount != tt.failureCount+1 {
+				t.Errorf("FailureCount = %d, want %d", fdnp.Status.FailureCount, tt.failureCount+1)
+			}
+			if fdnp.Status.LastFailureReason != ReasonPodEvicted {
+				t.Errorf("LastFailureReason = %q", fdnp.Status.LastFailureReason)
+			}
+			if fdnp.Status.Phase != tt.wantPhase {
+				t.Errorf("Phase = %q, want %q", fdnp.Status.Phase, tt.wantPhase)
+			}
+			if tt.wantRetry == 0 {
+				if fdnp.Status.NextRetryTime != nil {
+					t.Errorf("NextRetryTime = %v, want none", fdnp.Status.NextRetryTime)
+				}
+				return
+			}
+			if got := remainingBackoff(fdnp, now); got != tt.wantRetry {
+				t.Errorf("remaining backoff = %s, want %s", got, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestMaybeResetBackoff(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := &FlexDaemonSetNodePodReconciler{BackoffResetAfter: 2 * time.Minute}
+	readyPod := func(since time.Duration) *corev1.Pod {
+		return &corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{
+			Type: corev1.PodReady, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(now.Add(-since)),
+		}}}}
+	}
+
+	fdnp := testFDNPObject()
+	fdnp.Status.FailureCount = 2
+	if wait := r.maybeResetBackoff(fdnp, readyPod(30*time.Second), now); wait != 90*time.Second || fdnp.Status.FailureCount != 2 {
+		t.Errorf("ready for 30s: wait %s, FailureCount %d; want 1m30s and 2", wait, fdnp.Status.FailureCount)
+	}
+	if wait := r.maybeResetBackoff(fdnp, readyPod(3*time.Minute), now); wait != 0 || fdnp.Status.FailureCount != 0 {
+		t.Errorf("ready for 3m: wait %s, FailureCount %d; want 0 and 0", wait, fdnp.Status.FailureCount)
+	}
+}
+
+// A failed pod is deleted and counted once. While it terminates, further passes must neither count it again nor
+// create a replacement.
+func TestReconcileCountsTerminatingFailedPodOnce(t *testing.T) {
+	ctx := context.Background()
+	fdnp := testFDNPObject()
+	pod := testManagedPod(fdnp, "failed-pod", true)
+	// The finalizer keeps the pod around, terminating, after the controller deletes it.
+	pod.Finalizers = []string{"test/hold"}
+	pod.Status.Phase = corev1.PodFailed
+	pod.Status.Reason = ReasonPodEvicted
+	c := newTestClient(t, testDaemonSetObject(), fdnp, pod)
+	r := &FlexDaemonSetNodePodReconciler{Client: c, Scheme: c.Scheme(), BackoffBase: time.Minute}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(fdnp)}
+
+	for pass := 1; pass <= 3; pass++ {
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatalf("pass %d: %v", pass, err)
+		}
+		got := getFDNP(t, c, fdnp)
+		if got.Status.FailureCount != 1 {
+			t.Fatalf("pass %d: FailureCount = %d, want 1", pass, got.Status.FailureCount)
+		}
+		var pods corev1.PodList
+		if err := c.List(ctx, &pods, client.InNamespace(testNamespace)); err != nil {
+			t.Fatal(err)
+		}
+		if len(pods.Items) != 1 || pods.Items[0].DeletionTimestamp.IsZero() {
+			t.Fatalf("pass %d: want only the terminating pod, got %d pods", pass, len(pods.Items))
+		}
+	}
+
+	// Once the pod is gone the FDNP waits out its backoff without counting the pod a second time.
+	terminating := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pod), terminating); err != nil {
+		t.Fatal(err)
+	}
+	terminating.Finalizers = nil
+	if err := c.Update(ctx, terminating); err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := getFDNP(t, c, fdnp); got.Status.FailureCount != 1 || result.RequeueAfter <= 0 {
+		t.Errorf("after pod removal: FailureCount = %d, requeue %s; want 1 and a backoff", got.Status.FailureCount, result.RequeueAfter)
+	}
+}
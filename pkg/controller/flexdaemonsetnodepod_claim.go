@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+)
+
+// Event reasons emitted while claiming pods for a FlexDaemonSetNodePod.
+const (
+	ReasonPodAdopted        = "PodAdopted"
+	ReasonPodReleased       = "PodReleased"
+	ReasonPodOwnedByOther   = "PodOwnedByOther"
+	ReasonDuplicatePod      = "DuplicatePodDeleted"
+	ReasonAdoptionFailed    = "PodAdoptionFailed"
+	ReasonReleaseFailed     = "PodReleaseFailed"
+	ReasonAdoptionForbidden = "PodAdoptionForbidden"
+)
+
+// podClaimResult is the outcome of claiming pods for a FlexDaemonSetNodePod.
+type podClaimResult struct {
+	// Claimed are the pods controlled by the FDNP after adoption and release, oldest first.
+	Claimed []*corev1.Pod
+	// OwnedByOthers are pods that match the FDNP but are controlled by a different owner.
+	OwnedByOthers []*corev1.Pod
+}
+
+// podMatchesFDNP reports whether the pod carries the labels and placement of a pod managed by the FDNP.
+func podMatchesFDNP(pod *corev1.Pod, fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod) bool {
+	if pod.Namespace != fdnp.Namespace {
+		return false
+	}
+	if pod.Labels[LabelManagedBy] != FlexDaemonSetNodePodControllerName || pod.Labels[LabelOwnerCR] != fdnp.Name {
+		return false
+	}
+	// An unscheduled pod (NodeAffinity mode) still matches; a pod bound to a different node does not.
+	return pod.Spec.NodeName == "" || pod.Spec.NodeName == fdnp.Spec.NodeName
+}
+
+// claimPods adopts and releases pods for a FlexDaemonSetNodePod. It follows the semantics of the
+// ControllerRefManager used by the upstream workload controllers:
+//   - a matching pod without a controller is adopted (unless the FDNP is being deleted),
+//   - a pod controlled by the FDNP that no longer matches is released by removing the owner reference,
+//   - a pod controlled by another owner is never touched, only reported in OwnedByOthers.
+func (r *FlexDaemonSetNodePodReconciler) claimPods(ctx context.Context, fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, pods []corev1.Pod) (*podClaimResult, error) {
+	logger := log.FromContext(ctx)
+	result := &podClaimResult{}
+	fdnpDeleting := !fdnp.DeletionTimestamp.IsZero()
+	adoptionChecked := false
+
+	for i := range pods {
+		pod := &pods[i]
+		matches := podMatchesFDNP(pod, fdnp)
+		controllerRef := metav1.GetControllerOf(pod)
+
+		switch {
+		case controllerRef != nil && controllerRef.UID == fdnp.UID:
+			if matches || fdnpDeleting {
+				result.Claimed = append(result.Claimed, pod)
+				continue
+			}
+			if err := r.releasePod(ctx, fdnp, pod); err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				r.eventf(fdnp, corev1.EventTypeWarning, ReasonReleaseFailed, "Failed to release pod %s: %v", pod.Name, err)
+				return nil, err
+			}
+			logger.Info("Released pod that no longer matches FlexDaemonSetNodePod", "podName", pod.Name, "podNode", pod.Spec.NodeName)
+			r.eventf(fdnp, corev1.EventTypeNormal, ReasonPodReleased, "Released pod %s: it no longer matches node %s or the managed-pod labels", pod.Name, fdnp.Spec.NodeName)
+
+		case controllerRef != nil:
+			if matches {
+				result.OwnedByOthers = append(result.OwnedByOthers, pod)
+			}
+
+		default:
+			if !matches || fdnpDeleting || !pod.DeletionTimestamp.IsZero() {
+				continue
+			}
+			if !adoptionChecked {
+				// Like the upstream RecheckDeletionTimestamp, make sure the FDNP read from the cache is still the live
+				// object before adopting, so pods are never adopted by an FDNP that is gone or being deleted.
+				if err := r.canAdopt(ctx, fdnp); err != nil {
+					r.eventf(fdnp, corev1.EventTypeWarning, ReasonAdoptionForbidden, "Cannot adopt pods: %v", err)
+					return nil, err
+				}
+				adoptionChecked = true
+			}
+			if err := r.adoptPod(ctx, fdnp, pod); err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				r.eventf(fdnp, corev1.EventTypeWarning, ReasonAdoptionFailed, "Failed to adopt orphaned pod %s: %v", pod.Name, err)
+				return nil, err
+			}
+			logger.Info("Adopted orphaned pod", "podName", pod.Name)
+			r.eventf(fdnp, corev1.EventTypeNormal, ReasonPodAdopted, "Adopted orphaned pod %s on node %s", pod.Name, fdnp.Spec.NodeName)
+			result.Claimed = append(result.Claimed, pod)
+		}
+	}
+
+	sort.SliceStable(result.Claimed, func(i, j int) bool {
+		return result.Claimed[i].CreationTimestamp.Before(&result.Claimed[j].CreationTimestamp)
+	})
+	return result, nil
+}
+
+// canAdopt re-reads the FDNP from the API server and refuses adoption if it was deleted, replaced or is terminating.
+func (r *FlexDaemonSetNodePodReconciler) canAdopt(ctx context.Context, fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod) error {
+	fresh := &flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}
+	if err := r.apiReader().Get(ctx, types.NamespacedName{Namespace: fdnp.Namespace, Name: fdnp.Name}, fresh); err != nil {
+		return err
+	}
+	if fresh.UID != fdnp.UID {
+		return fmt.Errorf("original FlexDaemonSetNodePod %s/%s is gone: got uid %v, wanted %v", fdnp.Namespace, fdnp.Name, fresh.UID, fdnp.UID)
+	}
+	if !fresh.DeletionTimestamp.IsZero() {
+		return fmt.Errorf("FlexDaemonSetNodePod %s/%s has just been deleted at %v", fdnp.Namespace, fdnp.Name, fresh.DeletionTimestamp)
+	}
+	return nil
+}
+
+// adoptPod sets the FDNP as controller of the pod. The patch is guarded by the pod's resourceVersion
+// so that a concurrent change of ownership is detected instead of overwritten.
+func (r *FlexDaemonSetNodePodReconciler) adoptPod(ctx context.Context, fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, pod *corev1.Pod) error {
+	original := pod.DeepCopy()
+	if err := controllerutil.SetControllerReference(fdnp, pod, r.Scheme); err != nil {
+		return err
+	}
+	return r.Patch(ctx, pod, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+}
+
+// releasePod removes the FDNP's owner reference from the pod.
+func (r *FlexDaemonSetNodePodReconciler) releasePod(ctx context.Context, fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, pod *corev1.Pod) error {
+	original := pod.DeepCopy()
+	refs := make([]metav1.OwnerReference, 0, len(pod.OwnerReferences))
+	for _, ref := range pod.OwnerReferences {
+		if ref.UID != fdnp.UID {
+			refs = append(refs, ref)
+		}
+	}
+	pod.OwnerReferences = refs
+	return r.Patch(ctx, pod, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+}
+
+// describeController returns "Kind/name" for the pod's controller, for use in messages.
+func describeController(pod *corev1.Pod) string {
+	if ref := metav1.GetControllerOf(pod); ref != nil {
+		return fmt.Sprintf("%s/%s", ref.Kind, ref.Name)
+	}
+	return "no controller"
+}
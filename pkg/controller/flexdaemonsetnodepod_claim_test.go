@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestClaimPods(t *testing.T) {
+	fdnp := testFDNPObject()
+	now := metav1.Now()
+
+	otherNode := testManagedPod(fdnp, "other-node", true)
+	otherNode.Spec.NodeName = "node-b"
+	relabelled := testManagedPod(fdnp, "relabelled", true)
+	relabelled.Labels[LabelOwnerCR] = "another-fdnp"
+	ownedByDaemonSet := testManagedPod(fdnp, "ds-owned", false)
+	isController := true
+	ownedByDaemonSet.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "DaemonSet", Name: testDaemonSet, UID: "ds-uid", Controller: &isController}}
+	unlabelled := testManagedPod(fdnp, "unlabelled", false)
+	unlabelled.Labels = nil
+	terminating := testManagedPod(fdnp, "terminating", false)
+	terminating.DeletionTimestamp = &now
+	terminating.Finalizers = []string{"example.com/keep"}
+	unscheduled := testManagedPod(fdnp, "unscheduled", false)
+	unscheduled.Spec.NodeName = ""
+
+	tests := []struct {
+		name          string
+		pod           *corev1.Pod
+		fdnpDeleting  bool
+		storedFDNPUID types.UID
+		wantClaimed   bool
+		wantOthers    bool
+		wantOwned     bool
+		wantErr       bool
+		wantEvent     string
+	}{
+		{name: "owned and matching", pod: testManagedPod(fdnp, "owned", true), wantClaimed: true, wantOwned: true},
+		{name: "orphan is adopted", pod: testManagedPod(fdnp, "orphan", false), wantClaimed: true, wantOwned: true, wantEvent: ReasonPodAdopted},
+		{name: "unscheduled orphan is adopted", pod: unscheduled, wantClaimed: true, wantOwned: true, wantEvent: ReasonPodAdopted},
+		{name: "owned pod on another node is released", pod: otherNode, wantEvent: ReasonPodReleased},
+		{name: "owned pod with other labels is released", pod: relabelled, wantEvent: ReasonPodReleased},
+		{name: "owned pod kept while the FDNP is deleted", pod: relabelled, fdnpDeleting: true, wantClaimed: true, wantOwned: true},
+		{name: "pod of another controller is reported", pod: ownedByDaemonSet, wantOthers: true},
+		{name: "orphan without the labels is ignored", pod: unlabelled},
+		{name: "terminating orphan is not adopted", pod: terminating},
+		{name: "orphan not adopted by a deleted FDNP", pod: testManagedPod(fdnp, "orphan", false), fdnpDeleting: true},
+		{name: "orphan not adopted by a replaced FDNP", pod: testManagedPod(fdnp, "orphan", false), storedFDNPUID: "new-uid", wantErr: true, wantEvent: ReasonAdoptionForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stored := testFDNPObject()
+			if tt.storedFDNPUID != "" {
+				stored.UID = tt.storedFDNPUID
+			}
+			c := newTestClient(t, stored, tt.pod.DeepCopy())
+			recorder := record.NewFakeRecorder(10)
+			r := &FlexDaemonSetNodePodReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+
+			claiming := testFDNPObject()
+			if tt.fdnpDeleting {
+				claiming.DeletionTimestamp = &now
+			}
+			var pods corev1.PodList
+			if err := c.List(ctx, &pods); err != nil {
+				t.Fatal(err)
+			}
+			result, err := r.claimPods(ctx, claiming, pods.Items)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("claimPods() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if got := len(result.Claimed) == 1; got != tt.wantClaimed {
+					t.Errorf("claimed = %d pods, want claimed %v", len(result.Claimed), tt.wantClaimed)
+				}
+				if got := len(result.OwnedByOthers) == 1; got != tt.wantOthers {
+					t.Errorf("owned by others = %d pods, want %v", len(result.OwnedByOthers), tt.wantOthers)
+				}
+			}
+
+			live := &corev1.Pod{}
+			if err := c.Get(ctx, client.ObjectKeyFromObject(tt.pod), live); err != nil {
+				t.Fatal(err)
+			}
+			if got := metav1.IsControlledBy(live, claiming); got != tt.wantOwned {
+				t.Errorf("pod controlled by the FDNP = %v, want %v (owners %v)", got, tt.wantOwned, live.OwnerReferences)
+			}
+			if tt.wantOthers && metav1.GetControllerOf(live) == nil {
+				t.Errorf("pod of another controller lost its owner reference")
+			}
+
+			var event string
+			if len(recorder.Events) > 0 {
+				event = <-recorder.Events
+			}
+			if tt.wantEvent == "" && event != "" {
+				t.Errorf("event = %q, want none", event)
+			}
+			if tt.wantEvent != "" && !hasReason(event, tt.wantEvent) {
+				t.Errorf("event = %q, want reason %s", event, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestClaimPodsOrdersOldestFirst(t *testing.T) {
+	fdnp := testFDNPObject()
+	newer := testManagedPod(fdnp, "newer", true)
+	newer.CreationTimestamp = metav1.Now()
+	older := testManagedPod(fdnp, "older", true)
+	older.CreationTimestamp = metav1.NewTime(newer.CreationTimestamp.Add(-time.Minute))
+
+	r := &FlexDaemonSetNodePodReconciler{}
+	result, err := r.claimPods(context.Background(), fdnp, []corev1.Pod{*newer, *older})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Claimed) != 2 || result.Claimed[0].Name != "older" {
+		t.Errorf("claimed = %v, want older first", result.Claimed)
+	}
+}
+
+// hasReason reports whether a fake recorder event ("Type Reason message") has the reason.
+func hasReason(event, reason string) bool {
+	fields := strings.SplitN(event, " ", 3)
+	return len(fields) >= 2 && fields[1] == reason
+}
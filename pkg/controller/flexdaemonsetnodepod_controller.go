@@ -3,20 +3,28 @@ package controller
 import (
 	"context"
 	"fmt"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
 )
 
 const (
@@ -33,23 +41,75 @@ const (
 	PhaseYielded        = "Yielded"
 	PhaseFailed         = "Failed"
 	PhaseTerminating    = "Terminating"
+	PhaseUnschedulable  = "Unschedulable"
+	PhaseDegraded       = "Degraded"
+	PhaseBackOff        = "BackOff"
+
+	// ownershipConflictRecheckInterval is how often an FDNP blocked by a pod it does not own is re-evaluated.
+	ownershipConflictRecheckInterval = 5 * time.Minute
+	// terminatingPodRecheckInterval is how often an FDNP waits for its terminating pod to disappear, in case the
+	// deletion event is missed.
+	terminatingPodRecheckInterval = 5 * time.Second
+)
+
+// PodSchedulingMode controls how a pod created for a FlexDaemonSetNodePod is placed on its target node.
+type PodSchedulingMode string
+
+const (
+	// SchedulingModeNodeName binds the pod directly by setting spec.nodeName. This bypasses the scheduler,
+	// so resource fit is only checked by the kubelet, which rejects the pod (e.g. OutOfcpu) if it does not fit.
+	SchedulingModeNodeName PodSchedulingMode = "NodeName"
+	// SchedulingModeNodeAffinity pins the pod with a required node affinity on metadata.name, merged into the
+	// DaemonSet template's affinity, the same way the DaemonSet controller does. The scheduler then performs
+	// its usual fit, taint and pod (anti-)affinity checks.
+	SchedulingModeNodeAffinity PodSchedulingMode = "NodeAffinity"
 )
 
 // FlexDaemonSetNodePodReconciler reconciles a FlexDaemonSetNodePod object
 type FlexDaemonSetNodePodReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// SchedulingMode selects how managed pods are placed on their node. Defaults to SchedulingModeNodeName.
+	SchedulingMode PodSchedulingMode
+
+	// APIReader reads directly from the API server. It is used to double-check the cache before
+	// treating a previously created pod as lost. Falls back to the cached client when nil.
+	APIReader client.Reader
+
+	// Recorder emits Kubernetes events on FlexDaemonSetNodePods.
+	Recorder record.EventRecorder
+
+	// MaxPodRecreations is the number of consecutive managed pod failures after which the FDNP is moved to
+	// the Failed phase and no longer recreates its pod. Zero disables the limit.
+	MaxPodRecreations int32
+	// BackoffBase and BackoffMax bound the exponential delay before a failed managed pod is recreated.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// BackoffResetAfter is how long a recreated pod has to stay Ready before its failure count is reset.
+	BackoffResetAfter time.Duration
+
+	// DryRun works out what would be done to managed pods but never creates, deletes, adopts or releases them, and
+	// leaves FDNPs and their status untouched, as if every template were in dry-run mode.
+	DryRun bool
+
+	// MaxConcurrentReconciles is the number of FDNPs reconciled in parallel. Zero uses controller-runtime's default of 1.
+	MaxConcurrentReconciles int
+
+	dryRunActions dryRunActions
 }
 
 //+kubebuilder:rbac:groups=flexdaemonsets.xai,resources=flexdaemonsetnodepods,verbs=get;list;watch;update;patch;delete
 //+kubebuilder:rbac:groups=flexdaemonsets.xai,resources=flexdaemonsetnodepods/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch
-//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch // May not be strictly needed if all info is in FDNP
+//+kubebuilder:rbac:groups=flexdaemonsets.xai,resources=flexdaemonsettemplates,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-func (r *FlexDaemonSetNodePodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *FlexDaemonSetNodePodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
 	logger := log.FromContext(ctx).WithValues("flexdaemonsetnodepod", req.NamespacedName)
 
 	fdnp := &flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}
@@ -62,10 +122,15 @@ func (r *FlexDaemonSetNodePodReconciler) Reconcile(ctx context.Context, req ctrl
 		return ctrl.Result{}, err
 	}
 
-	currentPhase := fdnp.Status.Phase
+	// In dry-run mode nothing is written, not even the status. The template's own setting is known once it is read.
+	dryRun := utils.DryRunReason(r.DryRun, nil)
+	originalStatus := fdnp.Status.DeepCopy()
 	defer func() {
-		if fdnp.Status.Phase != currentPhase || fdnp.Status.ObservedGeneration != fdnp.Generation {
+		// Only report the generation as observed once a pass over it finished without error.
+		if reterr == nil {
 			fdnp.Status.ObservedGeneration = fdnp.Generation
+		}
+		if dryRun == "" && !equality.Semantic.DeepEqual(originalStatus, &fdnp.Status) {
 			if err := r.Status().Update(ctx, fdnp); err != nil {
 				logger.Error(err, "Failed to update FlexDaemonSetNodePod status")
 			}
@@ -82,6 +147,12 @@ func (r *FlexDaemonSetNodePodReconciler) Reconcile(ctx context.Context, req ctrl
 		return ctrl.Result{}, nil
 	}
 
+	// A spec change (e.g. new resources after a template or DaemonSet fix) gets a fresh recreation budget.
+	if fdnp.Status.FailureCount > 0 && fdnp.Status.ObservedGeneration != fdnp.Generation {
+		logger.Info("FlexDaemonSetNodePod spec changed since last pod failure, resetting failure tracking", "failureCount", fdnp.Status.FailureCount)
+		resetFailureTracking(fdnp)
+	}
+
 	// Fetch the original DaemonSet
 	originalDS := &appsv1.DaemonSet{}
 	dsNamespacedName := types.NamespacedName{
@@ -91,18 +162,37 @@ func (r *FlexDaemonSetNodePodReconciler) Reconcile(ctx context.Context, req ctrl
 	if err := r.Get(ctx, dsNamespacedName, originalDS); err != nil {
 		if errors.IsNotFound(err) {
 			logger.Error(err, "Original DaemonSet not found", "daemonset", dsNamespacedName.String())
-			fdnp.Status.Phase = PhaseFailed
-			fdnp.Status.Message = fmt.Sprintf("Original DaemonSet %s not found", dsNamespacedName.String())
+			markFDNPDegraded(fdnp, PhaseFailed, ReasonDaemonSetNotFound, fmt.Sprintf("Original DaemonSet %s not found", dsNamespacedName.String()))
 			return ctrl.Result{Requeue: true}, nil // Requeue as DS might appear later
 		}
 		logger.Error(err, "Failed to get original DaemonSet", "daemonset", dsNamespacedName.String())
 		return ctrl.Result{}, err
 	}
 
+	// Surface a paused DaemonSet or suspended template in the status. The pod itself keeps running with the
+	// resources already in the spec; NodeCoverageReconciler stops updating the spec while paused.
+	var flexTemplate *flexdaemonsetsv1alpha1.FlexDaemonsetTemplate
+	if templateName := originalDS.Annotations[utils.FlexDaemonsetTemplateAnnotation]; templateName != "" {
+		flexTemplate = &flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{}
+		if err := r.Get(ctx, types.NamespacedName{Name: templateName}, flexTemplate); err != nil {
+			if !errors.IsNotFound(err) {
+				return ctrl.Result{}, err
+			}
+			flexTemplate = nil
+		}
+	}
+	setFDNPPaused(fdnp, utils.PauseReason(originalDS, flexTemplate))
+	dryRun = utils.DryRunReason(r.DryRun, flexTemplate)
+
 	// Check for Conflicting DaemonSet Pod (a pod directly owned by the DaemonSet on the target node)
+	// Only pods on the target node are listed (via the pod node-name index), filtered by the DaemonSet's full selector.
 	var dsOwnedPods corev1.PodList
-	dsPodSelector := labels.SelectorFromSet(originalDS.Spec.Selector.MatchLabels)
-	if err := r.List(ctx, &dsOwnedPods, client.InNamespace(fdnp.Spec.DaemonSetNamespace), client.MatchingLabelsSelector{Selector: dsPodSelector}); err != nil {
+	dsPodSelector, err := daemonSetPodSelector(originalDS)
+	if err != nil {
+		logger.Error(err, "Invalid selector on original DaemonSet", "daemonSet", originalDS.Name)
+		return ctrl.Result{}, err
+	}
+	if err := r.List(ctx, &dsOwnedPods, client.InNamespace(fdnp.Spec.DaemonSetNamespace), client.MatchingLabelsSelector{Selector: dsPodSelector}, client.MatchingFields{PodNodeNameIndex: fdnp.Spec.NodeName}); err != nil {
 		logger.Error(err, "Failed to list pods for original DaemonSet", "daemonSet", originalDS.Name)
 		return ctrl.Result{}, err
 	}
@@ -115,6 +205,10 @@ func (r *FlexDaemonSetNodePodReconciler) Reconcile(ctx context.Context, req ctrl
 					logger.Info("Conflicting DaemonSet pod found on node. Deleting FlexDaemonSetNodePod.", "nodeName", fdnp.Spec.NodeName, "conflictingPod", pod.Name)
 					fdnp.Status.Phase = PhaseConflict
 					fdnp.Status.Message = fmt.Sprintf("Conflicting pod %s from DaemonSet %s found on node %s", pod.Name, originalDS.Name, fdnp.Spec.NodeName)
+					if dryRun != "" {
+						r.reportDryRun(ctx, fdnp, originalDS, dryRun, fmt.Sprintf("Would delete FlexDaemonSetNodePod %s: %s", fdnp.Name, fdnp.Status.Message))
+						return ctrl.Result{}, nil
+					}
 					// Deleting the FDNP CR itself. Its owned pod will be GC'd.
 					if err := r.Delete(ctx, fdnp); err != nil {
 						logger.Error(err, "Failed to delete FlexDaemonSetNodePod due to conflict")
@@ -127,74 +221,193 @@ func (r *FlexDaemonSetNodePodReconciler) Reconcile(ctx context.Context, req ctrl
 		}
 	}
 	
-	// Check for Existing Managed Pod (owned by this FDNP instance)
-	managedPodName := r.generateManagedPodName(fdnp)
-	managedPod := &corev1.Pod{}
-	err := r.Get(ctx, types.NamespacedName{Name: managedPodName, Namespace: fdnp.Namespace}, managedPod)
-	if err == nil {
+	if dryRun != "" {
+		return r.reconcileDryRun(ctx, fdnp, originalDS, dryRun)
+	}
+
+	now := time.Now()
+
+	// Check for Existing Managed Pod (owned by this FDNP instance). It is looked up through the managed-pod labels
+	// rather than a reconstructed name, adopting matching orphans and releasing owned pods that no longer match.
+	managedPod, ownedByOthers, err := r.findManagedPod(ctx, fdnp)
+	if err != nil {
+		logger.Error(err, "Failed to claim managed pods")
+		return ctrl.Result{}, err
+	}
+	if managedPod == nil && len(ownedByOthers) > 0 {
+		other := ownedByOthers[0]
+		msg := fmt.Sprintf("Pod %s matches this FlexDaemonSetNodePod but is controlled by %s; it will not be adopted", other.Name, describeController(other))
+		logger.Info("Matching pod is controlled by another owner", "podName", other.Name, "controller", describeController(other))
+		r.eventf(fdnp, corev1.EventTypeWarning, ReasonPodOwnedByOther, "%s", msg)
+		markFDNPDegraded(fdnp, PhaseFailed, ReasonOwnershipConflict, msg)
+		return ctrl.Result{RequeueAfter: ownershipConflictRecheckInterval}, nil
+	}
+	if managedPod != nil {
 		// Managed pod exists
 		logger.Info("Found existing managed pod", "podName", managedPod.Name)
 		// TODO: Compare its resources and other critical specs with fdnp.Spec.
 		// For now, assume if pod exists and is owned by fdnp, it's correctly configured.
-		// A more robust check would verify owner references and key spec fields.
-		isOwned := false
-		for _, ref := range managedPod.OwnerReferences {
-			if ref.UID == fdnp.UID {
-				isOwned = true
-				break
-			}
+
+		// A terminating pod is on its way out: either this controller deleted it after counting its failure, or
+		// someone else did and it is counted once gone, through Status.PodName. Counting it again here would
+		// emit a failure and use up backoff on every pass until it disappears.
+		if !managedPod.DeletionTimestamp.IsZero() {
+			logger.V(1).Info("Managed pod is terminating, waiting for it to disappear", "podName", managedPod.Name)
+			return ctrl.Result{RequeueAfter: terminatingPodRecheckInterval}, nil
 		}
-		if !isOwned {
-			logger.Error(fmt.Errorf("pod %s exists but not owned by this FDNP", managedPodName), "Ownership mismatch, potential conflict or adoption needed.")
-			fdnp.Status.Phase = PhaseFailed
-			fdnp.Status.Message = "Found pod with same name but not owned by this FDNP."
-			// This scenario needs careful handling - potentially delete the non-owned pod if naming convention is strict,
-			// or fail the FDNP. For now, fail.
-			return ctrl.Result{Requeue: true}, nil
+
+		// Mirror the pod's health into the FDNP status. Scheduling failures are surfaced here too: in NodeAffinity
+		// mode the scheduler reports them through the PodScheduled condition, in NodeName mode the kubelet rejects
+		// the pod at admission and marks it Failed (e.g. OutOfcpu). The pod watch requeues us on every change.
+		if reason := podFailureReason(managedPod); reason != "" {
+			// The pod will not run again on its own. Remove it and recreate it after a backoff.
+			msg := fmt.Sprintf("pod %s %s: %s", managedPod.Name, reason, managedPod.Status.Message)
+			logger.Info("Managed pod terminated, deleting it for recreation", "podName", managedPod.Name, "reason", reason)
+			if err := r.Delete(ctx, managedPod); err != nil && !errors.IsNotFound(err) {
+				logger.Error(err, "Failed to delete terminated managed pod", "podName", managedPod.Name)
+				return ctrl.Result{}, err
+			}
+			clearFDNPPodStatus(fdnp, reason, msg)
+			r.recordPodFailure(fdnp, now, reason, msg)
+			return ctrl.Result{RequeueAfter: remainingBackoff(fdnp, now)}, nil
 		}
 
-		fdnp.Status.Phase = PhaseActive
-		fdnp.Status.Message = fmt.Sprintf("Pod %s is active on node %s", managedPod.Name, fdnp.Spec.NodeName)
+		mirrorPodStatus(fdnp, managedPod)
+		logger.V(1).Info("Mirrored managed pod status", "podName", managedPod.Name, "phase", fdnp.Status.Phase, "ready", fdnp.Status.Ready, "restarts", fdnp.Status.RestartCount)
+		if wait := r.maybeResetBackoff(fdnp, managedPod, now); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
 		return ctrl.Result{}, nil
 	}
 	
-	if !errors.IsNotFound(err) {
-		logger.Error(err, "Failed to get managed pod", "podName", managedPodName)
-		return ctrl.Result{}, err
+	if fdnp.Status.PodName != "" {
+		// The cache may not have caught up with a pod created in a previous pass; confirm with the API server
+		// before counting the pod as lost.
+		livePod := &corev1.Pod{}
+		if err := r.apiReader().Get(ctx, types.NamespacedName{Namespace: fdnp.Namespace, Name: fdnp.Status.PodName}, livePod); err == nil {
+			if metav1.IsControlledBy(livePod, fdnp) {
+				logger.V(1).Info("Managed pod not yet in cache, requeueing", "podName", livePod.Name)
+				return ctrl.Result{Requeue: true}, nil
+			}
+		} else if !errors.IsNotFound(err) {
+			logger.Error(err, "Failed to get managed pod", "podName", fdnp.Status.PodName)
+			return ctrl.Result{}, err
+		}
+		// A pod we created is gone without this controller deleting it (evicted and GC'd, node drain, manual delete).
+		msg := fmt.Sprintf("pod %s was deleted", fdnp.Status.PodName)
+		logger.Info("Managed pod disappeared", "podName", fdnp.Status.PodName)
+		clearFDNPPodStatus(fdnp, ReasonPodDeleted, msg)
+		r.recordPodFailure(fdnp, now, ReasonPodDeleted, msg)
+	}
+	if r.recreationLimitReached(fdnp) {
+		logger.Info("Pod recreation limit reached, not recreating managed pod", "failureCount", fdnp.Status.FailureCount, "limit", r.MaxPodRecreations)
+		return ctrl.Result{}, nil
+	}
+	if wait := remainingBackoff(fdnp, now); wait > 0 {
+		logger.Info("Backing off before recreating managed pod", "failureCount", fdnp.Status.FailureCount, "retryIn", wait)
+		return ctrl.Result{RequeueAfter: wait}, nil
 	}
 
 	// No Managed Pod Exists (and no conflicting DS pod), proceed to create
 	logger.Info("No managed pod found, creating a new one.", "targetNode", fdnp.Spec.NodeName)
 	fdnp.Status.Phase = PhaseCreatingPod
+	clearFDNPPodStatus(fdnp, ReasonPodNotCreated, "Managed pod does not exist yet")
 
 	newPod, err := r.constructPodForFlexDaemonSetNodePod(fdnp, originalDS)
 	if err != nil {
 		logger.Error(err, "Failed to construct pod for FlexDaemonSetNodePod")
-		fdnp.Status.Phase = PhaseFailed
-		fdnp.Status.Message = fmt.Sprintf("Failed to construct pod: %v", err)
+		markFDNPDegraded(fdnp, PhaseFailed, ReasonPodConstructFailed, fmt.Sprintf("Failed to construct pod: %v", err))
 		return ctrl.Result{}, err // Error is likely not recoverable by requeue if construction fails
 	}
 
 	if err := r.Create(ctx, newPod); err != nil {
 		if errors.IsAlreadyExists(err) {
-			logger.Info("Managed pod already exists, though Get failed earlier. Requeueing.", "podName", newPod.Name)
+			existing := &corev1.Pod{}
+			if getErr := r.apiReader().Get(ctx, client.ObjectKeyFromObject(newPod), existing); getErr == nil && !metav1.IsControlledBy(existing, fdnp) {
+				// Matching orphans are adopted by findManagedPod, so this pod either lacks the managed-pod labels
+				// or belongs to someone else. Neither is safe to take over; report it and check back later.
+				msg := fmt.Sprintf("Pod %s already exists and is not managed by this FlexDaemonSetNodePod (controller: %s)", existing.Name, describeController(existing))
+				logger.Error(fmt.Errorf("pod %s exists but not owned by this FDNP", newPod.Name), "Ownership mismatch", "controller", describeController(existing))
+				r.eventf(fdnp, corev1.EventTypeWarning, ReasonPodOwnedByOther, "%s", msg)
+				markFDNPDegraded(fdnp, PhaseFailed, ReasonOwnershipConflict, msg)
+				return ctrl.Result{RequeueAfter: ownershipConflictRecheckInterval}, nil
+			}
+			logger.Info("Managed pod already exists, though it was not found in the cache. Requeueing.", "podName", newPod.Name)
 			return ctrl.Result{Requeue: true}, nil
 		}
 		logger.Error(err, "Failed to create new managed pod", "podName", newPod.Name)
-		fdnp.Status.Phase = PhaseFailed
-		fdnp.Status.Message = fmt.Sprintf("Failed to create pod %s: %v", newPod.Name, err)
+		markFDNPDegraded(fdnp, PhaseFailed, ReasonPodCreateFailed, fmt.Sprintf("Failed to create pod %s: %v", newPod.Name, err))
+		r.eventf(fdnp, corev1.EventTypeWarning, ReasonPodCreateFailed, "Failed to create pod %s on node %s: %v", newPod.Name, fdnp.Spec.NodeName, err)
 		return ctrl.Result{}, err
 	}
 
 	logger.Info("Successfully created managed pod", "podName", newPod.Name, "nodeName", fdnp.Spec.NodeName)
-	fdnp.Status.Phase = PhaseActive
-	fdnp.Status.Message = fmt.Sprintf("Pod %s created and active on node %s", newPod.Name, fdnp.Spec.NodeName)
+	r.eventf(fdnp, corev1.EventTypeNormal, ReasonPodCreated, "Created pod %s on node %s with requests and limits %s",
+		newPod.Name, fdnp.Spec.NodeName, utils.DescribeResources(fdnp.Spec.Resources.Requests))
+	// The pod only counts as Active once it reports Ready; until then the FDNP is Pending and Progressing.
+	fdnp.Status.Phase = PhasePending
+	fdnp.Status.Message = fmt.Sprintf("Pod %s created for node %s", newPod.Name, fdnp.Spec.NodeName)
+	fdnp.Status.PodName = newPod.Name
+	setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodProgressing, metav1.ConditionTrue, ReasonPodCreated, fdnp.Status.Message)
+	setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodDegraded, metav1.ConditionFalse, ReasonAsExpected, fdnp.Status.Message)
 	
 	return ctrl.Result{}, nil
 }
 
+// eventf records an event on the given object if an event recorder is configured.
+func (r *FlexDaemonSetNodePodReconciler) eventf(obj runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(obj, eventType, reason, messageFmt, args...)
+}
+
+// apiReader returns the uncached reader if one is configured, falling back to the (cached) client.
+func (r *FlexDaemonSetNodePodReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// findManagedPod lists candidate pods through the LabelManagedBy label, claims them (see claimPods) and returns
+// the pod controlled by the FDNP, or nil if there is none. If several pods are claimed, the oldest one that is not
+// terminating is kept and the other live ones are deleted; a terminating pod is only returned if no other is left.
+// Matching pods controlled by another owner are returned for reporting.
+func (r *FlexDaemonSetNodePodReconciler) findManagedPod(ctx context.Context, fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod) (*corev1.Pod, []*corev1.Pod, error) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(fdnp.Namespace), client.MatchingLabels{LabelManagedBy: FlexDaemonSetNodePodControllerName}); err != nil {
+		return nil, nil, err
+	}
+	claim, err := r.claimPods(ctx, fdnp, pods.Items)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(claim.Claimed) == 0 {
+		return nil, claim.OwnedByOthers, nil
+	}
+	var live, terminating []*corev1.Pod
+	for _, pod := range claim.Claimed {
+		if pod.DeletionTimestamp.IsZero() {
+			live = append(live, pod)
+		} else {
+			terminating = append(terminating, pod)
+		}
+	}
+	if len(live) == 0 {
+		return terminating[0], nil, nil
+	}
+	for _, duplicate := range live[1:] {
+		if err := r.Delete(ctx, duplicate); err != nil && !errors.IsNotFound(err) {
+			return nil, nil, err
+		}
+		r.eventf(fdnp, corev1.EventTypeNormal, ReasonDuplicatePod, "Deleted duplicate managed pod %s, keeping %s", duplicate.Name, live[0].Name)
+	}
+	return live[0], nil, nil
+}
+
 func (r *FlexDaemonSetNodePodReconciler) generateManagedPodName(fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod) string {
-	return fmt.Sprintf("%s-pod", fdnp.Name) // Example: my-fdnp-cr-pod
+	return utils.ManagedPodName(fdnp.Name) // Example: my-fdnp-cr-pod
 }
 
 func (r *FlexDaemonSetNodePodReconciler) constructPodForFlexDaemonSetNodePod(
@@ -235,8 +448,20 @@ func (r *FlexDaemonSetNodePodReconciler) constructPodForFlexDaemonSetNodePod(
 	}
 
 
-	// Override NodeName
-	pod.Spec.NodeName = fdnp.Spec.NodeName
+	// Place the pod on the target node
+	switch r.SchedulingMode {
+	case SchedulingModeNodeAffinity:
+		// Let the scheduler bind the pod so resource fit and the DaemonSet author's (anti-)affinity rules apply.
+		pod.Spec.NodeName = ""
+		pod.Spec.Affinity = utils.ReplaceNodeNameNodeAffinity(ds.Spec.Template.Spec.Affinity, fdnp.Spec.NodeName)
+		utils.AddDaemonPodTolerations(&pod.Spec)
+	case SchedulingModeNodeName, "":
+		pod.Spec.NodeName = fdnp.Spec.NodeName
+		pod.Spec.Affinity = nil // Affinity for a single pod is usually not copied from a DS template directly.
+		pod.Spec.Tolerations = ds.Spec.Template.Spec.Tolerations // Keep tolerations from DS
+	default:
+		return nil, fmt.Errorf("unknown pod scheduling mode %q", r.SchedulingMode)
+	}
 
 	// Override resources for all containers
 	if len(pod.Spec.Containers) > 0 {
@@ -252,10 +477,6 @@ func (r *FlexDaemonSetNodePodReconciler) constructPodForFlexDaemonSetNodePod(
 		}
 	}
 	
-	// Remove DaemonSet specific fields that are not applicable or managed differently for a single pod
-	pod.Spec.Affinity = nil // Affinity for a single pod is usually not copied from a DS template directly.
-	pod.Spec.Tolerations = ds.Spec.Template.Spec.Tolerations // Keep tolerations from DS
-
 	// Ensure RestartPolicy is appropriate (DS often uses Always, which is fine for a standalone pod too)
 	if pod.Spec.RestartPolicy == "" {
 		pod.Spec.RestartPolicy = corev1.RestartPolicyAlways
@@ -265,11 +486,61 @@ func (r *FlexDaemonSetNodePodReconciler) constructPodForFlexDaemonSetNodePod(
 }
 
 
+// findFDNPForOrphanPod is a handler.MapFunc that maps a pod without a controller that carries the
+// managed-pod labels to the FlexDaemonSetNodePod named in its LabelOwnerCR label.
+func (r *FlexDaemonSetNodePodReconciler) findFDNPForOrphanPod(ctx context.Context, obj client.Object) []reconcile.Request {
+	if metav1.GetControllerOf(obj) != nil {
+		return nil // Pods we control are handled by Owns(); pods controlled by others are none of our business.
+	}
+	podLabels := obj.GetLabels()
+	if podLabels[LabelManagedBy] != FlexDaemonSetNodePodControllerName || podLabels[LabelOwnerCR] == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: podLabels[LabelOwnerCR]}}}
+}
+
+// findFDNPsForDaemonSet is a handler.MapFunc that maps a DaemonSet to its FlexDaemonSetNodePods, so a change of
+// its pause annotation is reflected in their status.
+func (r *FlexDaemonSetNodePodReconciler) findFDNPsForDaemonSet(ctx context.Context, obj client.Object) []reconcile.Request {
+	var fdnps flexdaemonsetsv1alpha1.FlexDaemonSetNodePodList
+	if err := r.List(ctx, &fdnps, client.InNamespace(obj.GetNamespace()), client.MatchingFields{FDNPDaemonSetIndex: obj.GetNamespace() + "/" + obj.GetName()}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list FlexDaemonSetNodePods for DaemonSet", "daemonset", client.ObjectKeyFromObject(obj).String())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(fdnps.Items))
+	for _, fdnp := range fdnps.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: fdnp.Namespace, Name: fdnp.Name}})
+	}
+	return requests
+}
+
+// findFDNPsForTemplate is a handler.MapFunc that maps a FlexDaemonsetTemplate to the FlexDaemonSetNodePods of
+// every DaemonSet using it, so suspending or resuming the template is reflected in their status.
+func (r *FlexDaemonSetNodePodReconciler) findFDNPsForTemplate(ctx context.Context, obj client.Object) []reconcile.Request {
+	var daemonSets appsv1.DaemonSetList
+	if err := r.List(ctx, &daemonSets, client.MatchingFields{DaemonSetTemplateIndex: obj.GetName()}); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list DaemonSets for FlexDaemonsetTemplate", "template", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range daemonSets.Items {
+		requests = append(requests, r.findFDNPsForDaemonSet(ctx, &daemonSets.Items[i])...)
+	}
+	return requests
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *FlexDaemonSetNodePodReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}).
 		Owns(&corev1.Pod{}). // Reacts to changes/deletions of pods it creates
+		// Orphaned pods carrying the managed-pod labels are mapped to the FDNP named in LabelOwnerCR for adoption.
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.findFDNPForOrphanPod)).
+		// Pausing a DaemonSet or suspending its template is reflected in the Paused condition.
+		Watches(&appsv1.DaemonSet{}, handler.EnqueueRequestsFromMapFunc(r.findFDNPsForDaemonSet),
+			builder.WithPredicates(predicate.AnnotationChangedPredicate{})).
+		Watches(&flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{}, handler.EnqueueRequestsFromMapFunc(r.findFDNPsForTemplate),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		// TODO: Consider watching DaemonSet pods on the target node to detect conflicts more proactively.
 		// This would require a more complex Watch setup with custom EnqueueRequestsFromMapFunc.
 		// For example:
@@ -282,5 +553,6 @@ func (r *FlexDaemonSetNodePodReconciler) SetupWithManager(mgr ctrl.Manager) erro
 		// 		DeleteFunc: func(e event.DeleteEvent) bool { return false; }, // Usually FDNP creates pods
 		// 	}),
 		// ).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Complete(r)
 }
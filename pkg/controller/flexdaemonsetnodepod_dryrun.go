@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/metrics"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+// reconcileDryRun works out what a pass would do to the FDNP's pods without doing it: no pod is adopted, released,
+// deleted or created. The planned write, if any, is reported through reportDryRun.
+func (r *FlexDaemonSetNodePodReconciler) reconcileDryRun(ctx context.Context, fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, ds *appsv1.DaemonSet, dryRun string) (ctrl.Result, error) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(fdnp.Namespace), client.MatchingLabels{LabelManagedBy: FlexDaemonSetNodePodControllerName}); err != nil {
+		return ctrl.Result{}, err
+	}
+	var owned, orphans []*corev1.Pod
+	terminating := false
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		switch {
+		case metav1.IsControlledBy(pod, fdnp) && !pod.DeletionTimestamp.IsZero():
+			terminating = true
+		case metav1.IsControlledBy(pod, fdnp):
+			owned = append(owned, pod)
+		case metav1.GetControllerOf(pod) == nil && podMatchesFDNP(pod, fdnp) && pod.DeletionTimestamp.IsZero():
+			orphans = append(orphans, pod)
+		}
+	}
+	sort.SliceStable(owned, func(i, j int) bool {
+		return owned[i].CreationTimestamp.Before(&owned[j].CreationTimestamp)
+	})
+
+	var action string
+	switch {
+	case len(owned) > 1:
+		action = fmt.Sprintf("Would delete %d duplicate managed pods, keeping %s", len(owned)-1, owned[0].Name)
+	case len(owned) == 1:
+		if reason := podFailureReason(owned[0]); reason != "" {
+			action = fmt.Sprintf("Would delete pod %s (%s) and recreate it", owned[0].Name, reason)
+		}
+	case len(orphans) > 0:
+		action = fmt.Sprintf("Would adopt orphaned pod %s", orphans[0].Name)
+	case !terminating:
+		action = fmt.Sprintf("Would create pod %s on node %s with requests and limits %s",
+			utils.ManagedPodName(fdnp.Name), fdnp.Spec.NodeName, utils.DescribeResources(fdnp.Spec.Resources.Requests))
+	}
+	if action == "" {
+		r.dryRunActions.forget(fdnp.Namespace + "/" + fdnp.Name)
+		return ctrl.Result{}, nil
+	}
+	r.reportDryRun(ctx, fdnp, ds, dryRun, action)
+	return ctrl.Result{}, nil
+}
+
+// reportDryRun records a write skipped because of dry-run mode as an event on the FDNP and in the dry-run metric.
+// A write that was already planned on an earlier pass is only logged.
+func (r *FlexDaemonSetNodePodReconciler) reportDryRun(ctx context.Context, fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, ds *appsv1.DaemonSet, dryRun, action string) {
+	logger := log.FromContext(ctx).WithValues("reason", dryRun, "action", action)
+	if !r.dryRunActions.changed(fdnp.Namespace+"/"+fdnp.Name, action) {
+		logger.V(1).Info("Dry-run, still skipping write")
+		return
+	}
+	logger.Info("Dry-run, skipping write")
+	metrics.DryRunDecisions.WithLabelValues(ds.Annotations[utils.FlexDaemonsetTemplateAnnotation], metrics.DryRunComponentFlexDaemonSetNodePod).Inc()
+	r.eventf(fdnp, corev1.EventTypeNormal, ReasonDryRun, "%s (%s)", action, dryRun)
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// recordWrites returns interceptor funcs appending every write issued through the client to writes.
+func recordWrites(writes *[]string) interceptor.Funcs {
+	record := func(verb string, obj client.Object) {
+		*writes = append(*writes, fmt.Sprintf("%s %T %s", verb, obj, obj.GetName()))
+	}
+	return interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			record("create", obj)
+			return c.Create(ctx, obj, opts...)
+		},
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			record("update", obj)
+			return c.Update(ctx, obj, opts...)
+		},
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			record("patch", obj)
+			return c.Patch(ctx, obj, patch, opts...)
+		},
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			record("delete", obj)
+			return c.Delete(ctx, obj, opts...)
+		},
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			record("update "+subResource, obj)
+			return c.SubResource(subResource).Update(ctx, obj, opts...)
+		},
+		SubResourcePatch: func(ctx context.Context, c client.Client, subResource string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+			record("patch "+subResource, obj)
+			return c.SubResource(subResource).Patch(ctx, obj, patch, opts...)
+		},
+	}
+}
+
+func TestReconcileDryRunDoesNotWrite(t *testing.T) {
+	fdnp := testFDNPObject()
+	failedPod := testManagedPod(fdnp, "failed-pod", true)
+	failedPod.Status.Phase = corev1.PodFailed
+	runningPod := testManagedPod(fdnp, "running-pod", true)
+	runningPod.Status.Phase = corev1.PodRunning
+	runningPod.CreationTimestamp = metav1.Now()
+	olderPod := testManagedPod(fdnp, "older-pod", true)
+	olderPod.CreationTimestamp = metav1.NewTime(runningPod.CreationTimestamp.Add(-time.Hour))
+
+	tests := []struct {
+		name       string
+		objs       []client.Object
+		template   bool
+		wantAction string
+	}{
+		{name: "no pod", wantAction: "Would create pod " + testFDNPObject().Name + "-pod"},
+		{name: "orphaned pod", objs: []client.Object{testManagedPod(fdnp, "orphan", false)}, wantAction: "Would adopt orphaned pod orphan"},
+		{name: "failed pod", objs: []client.Object{failedPod}, wantAction: "Would delete pod failed-pod"},
+		{name: "duplicate pods", objs: []client.Object{runningPod, olderPod}, wantAction: "Would delete 1 duplicate managed pods, keeping older-pod"},
+		{name: "running pod", objs: []client.Object{runningPod}},
+		{name: "conflicting DaemonSet pod", objs: []client.Object{testDaemonSetPod("ds-pod", testNode, false, true)}, wantAction: "Would delete FlexDaemonSetNodePod"},
+		{name: "template in dry-run", template: true, wantAction: "Would create pod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			objs := append([]client.Object{testDaemonSetObject(), testFDNPObject()}, tt.objs...)
+			if tt.template {
+				template := testTemplateObject()
+				template.Spec.DryRun = true
+				objs = append(objs, template)
+			}
+			var writes []string
+			c := newTestClientBuilder(t, objs...).WithInterceptorFuncs(recordWrites(&writes)).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &FlexDaemonSetNodePodReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder, DryRun: !tt.template}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(fdnp)}
+
+			// The same plan on a second pass is not reported again.
+			for pass := 1; pass <= 2; pass++ {
+				if _, err := r.Reconcile(ctx, req); err != nil {
+					t.Fatalf("pass %d: %v", pass, err)
+				}
+			}
+			if len(writes) > 0 {
+				t.Errorf("dry-run wrote %v", writes)
+			}
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			if tt.wantAction == "" {
+				if len(events) > 0 {
+					t.Errorf("events = %v, want none", events)
+				}
+				return
+			}
+			if len(events) != 1 || !strings.Contains(events[0], ReasonDryRun) || !strings.Contains(events[0], tt.wantAction) {
+				t.Errorf("events = %v, want one %s event with %q", events, ReasonDryRun, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestDryRunActionsChanged(t *testing.T) {
+	var actions dryRunActions
+	steps := []struct {
+		key, action string
+		forget      bool
+		want        bool
+	}{
+		{key: "a", action: "create", want: true},
+		{key: "a", action: "create"},
+		{key: "b", action: "create", want: true},
+		{key: "a", action: "update", want: true},
+		{key: "a", forget: true},
+		{key: "a", action: "update", want: true},
+	}
+	for i, step := range steps {
+		if step.forget {
+			actions.forget(step.key)
+			continue
+		}
+		if got := actions.changed(step.key, step.action); got != step.want {
+			t.Errorf("step %d: changed(%s, %s) = %v, want %v", i, step.key, step.action, got, step.want)
+		}
+	}
+}
+
+// NodeCoverage reports a skipped FDNP write once, and again when the planned write changes.
+func TestNodeCoverageDryRunReportsChanges(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, testDaemonSetObject(), testTemplateObject(), testAllocatableNode(testNode))
+	recorder := record.NewFakeRecorder(10)
+	r := &NodeCoverageReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder, DryRun: true}
+	req := nodeCoverageRequest(testNamespace, testDaemonSet, testNode)
+
+	reconcile := func() []string {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		return events
+	}
+	if events := reconcile(); len(events) != 1 {
+		t.Fatalf("first pass events = %v, want one", events)
+	}
+	if events := reconcile(); len(events) != 0 {
+		t.Errorf("unchanged plan reported again: %v", events)
+	}
+
+	template := testTemplateObject()
+	if err := c.Get(ctx, client.ObjectKeyFromObject(template), template); err != nil {
+		t.Fatal(err)
+	}
+	template.Spec.CPUPercentage = 20
+	if err := c.Update(ctx, template); err != nil {
+		t.Fatal(err)
+	}
+	if events := reconcile(); len(events) != 1 {
+		t.Errorf("changed plan events = %v, want one", events)
+	}
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+// Condition reasons used on FlexDaemonSetNodePod conditions.
+const (
+	ReasonPodNotCreated      = "PodNotCreated"
+	ReasonPodCreated         = "PodCreated"
+	ReasonPodScheduled       = "Scheduled"
+	ReasonPodPending         = "PodPending"
+	ReasonUnschedulable      = "Unschedulable"
+	ReasonPodReady           = "PodReady"
+	ReasonPodNotReady        = "PodNotReady"
+	ReasonPodFailed          = "PodFailed"
+	ReasonPodCompleted       = "PodCompleted"
+	ReasonContainerWaiting   = "ContainerWaiting"
+	ReasonAsExpected         = "AsExpected"
+	ReasonDaemonSetNotFound  = "DaemonSetNotFound"
+	ReasonOwnershipConflict  = "OwnershipConflict"
+	ReasonPodConstructFailed = "PodConstructFailed"
+	ReasonPodCreateFailed    = "PodCreateFailed"
+	ReasonSizingPaused       = "SizingPaused"
+)
+
+// degradedWaitingReasons are container waiting reasons that will not resolve on their own.
+var degradedWaitingReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+}
+
+// setFDNPCondition sets a condition on the FDNP status, stamping it with the FDNP's current generation.
+func setFDNPCondition(fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&fdnp.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: fdnp.Generation,
+	})
+}
+
+// markFDNPDegraded records a failure that prevents the FDNP from running its pod.
+func markFDNPDegraded(fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, phase, reason, message string) {
+	fdnp.Status.Phase = phase
+	fdnp.Status.Message = message
+	setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodDegraded, metav1.ConditionTrue, reason, message)
+	setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodProgressing, metav1.ConditionFalse, reason, message)
+}
+
+// setFDNPPaused sets the Paused condition while sizing is frozen for the FDNP's DaemonSet and removes it
+// once reason is empty again.
+func setFDNPPaused(fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, reason string) {
+	if reason == "" {
+		meta.RemoveStatusCondition(&fdnp.Status.Conditions, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodPaused)
+		return
+	}
+	setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodPaused, metav1.ConditionTrue, ReasonSizingPaused, reason)
+}
+
+// clearFDNPPodStatus resets the mirrored pod fields when no managed pod exists.
+func clearFDNPPodStatus(fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, reason, message string) {
+	fdnp.Status.PodName = ""
+	fdnp.Status.PodPhase = ""
+	fdnp.Status.Ready = false
+	fdnp.Status.RestartCount = 0
+	fdnp.Status.LastTerminationReason = ""
+	fdnp.Status.Image = ""
+	setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodPodScheduled, metav1.ConditionFalse, reason, message)
+	setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodPodReady, metav1.ConditionFalse, reason, message)
+}
+
+// mirrorPodStatus copies the health of the managed pod into the FDNP status and derives
+// the FDNP phase and conditions from it. It is only called for pods that may still run: terminated pods, those
+// with a podFailureReason, are deleted and recreated by the reconciler instead.
+func mirrorPodStatus(fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, pod *corev1.Pod) {
+	fdnp.Status.PodName = pod.Name
+	fdnp.Status.PodPhase = pod.Status.Phase
+	fdnp.Status.Ready = isPodReady(pod)
+	fdnp.Status.Image = ""
+	if len(pod.Spec.Containers) > 0 {
+		fdnp.Status.Image = pod.Spec.Containers[0].Image
+	}
+
+	var restarts int32
+	var lastTermination *corev1.ContainerStateTerminated
+	var waiting *corev1.ContainerStateWaiting
+	for i := range pod.Status.ContainerStatuses {
+		cs := &pod.Status.ContainerStatuses[i]
+		restarts += cs.RestartCount
+		if t := cs.LastTerminationState.Terminated; t != nil {
+			if lastTermination == nil || t.FinishedAt.After(lastTermination.FinishedAt.Time) {
+				lastTermination = t
+			}
+		}
+		if w := cs.State.Waiting; w != nil && degradedWaitingReasons[w.Reason] && waiting == nil {
+			waiting = w
+		}
+	}
+	fdnp.Status.RestartCount = restarts
+	if lastTermination != nil {
+		fdnp.Status.LastTerminationReason = lastTermination.Reason
+	}
+
+	// PodScheduled
+	scheduledMessage := fmt.Sprintf("Pod %s is bound to node %s", pod.Name, pod.Spec.NodeName)
+	unschedulable := utils.PodUnschedulableCondition(pod)
+	switch {
+	case unschedulable != nil:
+		setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodPodScheduled, metav1.ConditionFalse, ReasonUnschedulable, unschedulable.Message)
+	case pod.Spec.NodeName != "":
+		setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodPodScheduled, metav1.ConditionTrue, ReasonPodScheduled, scheduledMessage)
+	default:
+		setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodPodScheduled, metav1.ConditionFalse, ReasonPodPending, fmt.Sprintf("Pod %s is waiting to be scheduled", pod.Name))
+	}
+
+	// PodReady
+	if fdnp.Status.Ready {
+		setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodPodReady, metav1.ConditionTrue, ReasonPodReady, fmt.Sprintf("Pod %s is ready", pod.Name))
+	} else {
+		setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodPodReady, metav1.ConditionFalse, ReasonPodNotReady, fmt.Sprintf("Pod %s is not ready", pod.Name))
+	}
+
+	// Phase, Progressing and Degraded
+	switch {
+	case unschedulable != nil:
+		markFDNPDegraded(fdnp, PhaseUnschedulable, ReasonUnschedulable,
+			fmt.Sprintf("Pod %s cannot be scheduled on node %s: %s", pod.Name, fdnp.Spec.NodeName, unschedulable.Message))
+	case waiting != nil:
+		markFDNPDegraded(fdnp, PhaseDegraded, ReasonContainerWaiting,
+			fmt.Sprintf("Pod %s on node %s is not starting: %s: %s", pod.Name, fdnp.Spec.NodeName, waiting.Reason, waiting.Message))
+	case fdnp.Status.Ready:
+		fdnp.Status.Phase = PhaseActive
+		fdnp.Status.Message = fmt.Sprintf("Pod %s is ready on node %s", pod.Name, fdnp.Spec.NodeName)
+		setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodProgressing, metav1.ConditionFalse, ReasonPodReady, fdnp.Status.Message)
+		setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodDegraded, metav1.ConditionFalse, ReasonAsExpected, fdnp.Status.Message)
+	default:
+		fdnp.Status.Phase = PhasePending
+		if pod.Spec.NodeName == "" {
+			fdnp.Status.Message = fmt.Sprintf("Pod %s is waiting to be scheduled on node %s", pod.Name, fdnp.Spec.NodeName)
+		} else {
+			fdnp.Status.Message = fmt.Sprintf("Pod %s is starting on node %s", pod.Name, fdnp.Spec.NodeName)
+		}
+		setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodProgressing, metav1.ConditionTrue, ReasonPodPending, fdnp.Status.Message)
+		setFDNPCondition(fdnp, flexdaemonsetsv1alpha1.FlexDaemonSetNodePodDegraded, metav1.ConditionFalse, ReasonAsExpected, fdnp.Status.Message)
+	}
+}
+
+// isPodReady reports whether the pod has the Ready condition set to True.
+func isPodReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
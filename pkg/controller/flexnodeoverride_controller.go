@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+const (
+	FlexNodeOverrideControllerName = "FlexNodeOverrideController"
+
+	ReasonOverrideActive      = "OverrideActive"
+	ReasonOverrideExpired     = "OverrideExpired"
+	ReasonOverrideInvalid     = "InvalidOverride"
+	ReasonNodeOverrideApplied = "NodeOverrideApplied"
+)
+
+// FlexNodeOverrideReconciler maintains the Active condition of FlexNodeOverrides. Flipping the condition when an
+// override expires is what makes the NodeCoverageReconciler, which watches overrides, resize the affected
+// FlexDaemonSetNodePods back to the template's result.
+type FlexNodeOverrideReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Recorder emits Kubernetes events on FlexNodeOverrides.
+	Recorder record.EventRecorder
+
+	// MaxConcurrentReconciles is the number of overrides reconciled in parallel. Zero uses controller-runtime's default of 1.
+	MaxConcurrentReconciles int
+}
+
+//+kubebuilder:rbac:groups=flexdaemonsets.xai,resources=flexnodeoverrides,verbs=get;list;watch
+//+kubebuilder:rbac:groups=flexdaemonsets.xai,resources=flexnodeoverrides/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile updates the Active condition and requeues at the expiry time.
+func (r *FlexNodeOverrideReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("flexnodeoverride", req.Name)
+
+	override := &flexdaemonsetsv1alpha1.FlexNodeOverride{}
+	if err := r.Get(ctx, req.NamespacedName, override); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to get FlexNodeOverride")
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	condition := metav1.Condition{
+		Type:               flexdaemonsetsv1alpha1.FlexNodeOverrideActive,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonOverrideActive,
+		Message:            "Override is applied on top of the template",
+		ObservedGeneration: override.Generation,
+	}
+	var result ctrl.Result
+	if err := validateNodeOverride(override); err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = ReasonOverrideInvalid
+		condition.Message = err.Error()
+	} else if utils.IsNodeOverrideExpired(override, now) {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = ReasonOverrideExpired
+		condition.Message = "Override expired at " + override.Spec.ExpiresAt.UTC().Format(time.RFC3339)
+	} else if override.Spec.ExpiresAt != nil {
+		// Wake up once the override expires; the small margin avoids waking up just before it.
+		result.RequeueAfter = override.Spec.ExpiresAt.Sub(now) + time.Second
+	}
+
+	original := override.Status.DeepCopy()
+	meta.SetStatusCondition(&override.Status.Conditions, condition)
+	if equality.Semantic.DeepEqual(original, &override.Status) {
+		return result, nil
+	}
+	if err := r.Status().Update(ctx, override); err != nil {
+		logger.Error(err, "Failed to update FlexNodeOverride status")
+		return ctrl.Result{}, err
+	}
+	logger.Info("FlexNodeOverride state changed", "active", condition.Status, "reason", condition.Reason)
+	eventType := corev1.EventTypeNormal
+	if condition.Reason == ReasonOverrideInvalid {
+		eventType = corev1.EventTypeWarning
+	}
+	r.eventf(override, eventType, condition.Reason, "%s", condition.Message)
+	return result, nil
+}
+
+// validateNodeOverride checks what the CRD schema cannot: the node selector and the quantities must parse.
+func validateNodeOverride(override *flexdaemonsetsv1alpha1.FlexNodeOverride) error {
+	if override.Spec.NodeSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(override.Spec.NodeSelector); err != nil {
+			return err
+		}
+	}
+	return utils.ApplyNodeOverride(&utils.CalculationResult{
+		Resources: corev1.ResourceList{},
+		Bounds:    map[corev1.ResourceName]utils.Bound{},
+	}, override, nil)
+}
+
+// eventf records an event on the given object if an event recorder is configured.
+func (r *FlexNodeOverrideReconciler) eventf(obj runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(obj, eventType, reason, messageFmt, args...)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *FlexNodeOverrideReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&flexdaemonsetsv1alpha1.FlexNodeOverride{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+// applyNodeOverride applies the FlexNodeOverride matching the DaemonSet on the node, if any, on top of the
+// template's calculation; percentages are taken of allocatable. It returns the applied override, or nil.
+// Overrides that cannot be evaluated are skipped in favour of the next matching one, or the template's result,
+// rather than failing the sizing; each is logged and reported in a warning event on the override.
+func applyNodeOverride(ctx context.Context, c client.Reader, recorder record.EventRecorder, calculation *utils.CalculationResult, node *corev1.Node, allocatable corev1.ResourceList, ds *appsv1.DaemonSet, templateName string) (*flexdaemonsetsv1alpha1.FlexNodeOverride, error) {
+	logger := log.FromContext(ctx)
+
+	var overrides flexdaemonsetsv1alpha1.FlexNodeOverrideList
+	if err := c.List(ctx, &overrides); err != nil {
+		logger.Error(err, "Failed to list FlexNodeOverrides")
+		return nil, err
+	}
+	override, skipped := utils.ApplyNodeOverrides(calculation, overrides.Items, node, allocatable, ds, templateName, time.Now())
+	for _, s := range skipped {
+		logger.Error(s.Err, "Skipping invalid FlexNodeOverride", "override", s.Override.Name)
+		if recorder != nil {
+			recorder.Eventf(s.Override, corev1.EventTypeWarning, ReasonOverrideInvalid, "Not applied to DaemonSet %s/%s on node %s: %v", ds.Namespace, ds.Name, node.Name, s.Err)
+		}
+	}
+	if override == nil {
+		return nil, nil
+	}
+	logger.Info("Applied FlexNodeOverride", "override", override.Name, "nodeName", node.Name)
+	return override, nil
+}
+
+// nodeOverrideName returns the override's name, or "" if none was applied.
+func nodeOverrideName(override *flexdaemonsetsv1alpha1.FlexNodeOverride) string {
+	if override == nil {
+		return ""
+	}
+	return override.Name
+}
+
+// setNodeOverrideAnnotation records the applied override on the object, or removes a stale record.
+func setNodeOverrideAnnotation(obj *metav1.ObjectMeta, override *flexdaemonsetsv1alpha1.FlexNodeOverride) {
+	if override == nil {
+		delete(obj.Annotations, utils.NodeOverrideAnnotation)
+		return
+	}
+	if obj.Annotations == nil {
+		obj.Annotations = map[string]string{}
+	}
+	obj.Annotations[utils.NodeOverrideAnnotation] = override.Name
+}
+
+// findDaemonSetsForNodeOverride is a handler.MapFunc that maps a FlexNodeOverride to the DaemonSets it can
+// apply to, so creating, changing, expiring or deleting an override resizes their FlexDaemonSetNodePods.
+func (r *NodeCoverageReconciler) findDaemonSetsForNodeOverride(ctx context.Context, obj client.Object) []ctrl.Request {
+	override, ok := obj.(*flexdaemonsetsv1alpha1.FlexNodeOverride)
+	if !ok {
+		return nil
+	}
+	if ref := override.Spec.DaemonSet; ref != nil {
+		return []ctrl.Request{{NamespacedName: client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}}}
+	}
+	if override.Spec.TemplateName == "" {
+		return nil
+	}
+	return r.findDaemonSetsForTemplate(ctx, &flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{ObjectMeta: metav1.ObjectMeta{Name: override.Spec.TemplateName}})
+}
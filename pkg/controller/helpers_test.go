@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+const (
+	testNamespace = "monitoring"
+	testDaemonSet = "node-exporter"
+	testTemplate  = "small"
+	testNode      = "node-a"
+)
+
+func testScheme(t testing.TB) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := flexdaemonsetsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+// builderIndexer registers the indexes of SetupIndexes on a fake client builder.
+type builderIndexer struct {
+	builder *fake.ClientBuilder
+}
+
+func (i builderIndexer) IndexField(_ context.Context, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	i.builder.WithIndex(obj, field, extractValue)
+	return nil
+}
+
+// newTestClientBuilder returns a fake client builder holding objs, with the package's field indexes and status
+// subresources.
+func newTestClientBuilder(t testing.TB, objs ...client.Object) *fake.ClientBuilder {
+	t.Helper()
+	builder := fake.NewClientBuilder().
+		WithScheme(testScheme(t)).
+		WithObjects(objs...).
+		WithStatusSubresource(&flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}, &flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{})
+	if err := SetupIndexes(context.Background(), builderIndexer{builder}); err != nil {
+		t.Fatal(err)
+	}
+	return builder
+}
+
+// newTestClient returns a fake client holding objs, with the package's field indexes and status subresources.
+func newTestClient(t testing.TB, objs ...client.Object) client.Client {
+	t.Helper()
+	return newTestClientBuilder(t, objs...).Build()
+}
+
+func testDaemonSetObject() *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        testDaemonSet,
+			Namespace:   testNamespace,
+			UID:         types.UID("ds-uid"),
+			Annotations: map[string]string{utils.FlexDaemonsetTemplateAnnotation: testTemplate},
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": testDaemonSet}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": testDaemonSet}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "exporter", Image: "exporter:v1"}}},
+			},
+		},
+	}
+}
+
+func testFDNPObject() *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod {
+	return &flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      utils.FlexDaemonSetNodePodName(testDaemonSet, testNode),
+			Namespace: testNamespace,
+			UID:       types.UID("fdnp-uid"),
+		},
+		Spec: flexdaemonsetsv1alpha1.FlexDaemonSetNodePodSpec{
+			DaemonSetName:      testDaemonSet,
+			DaemonSetNamespace: testNamespace,
+			NodeName:           testNode,
+		},
+	}
+}
+
+// testManagedPod returns a pod carrying the managed-pod labels of fdnp, controlled by it if owned is set.
+func testManagedPod(fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, name string, owned bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: fdnp.Namespace,
+			UID:       types.UID(name + "-uid"),
+			Labels:    map[string]string{LabelManagedBy: FlexDaemonSetNodePodControllerName, LabelOwnerCR: fdnp.Name},
+		},
+		Spec: corev1.PodSpec{NodeName: fdnp.Spec.NodeName, Containers: []corev1.Container{{Name: "exporter"}}},
+	}
+	if owned {
+		isController := true
+		pod.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: flexdaemonsetsv1alpha1.GroupVersion.String(),
+			Kind:       "FlexDaemonSetNodePod",
+			Name:       fdnp.Name,
+			UID:        fdnp.UID,
+			Controller: &isController,
+		}}
+	}
+	return pod
+}
+
+func getFDNP(t testing.TB, c client.Client, fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod) *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod {
+	t.Helper()
+	got := &flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(fdnp), got); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+// Field indexes registered on the manager's cache. They are shared by all controllers, so they are
+// registered once from SetupIndexes rather than from an individual controller's SetupWithManager.
+const (
+	// FDNPNodeNameIndex indexes FlexDaemonSetNodePods by spec.nodeName.
+	FDNPNodeNameIndex = ".spec.nodeName"
+	// FDNPDaemonSetIndex indexes FlexDaemonSetNodePods by "<daemonSetNamespace>/<daemonSetName>".
+	FDNPDaemonSetIndex = ".spec.daemonSetNamespacedName"
+
+	// PodControllerUIDIndex indexes pods by the UID of their controller owner reference.
+	PodControllerUIDIndex = ".metadata.controllerUID"
+	// PodNodeNameIndex indexes pods by spec.nodeName. Unscheduled pods are not indexed.
+	PodNodeNameIndex = ".spec.nodeName"
+
+	// DaemonSetTemplateIndex indexes DaemonSets by the value of the FlexDaemonsetTemplateAnnotation.
+	// Every annotated DaemonSet is additionally indexed under AnyTemplateIndexValue.
+	DaemonSetTemplateIndex = ".metadata.annotations.resourceTemplate"
+	// AnyTemplateIndexValue matches every DaemonSet carrying the FlexDaemonsetTemplateAnnotation.
+	// Template names are DNS subdomains, so it cannot collide with a real template name.
+	AnyTemplateIndexValue = "*"
+)
+
+// SetupIndexes registers the field indexes used by the controllers in this package.
+// It must be called before the manager is started.
+func SetupIndexes(ctx context.Context, indexer client.FieldIndexer) error {
+	if err := indexer.IndexField(ctx, &flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}, FDNPNodeNameIndex, func(rawObj client.Object) []string {
+		fdnp := rawObj.(*flexdaemonsetsv1alpha1.FlexDaemonSetNodePod)
+		if fdnp.Spec.NodeName == "" {
+			return nil
+		}
+		return []string{fdnp.Spec.NodeName}
+	}); err != nil {
+		return err
+	}
+
+	if err := indexer.IndexField(ctx, &flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}, FDNPDaemonSetIndex, func(rawObj client.Object) []string {
+		fdnp := rawObj.(*flexdaemonsetsv1alpha1.FlexDaemonSetNodePod)
+		if fdnp.Spec.DaemonSetName == "" || fdnp.Spec.DaemonSetNamespace == "" {
+			return nil
+		}
+		return []string{fdnp.Spec.DaemonSetNamespace + "/" + fdnp.Spec.DaemonSetName}
+	}); err != nil {
+		return err
+	}
+
+	if err := indexer.IndexField(ctx, &corev1.Pod{}, PodControllerUIDIndex, func(rawObj client.Object) []string {
+		owner := metav1.GetControllerOf(rawObj)
+		if owner == nil {
+			return nil
+		}
+		return []string{string(owner.UID)}
+	}); err != nil {
+		return err
+	}
+
+	if err := indexer.IndexField(ctx, &corev1.Pod{}, PodNodeNameIndex, func(rawObj client.Object) []string {
+		pod := rawObj.(*corev1.Pod)
+		if pod.Spec.NodeName == "" {
+			return nil
+		}
+		return []string{pod.Spec.NodeName}
+	}); err != nil {
+		return err
+	}
+
+	return indexer.IndexField(ctx, &appsv1.DaemonSet{}, DaemonSetTemplateIndex, func(rawObj client.Object) []string {
+		templateName, ok := rawObj.GetAnnotations()[utils.FlexDaemonsetTemplateAnnotation]
+		if !ok {
+			return nil
+		}
+		if templateName == "" {
+			return []string{AnyTemplateIndexValue}
+		}
+		return []string{templateName, AnyTemplateIndexValue}
+	})
+}
+
+// daemonSetPodSelector converts the DaemonSet's label selector, including MatchExpressions, into a labels.Selector.
+func daemonSetPodSelector(ds *appsv1.DaemonSet) (labels.Selector, error) {
+	return metav1.LabelSelectorAsSelector(ds.Spec.Selector)
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+// indexTestObjects returns two DaemonSets using testTemplate, one using another template and one without the
+// annotation, and an FDNP for each of the first three.
+func indexTestObjects() []client.Object {
+	var objs []client.Object
+	for _, ds := range []struct{ name, template string }{
+		{testDaemonSet, testTemplate},
+		{"log-shipper", testTemplate},
+		{"large", "large"},
+		{"plain", ""},
+	} {
+		obj := testDaemonSetObject()
+		obj.Name, obj.UID = ds.name, types.UID("uid-"+ds.name)
+		if ds.template == "" {
+			obj.Annotations = nil
+		} else {
+			obj.Annotations = map[string]string{utils.FlexDaemonsetTemplateAnnotation: ds.template}
+			fdnp := testFDNPObject()
+			fdnp.Name, fdnp.UID = utils.FlexDaemonSetNodePodName(ds.name, testNode), "fdnp-"+obj.UID
+			fdnp.Spec.DaemonSetName = ds.name
+			objs = append(objs, fdnp)
+		}
+		objs = append(objs, obj)
+	}
+	return objs
+}
+
+func requestNames(requests []reconcile.Request) []string {
+	names := make([]string, 0, len(requests))
+	for _, req := range requests {
+		names = append(names, req.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestFindDaemonSetsForTemplate(t *testing.T) {
+	r := &NodeCoverageReconciler{Client: newTestClient(t, indexTestObjects()...)}
+	tests := []struct {
+		template string
+		want     []string
+	}{
+		{template: testTemplate, want: []string{testNamespace + "/log-shipper", testNamespace + "/" + testDaemonSet}},
+		{template: "large", want: []string{testNamespace + "/large"}},
+		{template: "unused", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			template := &flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{}
+			template.Name = tt.template
+			got := requestNames(r.findDaemonSetsForTemplate(context.Background(), template))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("requests = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindFDNPsForDaemonSetAndTemplate(t *testing.T) {
+	objs := indexTestObjects()
+	r := &FlexDaemonSetNodePodReconciler{Client: newTestClient(t, objs...)}
+	fdnpRequest := func(ds string) string {
+		return testNamespace + "/" + utils.FlexDaemonSetNodePodName(ds, testNode)
+	}
+
+	t.Run("DaemonSet", func(t *testing.T) {
+		ds := testDaemonSetObject()
+		ds.Name = "log-shipper"
+		got := requestNames(r.findFDNPsForDaemonSet(context.Background(), ds))
+		if want := []string{fdnpRequest("log-shipper")}; !slices.Equal(got, want) {
+			t.Errorf("requests = %v, want %v", got, want)
+		}
+	})
+	t.Run("DaemonSet in another namespace", func(t *testing.T) {
+		ds := testDaemonSetObject()
+		ds.Namespace = "logging"
+		if got := r.findFDNPsForDaemonSet(context.Background(), ds); len(got) != 0 {
+			t.Errorf("requests = %v, want none", got)
+		}
+	})
+	t.Run("template", func(t *testing.T) {
+		template := &flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{}
+		template.Name = testTemplate
+		got := requestNames(r.findFDNPsForTemplate(context.Background(), template))
+		want := []string{fdnpRequest("log-shipper"), fdnpRequest(testDaemonSet)}
+		sort.Strings(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("requests = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestFindFDNPForOrphanPod(t *testing.T) {
+	fdnp := testFDNPObject()
+	unlabelled := testManagedPod(fdnp, "unlabelled", false)
+	unlabelled.Labels = nil
+	want := testNamespace + "/" + fdnp.Name
+
+	tests := []struct {
+		name string
+		obj  client.Object
+		want []string
+	}{
+		{name: "orphan with the managed-pod labels", obj: testManagedPod(fdnp, "orphan", false), want: []string{want}},
+		{name: "controlled pod", obj: testManagedPod(fdnp, "owned", true), want: []string{}},
+		{name: "orphan without the labels", obj: unlabelled, want: []string{}},
+	}
+	r := &FlexDaemonSetNodePodReconciler{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := requestNames(r.findFDNPForOrphanPod(context.Background(), tt.obj)); !slices.Equal(got, tt.want) {
+				t.Errorf("requests = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -10,9 +11,12 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
@@ -21,6 +25,7 @@ import (
 	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
 	"reflect" // For DeepEqual
 
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/metrics"
 	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
 )
 
@@ -30,56 +35,193 @@ import (
 type NodeCoverageReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Recorder emits Kubernetes events on DaemonSets and templates describing FDNP sizing decisions.
+	Recorder record.EventRecorder
+
+	// TemplateDefaults fill in the fields templates leave unset before sizing.
+	TemplateDefaults utils.TemplateDefaults
+
+	// DryRun calculates FlexDaemonSetNodePod resources but never creates, updates or deletes them, as if every
+	// template were in dry-run mode.
+	DryRun bool
+
+	// MaxConcurrentReconciles is the number of DaemonSets reconciled in parallel. Zero uses controller-runtime's default of 1.
+	MaxConcurrentReconciles int
+
+	dryRunActions dryRunActions
 }
 
+const (
+	NodeCoverageControllerName = "NodeCoverageController"
+
+	ReasonFDNPCreated       = "FlexDaemonSetNodePodCreated"
+	ReasonFDNPUpdated       = "FlexDaemonSetNodePodUpdated"
+	ReasonFDNPCreateFailed  = "FlexDaemonSetNodePodCreateFailed"
+	ReasonFDNPUpdateFailed  = "FlexDaemonSetNodePodUpdateFailed"
+	ReasonFDNPNameCollision = "FlexDaemonSetNodePodNameCollision"
+)
+
 //+kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;update;patch
 //+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
 //+kubebuilder:rbac:groups=flexdaemonsets.xai,resources=flexdaemonsettemplates,verbs=get;list;watch
+//+kubebuilder:rbac:groups=flexdaemonsets.xai,resources=flexnodeoverrides,verbs=get;list;watch
 //+kubebuilder:rbac:groups=flexdaemonsets.xai,resources=flexdaemonsetnodepods,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// nodeRequestSeparator separates the DaemonSet and node names in per-node reconcile requests.
+// DaemonSet names are DNS subdomains and cannot contain it, so the encoding is unambiguous.
+const nodeRequestSeparator = "/"
+
+// nodeCoverageRequest builds the reconcile request that covers a single (DaemonSet, node) pair.
+func nodeCoverageRequest(dsNamespace, dsName, nodeName string) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{
+		Namespace: dsNamespace,
+		Name:      dsName + nodeRequestSeparator + nodeName,
+	}}
+}
+
+// parseNodeCoverageRequest splits a request into the DaemonSet key and, for per-node requests, the node name.
+// Requests produced by DaemonSet events carry no node name and cover every node.
+func parseNodeCoverageRequest(req ctrl.Request) (types.NamespacedName, string) {
+	if i := strings.Index(req.Name, nodeRequestSeparator); i >= 0 {
+		return types.NamespacedName{Namespace: req.Namespace, Name: req.Name[:i]}, req.Name[i+1:]
+	}
+	return req.NamespacedName, ""
+}
+
+// coverageState is the per-DaemonSet state needed to decide coverage on one or more nodes.
+type coverageState struct {
+	ds              *appsv1.DaemonSet
+	template        *flexdaemonsetsv1alpha1.FlexDaemonsetTemplate
+	podsByNodeName  map[string]bool
+	fdnpsByNodeName map[string][]*flexdaemonsetsv1alpha1.FlexDaemonSetNodePod
+	// dryRun is the reason FDNP writes are only reported, or "" if they are applied.
+	dryRun string
+}
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
+// DaemonSet events reconcile the DaemonSet on every node; Node events are keyed per (DaemonSet, node)
+// pair so that a single node change only touches that node.
 func (r *NodeCoverageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
-	logger.Info("Reconciling NodeCoverage", "request", req.NamespacedName)
+	dsKey, nodeName := parseNodeCoverageRequest(req)
+	logger.Info("Reconciling NodeCoverage", "daemonset", dsKey, "nodeName", nodeName)
 
-	// The request could be for a DaemonSet due to a DS change or a Node change mapped to a DS.
 	var currentDS appsv1.DaemonSet
-	if err := r.Get(ctx, req.NamespacedName, &currentDS); err != nil {
+	if err := r.Get(ctx, dsKey, &currentDS); err != nil {
 		if errors.IsNotFound(err) {
-			logger.Info("DaemonSet not found, possibly deleted or request was for a Node no longer relevant to any DS.", "request", req.NamespacedName)
+			logger.Info("DaemonSet not found, possibly deleted.", "daemonset", dsKey)
 			return ctrl.Result{}, nil
 		}
 		logger.Error(err, "Failed to get DaemonSet for NodeCoverage reconciliation")
 		return ctrl.Result{}, err
 	}
 
+	if needsRelease(&currentDS) {
+		return r.reconcileRelease(ctx, &currentDS)
+	}
+
+	if nodeName != "" {
+		logger.V(1).Info("Reconciliation triggered for DaemonSet on a single node", "daemonset", currentDS.Name, "nodeName", nodeName)
+		return r.reconcileSingleNodeCoverage(ctx, &currentDS, nodeName)
+	}
 	logger.Info("Reconciliation triggered for DaemonSet", "daemonset", currentDS.Name)
 	return r.reconcileDaemonSetCoverage(ctx, &currentDS)
 }
 
-// reconcileDaemonSetCoverage handles the logic when a DaemonSet event triggers reconciliation.
-// It ensures that for each node where the DaemonSet should run, a FlexDaemonSetNodePod exists if the DS pod itself is not there.
-func (r *NodeCoverageReconciler) reconcileDaemonSetCoverage(ctx context.Context, ds *appsv1.DaemonSet) (ctrl.Result, error) {
+// loadCoverageState fetches the template, DaemonSet pods and FDNPs for the DaemonSet. If nodeName is set,
+// pods and FDNPs are restricted to that node. It returns nil state if the DaemonSet is not flex-managed or sizing is paused.
+func (r *NodeCoverageReconciler) loadCoverageState(ctx context.Context, ds *appsv1.DaemonSet, nodeName string) (*coverageState, error) {
 	logger := log.FromContext(ctx).WithValues("daemonset", client.ObjectKeyFromObject(ds).String())
 
 	templateName, ok := ds.Annotations[utils.FlexDaemonsetTemplateAnnotation]
 	if !ok {
+		// Unannotated DaemonSets are routed to reconcileRelease before this point; this only guards against races.
 		logger.Info("DaemonSet does not have the required annotation, skipping", "annotation", utils.FlexDaemonsetTemplateAnnotation)
-		// If annotation is removed, existing FDNPs should ideally be cleaned up by their own controller or a cleanup mechanism.
-		// This controller focuses on ensuring FDNPs exist when they *should*.
-		return ctrl.Result{}, nil
+		return nil, nil
 	}
 
-	var fdsTemplate flexdaemonsetsv1alpha1.FlexDaemonsetTemplate
-	if err := r.Get(ctx, types.NamespacedName{Name: templateName, Namespace: ds.Namespace}, &fdsTemplate); err != nil {
+	fdsTemplate := &flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{}
+	if err := r.Get(ctx, types.NamespacedName{Name: templateName}, fdsTemplate); err != nil {
 		logger.Error(err, "Failed to get FlexDaemonsetTemplate", "templateName", templateName)
+		if errors.IsNotFound(err) {
+			r.eventf(ds, corev1.EventTypeWarning, ReasonTemplateNotFound, "FlexDaemonsetTemplate %s not found; node coverage is not reconciled", templateName)
+		}
 		// If template is gone, we can't calculate resources. Could requeue or set error status on FDNP.
+		return nil, err
+	}
+
+	// While paused, existing FDNPs keep their resources and no new ones are created. Unpausing changes the
+	// DaemonSet annotation or the template generation, both of which trigger a full reconcile.
+	if reason := utils.PauseReason(ds, fdsTemplate); reason != "" {
+		logger.Info("Sizing is paused, not creating or updating FlexDaemonSetNodePods", "reason", reason)
+		return nil, nil
+	}
+
+	// DaemonSet pods are the pods selected by the DaemonSet and controlled by it. The selector is converted with
+	// LabelSelectorAsSelector so MatchExpressions are honoured; the candidate set comes from a cache index.
+	dsPodSelector, err := daemonSetPodSelector(ds)
+	if err != nil {
+		logger.Error(err, "Invalid DaemonSet selector", "selector", ds.Spec.Selector)
+		return nil, err
+	}
+	podIndex := client.MatchingFields{PodControllerUIDIndex: string(ds.UID)}
+	if nodeName != "" {
+		podIndex = client.MatchingFields{PodNodeNameIndex: nodeName}
+	}
+	listOpts := []client.ListOption{client.InNamespace(ds.Namespace), client.MatchingLabelsSelector{Selector: dsPodSelector}, podIndex}
+	var dsPods corev1.PodList
+	if err := r.List(ctx, &dsPods, listOpts...); err != nil {
+		logger.Error(err, "Failed to list pods for DaemonSet", "selector", dsPodSelector.String())
+		return nil, err
+	}
+
+	podsByNodeName := make(map[string]bool)
+	for i := range dsPods.Items {
+		pod := &dsPods.Items[i]
+		if pod.Spec.NodeName != "" && metav1.IsControlledBy(pod, ds) {
+			podsByNodeName[pod.Spec.NodeName] = true
+		}
+	}
+
+	// Existing FDNPs are found through the DaemonSet index rather than by reconstructing their names,
+	// so FDNPs created under an older naming scheme are still recognised (and migrated in reconcileNode).
+	var fdnpList flexdaemonsetsv1alpha1.FlexDaemonSetNodePodList
+	if err := r.List(ctx, &fdnpList, client.InNamespace(ds.Namespace), client.MatchingFields{FDNPDaemonSetIndex: ds.Namespace + "/" + ds.Name}); err != nil {
+		logger.Error(err, "Failed to list FlexDaemonSetNodePods for DaemonSet")
+		return nil, err
+	}
+	fdnpsByNodeName := make(map[string][]*flexdaemonsetsv1alpha1.FlexDaemonSetNodePod)
+	for i := range fdnpList.Items {
+		fdnp := &fdnpList.Items[i]
+		if nodeName == "" || fdnp.Spec.NodeName == nodeName {
+			fdnpsByNodeName[fdnp.Spec.NodeName] = append(fdnpsByNodeName[fdnp.Spec.NodeName], fdnp)
+		}
+	}
+
+	return &coverageState{
+		ds:              ds,
+		template:        fdsTemplate,
+		podsByNodeName:  podsByNodeName,
+		fdnpsByNodeName: fdnpsByNodeName,
+		dryRun:          utils.DryRunReason(r.DryRun, fdsTemplate),
+	}, nil
+}
+
+// reconcileDaemonSetCoverage handles the logic when a DaemonSet event triggers reconciliation.
+// It ensures that for each node where the DaemonSet should run, a FlexDaemonSetNodePod exists if the DS pod itself is not there.
+func (r *NodeCoverageReconciler) reconcileDaemonSetCoverage(ctx context.Context, ds *appsv1.DaemonSet) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("daemonset", client.ObjectKeyFromObject(ds).String())
+
+	state, err := r.loadCoverageState(ctx, ds, "")
+	if err != nil || state == nil {
 		return ctrl.Result{}, err
 	}
 
-	logger.Info("Processing DaemonSet for node coverage", "templateName", templateName)
+	logger.Info("Processing DaemonSet for node coverage", "templateName", state.template.Name)
 
 	var nodeList corev1.NodeList
 	// TODO: Consider adding client.MatchingFields{".spec.schedulerName": ds.Spec.Template.Spec.SchedulerName} if relevant,
@@ -89,142 +231,313 @@ func (r *NodeCoverageReconciler) reconcileDaemonSetCoverage(ctx context.Context,
 		return ctrl.Result{}, err
 	}
 
-	var dsPods corev1.PodList
-	// Using ds.Spec.Selector which should be immutable.
-	if err := r.List(ctx, &dsPods, client.InNamespace(ds.Namespace), client.MatchingLabels(ds.Spec.Selector.MatchLabels)); err != nil {
-		logger.Error(err, "Failed to list pods for DaemonSet", "selector", ds.Spec.Selector.MatchLabels)
+	var errs []error
+	// For each node, determine if it's an "uncovered node"
+	for i := range nodeList.Items {
+		if err := r.reconcileNode(ctx, state, &nodeList.Items[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return ctrl.Result{}, utilerrors.NewAggregate(errs)
+}
+
+// reconcileSingleNodeCoverage reconciles the DaemonSet's coverage on one node, in response to a Node event.
+func (r *NodeCoverageReconciler) reconcileSingleNodeCoverage(ctx context.Context, ds *appsv1.DaemonSet, nodeName string) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("daemonset", client.ObjectKeyFromObject(ds).String(), "nodeName", nodeName)
+
+	state, err := r.loadCoverageState(ctx, ds, nodeName)
+	if err != nil || state == nil {
 		return ctrl.Result{}, err
 	}
 
-	podsByNodeName := make(map[string]bool)
-	for _, pod := range dsPods.Items {
-		if pod.Spec.NodeName != "" {
-			podsByNodeName[pod.Spec.NodeName] = true
+	node := &corev1.Node{}
+	if err := r.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+		if !errors.IsNotFound(err) {
+			logger.Error(err, "Failed to get Node")
+			return ctrl.Result{}, err
 		}
+		// The node is gone; its FDNPs can never be satisfied again.
+		var errs []error
+		for _, fdnp := range state.fdnpsByNodeName[nodeName] {
+			if state.dryRun != "" {
+				r.reportDryRun(ctx, state, fdnp.Name, "Would delete FlexDaemonSetNodePod %s of deleted node %s", fdnp.Name, nodeName)
+				continue
+			}
+			logger.Info("Node deleted, deleting its FlexDaemonSetNodePod", "fdnpName", fdnp.Name)
+			if delErr := r.Delete(ctx, fdnp); delErr != nil && !errors.IsNotFound(delErr) {
+				errs = append(errs, delErr)
+			}
+		}
+		return ctrl.Result{}, utilerrors.NewAggregate(errs)
 	}
 
-	// For each node, determine if it's an "uncovered node"
-	for i := range nodeList.Items {
-		node := &nodeList.Items[i] // Use pointer to allow modifications if needed, and for consistency
+	return ctrl.Result{}, r.reconcileNode(ctx, state, node)
+}
 
-		if !r.isNodeSchedulable(node) {
-			logger.V(1).Info("Skipping unschedulable node", "nodeName", node.Name)
-			continue
-		}
+// reconcileNode creates, updates or migrates the FlexDaemonSetNodePod for the DaemonSet on one node.
+func (r *NodeCoverageReconciler) reconcileNode(ctx context.Context, state *coverageState, node *corev1.Node) error {
+	ds := state.ds
+	fdsTemplate := state.template
+	logger := log.FromContext(ctx).WithValues("daemonset", client.ObjectKeyFromObject(ds).String())
 
-		// TODO: Implement more sophisticated check for DaemonSet node affinity/selector matching against the node's labels.
-		// This is a complex task involving evaluating node selectors, affinity, and taints/tolerations.
-		// For this iteration, we assume if a node is schedulable and doesn't have a DS pod, it's a candidate.
-		// A real implementation MUST check if the DaemonSet *would* schedule to this node.
+	if !r.isNodeSchedulable(node) {
+		logger.V(1).Info("Skipping unschedulable node", "nodeName", node.Name)
+		return nil
+	}
 
-		if _, hasDSPod := podsByNodeName[node.Name]; hasDSPod {
-			logger.V(1).Info("Node already has a DaemonSet pod, skipping FDNP creation", "nodeName", node.Name)
-			// Potentially, ensure any existing FDNP for this node is deleted if a real DS pod now exists.
-			// This might be handled by an FDNP controller or by adding cleanup logic here.
-			// For now, focus on creation/update.
-			continue
+	// TODO: Implement more sophisticated check for DaemonSet node affinity/selector matching against the node's labels.
+	// This is a complex task involving evaluating node selectors, affinity, and taints/tolerations.
+	// For this iteration, we assume if a node is schedulable and doesn't have a DS pod, it's a candidate.
+	// A real implementation MUST check if the DaemonSet *would* schedule to this node.
+
+	if _, hasDSPod := state.podsByNodeName[node.Name]; hasDSPod {
+		logger.V(1).Info("Node already has a DaemonSet pod, skipping FDNP creation", "nodeName", node.Name)
+		// Potentially, ensure any existing FDNP for this node is deleted if a real DS pod now exists.
+		// This might be handled by an FDNP controller or by adding cleanup logic here.
+		// For now, focus on creation/update.
+		return nil
+	}
+
+	logger.Info("Node identified as uncovered for DaemonSet", "nodeName", node.Name)
+
+	// --- Resource Calculation ---
+	// --- Resource Calculation ---
+	allocatable := effectiveAllocatable(ctx, r.Recorder, node)
+	calculation, errCalc := utils.CalculatePodResourcesWithBounds(r.TemplateDefaults.Apply(&fdsTemplate.Spec), allocatable)
+	if errCalc != nil {
+		logger.Error(errCalc, "Failed to calculate resources for FlexDaemonSetNodePod, skipping FDNP for this node", "nodeName", node.Name, "templateName", fdsTemplate.Name)
+		r.eventf(ds, corev1.EventTypeWarning, ReasonCalculationFailed, "Failed to calculate resources from template %s for node %s: %v", fdsTemplate.Name, node.Name, errCalc)
+		r.eventf(fdsTemplate, corev1.EventTypeWarning, ReasonCalculationFailed, "Failed to calculate resources for DaemonSet %s/%s on node %s: %v", ds.Namespace, ds.Name, node.Name, errCalc)
+		return nil // Skip creating/updating FDNP for this node if calculation fails
+	}
+	// A FlexNodeOverride for this node has the last word over the template.
+	override, errOverride := applyNodeOverride(ctx, r, r.Recorder, calculation, node, allocatable, ds, fdsTemplate.Name)
+	if errOverride != nil {
+		return errOverride
+	}
+	// Record what is applied, including the override.
+	metrics.RecordCalculation(fdsTemplate.Name, calculation)
+	calculatedPodResources := calculation.Resources
+
+	fdnpSpecResources := corev1.ResourceRequirements{
+		Limits:   calculatedPodResources,
+		Requests: calculatedPodResources,
+	}
+	// --- End Resource Calculation ---
+
+	fdnpName := utils.FlexDaemonSetNodePodName(ds.Name, node.Name)
+	fdnpNamespace := ds.Namespace // FDNP in the same namespace as the DaemonSet
+
+	var errs []error
+	var existingFdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod
+	var legacyFdnps []*flexdaemonsetsv1alpha1.FlexDaemonSetNodePod
+	for _, candidate := range state.fdnpsByNodeName[node.Name] {
+		if candidate.Name == fdnpName {
+			existingFdnp = candidate
+		} else {
+			legacyFdnps = append(legacyFdnps, candidate)
 		}
+	}
 
-		logger.Info("Node identified as uncovered for DaemonSet", "nodeName", node.Name)
+	if existingFdnp == nil {
+		// The name may already be taken by an FDNP of another DaemonSet or node: short names are not hashed, so
+		// e.g. DaemonSet "a" on node "b-c" and DaemonSet "a-b" on node "c" share one. It is never taken over.
+		taken := &flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}
+		if getErr := r.Get(ctx, types.NamespacedName{Namespace: fdnpNamespace, Name: fdnpName}, taken); getErr == nil {
+			if !fdnpCoversNode(taken, ds, node.Name) {
+				logger.Info("FlexDaemonSetNodePod name already used for another DaemonSet or node, not creating it", "fdnpName", fdnpName, "nodeName", node.Name,
+					"usedBy", taken.Spec.DaemonSetNamespace+"/"+taken.Spec.DaemonSetName, "usedOnNode", taken.Spec.NodeName)
+				r.eventf(ds, corev1.EventTypeWarning, ReasonFDNPNameCollision, "Cannot cover node %s: FlexDaemonSetNodePod %s already exists for DaemonSet %s/%s on node %s",
+					node.Name, fdnpName, taken.Spec.DaemonSetNamespace, taken.Spec.DaemonSetName, taken.Spec.NodeName)
+				return nil
+			}
+			existingFdnp = taken
+		} else if !errors.IsNotFound(getErr) {
+			logger.Error(getErr, "Failed to get FlexDaemonSetNodePod", "fdnpName", fdnpName)
+			return getErr
+		}
+	}
 
-		// --- Resource Calculation ---
-		// --- Resource Calculation ---
-		calculatedPodResources, errCalc := utils.CalculatePodResources(&fdsTemplate.Spec, node.Status.Allocatable)
-		if errCalc != nil {
-			logger.Error(errCalc, "Failed to calculate resources for FlexDaemonSetNodePod, skipping FDNP for this node", "nodeName", node.Name, "templateName", fdsTemplate.Name)
-			continue // Skip creating/updating FDNP for this node if calculation fails
+	for _, legacy := range legacyFdnps {
+		// Created under the old "<daemonset>-<node>" scheme, which could exceed name and label length limits.
+		// Names cannot be changed, so the old FDNP is replaced. Its pods are handed over to the new name first so
+		// that they keep running and are adopted by the new FDNP instead of being garbage collected with the old one.
+		if state.dryRun != "" {
+			r.reportDryRun(ctx, state, legacy.Name, "Would replace FlexDaemonSetNodePod %s on node %s by %s", legacy.Name, node.Name, fdnpName)
+			continue
+		}
+		logger.Info("Migrating FlexDaemonSetNodePod to length-safe name", "oldName", legacy.Name, "newName", fdnpName, "nodeName", node.Name)
+		if handOverErr := r.handOverManagedPods(ctx, legacy, fdnpName); handOverErr != nil {
+			logger.Error(handOverErr, "Failed to hand pods over from FlexDaemonSetNodePod with legacy name, keeping it", "fdnpName", legacy.Name)
+			errs = append(errs, handOverErr)
+			continue
 		}
+		if delErr := r.Delete(ctx, legacy); delErr != nil && !errors.IsNotFound(delErr) {
+			logger.Error(delErr, "Failed to delete FlexDaemonSetNodePod with legacy name", "fdnpName", legacy.Name)
+			errs = append(errs, delErr)
+		}
+	}
 
-		fdnpSpecResources := corev1.ResourceRequirements{
-			Limits:   calculatedPodResources,
-			Requests: calculatedPodResources,
+	if existingFdnp == nil {
+		if state.dryRun != "" {
+			r.reportDryRun(ctx, state, fdnpName, "Would create FlexDaemonSetNodePod %s for uncovered node %s with template %s: %s",
+				fdnpName, node.Name, fdsTemplate.Name, calculation.Describe())
+			return utilerrors.NewAggregate(errs)
 		}
-		// --- End Resource Calculation ---
-
-		fdnpName := fmt.Sprintf("%s-%s", ds.Name, node.Name)
-		fdnpNamespace := ds.Namespace // FDNP in the same namespace as the DaemonSet
-
-		var existingFdnp flexdaemonsetsv1alpha1.FlexDaemonSetNodePod
-		err := r.Get(ctx, types.NamespacedName{Name: fdnpName, Namespace: fdnpNamespace}, &existingFdnp)
-
-		if err != nil {
-			if errors.IsNotFound(err) {
-				// --- Create FlexDaemonSetNodePod ---
-				logger.Info("Creating FlexDaemonSetNodePod for uncovered node", "fdnpName", fdnpName, "nodeName", node.Name)
-				newFdnp := &flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      fdnpName,
-						Namespace: fdnpNamespace,
-						OwnerReferences: []metav1.OwnerReference{
-							*metav1.NewControllerRef(ds, appsv1.SchemeGroupVersion.WithKind("DaemonSet")),
-						},
-					},
-					Spec: flexdaemonsetsv1alpha1.FlexDaemonSetNodePodSpec{
-						DaemonSetName:                   ds.Name,
-						DaemonSetNamespace:              ds.Namespace,
-						NodeName:                        node.Name,
-						ObservedDaemonSetTemplateGeneration: ds.Generation, // Use DS metadata.generation
-						Resources:                       fdnpSpecResources,
-					},
-				}
-				if createErr := r.Create(ctx, newFdnp); createErr != nil {
-					logger.Error(createErr, "Failed to create FlexDaemonSetNodePod", "fdnpName", fdnpName)
-					// Consider requeue: return ctrl.Result{Requeue: true}, nil or return ctrl.Result{}, createErr
-					// For now, continue to next node.
-				}
-				continue // Move to the next node
-			} else {
-				logger.Error(err, "Failed to get FlexDaemonSetNodePod during create/update check", "fdnpName", fdnpName)
-				// Consider requeue or continue
-				continue // Move to the next node
+		// --- Create FlexDaemonSetNodePod ---
+		logger.Info("Creating FlexDaemonSetNodePod for uncovered node", "fdnpName", fdnpName, "nodeName", node.Name)
+		newFdnp := &flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fdnpName,
+				Namespace: fdnpNamespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(ds, appsv1.SchemeGroupVersion.WithKind("DaemonSet")),
+				},
+			},
+			Spec: flexdaemonsetsv1alpha1.FlexDaemonSetNodePodSpec{
+				DaemonSetName:                   ds.Name,
+				DaemonSetNamespace:              ds.Namespace,
+				NodeName:                        node.Name,
+				ObservedDaemonSetTemplateGeneration: ds.Generation, // Use DS metadata.generation
+				Resources:                       fdnpSpecResources,
+			},
+		}
+		setNodeOverrideAnnotation(&newFdnp.ObjectMeta, override)
+		if createErr := r.Create(ctx, newFdnp); createErr != nil {
+			logger.Error(createErr, "Failed to create FlexDaemonSetNodePod", "fdnpName", fdnpName)
+			r.eventf(ds, corev1.EventTypeWarning, ReasonFDNPCreateFailed, "Failed to create FlexDaemonSetNodePod %s for node %s: %v", fdnpName, node.Name, createErr)
+			// Returned so the request is requeued instead of failing silently.
+			errs = append(errs, fmt.Errorf("creating FlexDaemonSetNodePod %s/%s: %w", fdnpNamespace, fdnpName, createErr))
+		} else {
+			r.eventf(ds, corev1.EventTypeNormal, ReasonFDNPCreated, "Created FlexDaemonSetNodePod %s for uncovered node %s with template %s: %s",
+				fdnpName, node.Name, fdsTemplate.Name, calculation.Describe())
+			if override != nil {
+				r.eventf(override, corev1.EventTypeNormal, ReasonNodeOverrideApplied, "Sized FlexDaemonSetNodePod %s/%s on node %s: %s", fdnpNamespace, fdnpName, node.Name, calculation.Describe())
 			}
 		}
+		return utilerrors.NewAggregate(errs)
+	}
 
-		// --- Update FlexDaemonSetNodePod if it exists ---
-		// Compare ObservedDaemonSetTemplateGeneration and Resources
-		// Note: For ResourceRequirements, reflect.DeepEqual is reliable.
-		needsUpdate := false
-		if existingFdnp.Spec.ObservedDaemonSetTemplateGeneration != ds.Generation {
-			logger.Info("Update needed: ObservedDaemonSetTemplateGeneration changed",
-				"fdnpName", existingFdnp.Name,
-				"oldGeneration", existingFdnp.Spec.ObservedDaemonSetTemplateGeneration,
-				"newGeneration", ds.Generation)
-			needsUpdate = true
-		}
+	// --- Update FlexDaemonSetNodePod if it exists ---
+	// Compare ObservedDaemonSetTemplateGeneration and Resources
+	// Note: For ResourceRequirements, reflect.DeepEqual is reliable.
+	needsUpdate := false
+	if existingFdnp.Spec.ObservedDaemonSetTemplateGeneration != ds.Generation {
+		logger.Info("Update needed: ObservedDaemonSetTemplateGeneration changed",
+			"fdnpName", existingFdnp.Name,
+			"oldGeneration", existingFdnp.Spec.ObservedDaemonSetTemplateGeneration,
+			"newGeneration", ds.Generation)
+		needsUpdate = true
+	}
 
-		if !reflect.DeepEqual(existingFdnp.Spec.Resources, fdnpSpecResources) {
-			logger.Info("Update needed: Resources changed",
-				"fdnpName", existingFdnp.Name,
-				"oldResources", existingFdnp.Spec.Resources,
-				"newResources", fdnpSpecResources)
-			needsUpdate = true
+	if !reflect.DeepEqual(existingFdnp.Spec.Resources, fdnpSpecResources) {
+		logger.Info("Update needed: Resources changed",
+			"fdnpName", existingFdnp.Name,
+			"oldResources", existingFdnp.Spec.Resources,
+			"newResources", fdnpSpecResources)
+		needsUpdate = true
+	}
+
+	if existingFdnp.Annotations[utils.NodeOverrideAnnotation] != nodeOverrideName(override) {
+		logger.Info("Update needed: FlexNodeOverride changed",
+			"fdnpName", existingFdnp.Name,
+			"oldOverride", existingFdnp.Annotations[utils.NodeOverrideAnnotation],
+			"newOverride", nodeOverrideName(override))
+		needsUpdate = true
+	}
+
+	if needsUpdate && state.dryRun != "" {
+		r.reportDryRun(ctx, state, existingFdnp.Name, "Would update FlexDaemonSetNodePod %s for node %s with template %s: %s",
+			existingFdnp.Name, node.Name, fdsTemplate.Name, calculation.Describe())
+	} else if needsUpdate {
+		logger.Info("Updating existing FlexDaemonSetNodePod", "fdnpName", existingFdnp.Name)
+		updatedFdnp := existingFdnp.DeepCopy() // Work on a copy
+		updatedFdnp.Spec.ObservedDaemonSetTemplateGeneration = ds.Generation
+		updatedFdnp.Spec.Resources = fdnpSpecResources
+		setNodeOverrideAnnotation(&updatedFdnp.ObjectMeta, override)
+		// Ensure owner reference is still correct (though it should be immutable if set correctly at creation)
+		updatedFdnp.OwnerReferences = []metav1.OwnerReference{
+			*metav1.NewControllerRef(ds, appsv1.SchemeGroupVersion.WithKind("DaemonSet")),
 		}
 
-		if needsUpdate {
-			logger.Info("Updating existing FlexDaemonSetNodePod", "fdnpName", existingFdnp.Name)
-			updatedFdnp := existingFdnp.DeepCopy() // Work on a copy
-			updatedFdnp.Spec.ObservedDaemonSetTemplateGeneration = ds.Generation
-			updatedFdnp.Spec.Resources = fdnpSpecResources
-			// Ensure owner reference is still correct (though it should be immutable if set correctly at creation)
-			updatedFdnp.OwnerReferences = []metav1.OwnerReference{
-				*metav1.NewControllerRef(ds, appsv1.SchemeGroupVersion.WithKind("DaemonSet")),
+		if updateErr := r.Update(ctx, updatedFdnp); updateErr != nil {
+			logger.Error(updateErr, "Failed to update FlexDaemonSetNodePod", "fdnpName", updatedFdnp.Name)
+			r.eventf(ds, corev1.EventTypeWarning, ReasonFDNPUpdateFailed, "Failed to update FlexDaemonSetNodePod %s for node %s: %v", updatedFdnp.Name, node.Name, updateErr)
+			errs = append(errs, fmt.Errorf("updating FlexDaemonSetNodePod %s/%s: %w", updatedFdnp.Namespace, updatedFdnp.Name, updateErr))
+		} else {
+			r.eventf(ds, corev1.EventTypeNormal, ReasonFDNPUpdated, "Updated FlexDaemonSetNodePod %s for node %s with template %s: %s",
+				updatedFdnp.Name, node.Name, fdsTemplate.Name, calculation.Describe())
+			if override != nil {
+				r.eventf(override, corev1.EventTypeNormal, ReasonNodeOverrideApplied, "Sized FlexDaemonSetNodePod %s/%s on node %s: %s", updatedFdnp.Namespace, updatedFdnp.Name, node.Name, calculation.Describe())
 			}
+		}
+	} else {
+		logger.V(1).Info("No update needed for existing FlexDaemonSetNodePod", "fdnpName", existingFdnp.Name)
+		r.dryRunActions.forget(existingFdnp.Namespace + "/" + existingFdnp.Name)
+	}
+	return utilerrors.NewAggregate(errs)
+}
 
-			if updateErr := r.Update(ctx, updatedFdnp); updateErr != nil {
-				logger.Error(updateErr, "Failed to update FlexDaemonSetNodePod", "fdnpName", updatedFdnp.Name)
-				// Consider requeue
+// fdnpCoversNode reports whether the FlexDaemonSetNodePod covers the DaemonSet on the named node.
+func fdnpCoversNode(fdnp *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, ds *appsv1.DaemonSet, nodeName string) bool {
+	return fdnp.Spec.DaemonSetNamespace == ds.Namespace && fdnp.Spec.DaemonSetName == ds.Name && fdnp.Spec.NodeName == nodeName
+}
+
+// handOverManagedPods relabels the pods of the legacy FlexDaemonSetNodePod for the one named newName and removes
+// the legacy owner reference, so deleting the legacy FDNP does not garbage collect them and the new FDNP adopts them
+// (see claimPods). Pods controlled by anything else are left alone.
+func (r *NodeCoverageReconciler) handOverManagedPods(ctx context.Context, legacy *flexdaemonsetsv1alpha1.FlexDaemonSetNodePod, newName string) error {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(legacy.Namespace), client.MatchingLabels{LabelManagedBy: FlexDaemonSetNodePodControllerName, LabelOwnerCR: legacy.Name}); err != nil {
+		return fmt.Errorf("listing pods of FlexDaemonSetNodePod %s/%s: %w", legacy.Namespace, legacy.Name, err)
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if ref := metav1.GetControllerOf(pod); ref != nil && ref.UID != legacy.UID {
+			continue
+		}
+		original := pod.DeepCopy()
+		pod.Labels[LabelOwnerCR] = newName
+		refs := make([]metav1.OwnerReference, 0, len(pod.OwnerReferences))
+		for _, ref := range pod.OwnerReferences {
+			if ref.UID != legacy.UID {
+				refs = append(refs, ref)
 			}
-		} else {
-			logger.V(1).Info("No update needed for existing FlexDaemonSetNodePod", "fdnpName", existingFdnp.Name)
 		}
-	} // End loop over nodes
+		pod.OwnerReferences = refs
+		if err := r.Patch(ctx, pod, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("handing pod %s/%s over to FlexDaemonSetNodePod %s: %w", pod.Namespace, pod.Name, newName, err)
+		}
+	}
+	return nil
+}
 
-	return ctrl.Result{}, nil
+// reportDryRun records a write to the named FlexDaemonSetNodePod skipped because of dry-run mode as an event on the
+// DaemonSet and in the dry-run metric. A write that was already planned on an earlier pass is only logged.
+func (r *NodeCoverageReconciler) reportDryRun(ctx context.Context, state *coverageState, fdnpName, messageFmt string, args ...interface{}) {
+	message := fmt.Sprintf(messageFmt, args...)
+	logger := log.FromContext(ctx).WithValues("daemonset", client.ObjectKeyFromObject(state.ds).String(), "reason", state.dryRun, "action", message)
+	if !r.dryRunActions.changed(state.ds.Namespace+"/"+fdnpName, message) {
+		logger.V(1).Info("Dry-run, still skipping FlexDaemonSetNodePod write")
+		return
+	}
+	logger.Info("Dry-run, skipping FlexDaemonSetNodePod write")
+	metrics.DryRunDecisions.WithLabelValues(state.template.Name, metrics.DryRunComponentNodeCoverage).Inc()
+	r.eventf(state.ds, corev1.EventTypeNormal, ReasonDryRun, "%s (%s)", message, state.dryRun)
+}
+
+// eventf records an event on the given object if an event recorder is configured.
+func (r *NodeCoverageReconciler) eventf(obj runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(obj, eventType, reason, messageFmt, args...)
 }
 
 // findDaemonSetsForNode is a handler.MapFunc that finds all DaemonSets with the
-// FlexDaemonsetTemplateAnnotation and returns reconcile.Requests for them.
-// This is used when a Node event occurs, to trigger reconciliation for all relevant DaemonSets.
+// FlexDaemonsetTemplateAnnotation and returns one per-node reconcile.Request for each of them,
+// so that a Node event only re-evaluates coverage on that node.
 func (r *NodeCoverageReconciler) findDaemonSetsForNode(ctx context.Context, nodeObj client.Object) []reconcile.Request {
 	logger := log.FromContext(ctx)
 	node, ok := nodeObj.(*corev1.Node)
@@ -233,27 +546,38 @@ func (r *NodeCoverageReconciler) findDaemonSetsForNode(ctx context.Context, node
 		return nil
 	}
 
-	logger.Info("Node event triggered findDaemonSetsForNode", "nodeName", node.Name)
+	logger.V(1).Info("Node event triggered findDaemonSetsForNode", "nodeName", node.Name)
 
 	var daemonSetList appsv1.DaemonSetList
-	if err := r.List(ctx, &daemonSetList); err != nil {
+	if err := r.List(ctx, &daemonSetList, client.MatchingFields{DaemonSetTemplateIndex: AnyTemplateIndexValue}); err != nil {
 		logger.Error(err, "Failed to list DaemonSets in findDaemonSetsForNode")
 		return nil
 	}
 
-	requests := make([]reconcile.Request, 0)
+	requests := make([]reconcile.Request, 0, len(daemonSetList.Items))
 	for _, ds := range daemonSetList.Items {
-		if _, ok := ds.Annotations[utils.FlexDaemonsetTemplateAnnotation]; ok {
-			requests = append(requests, reconcile.Request{
-				NamespacedName: types.NamespacedName{
-					Name:      ds.Name,
-					Namespace: ds.Namespace,
-				},
-			})
-		}
+		requests = append(requests, nodeCoverageRequest(ds.Namespace, ds.Name, node.Name))
 	}
 	if len(requests) > 0 {
-		logger.Info("Mapping Node event to DaemonSet requests", "nodeName", node.Name, "numberOfDaemonSets", len(requests))
+		logger.Info("Mapping Node event to per-node DaemonSet requests", "nodeName", node.Name, "numberOfDaemonSets", len(requests))
+	}
+	return requests
+}
+
+// findDaemonSetsForTemplate is a handler.MapFunc that maps a FlexDaemonsetTemplate to the DaemonSets that
+// reference it, so that changing a template resizes the FDNPs of every DaemonSet using it.
+func (r *NodeCoverageReconciler) findDaemonSetsForTemplate(ctx context.Context, templateObj client.Object) []reconcile.Request {
+	logger := log.FromContext(ctx)
+
+	var daemonSetList appsv1.DaemonSetList
+	if err := r.List(ctx, &daemonSetList, client.MatchingFields{DaemonSetTemplateIndex: templateObj.GetName()}); err != nil {
+		logger.Error(err, "Failed to list DaemonSets in findDaemonSetsForTemplate", "templateName", templateObj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(daemonSetList.Items))
+	for _, ds := range daemonSetList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: ds.Namespace, Name: ds.Name}})
 	}
 	return requests
 }
@@ -277,29 +601,6 @@ func (r *NodeCoverageReconciler) isNodeSchedulable(node *corev1.Node) bool {
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *NodeCoverageReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	// Index FlexDaemonSetNodePod by NodeName for efficient lookup if needed by other controllers
-	// or for more complex logic within this controller (not strictly used by current simple reconcile path).
-	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}, ".spec.nodeName", func(rawObj client.Object) []string {
-		fdnp := rawObj.(*flexdaemonsetsv1alpha1.FlexDaemonSetNodePod)
-		if fdnp.Spec.NodeName == "" {
-			return nil
-		}
-		return []string{fdnp.Spec.NodeName}
-	}); err != nil {
-		return err
-	}
-
-	// Index FlexDaemonSetNodePod by DaemonSet namespaced name for efficient lookup
-	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}, ".spec.daemonSetNamespacedName", func(rawObj client.Object) []string {
-		fdnp := rawObj.(*flexdaemonsetsv1alpha1.FlexDaemonSetNodePod)
-		if fdnp.Spec.DaemonSetName == "" || fdnp.Spec.DaemonSetNamespace == "" {
-			return nil
-		}
-		return []string{fdnp.Spec.DaemonSetNamespace + "/" + fdnp.Spec.DaemonSetName}
-	}); err != nil {
-		return err
-	}
-	
 	// Predicate for DaemonSets: react to create, update (annotation change, spec change affecting template generation).
 	// Using AnnotationChangedPredicate for the specific annotation.
 	// Also react to spec changes that change metadata.generation (which we use for ObservedDaemonSetTemplateGeneration)
@@ -317,9 +618,21 @@ func (r *NodeCoverageReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Watches(
 			&corev1.Node{},
 			handler.EnqueueRequestsFromMapFunc(r.findDaemonSetsForNode),
-			// React to node becoming schedulable/unschedulable, label changes, etc.
-			// ResourceVersionChangedPredicate is a bit broad, might need more specific node predicates later.
-			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}),
+			// Only react to changes that can affect coverage (labels, taints, unschedulable, allocatable, readiness),
+			// not to the periodic heartbeat status updates every node produces.
+			builder.WithPredicates(nodeCoveragePredicate),
+		).
+		// Template spec changes alter the calculated resources of every DaemonSet referencing the template.
+		Watches(
+			&flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{},
+			handler.EnqueueRequestsFromMapFunc(r.findDaemonSetsForTemplate),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		// Overrides are re-evaluated when they are created, changed or deleted, and when they expire
+		// (the FlexNodeOverrideReconciler then flips their Active condition).
+		Watches(
+			&flexdaemonsetsv1alpha1.FlexNodeOverride{},
+			handler.EnqueueRequestsFromMapFunc(r.findDaemonSetsForNodeOverride),
 		).
 		// We are creating FlexDaemonSetNodePod, so Owns could be used if FDNP changes should re-trigger reconciliation of the DS.
 		// However, the primary trigger for FDNP creation/update is DS or Node state.
@@ -327,6 +640,7 @@ func (r *NodeCoverageReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		// For now, we explicitly create/update FDNPs. If an FDNP is deleted externally, this reconciler
 		// should recreate it on the next DS/Node reconciliation pass.
 		// Owns(&flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Complete(r)
 }
 
@@ -335,13 +649,13 @@ func (r *NodeCoverageReconciler) SetupWithManager(mgr ctrl.Manager) error {
 // The OwnerReferences for FDNP should point to the DS.
 // The ObservedDaemonSetTemplateGeneration in FDNP spec should be ds.Generation.
 // The reconciliation for a DaemonSet should list *all* nodes and check coverage.
-// The reconciliation for a Node (via findDaemonSetsForNode) triggers DS reconciliation, which is fine.
+// The reconciliation for a Node (via findDaemonSetsForNode) is keyed per (DaemonSet, node) and only touches that node.
 // Consider using Server-Side Apply for creating/updating FDNPs for better conflict management.
 // client.Patch(ctx, fdnp, client.Apply, client.FieldOwner("node-coverage-controller"))
 // Need to ensure the controller has permissions to update DaemonSet status if that becomes necessary. (Not currently updating DS status).
 // The current dsPredicate for DaemonSets (AnnotationChangedPredicate and GenerationChangedPredicate) is a good start.
-// The Node predicate (ResourceVersionChangedPredicate) is broad; could be refined e.g. specific label changes or status changes.
+// The Node predicate (nodeCoveragePredicate) ignores heartbeat-only updates; extend nodeCoverageChanged if new node fields matter.
 // The isNodeSchedulable logic is basic; real DS scheduling involves taints/tolerations, node selectors, affinity/anti-affinity.
 // This will be refined in subsequent steps.
-// The name for FlexDaemonSetNodePod (dsname-nodename) seems reasonable.
+// The name for FlexDaemonSetNodePod is dsname-nodename, truncated and hashed by utils.FlexDaemonSetNodePodName when too long.
 // Namespace for FDNP is correctly set to ds.Namespace.
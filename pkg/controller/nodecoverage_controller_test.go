@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+// longNode is a node name long enough for the legacy "<daemonset>-<node>" FDNP name to exceed the label limit.
+const longNode = "ip-10-0-0-1.eu-west-1.compute.internal-with-a-rather-long-suffix"
+
+func testTemplateObject() *flexdaemonsetsv1alpha1.FlexDaemonsetTemplate {
+	return &flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: testTemplate},
+		Spec:       flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec{CPUPercentage: 10, MemoryPercentage: 10, StoragePercentage: 10},
+	}
+}
+
+func testAllocatableNode(name string) *corev1.Node {
+	node := testNodeObject(name)
+	node.Status.Allocatable = corev1.ResourceList{
+		corev1.ResourceCPU:              resource.MustParse("4"),
+		corev1.ResourceMemory:           resource.MustParse("8Gi"),
+		corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+	}
+	return node
+}
+
+// An FDNP created under the legacy name is replaced without its running pod being deleted: the pod is handed over
+// to the new FDNP, which adopts it instead of creating another one.
+func TestReconcileNodeMigratesLegacyFDNP(t *testing.T) {
+	ctx := context.Background()
+	legacy := testFDNPObject()
+	legacy.Name = testDaemonSet + "-" + longNode
+	legacy.UID = "legacy-uid"
+	legacy.Spec.NodeName = longNode
+	legacyPod := testManagedPod(legacy, "legacy-pod", true)
+	otherPod := testManagedPod(legacy, "foreign-pod", false)
+	otherPod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "ReplicationController", Name: "other", UID: "other-uid", Controller: &[]bool{true}[0]}}
+	c := newTestClient(t, testDaemonSetObject(), testTemplateObject(), testAllocatableNode(longNode), legacy, legacyPod, otherPod)
+	r := &NodeCoverageReconciler{Client: c, Scheme: c.Scheme()}
+
+	if _, err := r.Reconcile(ctx, nodeCoverageRequest(testNamespace, testDaemonSet, longNode)); err != nil {
+		t.Fatal(err)
+	}
+
+	newName := utils.FlexDaemonSetNodePodName(testDaemonSet, longNode)
+	if newName == legacy.Name {
+		t.Fatalf("test node name too short: %s is not a legacy name", legacy.Name)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(legacy), &flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}); !errors.IsNotFound(err) {
+		t.Errorf("legacy FDNP still present: %v", err)
+	}
+	fdnp := &flexdaemonsetsv1alpha1.FlexDaemonSetNodePod{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: testNamespace, Name: newName}, fdnp); err != nil {
+		t.Fatalf("new FDNP: %v", err)
+	}
+
+	handedOver := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(legacyPod), handedOver); err != nil {
+		t.Fatalf("legacy pod deleted: %v", err)
+	}
+	if handedOver.Labels[LabelOwnerCR] != newName || metav1.GetControllerOf(handedOver) != nil {
+		t.Errorf("legacy pod not handed over: labels %v, owners %v", handedOver.Labels, handedOver.OwnerReferences)
+	}
+	untouched := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(otherPod), untouched); err != nil {
+		t.Fatal(err)
+	}
+	if untouched.Labels[LabelOwnerCR] != legacy.Name {
+		t.Errorf("pod controlled by another owner was relabelled: %v", untouched.Labels)
+	}
+
+	fdnpReconciler := &FlexDaemonSetNodePodReconciler{Client: c, Scheme: c.Scheme()}
+	if _, err := fdnpReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(fdnp)}); err != nil {
+		t.Fatal(err)
+	}
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.InNamespace(testNamespace), client.MatchingLabels{LabelOwnerCR: newName}); err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Items) != 1 || pods.Items[0].Name != legacyPod.Name {
+		t.Fatalf("pods of the new FDNP = %d, want only the adopted legacy pod", len(pods.Items))
+	}
+	if ref := metav1.GetControllerOf(&pods.Items[0]); ref == nil || ref.UID != fdnp.UID {
+		t.Errorf("legacy pod not adopted by the new FDNP: owners %v", pods.Items[0].OwnerReferences)
+	}
+}
+
+func TestReconcileNodeReportsNameCollision(t *testing.T) {
+	ctx := context.Background()
+	// DaemonSet "node" on node "exporter-node-a" gets the same short name as node-exporter on node-a.
+	taken := testFDNPObject()
+	taken.Spec.DaemonSetName = "node"
+	taken.Spec.NodeName = "exporter-node-a"
+	legacy := testFDNPObject()
+	legacy.Name, legacy.UID = "node-exporter-node-a-old", "legacy-uid"
+	legacyPod := testManagedPod(legacy, "legacy-pod", true)
+	c := newTestClient(t, testDaemonSetObject(), testTemplateObject(), testAllocatableNode(testNode), taken, legacy, legacyPod)
+	recorder := record.NewFakeRecorder(10)
+	r := &NodeCoverageReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder}
+
+	if _, err := r.Reconcile(ctx, nodeCoverageRequest(testNamespace, testDaemonSet, testNode)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := getFDNP(t, c, taken); got.Spec.DaemonSetName != "node" || got.Spec.NodeName != "exporter-node-a" {
+		t.Errorf("colliding FDNP overwritten: %+v", got.Spec)
+	}
+	// Without a name to migrate to, the legacy FDNP and its pod stay.
+	getFDNP(t, c, legacy)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(legacyPod), &corev1.Pod{}); err != nil {
+		t.Errorf("legacy pod: %v", err)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, ReasonFDNPNameCollision) {
+			t.Errorf("event = %q, want %s", event, ReasonFDNPNameCollision)
+		}
+	default:
+		t.Errorf("no %s event", ReasonFDNPNameCollision)
+	}
+}
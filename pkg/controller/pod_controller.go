@@ -4,6 +4,7 @@ import (
 	"context"
 	// "encoding/json" // For creating patches if needed - client.Patch with MergeFrom handles this
 	"fmt"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1" // To check DaemonSet owner reference
 	corev1 "k8s.io/api/core/v1"
@@ -11,29 +12,63 @@ import (
 	// metav1 "k8s.io/apimachinery/pkg/apis/meta/v1" // Not strictly needed for SchemeGroupVersion
 	"k8s.io/apimachinery/pkg/runtime" // Added for runtime.Scheme
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	// "sigs.k8s.io/controller-runtime/pkg/predicate" // If complex predicates are needed
 
 	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/audit"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/metrics"
 	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
 )
 
 const (
 	PodApplyTemplateAnnotation = "flexdaemonsets.xai/apply-template" // From webhook
+
+	PodControllerName = "FlexDaemonSetPodController"
+
+	// pausedRecheckInterval is how often a pod held back by a pause is re-evaluated.
+	pausedRecheckInterval = time.Minute
+
+	// Event reasons for sizing decisions.
+	ReasonResourcesApplied  = "ResourcesApplied"
+	ReasonCalculationFailed = "CalculationFailed"
+	ReasonTemplateNotFound  = "TemplateNotFound"
+	ReasonPodPatchFailed    = "PodPatchFailed"
+	ReasonTemplateApplied   = "TemplateApplied"
+	ReasonDryRun            = "DryRun"
 )
 
 // PodReconciler reconciles a Pod object
 type PodReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme // Changed from *ctrl.Scheme
+
+	// Recorder emits Kubernetes events on pods and templates describing sizing decisions.
+	Recorder record.EventRecorder
+
+	// AuditSink, if set, receives a copy of every sizing decision recorded on a pod.
+	AuditSink audit.Sink
+
+	// TemplateDefaults fill in the fields templates leave unset before sizing.
+	TemplateDefaults utils.TemplateDefaults
+
+	// DryRun calculates resources but never patches pods, as if every template were in dry-run mode.
+	DryRun bool
+
+	// MaxConcurrentReconciles is the number of pods reconciled in parallel. Zero uses controller-runtime's default of 1.
+	MaxConcurrentReconciles int
 }
 
 // +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
 // +kubebuilder:rbac:groups=flexdaemonsets.xai,resources=flexdaemonsettemplates,verbs=get;list;watch
-// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch // Needed to verify DS ownership if desired
+// +kubebuilder:rbac:groups=flexdaemonsets.xai,resources=flexnodeoverrides,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx).WithValues("pod", req.NamespacedName)
@@ -67,9 +102,11 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 
 	// 2. Verify it's a DaemonSet pod (optional but good for safety)
 	isDaemonSetPod := false
+	daemonSetName := ""
 	for _, ownerRef := range pod.OwnerReferences {
 		if ownerRef.APIVersion == appsv1.SchemeGroupVersion.String() && ownerRef.Kind == "DaemonSet" {
 			isDaemonSetPod = true
+			daemonSetName = ownerRef.Name
 			break
 		}
 	}
@@ -84,11 +121,30 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		}
 		if err := r.Patch(ctx, podToUpdate, client.MergeFrom(pod)); err != nil {
 			logger.Error(err, "Failed to patch Pod to remove annotation for non-DaemonSet pod")
+			metrics.PodPatchFailures.WithLabelValues(pod.Namespace, templateName).Inc()
+			r.eventf(pod, corev1.EventTypeWarning, ReasonPodPatchFailed, "Failed to remove annotation %s: %v", PodApplyTemplateAnnotation, err)
 			return ctrl.Result{}, err
 		}
 		logger.Info("Removed apply-template annotation from non-DaemonSet pod.")
 		return ctrl.Result{}, nil
 	}
+
+	// A DaemonSet that is being released is restored by the NodeCoverageReconciler; pods marked before the
+	// release must not be sized anymore.
+	owningDS := &appsv1.DaemonSet{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: daemonSetName}, owningDS); err != nil {
+		if errors.IsNotFound(err) {
+			logger.Info("Owning DaemonSet not found, skipping", "daemonSet", daemonSetName)
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to get owning DaemonSet", "daemonSet", daemonSetName)
+		return ctrl.Result{}, err
+	}
+	if needsRelease(owningDS) {
+		logger.Info("Owning DaemonSet is released from flex management, skipping", "daemonSet", daemonSetName)
+		return ctrl.Result{}, nil
+	}
+
 	logger.Info("Processing DaemonSet pod for resource allocation", "nodeName", pod.Spec.NodeName, "templateName", templateName)
 
 	// 3. Fetch the FlexDaemonsetTemplate
@@ -97,6 +153,7 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	if err != nil {
 		if errors.IsNotFound(err) {
 			logger.Error(err, "FlexDaemonsetTemplate not found. Cannot apply resources. Annotation will remain for now.", "templateName", templateName)
+			r.eventf(pod, corev1.EventTypeWarning, ReasonTemplateNotFound, "FlexDaemonsetTemplate %s not found; resources were not changed", templateName)
 			// Consider removing the annotation from the pod if the template is permanently gone
 			return ctrl.Result{}, nil // Don't requeue if template is not found
 		}
@@ -104,6 +161,14 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{}, err
 	}
 
+	// Sizing is frozen while the DaemonSet is paused or the template is suspended. Resuming does not generate a
+	// pod event, so check back periodically; the apply-template annotation keeps the pod eligible.
+	if reason := utils.PauseReason(owningDS, flexTemplate); reason != "" {
+		logger.Info("Sizing is paused, not resizing pod", "reason", reason)
+		r.eventf(pod, corev1.EventTypeNormal, ReasonSizingPaused, "Resources were not changed: %s", reason)
+		return ctrl.Result{RequeueAfter: pausedRecheckInterval}, nil
+	}
+
 	// 4. Fetch the Node
 	node := &corev1.Node{}
 	err = r.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, node)
@@ -117,11 +182,30 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	}
 
 	// 5. Calculate Resources
-	calculatedResources, err := utils.CalculatePodResources(&flexTemplate.Spec, node.Status.Allocatable)
+	allocatable := effectiveAllocatable(ctx, r.Recorder, node)
+	calculation, err := utils.CalculatePodResourcesWithBounds(r.TemplateDefaults.Apply(&flexTemplate.Spec), allocatable)
 	if err != nil {
 		logger.Error(err, "Failed to calculate pod resources")
+		r.eventf(pod, corev1.EventTypeWarning, ReasonCalculationFailed, "Failed to calculate resources from template %s for node %s: %v", templateName, node.Name, err)
+		r.eventf(flexTemplate, corev1.EventTypeWarning, ReasonCalculationFailed, "Failed to calculate resources for pod %s/%s on node %s: %v", pod.Namespace, pod.Name, node.Name, err)
 		return ctrl.Result{}, err // Requeue to retry calculation if it was a transient error
 	}
+	// A FlexNodeOverride for this node has the last word over the template.
+	override, err := applyNodeOverride(ctx, r, r.Recorder, calculation, node, allocatable, owningDS, templateName)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	// Record what is applied, including the override.
+	metrics.RecordCalculation(templateName, calculation)
+	calculatedResources := calculation.Resources
+
+	// Pods marked before dry-run was turned on keep their resources and their apply-template annotation, so they
+	// are sized once it is turned off. Like a pause, turning it off does not generate a pod event.
+	if reason := utils.DryRunReason(r.DryRun, flexTemplate); reason != "" {
+		logger.Info("Dry-run, not resizing pod", "reason", reason, "resources", calculation.Describe())
+		r.eventf(pod, corev1.EventTypeNormal, ReasonDryRun, "Would apply template %s for node %s: %s (%s)", templateName, node.Name, calculation.Describe(), reason)
+		return ctrl.Result{RequeueAfter: pausedRecheckInterval}, nil
+	}
 
 	// Prepare for patching
 	originalPod := pod.DeepCopy() // For creating a patch
@@ -138,9 +222,12 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		// Even if no resources to apply, we must patch to remove the annotation.
 		if err := r.Patch(ctx, podToPatch, client.MergeFrom(originalPod)); err != nil {
 			logger.Error(err, "Failed to patch Pod to remove annotation after empty resources")
+			metrics.PodPatchFailures.WithLabelValues(pod.Namespace, templateName).Inc()
+			r.eventf(pod, corev1.EventTypeWarning, ReasonPodPatchFailed, "Failed to remove annotation %s: %v", PodApplyTemplateAnnotation, err)
 			return ctrl.Result{}, err
 		}
 		logger.Info("Successfully removed annotation from Pod after processing with empty resources.")
+		r.eventf(pod, corev1.EventTypeNormal, ReasonResourcesApplied, "Template %s calculated no resources for node %s; resources were not changed", templateName, node.Name)
 		return ctrl.Result{}, nil
 	}
 
@@ -178,26 +265,54 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		podToPatch.Annotations = make(map[string]string)
 	}
 	delete(podToPatch.Annotations, PodApplyTemplateAnnotation)
-	if len(podToPatch.Annotations) == 0 { // If it was the only annotation
-		podToPatch.Annotations = nil // Set to nil to remove the annotations field itself
+
+	// Record how the resources were derived, including what each container originally asked for,
+	// so the decision can be reproduced and reversed.
+	decision := audit.NewDecision(originalPod, node, templateName, flexTemplate.Generation, calculation)
+	encodedDecision, err := decision.Encode()
+	if err != nil {
+		logger.Error(err, "Failed to encode sizing decision")
+		return ctrl.Result{}, err
 	}
+	podToPatch.Annotations[audit.DecisionAnnotation] = encodedDecision
+	setNodeOverrideAnnotation(&podToPatch.ObjectMeta, override)
 
 	if err := r.Patch(ctx, podToPatch, client.MergeFrom(originalPod)); err != nil {
 		logger.Error(err, "Failed to patch Pod to apply resources and remove annotation")
+		metrics.PodPatchFailures.WithLabelValues(pod.Namespace, templateName).Inc()
+		r.eventf(pod, corev1.EventTypeWarning, ReasonPodPatchFailed, "Failed to apply resources from template %s (%s): %v", templateName, calculation.Describe(), err)
 		return ctrl.Result{}, err
 	}
 
 	logger.Info("Successfully applied resources and removed annotation from Pod.")
+	if r.AuditSink != nil {
+		if err := r.AuditSink.Record(decision); err != nil {
+			// The decision is already on the pod; a failing sink must not block sizing.
+			logger.Error(err, "Failed to write sizing decision to audit sink")
+		}
+	}
+	r.eventf(pod, corev1.EventTypeNormal, ReasonResourcesApplied, "Applied template %s for node %s: %s", templateName, node.Name, calculation.Describe())
+	r.eventf(flexTemplate, corev1.EventTypeNormal, ReasonTemplateApplied, "Sized pod %s/%s on node %s: %s", pod.Namespace, pod.Name, node.Name, calculation.Describe())
+	if override != nil {
+		r.eventf(override, corev1.EventTypeNormal, ReasonNodeOverrideApplied, "Sized pod %s/%s on node %s: %s", pod.Namespace, pod.Name, node.Name, calculation.Describe())
+	}
 	return ctrl.Result{}, nil
 }
 
+// eventf records an event on the given object if an event recorder is configured.
+func (r *PodReconciler) eventf(obj runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(obj, eventType, reason, messageFmt, args...)
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *PodReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Pod{}).
-		// Optionally, use Owns() or Watches() for more complex scenarios,
-		// or Predicates to filter events before they hit Reconcile.
-		// For example, a predicate could filter for pods with the annotation.
-		// WithEventFilter(predicate.AnnotationChangedPredicate{Annotations: []string{PodApplyTemplateAnnotation}}). // Example
+		// Only pods with the apply-template annotation or controlled by a flex DaemonSet are enqueued;
+		// every other pod in the cluster is filtered out before it reaches the work queue.
+		For(&corev1.Pod{}, builder.WithPredicates(flexPodPredicate(mgr.GetCache()))).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Complete(r)
 }
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+// nodeCoveragePredicate filters Node events down to the changes that can affect where a DaemonSet runs
+// or how much a FlexDaemonSetNodePod gets: labels, taints, unschedulable, allocatable (including the flex-base and
+// reserved annotations) and readiness.
+// Kubelet status heartbeats, which only bump condition timestamps and resourceVersion, are dropped.
+var nodeCoveragePredicate = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool { return true },
+	DeleteFunc: func(e event.DeleteEvent) bool { return true },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNode, okOld := e.ObjectOld.(*corev1.Node)
+		newNode, okNew := e.ObjectNew.(*corev1.Node)
+		if !okOld || !okNew {
+			return false
+		}
+		return nodeCoverageChanged(oldNode, newNode)
+	},
+	GenericFunc: func(e event.GenericEvent) bool { return false },
+}
+
+// nodeCoverageChanged reports whether any node field relevant to coverage or sizing differs.
+func nodeCoverageChanged(oldNode, newNode *corev1.Node) bool {
+	if !equality.Semantic.DeepEqual(oldNode.Labels, newNode.Labels) {
+		return true
+	}
+	if oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable {
+		return true
+	}
+	if !equality.Semantic.DeepEqual(oldNode.Spec.Taints, newNode.Spec.Taints) {
+		return true
+	}
+	if !equality.Semantic.DeepEqual(oldNode.Status.Allocatable, newNode.Status.Allocatable) {
+		return true
+	}
+	for _, annotation := range []string{utils.ReservedAnnotation, utils.FlexBaseAnnotation} {
+		if oldNode.Annotations[annotation] != newNode.Annotations[annotation] {
+			return true
+		}
+	}
+	return nodeReadyStatus(oldNode) != nodeReadyStatus(newNode)
+}
+
+// nodeReadyStatus returns the status of the node's Ready condition, or Unknown if it is not reported.
+func nodeReadyStatus(node *corev1.Node) corev1.ConditionStatus {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status
+		}
+	}
+	return corev1.ConditionUnknown
+}
+
+// flexPodPredicate only admits pods that carry the apply-template annotation or are controlled by a DaemonSet
+// with the FlexDaemonsetTemplateAnnotation. The owning DaemonSet is read from the cache, so the check is cheap.
+func flexPodPredicate(c client.Reader) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		if _, ok := obj.GetAnnotations()[PodApplyTemplateAnnotation]; ok {
+			return true
+		}
+		owner := metav1.GetControllerOf(obj)
+		if owner == nil || owner.Kind != "DaemonSet" || owner.APIVersion != appsv1.SchemeGroupVersion.String() {
+			return false
+		}
+		ds := &appsv1.DaemonSet{}
+		if err := c.Get(context.Background(), types.NamespacedName{Namespace: obj.GetNamespace(), Name: owner.Name}, ds); err != nil {
+			return false
+		}
+		_, ok := ds.Annotations[utils.FlexDaemonsetTemplateAnnotation]
+		return ok && ds.UID == owner.UID
+	})
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+func TestNodeCoveragePredicate(t *testing.T) {
+	node := testAllocatableNode(testNode)
+	node.Status.Conditions = []corev1.NodeCondition{{
+		Type: corev1.NodeReady, Status: corev1.ConditionTrue, LastHeartbeatTime: metav1.Now(),
+	}}
+
+	tests := []struct {
+		name   string
+		mutate func(*corev1.Node)
+		want   bool
+	}{
+		{name: "heartbeat", want: false, mutate: func(n *corev1.Node) {
+			n.ResourceVersion = "2"
+			n.Status.Conditions[0].LastHeartbeatTime = metav1.NewTime(n.Status.Conditions[0].LastHeartbeatTime.Add(10 * time.Second))
+		}},
+		{name: "unrelated annotation", want: false, mutate: func(n *corev1.Node) {
+			n.Annotations = map[string]string{"example.com/note": "x"}
+		}},
+		{name: "label", want: true, mutate: func(n *corev1.Node) { n.Labels = map[string]string{"pool": "gpu"} }},
+		{name: "taint", want: true, mutate: func(n *corev1.Node) {
+			n.Spec.Taints = []corev1.Taint{{Key: "dedicated", Effect: corev1.TaintEffectNoSchedule}}
+		}},
+		{name: "cordoned", want: true, mutate: func(n *corev1.Node) { n.Spec.Unschedulable = true }},
+		{name: "allocatable", want: true, mutate: func(n *corev1.Node) {
+			n.Status.Allocatable[corev1.ResourceCPU] = resource.MustParse("8")
+		}},
+		{name: "reserved annotation", want: true, mutate: func(n *corev1.Node) {
+			n.Annotations = map[string]string{utils.ReservedAnnotation: "cpu=500m"}
+		}},
+		{name: "flex-base annotation", want: true, mutate: func(n *corev1.Node) {
+			n.Annotations = map[string]string{utils.FlexBaseAnnotation: "cpu=2"}
+		}},
+		{name: "readiness", want: true, mutate: func(n *corev1.Node) { n.Status.Conditions[0].Status = corev1.ConditionFalse }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := node.DeepCopy()
+			tt.mutate(updated)
+			if got := nodeCoveragePredicate.Update(event.UpdateEvent{ObjectOld: node, ObjectNew: updated}); got != tt.want {
+				t.Errorf("Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("create and delete", func(t *testing.T) {
+		if !nodeCoveragePredicate.Create(event.CreateEvent{Object: node}) || !nodeCoveragePredicate.Delete(event.DeleteEvent{Object: node}) {
+			t.Error("node creation and deletion must always be reconciled")
+		}
+	})
+	t.Run("not a node", func(t *testing.T) {
+		if nodeCoveragePredicate.Update(event.UpdateEvent{ObjectOld: &corev1.Pod{}, ObjectNew: &corev1.Pod{}}) {
+			t.Error("Update() admitted a non-Node object")
+		}
+	})
+}
+
+func TestNodeReadyStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []corev1.NodeCondition
+		want       corev1.ConditionStatus
+	}{
+		{name: "not reported", want: corev1.ConditionUnknown},
+		{name: "ready", conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}, want: corev1.ConditionTrue},
+		{name: "other conditions only", conditions: []corev1.NodeCondition{{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionTrue}}, want: corev1.ConditionUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &corev1.Node{Status: corev1.NodeStatus{Conditions: tt.conditions}}
+			if got := nodeReadyStatus(node); got != tt.want {
+				t.Errorf("nodeReadyStatus() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindDaemonSetsForNode(t *testing.T) {
+	flex := testDaemonSetObject()
+	plain := testDaemonSetObject()
+	plain.Name, plain.UID, plain.Annotations = "plain", "plain-uid", nil
+	r := &NodeCoverageReconciler{Client: newTestClient(t, flex, plain)}
+
+	requests := r.findDaemonSetsForNode(context.Background(), testAllocatableNode(testNode))
+	if len(requests) != 1 {
+		t.Fatalf("requests = %v, want one for the annotated DaemonSet", requests)
+	}
+	dsKey, nodeName := parseNodeCoverageRequest(requests[0])
+	if dsKey != client.ObjectKeyFromObject(flex) || nodeName != testNode {
+		t.Errorf("request = %v, want %s on node %s", requests[0], client.ObjectKeyFromObject(flex), testNode)
+	}
+	if got := r.findDaemonSetsForNode(context.Background(), &appsv1.DaemonSet{}); got != nil {
+		t.Errorf("non-Node object mapped to %v", got)
+	}
+}
+
+func TestParseNodeCoverageRequest(t *testing.T) {
+	key := types.NamespacedName{Namespace: testNamespace, Name: testDaemonSet}
+	tests := []struct {
+		name     string
+		req      ctrl.Request
+		wantNode string
+	}{
+		{name: "DaemonSet event", req: ctrl.Request{NamespacedName: key}},
+		{name: "node event", req: nodeCoverageRequest(testNamespace, testDaemonSet, testNode), wantNode: testNode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotKey, gotNode := parseNodeCoverageRequest(tt.req)
+			if gotKey != key || gotNode != tt.wantNode {
+				t.Errorf("parseNodeCoverageRequest() = %v, %q, want %v, %q", gotKey, gotNode, key, tt.wantNode)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/audit"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+const (
+	// releaseRecheckInterval is how often an in-progress release is re-evaluated.
+	releaseRecheckInterval = 10 * time.Second
+
+	ReasonReleaseStarted   = "ReleaseStarted"
+	ReasonReleaseCompleted = "ReleaseCompleted"
+	ReasonPodRestored      = "PodRestored"
+	ReasonPodRolled        = "PodRolled"
+	ReasonPodRestoreFailed = "PodRestoreFailed"
+)
+
+// needsRelease reports whether the DaemonSet is no longer (or should no longer be) flex-managed.
+func needsRelease(ds *appsv1.DaemonSet) bool {
+	if _, ok := ds.Annotations[utils.ReleaseAnnotation]; ok {
+		return true
+	}
+	_, managed := ds.Annotations[utils.FlexDaemonsetTemplateAnnotation]
+	return !managed
+}
+
+// reconcileRelease hands a DaemonSet back to the plain DaemonSet controller. It runs when the template annotation
+// has been removed or the release annotation is set:
+//  1. the template annotation is removed, so the webhook stops marking new pods;
+//  2. the DaemonSet's FlexDaemonSetNodePods are deleted (their pods are garbage collected with them);
+//  3. every sized pod is resized in place back to the DaemonSet template's resources, or deleted so the
+//     DaemonSet recreates it if the API server rejects the resize. Pods are deleted one at a time: the next one
+//     only once the previous one's replacement is running and ready (see ReleaseRollingNodeAnnotation);
+//  4. the release annotation is removed and ReleaseStatusAnnotation is set to Completed.
+//
+// DaemonSets that were never flex-managed have no FDNPs and no sized pods and are left untouched.
+func (r *NodeCoverageReconciler) reconcileRelease(ctx context.Context, ds *appsv1.DaemonSet) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("daemonset", client.ObjectKeyFromObject(ds).String())
+
+	_, releaseRequested := ds.Annotations[utils.ReleaseAnnotation]
+	_, stillAnnotated := ds.Annotations[utils.FlexDaemonsetTemplateAnnotation]
+	if stillAnnotated {
+		logger.Info("Release requested, removing template annotation")
+		original := ds.DeepCopy()
+		delete(ds.Annotations, utils.FlexDaemonsetTemplateAnnotation)
+		ds.Annotations[utils.ReleaseStatusAnnotation] = utils.ReleaseStatusInProgress
+		if err := r.Patch(ctx, ds, client.MergeFrom(original)); err != nil {
+			logger.Error(err, "Failed to remove template annotation from DaemonSet")
+			return ctrl.Result{}, err
+		}
+		r.eventf(ds, corev1.EventTypeNormal, ReasonReleaseStarted, "Releasing DaemonSet from flex resource management")
+	}
+
+	var errs []error
+
+	var fdnpList flexdaemonsetsv1alpha1.FlexDaemonSetNodePodList
+	if err := r.List(ctx, &fdnpList, client.InNamespace(ds.Namespace), client.MatchingFields{FDNPDaemonSetIndex: ds.Namespace + "/" + ds.Name}); err != nil {
+		logger.Error(err, "Failed to list FlexDaemonSetNodePods for release")
+		return ctrl.Result{}, err
+	}
+	for i := range fdnpList.Items {
+		fdnp := &fdnpList.Items[i]
+		logger.Info("Deleting FlexDaemonSetNodePod for released DaemonSet", "fdnpName", fdnp.Name)
+		if err := r.Delete(ctx, fdnp); err != nil && !errors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("deleting FlexDaemonSetNodePod %s/%s: %w", fdnp.Namespace, fdnp.Name, err))
+		}
+	}
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(ds.Namespace), client.MatchingFields{PodControllerUIDIndex: string(ds.UID)}); err != nil {
+		logger.Error(err, "Failed to list DaemonSet pods for release")
+		return ctrl.Result{}, err
+	}
+	mayRoll, err := r.releaseRollDone(ctx, ds, pods.Items)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	pending := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !metav1.IsControlledBy(pod, ds) || !hasFlexPodAnnotations(pod) {
+			continue
+		}
+		pending++
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		stillPending, rolled, err := r.restorePod(ctx, ds, pod, mayRoll)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if rolled {
+			mayRoll = false
+		}
+		if !stillPending {
+			pending--
+		}
+	}
+
+	if err := utilerrors.NewAggregate(errs); err != nil {
+		return ctrl.Result{}, err
+	}
+	if pending > 0 || len(fdnpList.Items) > 0 {
+		if ds.Annotations[utils.ReleaseStatusAnnotation] != utils.ReleaseStatusInProgress {
+			if err := r.setReleaseStatus(ctx, ds, utils.ReleaseStatusInProgress, false); err != nil {
+				return ctrl.Result{}, err
+			}
+			if !stillAnnotated {
+				r.eventf(ds, corev1.EventTypeNormal, ReasonReleaseStarted, "Releasing DaemonSet from flex resource management")
+			}
+		}
+		logger.Info("Release in progress", "fdnpsDeleted", len(fdnpList.Items), "podsPending", pending)
+		return ctrl.Result{RequeueAfter: releaseRecheckInterval}, nil
+	}
+
+	if !releaseRequested && ds.Annotations[utils.ReleaseStatusAnnotation] != utils.ReleaseStatusInProgress {
+		// Never managed, or released earlier: nothing to do.
+		return ctrl.Result{}, nil
+	}
+	if err := r.setReleaseStatus(ctx, ds, utils.ReleaseStatusCompleted, true); err != nil {
+		return ctrl.Result{}, err
+	}
+	logger.Info("Release completed")
+	r.eventf(ds, corev1.EventTypeNormal, ReasonReleaseCompleted, "DaemonSet released: FlexDaemonSetNodePods removed and pods restored to the DaemonSet template's resources")
+	return ctrl.Result{}, nil
+}
+
+// setReleaseStatus records the release progress on the DaemonSet, optionally removing the release annotation.
+func (r *NodeCoverageReconciler) setReleaseStatus(ctx context.Context, ds *appsv1.DaemonSet, status string, clearRequest bool) error {
+	original := ds.DeepCopy()
+	if ds.Annotations == nil {
+		ds.Annotations = map[string]string{}
+	}
+	ds.Annotations[utils.ReleaseStatusAnnotation] = status
+	if clearRequest {
+		delete(ds.Annotations, utils.ReleaseAnnotation)
+		delete(ds.Annotations, utils.ReleaseRollingNodeAnnotation)
+	}
+	if err := r.Patch(ctx, ds, client.MergeFrom(original)); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to update release status on DaemonSet", "status", status)
+		return err
+	}
+	return nil
+}
+
+// hasFlexPodAnnotations reports whether the pod was marked or sized by FlexDaemonsets, including with the
+// template's fallback resources.
+func hasFlexPodAnnotations(pod *corev1.Pod) bool {
+	_, marked := pod.Annotations[PodApplyTemplateAnnotation]
+	_, sized := pod.Annotations[audit.DecisionAnnotation]
+	_, fallback := pod.Annotations[utils.FallbackAnnotation]
+	return marked || sized || fallback
+}
+
+// releaseRollDone reports whether a pod may be deleted to be recreated: no pod of the DaemonSet is terminating and
+// the replacement of the previously deleted pod, if any, is running and ready. Once it is, or its node is gone,
+// ReleaseRollingNodeAnnotation is cleared.
+func (r *NodeCoverageReconciler) releaseRollDone(ctx context.Context, ds *appsv1.DaemonSet, pods []corev1.Pod) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	replaced := false
+	nodeName, rolling := ds.Annotations[utils.ReleaseRollingNodeAnnotation]
+	for i := range pods {
+		pod := &pods[i]
+		if !metav1.IsControlledBy(pod, ds) {
+			continue
+		}
+		if pod.DeletionTimestamp != nil {
+			logger.V(1).Info("Waiting for terminating pod before rolling another", "pod", pod.Name)
+			return false, nil
+		}
+		if rolling && pod.Spec.NodeName == nodeName && pod.Status.Phase == corev1.PodRunning && isPodReady(pod) {
+			replaced = true
+		}
+	}
+	if !rolling {
+		return true, nil
+	}
+	if !replaced {
+		node := &corev1.Node{}
+		err := r.Get(ctx, client.ObjectKey{Name: nodeName}, node)
+		if err == nil || !errors.IsNotFound(err) {
+			logger.V(1).Info("Waiting for the rolled pod's replacement to become ready", "node", nodeName)
+			return false, client.IgnoreNotFound(err)
+		}
+		logger.Info("Node of the rolled pod is gone, no longer waiting for its replacement", "node", nodeName)
+	}
+
+	original := ds.DeepCopy()
+	delete(ds.Annotations, utils.ReleaseRollingNodeAnnotation)
+	if err := r.Patch(ctx, ds, client.MergeFrom(original)); err != nil {
+		logger.Error(err, "Failed to clear rolling node on DaemonSet")
+		return false, err
+	}
+	return true, nil
+}
+
+// restorePod resizes the pod back to the DaemonSet template's resources and removes the flex annotations.
+// If the API server rejects the in-place resize and mayRoll is set, the pod's node is recorded in
+// ReleaseRollingNodeAnnotation and the pod is deleted so the DaemonSet controller recreates it from the template.
+// It reports whether the pod is still pending (deleted and waiting to be recreated, or waiting for its turn to be
+// rolled) and whether it was deleted.
+func (r *NodeCoverageReconciler) restorePod(ctx context.Context, ds *appsv1.DaemonSet, pod *corev1.Pod, mayRoll bool) (pending, rolled bool, err error) {
+	logger := log.FromContext(ctx).WithValues("pod", client.ObjectKeyFromObject(pod).String())
+
+	restored := pod.DeepCopy()
+	restoreContainerResources(restored.Spec.Containers, ds.Spec.Template.Spec.Containers)
+	restoreContainerResources(restored.Spec.InitContainers, ds.Spec.Template.Spec.InitContainers)
+	delete(restored.Annotations, PodApplyTemplateAnnotation)
+	delete(restored.Annotations, audit.DecisionAnnotation)
+	delete(restored.Annotations, utils.FallbackAnnotation)
+
+	err = r.Patch(ctx, restored, client.MergeFrom(pod))
+	if err == nil {
+		logger.Info("Restored pod to DaemonSet template resources")
+		r.eventf(pod, corev1.EventTypeNormal, ReasonPodRestored, "Restored resources from DaemonSet %s template after release", ds.Name)
+		return false, false, nil
+	}
+	if errors.IsNotFound(err) {
+		return false, false, nil
+	}
+	if !errors.IsInvalid(err) {
+		logger.Error(err, "Failed to restore pod resources")
+		r.eventf(pod, corev1.EventTypeWarning, ReasonPodRestoreFailed, "Failed to restore resources after release: %v", err)
+		return false, false, err
+	}
+	if !mayRoll {
+		// Another pod is being rolled; try again once its replacement is ready.
+		return true, false, nil
+	}
+
+	// The node is recorded before the pod is deleted, so a failure in between never lets a second pod go.
+	original := ds.DeepCopy()
+	if ds.Annotations == nil {
+		ds.Annotations = map[string]string{}
+	}
+	ds.Annotations[utils.ReleaseRollingNodeAnnotation] = pod.Spec.NodeName
+	if patchErr := r.Patch(ctx, ds, client.MergeFrom(original)); patchErr != nil {
+		logger.Error(patchErr, "Failed to record rolling node on DaemonSet")
+		return false, false, patchErr
+	}
+
+	logger.Info("In-place resize rejected, deleting pod so the DaemonSet recreates it", "reason", err.Error())
+	if delErr := r.Delete(ctx, pod); delErr != nil && !errors.IsNotFound(delErr) {
+		logger.Error(delErr, "Failed to delete pod for release")
+		return false, false, delErr
+	}
+	r.eventf(ds, corev1.EventTypeNormal, ReasonPodRolled, "Deleted pod %s so it is recreated with the template's resources (in-place resize rejected)", pod.Name)
+	return true, true, nil
+}
+
+// restoreContainerResources copies each template container's resources onto the pod container of the same name.
+func restoreContainerResources(containers, templateContainers []corev1.Container) {
+	byName := make(map[string]corev1.ResourceRequirements, len(templateContainers))
+	for _, c := range templateContainers {
+		byName[c.Name] = c.Resources
+	}
+	for i := range containers {
+		if resources, ok := byName[containers[i].Name]; ok {
+			containers[i].Resources = *resources.DeepCopy()
+		}
+	}
+}
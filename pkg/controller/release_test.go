@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+func testNodeObject(name string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+// testDaemonSetPod returns a running pod of testDaemonSetObject on the node, ready if ready is set and marked by
+// FlexDaemonsets if sized is set.
+func testDaemonSetPod(name, nodeName string, sized, ready bool) *corev1.Pod {
+	isController := true
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			UID:       types.UID(name + "-uid"),
+			Labels:    map[string]string{"app": testDaemonSet},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1", Kind: "DaemonSet", Name: testDaemonSet, UID: "ds-uid", Controller: &isController,
+			}},
+		},
+		Spec: corev1.PodSpec{NodeName: nodeName, Containers: []corev1.Container{{Name: "exporter", Image: "exporter:v1"}}},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}},
+		},
+	}
+	if sized {
+		pod.Annotations = map[string]string{PodApplyTemplateAnnotation: testTemplate}
+		pod.Spec.Containers[0].Resources.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")}
+	}
+	if ready {
+		pod.Status.Conditions[0].Status = corev1.ConditionTrue
+	}
+	return pod
+}
+
+// rejectPodResize fails every pod patch as the API server does when it rejects an in-place resize.
+var rejectPodResize = interceptor.Funcs{
+	Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			return errors.NewInvalid(schema.GroupKind{Kind: "Pod"}, pod.Name,
+				field.ErrorList{field.Forbidden(field.NewPath("spec"), "pod updates may not change resources")})
+		}
+		return c.Patch(ctx, obj, patch, opts...)
+	},
+}
+
+// Pods whose resize is rejected are deleted one at a time: the next one only once the previous one's replacement
+// is running and ready, so at most one node is without an available pod.
+func TestReconcileReleaseRollsOnePodAtATime(t *testing.T) {
+	ctx := context.Background()
+	nodes := []string{"node-a", "node-b", "node-c"}
+	ds := testDaemonSetObject()
+	ds.Annotations[utils.ReleaseAnnotation] = "true"
+	objs := []client.Object{ds}
+	for _, node := range nodes {
+		objs = append(objs, testNodeObject(node), testDaemonSetPod("sized-"+node, node, true, true))
+	}
+	c := newTestClientBuilder(t, objs...).WithInterceptorFuncs(rejectPodResize).Build()
+	r := &NodeCoverageReconciler{Client: c, Scheme: c.Scheme()}
+
+	// availability maps every node to its pod's state: "sized", "ready", "starting" or "" without a pod.
+	availability := func() map[string]string {
+		var pods corev1.PodList
+		if err := c.List(ctx, &pods, client.InNamespace(testNamespace)); err != nil {
+			t.Fatal(err)
+		}
+		states := map[string]string{}
+		for _, pod := range pods.Items {
+			switch {
+			case hasFlexPodAnnotations(&pod):
+				states[pod.Spec.NodeName] = "sized"
+			case isPodReady(&pod):
+				states[pod.Spec.NodeName] = "ready"
+			default:
+				states[pod.Spec.NodeName] = "starting"
+			}
+		}
+		return states
+	}
+
+	completed := false
+	for pass := 1; pass <= 20 && !completed; pass++ {
+		current := &appsv1.DaemonSet{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(ds), current); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.reconcileRelease(ctx, current); err != nil {
+			t.Fatalf("pass %d: %v", pass, err)
+		}
+
+		states := availability()
+		unavailable := 0
+		for _, node := range nodes {
+			if state := states[node]; state != "sized" && state != "ready" {
+				unavailable++
+			}
+		}
+		if unavailable > 1 {
+			t.Fatalf("pass %d: %d pods unavailable at once: %v", pass, unavailable, states)
+		}
+
+		// Play the DaemonSet controller and the kubelet: recreate a missing pod, then make it ready on the
+		// following pass.
+		for _, node := range nodes {
+			switch states[node] {
+			case "":
+				if err := c.Create(ctx, testDaemonSetPod(fmt.Sprintf("plain-%s-%d", node, pass), node, false, false)); err != nil {
+					t.Fatal(err)
+				}
+			case "starting":
+				var pods corev1.PodList
+				if err := c.List(ctx, &pods, client.InNamespace(testNamespace)); err != nil {
+					t.Fatal(err)
+				}
+				for i := range pods.Items {
+					if pod := &pods.Items[i]; pod.Spec.NodeName == node {
+						pod.Status.Conditions[0].Status = corev1.ConditionTrue
+						if err := c.Status().Update(ctx, pod); err != nil {
+							t.Fatal(err)
+						}
+					}
+				}
+			}
+		}
+		completed = current.Annotations[utils.ReleaseStatusAnnotation] == utils.ReleaseStatusCompleted
+	}
+
+	if !completed {
+		t.Fatalf("release did not complete: %v", availability())
+	}
+	for node, state := range availability() {
+		if state != "ready" {
+			t.Errorf("node %s: pod %s, want a ready replacement", node, state)
+		}
+	}
+}
+
+func TestReleaseRollDone(t *testing.T) {
+	tests := []struct {
+		name        string
+		rollingNode string
+		pods        []*corev1.Pod
+		nodes       []string
+		want        bool
+		wantCleared bool
+	}{
+		{name: "nothing rolled", pods: []*corev1.Pod{testDaemonSetPod("a", "node-a", true, true)}, want: true},
+		{
+			name:        "replacement ready",
+			rollingNode: "node-a",
+			pods:        []*corev1.Pod{testDaemonSetPod("a", "node-a", false, true)},
+			nodes:       []string{"node-a"},
+			want:        true,
+			wantCleared: true,
+		},
+		{
+			name:        "replacement not ready",
+			rollingNode: "node-a",
+			pods:        []*corev1.Pod{testDaemonSetPod("a", "node-a", false, false)},
+			nodes:       []string{"node-a"},
+		},
+		{name: "replacement not created", rollingNode: "node-a", nodes: []string{"node-a"}},
+		{name: "node gone", rollingNode: "node-a", want: true, wantCleared: true},
+		{
+			name: "pod terminating",
+			pods: []*corev1.Pod{func() *corev1.Pod {
+				pod := testDaemonSetPod("a", "node-a", true, true)
+				now := metav1.Now()
+				pod.DeletionTimestamp, pod.Finalizers = &now, []string{"test/hold"}
+				return pod
+			}()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			ds := testDaemonSetObject()
+			if tt.rollingNode != "" {
+				ds.Annotations[utils.ReleaseRollingNodeAnnotation] = tt.rollingNode
+			}
+			objs := []client.Object{ds}
+			var pods []corev1.Pod
+			for _, pod := range tt.pods {
+				objs = append(objs, pod)
+				pods = append(pods, *pod)
+			}
+			for _, node := range tt.nodes {
+				objs = append(objs, testNodeObject(node))
+			}
+			c := newTestClient(t, objs...)
+			r := &NodeCoverageReconciler{Client: c, Scheme: c.Scheme()}
+
+			got, err := r.releaseRollDone(ctx, ds, pods)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			stored := &appsv1.DaemonSet{}
+			if err := c.Get(ctx, client.ObjectKeyFromObject(ds), stored); err != nil {
+				t.Fatal(err)
+			}
+			_, marked := stored.Annotations[utils.ReleaseRollingNodeAnnotation]
+			if wantMarked := tt.rollingNode != "" && !tt.wantCleared; marked != wantMarked {
+				t.Errorf("rolling node annotation present = %v, want %v", marked, wantMarked)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Package events provides the event recorder shared by the FlexDaemonsets webhook and controllers.
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// DefaultInterval is the minimum time between two events of the same type and reason for the same object.
+const DefaultInterval = time.Minute
+
+// pruneThreshold is the number of tracked keys above which expired entries are dropped.
+const pruneThreshold = 4096
+
+type eventKey struct {
+	object    string
+	eventType string
+	reason    string
+}
+
+// RateLimitedRecorder wraps an EventRecorder and drops events that repeat the same type and reason for the same
+// object within Interval. Sizing decisions are re-evaluated on every reconcile, so without this a busy object would
+// flood the API server (and kubectl describe) with events. The message is not part of the key: messages carry
+// quantities and counters that change from one reconcile to the next, which would defeat the limit.
+type RateLimitedRecorder struct {
+	record.EventRecorder
+	Interval time.Duration
+
+	mu   sync.Mutex
+	last map[eventKey]time.Time
+}
+
+var _ record.EventRecorder = &RateLimitedRecorder{}
+
+// NewRateLimitedRecorder returns a recorder that forwards to rec at most once per interval for every
+// (object, type, reason). A non-positive interval uses DefaultInterval.
+func NewRateLimitedRecorder(rec record.EventRecorder, interval time.Duration) *RateLimitedRecorder {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &RateLimitedRecorder{
+		EventRecorder: rec,
+		Interval:      interval,
+		last:          map[eventKey]time.Time{},
+	}
+}
+
+// Event implements record.EventRecorder.
+func (r *RateLimitedRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if r.allow(object, eventtype, reason) {
+		r.EventRecorder.Event(object, eventtype, reason, message)
+	}
+}
+
+// Eventf implements record.EventRecorder.
+func (r *RateLimitedRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	if r.allow(object, eventtype, reason) {
+		r.EventRecorder.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+	}
+}
+
+// AnnotatedEventf implements record.EventRecorder.
+func (r *RateLimitedRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	if r.allow(object, eventtype, reason) {
+		r.EventRecorder.AnnotatedEventf(object, annotations, eventtype, reason, "%s", fmt.Sprintf(messageFmt, args...))
+	}
+}
+
+func (r *RateLimitedRecorder) allow(object runtime.Object, eventtype, reason string) bool {
+	key := eventKey{object: objectKey(object), eventType: eventtype, reason: reason}
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if last, ok := r.last[key]; ok && now.Sub(last) < r.Interval {
+		return false
+	}
+	r.last[key] = now
+	if len(r.last) > pruneThreshold {
+		for k, t := range r.last {
+			if now.Sub(t) >= r.Interval {
+				delete(r.last, k)
+			}
+		}
+	}
+	return true
+}
+
+// objectKey identifies the object an event is about. Objects that have not been persisted yet
+// (such as pods under admission) have no UID, so the kind and name are used instead.
+func objectKey(object runtime.Object) string {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return fmt.Sprintf("%T", object)
+	}
+	if uid := accessor.GetUID(); uid != "" {
+		return string(uid)
+	}
+	name := accessor.GetName()
+	if name == "" {
+		name = accessor.GetGenerateName()
+	}
+	return fmt.Sprintf("%T/%s/%s", object, accessor.GetNamespace(), name)
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRateLimitedRecorder(t *testing.T) {
+	podA := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "a", UID: "uid-a"}}
+	podB := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "b", UID: "uid-b"}}
+	admitted := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", GenerateName: "exporter-"}}
+
+	type event struct {
+		object    runtime.Object
+		eventType string
+		reason    string
+		message   string
+	}
+	tests := []struct {
+		name        string
+		second      event
+		wantForward bool
+	}{
+		{name: "same event", second: event{podA, corev1.EventTypeNormal, "Resized", "cpu=100m"}},
+		{name: "same reason, new message", second: event{podA, corev1.EventTypeNormal, "Resized", "cpu=200m"}},
+		{name: "other reason", second: event{podA, corev1.EventTypeNormal, "Skipped", "cpu=100m"}, wantForward: true},
+		{name: "other type", second: event{podA, corev1.EventTypeWarning, "Resized", "cpu=100m"}, wantForward: true},
+		{name: "other object", second: event{podB, corev1.EventTypeNormal, "Resized", "cpu=100m"}, wantForward: true},
+		{name: "object without a UID", second: event{admitted, corev1.EventTypeNormal, "Resized", "cpu=100m"}, wantForward: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := record.NewFakeRecorder(10)
+			rec := NewRateLimitedRecorder(fake, time.Hour)
+			rec.Eventf(podA, corev1.EventTypeNormal, "Resized", "cpu=%s", "100m")
+			rec.Event(tt.second.object, tt.second.eventType, tt.second.reason, tt.second.message)
+
+			want := 1
+			if tt.wantForward {
+				want = 2
+			}
+			if got := len(fake.Events); got != want {
+				t.Errorf("forwarded %d events, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestRateLimitedRecorderForwardsAfterInterval(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "a", UID: "uid-a"}}
+	fake := record.NewFakeRecorder(10)
+	rec := NewRateLimitedRecorder(fake, time.Hour)
+
+	rec.Event(pod, corev1.EventTypeNormal, "Resized", "cpu=100m")
+	// Age the recorded event past the interval.
+	for key := range rec.last {
+		rec.last[key] = time.Now().Add(-2 * time.Hour)
+	}
+	rec.Event(pod, corev1.EventTypeNormal, "Resized", "cpu=200m")
+
+	if got := len(fake.Events); got != 2 {
+		t.Fatalf("forwarded %d events, want 2", got)
+	}
+	<-fake.Events
+	if got := <-fake.Events; got != "Normal Resized cpu=200m" {
+		t.Errorf("second event = %q, want the new message", got)
+	}
+}
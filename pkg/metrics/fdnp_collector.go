@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+)
+
+// collectTimeout bounds how long a scrape waits for the cache.
+const collectTimeout = 5 * time.Second
+
+var collectorLog = ctrl.Log.WithName("metrics").WithName("fdnp-collector")
+
+var (
+	fdnpsByPhaseDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "fdnps"),
+		"Number of FlexDaemonSetNodePods, by DaemonSet and phase.",
+		[]string{"namespace", "daemonset", "phase"}, nil,
+	)
+	uncoveredNodesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "uncovered_nodes"),
+		"Number of nodes on which a DaemonSet's own pod is missing and a FlexDaemonSetNodePod stands in for it.",
+		[]string{"namespace", "daemonset"}, nil,
+	)
+)
+
+// coveringPhases are the FlexDaemonSetNodePod phases in which it stands in for a missing DaemonSet pod: its pod
+// is being created or exists. They mirror the Phase constants of the controller package, which imports this one.
+// Conflicting, yielded, terminating, failed, backed-off and unschedulable FDNPs cover no node.
+var coveringPhases = map[string]bool{
+	"Pending":     true,
+	"CreatingPod": true,
+	"Active":      true,
+	"Degraded":    true,
+}
+
+// FDNPCollector derives FlexDaemonSetNodePod gauges from the manager's cache at scrape time,
+// so the values are always consistent with the cluster and never need to be reset.
+type FDNPCollector struct {
+	Reader client.Reader
+}
+
+// NewFDNPCollector returns a collector reading FlexDaemonSetNodePods through the given reader,
+// normally the manager's cache.
+func NewFDNPCollector(reader client.Reader) *FDNPCollector {
+	return &FDNPCollector{Reader: reader}
+}
+
+// Describe implements prometheus.Collector.
+func (c *FDNPCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- fdnpsByPhaseDesc
+	ch <- uncoveredNodesDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *FDNPCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	var fdnps flexdaemonsetsv1alpha1.FlexDaemonSetNodePodList
+	if err := c.Reader.List(ctx, &fdnps); err != nil {
+		collectorLog.Error(err, "Failed to list FlexDaemonSetNodePods for metrics")
+		return
+	}
+
+	type dsKey struct{ namespace, name string }
+	type phaseKey struct {
+		ds    dsKey
+		phase string
+	}
+	byPhase := map[phaseKey]int{}
+	uncovered := map[dsKey]int{}
+	for _, fdnp := range fdnps.Items {
+		ds := dsKey{fdnp.Spec.DaemonSetNamespace, fdnp.Spec.DaemonSetName}
+		byPhase[phaseKey{ds, fdnp.Status.Phase}]++
+		// Every DaemonSet with an FDNP reports, at zero if none of them covers a node.
+		n := uncovered[ds]
+		if coveringPhases[fdnp.Status.Phase] {
+			n++
+		}
+		uncovered[ds] = n
+	}
+
+	for k, n := range byPhase {
+		ch <- prometheus.MustNewConstMetric(fdnpsByPhaseDesc, prometheus.GaugeValue, float64(n), k.ds.namespace, k.ds.name, k.phase)
+	}
+	for k, n := range uncovered {
+		ch <- prometheus.MustNewConstMetric(uncoveredNodesDesc, prometheus.GaugeValue, float64(n), k.namespace, k.name)
+	}
+}
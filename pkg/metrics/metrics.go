@@ -0,0 +1,162 @@
+// Package metrics defines the Prometheus collectors exported by the FlexDaemonsets manager.
+// All collectors are registered in the controller-runtime metrics registry, so they are served
+// by the manager's metrics endpoint alongside the built-in controller metrics.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+const namespace = "flexdaemonsets"
+
+var (
+	// FDNPPodFailures counts managed pod failures (failed, evicted or deleted pods) observed for FlexDaemonSetNodePods.
+	FDNPPodFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fdnp_pod_failures_total",
+		Help:      "Number of managed pod failures observed for FlexDaemonSetNodePods, by DaemonSet and failure reason.",
+	}, []string{"namespace", "daemonset", "reason"})
+
+	// FDNPRecreationLimitReached counts FlexDaemonSetNodePods that exhausted their pod recreation budget.
+	FDNPRecreationLimitReached = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fdnp_recreation_limit_reached_total",
+		Help:      "Number of times a FlexDaemonSetNodePod was moved to Failed after exhausting its pod recreation limit.",
+	}, []string{"namespace", "daemonset"})
+
+	// WebhookAdmissions counts pod admission decisions made by the mutating webhook.
+	WebhookAdmissions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_admissions_total",
+		Help:      "Number of pod admission requests handled by the mutating webhook, by outcome.",
+	}, []string{"outcome"})
+
+	// WebhookAdmissionDuration observes how long the mutating webhook takes to answer.
+	WebhookAdmissionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "webhook_admission_duration_seconds",
+		Help:      "Latency of pod admission requests handled by the mutating webhook, by outcome.",
+		Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}, []string{"outcome"})
+
+	// WebhookFailures counts pods the mutating webhook could not mark for sizing.
+	WebhookFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_failures_total",
+		Help:      "Number of pods the mutating webhook could not mark for sizing, by failure reason and the failure policy applied (Allow, Fallback or Deny).",
+	}, []string{"reason", "policy"})
+
+	// CalculatedResources is the most recent quantity calculated for a template and resource.
+	CalculatedResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "calculated_resource_quantity",
+		Help:      "Most recent quantity calculated by CalculatePodResources, by template and resource. CPU is in cores, other resources in bytes.",
+	}, []string{"template", "resource"})
+
+	// ResourceBoundHits counts calculations in which a template bound or a node override replaced the percentage result.
+	ResourceBoundHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "resource_bound_hits_total",
+		Help:      "Number of resource calculations decided by a template minimum (bound=\"floor\"), a template maximum (bound=\"cap\") or a FlexNodeOverride (bound=\"override\") rather than the percentage, by template, resource and bound.",
+	}, []string{"template", "resource", "bound"})
+
+	// PodPatchFailures counts failed attempts to patch calculated resources onto DaemonSet pods.
+	PodPatchFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pod_patch_failures_total",
+		Help:      "Number of failed pod patches issued by the pod controller, by namespace and template.",
+	}, []string{"namespace", "template"})
+
+	// DryRunDecisions counts sizing decisions that were recorded but not applied because of dry-run mode.
+	DryRunDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dry_run_decisions_total",
+		Help:      "Number of sizing decisions recorded but not applied because of dry-run mode, by template and component (webhook, nodecoverage or flexdaemonsetnodepod).",
+	}, []string{"template", "component"})
+
+	// WebhookCertificateExpiry is the expiry of the self-managed webhook certificates.
+	WebhookCertificateExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "webhook_certificate_expiry_timestamp_seconds",
+		Help:      "Expiry of the self-managed webhook certificates as a Unix timestamp, by certificate (ca or serving).",
+	}, []string{"certificate"})
+
+	// WebhookCertificateRotations counts self-managed webhook certificates generated by the leader.
+	WebhookCertificateRotations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_certificate_rotations_total",
+		Help:      "Number of self-managed webhook certificates generated, by certificate (ca or serving).",
+	}, []string{"certificate"})
+)
+
+// Webhook admission outcomes.
+const (
+	AdmissionMutated = "mutated"
+	AdmissionSkipped = "skipped"
+	AdmissionErrored = "errored"
+	// AdmissionDenied is a pod rejected by the Deny failure policy; AdmissionFallback one given the template's
+	// fallback resources. See WebhookFailures.
+	AdmissionDenied   = "denied"
+	AdmissionFallback = "fallback"
+	// AdmissionDryRun is a pod annotated with the decision it would have been sized with, see DryRunDecisions.
+	AdmissionDryRun = "dry_run"
+)
+
+// Reasons the webhook could not mark a pod for sizing.
+const (
+	WebhookFailureDaemonSetUnavailable = "daemonset_unavailable"
+	WebhookFailureTemplateNotFound     = "template_not_found"
+	WebhookFailureTemplateUnavailable  = "template_unavailable"
+	WebhookFailureTemplateInvalid      = "template_invalid"
+)
+
+// Components reporting dry-run decisions.
+const (
+	DryRunComponentWebhook              = "webhook"
+	DryRunComponentNodeCoverage         = "nodecoverage"
+	DryRunComponentFlexDaemonSetNodePod = "flexdaemonsetnodepod"
+)
+
+// ObserveAdmission records an admission decision and its latency.
+func ObserveAdmission(outcome string, start time.Time) {
+	WebhookAdmissions.WithLabelValues(outcome).Inc()
+	WebhookAdmissionDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
+
+// RecordCalculation exports the result of a resource calculation for the given template.
+func RecordCalculation(templateName string, result *utils.CalculationResult) {
+	for name, quantity := range result.Resources {
+		CalculatedResources.WithLabelValues(templateName, string(name)).Set(quantity.AsApproximateFloat64())
+		if bound := result.Bounds[name]; bound != utils.BoundPercentage {
+			ResourceBoundHits.WithLabelValues(templateName, string(name), string(bound)).Inc()
+		}
+	}
+	// Resources omitted from the result were calculated as zero.
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage} {
+		if _, ok := result.Resources[name]; !ok {
+			CalculatedResources.WithLabelValues(templateName, string(name)).Set(0)
+		}
+	}
+}
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		FDNPPodFailures,
+		FDNPRecreationLimitReached,
+		WebhookAdmissions,
+		WebhookAdmissionDuration,
+		WebhookFailures,
+		CalculatedResources,
+		ResourceBoundHits,
+		PodPatchFailures,
+		DryRunDecisions,
+		WebhookCertificateExpiry,
+		WebhookCertificateRotations,
+	)
+}
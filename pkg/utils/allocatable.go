@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// ReservedAnnotation on a Node lists quantities reserved outside Kubernetes (for example DPDK cores or a
+	// host-level cache), as "cpu=2,memory=4Gi". They are subtracted from the node's allocatable before template
+	// percentages are applied.
+	ReservedAnnotation = "flexdaemonsets.xai/reserved"
+	// FlexBaseAnnotation on a Node replaces the node's allocatable for the listed resources, as "cpu=8,memory=16Gi".
+	// ReservedAnnotation is subtracted from the flex base.
+	FlexBaseAnnotation = "flexdaemonsets.xai/flex-base"
+
+	// ReservedLabelPrefix and FlexBaseLabelPrefix followed by a resource name (e.g. "flexdaemonsets.xai/reserved-cpu")
+	// set a single resource through a node label, so the adjustment can be applied at node registration with
+	// kubelet --node-labels. The annotations take precedence over the labels for the same resource.
+	ReservedLabelPrefix = "flexdaemonsets.xai/reserved-"
+	FlexBaseLabelPrefix = "flexdaemonsets.xai/flex-base-"
+)
+
+// adjustableResources are the resources a node may adjust, i.e. the ones templates size.
+var adjustableResources = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage}
+
+// HasAllocatableAdjustments reports whether the node carries any flex-base or reserved annotation or label.
+func HasAllocatableAdjustments(node *corev1.Node) bool {
+	if _, ok := node.Annotations[ReservedAnnotation]; ok {
+		return true
+	}
+	if _, ok := node.Annotations[FlexBaseAnnotation]; ok {
+		return true
+	}
+	for _, name := range adjustableResources {
+		if _, ok := node.Labels[ReservedLabelPrefix+string(name)]; ok {
+			return true
+		}
+		if _, ok := node.Labels[FlexBaseLabelPrefix+string(name)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectiveAllocatable returns the allocatable resources templates are applied to: the node's allocatable with
+// the flex base substituted and the reserved quantities subtracted, never going below zero. Malformed entries are
+// ignored and returned as errors; the remaining, valid entries are still applied.
+func EffectiveAllocatable(node *corev1.Node) (corev1.ResourceList, []error) {
+	if !HasAllocatableAdjustments(node) {
+		return node.Status.Allocatable, nil
+	}
+
+	var errs []error
+	base, baseErrs := nodeAdjustment(node, FlexBaseAnnotation, FlexBaseLabelPrefix)
+	errs = append(errs, baseErrs...)
+	reserved, reservedErrs := nodeAdjustment(node, ReservedAnnotation, ReservedLabelPrefix)
+	errs = append(errs, reservedErrs...)
+
+	effective := node.Status.Allocatable.DeepCopy()
+	if effective == nil {
+		effective = corev1.ResourceList{}
+	}
+	for name, quantity := range base {
+		effective[name] = quantity
+	}
+	for name, quantity := range reserved {
+		current, ok := effective[name]
+		if !ok {
+			continue
+		}
+		current.Sub(quantity)
+		if current.Sign() < 0 {
+			current = *resource.NewQuantity(0, current.Format)
+		}
+		effective[name] = current
+	}
+	return effective, errs
+}
+
+// nodeAdjustment collects one kind of adjustment from the per-resource labels and the annotation, the latter winning.
+func nodeAdjustment(node *corev1.Node, annotation, labelPrefix string) (corev1.ResourceList, []error) {
+	var errs []error
+	adjustment := corev1.ResourceList{}
+	for _, name := range adjustableResources {
+		value, ok := node.Labels[labelPrefix+string(name)]
+		if !ok {
+			continue
+		}
+		quantity, err := parseAdjustmentQuantity(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("label %s%s: %w", labelPrefix, name, err))
+			continue
+		}
+		adjustment[name] = quantity
+	}
+
+	value, ok := node.Annotations[annotation]
+	if !ok {
+		return adjustment, errs
+	}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, rawQuantity, found := strings.Cut(entry, "=")
+		if !found {
+			errs = append(errs, fmt.Errorf("annotation %s: entry %q is not of the form <resource>=<quantity>", annotation, entry))
+			continue
+		}
+		resourceName := corev1.ResourceName(strings.TrimSpace(name))
+		if !isAdjustableResource(resourceName) {
+			errs = append(errs, fmt.Errorf("annotation %s: unsupported resource %q, expected one of %v", annotation, resourceName, adjustableResources))
+			continue
+		}
+		quantity, err := parseAdjustmentQuantity(strings.TrimSpace(rawQuantity))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("annotation %s: resource %s: %w", annotation, resourceName, err))
+			continue
+		}
+		adjustment[resourceName] = quantity
+	}
+	return adjustment, errs
+}
+
+func parseAdjustmentQuantity(value string) (resource.Quantity, error) {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("invalid quantity %q: %w", value, err)
+	}
+	if quantity.Sign() < 0 {
+		return resource.Quantity{}, fmt.Errorf("quantity %q must not be negative", value)
+	}
+	return quantity, nil
+}
+
+func isAdjustableResource(name corev1.ResourceName) bool {
+	for _, adjustable := range adjustableResources {
+		if name == adjustable {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEffectiveAllocatable(t *testing.T) {
+	allocatable := corev1.ResourceList{
+		corev1.ResourceCPU:              resource.MustParse("16"),
+		corev1.ResourceMemory:           resource.MustParse("64Gi"),
+		corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		labels      map[string]string
+		want        corev1.ResourceList
+		wantErrs    int
+	}{
+		{
+			name: "no adjustments",
+			want: allocatable,
+		},
+		{
+			name:        "flex base replaces allocatable",
+			annotations: map[string]string{FlexBaseAnnotation: "cpu=8,memory=16Gi"},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("8"),
+				corev1.ResourceMemory:           resource.MustParse("16Gi"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+			},
+		},
+		{
+			name:        "reserved is subtracted",
+			annotations: map[string]string{ReservedAnnotation: "cpu=2, memory=4Gi"},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("14"),
+				corev1.ResourceMemory:           resource.MustParse("60Gi"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+			},
+		},
+		{
+			name:        "reserved is subtracted from the flex base",
+			annotations: map[string]string{FlexBaseAnnotation: "cpu=8", ReservedAnnotation: "cpu=2"},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("6"),
+				corev1.ResourceMemory:           resource.MustParse("64Gi"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+			},
+		},
+		{
+			name:        "reserved beyond allocatable clamps at zero",
+			annotations: map[string]string{ReservedAnnotation: "cpu=32"},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("0"),
+				corev1.ResourceMemory:           resource.MustParse("64Gi"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+			},
+		},
+		{
+			name:   "labels set single resources",
+			labels: map[string]string{FlexBaseLabelPrefix + "memory": "32Gi", ReservedLabelPrefix + "cpu": "1"},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("15"),
+				corev1.ResourceMemory:           resource.MustParse("32Gi"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+			},
+		},
+		{
+			name:        "annotation wins over label",
+			annotations: map[string]string{ReservedAnnotation: "cpu=4"},
+			labels:      map[string]string{ReservedLabelPrefix + "cpu": "1"},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("12"),
+				corev1.ResourceMemory:           resource.MustParse("64Gi"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+			},
+		},
+		{
+			name:        "malformed entries are reported and valid ones applied",
+			annotations: map[string]string{ReservedAnnotation: "cpu=2,memory,gpu=1,ephemeral-storage=lots"},
+			labels:      map[string]string{FlexBaseLabelPrefix + "memory": "-1Gi"},
+			want: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("14"),
+				corev1.ResourceMemory:           resource.MustParse("64Gi"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+			},
+			wantErrs: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node", Annotations: tt.annotations, Labels: tt.labels},
+				Status:     corev1.NodeStatus{Allocatable: allocatable.DeepCopy()},
+			}
+			got, errs := EffectiveAllocatable(node)
+			if len(errs) != tt.wantErrs {
+				t.Errorf("got %d errors %v, want %d", len(errs), errs, tt.wantErrs)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for name, want := range tt.want {
+				if quantity := got[name]; quantity.Cmp(want) != 0 {
+					t.Errorf("%s: got %s, want %s", name, quantity.String(), want.String())
+				}
+			}
+			if node.Status.Allocatable.Cpu().Cmp(allocatable[corev1.ResourceCPU]) != 0 {
+				t.Errorf("node allocatable was modified: %v", node.Status.Allocatable)
+			}
+		})
+	}
+}
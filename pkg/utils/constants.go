@@ -1,3 +1,67 @@
 package utils
 
+import (
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+)
+
 const FlexDaemonsetTemplateAnnotation = "flexdaemonsets.xai/resource-template"
+
+// PausedAnnotation set to "true" on a DaemonSet freezes sizing for it: the webhook passes its pods through,
+// and its FlexDaemonSetNodePods and pods keep their current resources until the annotation is removed.
+const PausedAnnotation = "flexdaemonsets.xai/paused"
+
+// IsDaemonSetPaused reports whether the DaemonSet carries PausedAnnotation with a true value.
+func IsDaemonSetPaused(ds *appsv1.DaemonSet) bool {
+	paused, err := strconv.ParseBool(ds.Annotations[PausedAnnotation])
+	return err == nil && paused
+}
+
+// PauseReason returns a human readable reason if sizing is frozen for the DaemonSet, either because the
+// DaemonSet is paused or because its template (which may be nil) is suspended. It returns "" otherwise.
+func PauseReason(ds *appsv1.DaemonSet, template *flexdaemonsetsv1alpha1.FlexDaemonsetTemplate) string {
+	if IsDaemonSetPaused(ds) {
+		return "DaemonSet " + ds.Namespace + "/" + ds.Name + " is paused"
+	}
+	if template != nil && template.Spec.Suspend {
+		return "FlexDaemonsetTemplate " + template.Name + " is suspended"
+	}
+	return ""
+}
+
+// DryRunReason returns a human readable reason if sizing decisions are only recorded, not applied, either because
+// the manager runs with dry-run enabled or because the template (which may be nil) is in dry-run mode. It returns
+// "" otherwise.
+func DryRunReason(globalDryRun bool, template *flexdaemonsetsv1alpha1.FlexDaemonsetTemplate) string {
+	if globalDryRun {
+		return "the manager runs in dry-run mode"
+	}
+	if template != nil && template.Spec.DryRun {
+		return "FlexDaemonsetTemplate " + template.Name + " is in dry-run mode"
+	}
+	return ""
+}
+
+// FallbackAnnotation on a pod records why the webhook gave it the template's fallback resources instead of
+// marking it for sizing.
+const FallbackAnnotation = "flexdaemonsets.xai/fallback-reason"
+
+// ReleaseAnnotation on a DaemonSet requests that it be handed back to the plain DaemonSet controller: its
+// FlexDaemonSetNodePods are deleted, its pods restored to the template's resources and the template annotation
+// removed. Removing FlexDaemonsetTemplateAnnotation directly has the same effect.
+const ReleaseAnnotation = "flexdaemonsets.xai/release"
+
+// ReleaseStatusAnnotation reports the progress of a release on the DaemonSet.
+const ReleaseStatusAnnotation = "flexdaemonsets.xai/release-status"
+
+// ReleaseRollingNodeAnnotation on a DaemonSet being released names the node whose pod was deleted to be recreated
+// from the template. No other pod is deleted until the replacement on that node is running and ready.
+const ReleaseRollingNodeAnnotation = "flexdaemonsets.xai/release-rolling-node"
+
+const (
+	ReleaseStatusInProgress = "InProgress"
+	ReleaseStatusCompleted  = "Completed"
+)
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// MaxGeneratedNameLength is the maximum length of names generated for FlexDaemonSetNodePods and their pods.
+// Names are kept within the 63-character label value limit because they are also used as the value of the
+// owner label on managed pods; this is well below the 253-character object name limit.
+const MaxGeneratedNameLength = validation.LabelValueMaxLength
+
+// nameHashLength is the length of the hex-encoded hash suffix appended to truncated names.
+const nameHashLength = 8
+
+// FlexDaemonSetNodePodName returns the name of the FlexDaemonSetNodePod covering the given DaemonSet on the given node.
+// Short names keep the historical "<daemonset>-<node>" form. Longer ones are truncated and suffixed with a stable
+// hash of the DaemonSet and node names so that they stay unique and deterministic.
+func FlexDaemonSetNodePodName(daemonSetName, nodeName string) string {
+	return safeName(daemonSetName+"-"+nodeName, daemonSetName+"/"+nodeName)
+}
+
+// ManagedPodName returns the name of the pod created for the FlexDaemonSetNodePod with the given name.
+func ManagedPodName(fdnpName string) string {
+	return safeName(fdnpName+"-pod", fdnpName+"/pod")
+}
+
+// safeName returns name if it fits in MaxGeneratedNameLength. Otherwise it truncates name and appends
+// a hash of hashInput, trimming characters that are not allowed at the end of a name before the suffix.
+func safeName(name, hashInput string) string {
+	if len(name) <= MaxGeneratedNameLength {
+		return name
+	}
+	hasher := fnv.New32a()
+	hasher.Write([]byte(hashInput)) // fnv's Write never returns an error
+	suffix := fmt.Sprintf("%0*x", nameHashLength, hasher.Sum32())
+	prefix := strings.TrimRight(name[:MaxGeneratedNameLength-nameHashLength-1], "-.")
+	return prefix + "-" + suffix
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestFlexDaemonSetNodePodName(t *testing.T) {
+	longNode := "ip-10-0-0-1.eu-west-1.compute.internal-with-a-rather-long-suffix"
+	tests := []struct {
+		name      string
+		daemonSet string
+		node      string
+		want      string
+	}{
+		{name: "short names keep the historical form", daemonSet: "node-exporter", node: "node-a", want: "node-exporter-node-a"},
+		{name: "exactly at the limit", daemonSet: "ds", node: strings.Repeat("n", MaxGeneratedNameLength-3), want: "ds-" + strings.Repeat("n", MaxGeneratedNameLength-3)},
+		{name: "long node name", daemonSet: "node-exporter", node: longNode},
+		{name: "long DaemonSet name", daemonSet: strings.Repeat("d", 80), node: "node-a"},
+		{name: "truncation ends on a separator", daemonSet: strings.Repeat("d", 53) + ".", node: longNode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FlexDaemonSetNodePodName(tt.daemonSet, tt.node)
+			if tt.want != "" && got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if got != FlexDaemonSetNodePodName(tt.daemonSet, tt.node) {
+				t.Error("name is not deterministic")
+			}
+			if errs := validation.IsDNS1123Subdomain(got); len(errs) > 0 {
+				t.Errorf("%q is not a valid object name: %v", got, errs)
+			}
+			if errs := validation.IsValidLabelValue(got); len(errs) > 0 {
+				t.Errorf("%q is not a valid label value: %v", got, errs)
+			}
+			if errs := validation.IsValidLabelValue(ManagedPodName(got)); len(errs) > 0 {
+				t.Errorf("pod name %q is not a valid label value: %v", ManagedPodName(got), errs)
+			}
+		})
+	}
+}
+
+func TestFlexDaemonSetNodePodNameUnique(t *testing.T) {
+	prefix := "ip-10-0-0-1.eu-west-1.compute.internal-with-a-rather-long-suffix"
+	seen := map[string]string{}
+	for _, daemonSet := range []string{"node-exporter", "node-exporter-2", strings.Repeat("d", 70)} {
+		for _, node := range []string{prefix + "-a", prefix + "-b", prefix + "-aa", "node-a"} {
+			name := FlexDaemonSetNodePodName(daemonSet, node)
+			if previous, ok := seen[name]; ok {
+				t.Errorf("%s/%s and %s share the name %q", daemonSet, node, previous, name)
+			}
+			seen[name] = daemonSet + "/" + node
+		}
+	}
+}
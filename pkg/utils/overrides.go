@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+)
+
+// NodeOverrideAnnotation names the FlexNodeOverride that was applied on top of the template. It is set on
+// FlexDaemonSetNodePods (and copied to their pods) and on DaemonSet pods sized by the pod controller.
+const NodeOverrideAnnotation = "flexdaemonsets.xai/node-override"
+
+// BoundOverride means a FlexNodeOverride replaced the quantity calculated from the template.
+const BoundOverride Bound = "override"
+
+// IsNodeOverrideExpired reports whether the override's expiry time has passed.
+func IsNodeOverrideExpired(override *flexdaemonsetsv1alpha1.FlexNodeOverride, now time.Time) bool {
+	return override.Spec.ExpiresAt != nil && !now.Before(override.Spec.ExpiresAt.Time)
+}
+
+// NodeOverrideMatches reports whether the override applies to pods of the DaemonSet, sized with the named
+// template, on the node. Expired overrides never match. An invalid node selector is returned as an error.
+func NodeOverrideMatches(override *flexdaemonsetsv1alpha1.FlexNodeOverride, node *corev1.Node, ds *appsv1.DaemonSet, templateName string, now time.Time) (bool, error) {
+	if IsNodeOverrideExpired(override, now) {
+		return false, nil
+	}
+
+	spec := &override.Spec
+	switch {
+	case spec.DaemonSet != nil:
+		if spec.DaemonSet.Namespace != ds.Namespace || spec.DaemonSet.Name != ds.Name {
+			return false, nil
+		}
+	case spec.TemplateName != "":
+		if spec.TemplateName != templateName {
+			return false, nil
+		}
+	default:
+		return false, nil
+	}
+
+	switch {
+	case spec.NodeName != "":
+		return spec.NodeName == node.Name, nil
+	case spec.NodeSelector != nil:
+		selector, err := metav1.LabelSelectorAsSelector(spec.NodeSelector)
+		if err != nil {
+			return false, fmt.Errorf("invalid nodeSelector in FlexNodeOverride %s: %w", override.Name, err)
+		}
+		return selector.Matches(labels.Set(node.Labels)), nil
+	}
+	return false, nil
+}
+
+// SkippedNodeOverride is a FlexNodeOverride that could not be evaluated or applied, with the reason.
+type SkippedNodeOverride struct {
+	Override *flexdaemonsetsv1alpha1.FlexNodeOverride
+	Err      error
+}
+
+// MatchingNodeOverrides returns the overrides that match the DaemonSet on the node, most specific first: a
+// DaemonSet reference over a template reference, then a node name over a node selector, then the alphabetically
+// first name. Overrides with an invalid node selector are returned as skipped.
+func MatchingNodeOverrides(overrides []flexdaemonsetsv1alpha1.FlexNodeOverride, node *corev1.Node, ds *appsv1.DaemonSet, templateName string, now time.Time) ([]*flexdaemonsetsv1alpha1.FlexNodeOverride, []SkippedNodeOverride) {
+	var matching []*flexdaemonsetsv1alpha1.FlexNodeOverride
+	var skipped []SkippedNodeOverride
+	for i := range overrides {
+		ok, err := NodeOverrideMatches(&overrides[i], node, ds, templateName, now)
+		if err != nil {
+			skipped = append(skipped, SkippedNodeOverride{Override: &overrides[i], Err: err})
+			continue
+		}
+		if ok {
+			matching = append(matching, &overrides[i])
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		if si, sj := overrideSpecificity(matching[i]), overrideSpecificity(matching[j]); si != sj {
+			return si > sj
+		}
+		return matching[i].Name < matching[j].Name
+	})
+	return matching, skipped
+}
+
+// ApplyNodeOverrides applies the first matching override that is valid, in the order of MatchingNodeOverrides,
+// on top of result. An invalid override is skipped in favour of the next one; if none is left, result keeps the
+// template's quantities. It returns the applied override, or nil, and every override it skipped.
+func ApplyNodeOverrides(result *CalculationResult, overrides []flexdaemonsetsv1alpha1.FlexNodeOverride, node *corev1.Node, nodeAllocatable corev1.ResourceList, ds *appsv1.DaemonSet, templateName string, now time.Time) (*flexdaemonsetsv1alpha1.FlexNodeOverride, []SkippedNodeOverride) {
+	matching, skipped := MatchingNodeOverrides(overrides, node, ds, templateName, now)
+	for _, override := range matching {
+		if err := ApplyNodeOverride(result, override, nodeAllocatable); err != nil {
+			skipped = append(skipped, SkippedNodeOverride{Override: override, Err: err})
+			continue
+		}
+		return override, skipped
+	}
+	return nil, skipped
+}
+
+func overrideSpecificity(override *flexdaemonsetsv1alpha1.FlexNodeOverride) int {
+	specificity := 0
+	if override.Spec.DaemonSet != nil {
+		specificity += 2
+	}
+	if override.Spec.NodeName != "" {
+		specificity++
+	}
+	return specificity
+}
+
+// ApplyNodeOverride replaces the quantities of result with those of the override. It must be called after the
+// template calculation, so the override always has the last word. A percentage is taken of the node's
+// allocatable; if the node does not report the resource, the template's result is kept. Every entry is evaluated
+// before result is changed, so an invalid override returns an error and leaves result untouched.
+func ApplyNodeOverride(result *CalculationResult, override *flexdaemonsetsv1alpha1.FlexNodeOverride, nodeAllocatable corev1.ResourceList) error {
+	type replacement struct {
+		name     corev1.ResourceName
+		quantity *resource.Quantity
+	}
+	var replacements []replacement
+	for _, o := range override.Spec.Resources {
+		var quantity *resource.Quantity
+		if o.Quantity != "" {
+			parsed, err := resource.ParseQuantity(o.Quantity)
+			if err != nil {
+				return fmt.Errorf("failed to parse quantity '%s' for %s in FlexNodeOverride %s: %w", o.Quantity, o.Resource, override.Name, err)
+			}
+			quantity = &parsed
+		} else if o.Percentage != nil {
+			rule := resourceRule{name: o.Resource, field: "Percentage", percentage: *o.Percentage, milli: o.Resource == corev1.ResourceCPU}
+			if _, ok := nodeAllocatable[o.Resource]; !ok {
+				log.Info("Node has no allocatable information for overridden resource, keeping template result", "resource", o.Resource, "override", override.Name)
+				continue
+			}
+			step, err := calculateResource(rule, nodeAllocatable)
+			if err != nil {
+				return err
+			}
+			quantity = step.Result
+		}
+		if quantity == nil || !isPositive(quantity, o.Resource == corev1.ResourceCPU) {
+			log.Info("Override calculates to zero, keeping template result", "resource", o.Resource, "override", override.Name)
+			continue
+		}
+		replacements = append(replacements, replacement{name: o.Resource, quantity: quantity})
+	}
+	for _, r := range replacements {
+		result.Resources[r.name] = *r.quantity
+		result.Bounds[r.name] = BoundOverride
+		result.setOverrideStep(r.name, r.quantity, override.Name)
+	}
+	result.Override = override.Name
+	return nil
+}
+
+// setOverrideStep records on the resource's step that the override replaced the template's result.
+func (r *CalculationResult) setOverrideStep(name corev1.ResourceName, quantity *resource.Quantity, overrideName string) {
+	for i := range r.Steps {
+		if r.Steps[i].Resource == name {
+			r.Steps[i].Result, r.Steps[i].Bound, r.Steps[i].Override = quantity, BoundOverride, overrideName
+			return
+		}
+	}
+	r.Steps = append(r.Steps, CalculationStep{Resource: name, Result: quantity, Bound: BoundOverride, Override: overrideName})
+}
@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+)
+
+var overrideTestNow = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func overrideTestObjects() (*corev1.Node, *appsv1.DaemonSet) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: map[string]string{"pool": "gpu"}},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU:              resource.MustParse("8"),
+			corev1.ResourceMemory:           resource.MustParse("32Gi"),
+			corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+		}},
+	}
+	ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "node-exporter"}}
+	return node, ds
+}
+
+func nodeOverride(name string, mutate func(*flexdaemonsetsv1alpha1.FlexNodeOverrideSpec)) flexdaemonsetsv1alpha1.FlexNodeOverride {
+	override := flexdaemonsetsv1alpha1.FlexNodeOverride{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: flexdaemonsetsv1alpha1.FlexNodeOverrideSpec{
+			TemplateName: "small",
+			NodeName:     "node-a",
+			Resources:    []flexdaemonsetsv1alpha1.ResourceOverride{{Resource: corev1.ResourceCPU, Quantity: "2"}},
+		},
+	}
+	if mutate != nil {
+		mutate(&override.Spec)
+	}
+	return override
+}
+
+func TestNodeOverrideMatches(t *testing.T) {
+	node, ds := overrideTestObjects()
+	past := metav1.NewTime(overrideTestNow.Add(-time.Minute))
+	future := metav1.NewTime(overrideTestNow.Add(time.Minute))
+
+	tests := []struct {
+		name    string
+		mutate  func(*flexdaemonsetsv1alpha1.FlexNodeOverrideSpec)
+		want    bool
+		wantErr bool
+	}{
+		{name: "template and node name", want: true},
+		{name: "other template", mutate: func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) { s.TemplateName = "large" }},
+		{name: "other node", mutate: func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) { s.NodeName = "node-b" }},
+		{name: "daemonset reference", want: true, mutate: func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) {
+			s.TemplateName, s.DaemonSet = "", &flexdaemonsetsv1alpha1.DaemonSetReference{Namespace: "monitoring", Name: "node-exporter"}
+		}},
+		{name: "other daemonset", mutate: func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) {
+			s.TemplateName, s.DaemonSet = "", &flexdaemonsetsv1alpha1.DaemonSetReference{Namespace: "logging", Name: "node-exporter"}
+		}},
+		{name: "node selector", want: true, mutate: func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) {
+			s.NodeName, s.NodeSelector = "", &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "gpu"}}
+		}},
+		{name: "node selector not matching", mutate: func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) {
+			s.NodeName, s.NodeSelector = "", &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "cpu"}}
+		}},
+		{name: "invalid node selector", wantErr: true, mutate: func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) {
+			s.NodeName, s.NodeSelector = "", &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "pool", Operator: "Bogus"}}}
+		}},
+		{name: "expired", mutate: func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) { s.ExpiresAt = &past }},
+		{name: "not yet expired", want: true, mutate: func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) { s.ExpiresAt = &future }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			override := nodeOverride("o", tt.mutate)
+			got, err := NodeOverrideMatches(&override, node, ds, "small", overrideTestNow)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchingNodeOverridesPrecedence(t *testing.T) {
+	node, ds := overrideTestObjects()
+	bySelector := func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) {
+		s.NodeName, s.NodeSelector = "", &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "gpu"}}
+	}
+	byDaemonSet := func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) {
+		s.TemplateName, s.DaemonSet = "", &flexdaemonsetsv1alpha1.DaemonSetReference{Namespace: "monitoring", Name: "node-exporter"}
+	}
+	overrides := []flexdaemonsetsv1alpha1.FlexNodeOverride{
+		nodeOverride("template-selector", bySelector),
+		nodeOverride("template-node-b", nil),
+		nodeOverride("template-node-a", nil),
+		nodeOverride("daemonset-selector", func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) { byDaemonSet(s); bySelector(s) }),
+		nodeOverride("daemonset-node", byDaemonSet),
+		nodeOverride("unrelated", func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) { s.TemplateName = "large" }),
+	}
+	matching, skipped := MatchingNodeOverrides(overrides, node, ds, "small", overrideTestNow)
+	if len(skipped) != 0 {
+		t.Fatalf("unexpected skipped overrides: %v", skipped)
+	}
+	want := []string{"daemonset-node", "daemonset-selector", "template-node-a", "template-node-b", "template-selector"}
+	if len(matching) != len(want) {
+		t.Fatalf("got %d matching overrides, want %d", len(matching), len(want))
+	}
+	for i, name := range want {
+		if matching[i].Name != name {
+			t.Errorf("position %d: got %s, want %s", i, matching[i].Name, name)
+		}
+	}
+}
+
+func TestApplyNodeOverrides(t *testing.T) {
+	node, ds := overrideTestObjects()
+	template := &flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec{CPUPercentage: 10, MemoryPercentage: 10, StoragePercentage: 10}
+	percentage := func(p int32) *int32 { return &p }
+
+	tests := []struct {
+		name        string
+		overrides   []flexdaemonsetsv1alpha1.FlexNodeOverride
+		wantApplied string
+		wantSkipped int
+		want        corev1.ResourceList
+	}{
+		{
+			name: "no override keeps the template result",
+			want: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("800m"), corev1.ResourceMemory: resource.MustParse("3435973836")},
+		},
+		{
+			name:        "quantity and percentage",
+			wantApplied: "o",
+			overrides: []flexdaemonsetsv1alpha1.FlexNodeOverride{nodeOverride("o", func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) {
+				s.Resources = []flexdaemonsetsv1alpha1.ResourceOverride{
+					{Resource: corev1.ResourceCPU, Quantity: "2"},
+					{Resource: corev1.ResourceMemory, Percentage: percentage(50)},
+				}
+			})},
+			want: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("16Gi")},
+		},
+		{
+			name:        "invalid entry leaves the result untouched",
+			wantSkipped: 1,
+			overrides: []flexdaemonsetsv1alpha1.FlexNodeOverride{nodeOverride("broken", func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) {
+				s.Resources = []flexdaemonsetsv1alpha1.ResourceOverride{
+					{Resource: corev1.ResourceCPU, Quantity: "2"},
+					{Resource: corev1.ResourceMemory, Quantity: "lots"},
+				}
+			})},
+			want: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("800m"), corev1.ResourceMemory: resource.MustParse("3435973836")},
+		},
+		{
+			name:        "invalid override falls back to the next match",
+			wantApplied: "fallback",
+			wantSkipped: 1,
+			overrides: []flexdaemonsetsv1alpha1.FlexNodeOverride{
+				nodeOverride("broken", func(s *flexdaemonsetsv1alpha1.FlexNodeOverrideSpec) {
+					s.TemplateName, s.DaemonSet = "", &flexdaemonsetsv1alpha1.DaemonSetReference{Namespace: "monitoring", Name: "node-exporter"}
+					s.Resources = []flexdaemonsetsv1alpha1.ResourceOverride{{Resource: corev1.ResourceCPU, Quantity: "lots"}}
+				}),
+				nodeOverride("fallback", nil),
+			},
+			want: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("3435973836")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := CalculatePodResourcesWithBounds(template, node.Status.Allocatable)
+			if err != nil {
+				t.Fatal(err)
+			}
+			applied, skipped := ApplyNodeOverrides(result, tt.overrides, node, node.Status.Allocatable, ds, "small", overrideTestNow)
+			if got := ""; applied != nil {
+				got = applied.Name
+				if got != tt.wantApplied {
+					t.Errorf("applied %q, want %q", got, tt.wantApplied)
+				}
+			} else if tt.wantApplied != "" {
+				t.Errorf("nothing applied, want %q", tt.wantApplied)
+			}
+			if len(skipped) != tt.wantSkipped {
+				t.Errorf("skipped %v, want %d", skipped, tt.wantSkipped)
+			}
+			if result.Override != tt.wantApplied {
+				t.Errorf("result.Override = %q, want %q", result.Override, tt.wantApplied)
+			}
+			for name, want := range tt.want {
+				if got := result.Resources[name]; got.Cmp(want) != 0 {
+					t.Errorf("%s = %s, want %s", name, got.String(), want.String())
+				}
+				wantBound := result.Bounds[name] == BoundOverride
+				if overridden := applied != nil && hasOverrideEntry(applied, name); wantBound != overridden {
+					t.Errorf("%s bound = %q, overridden %v", name, result.Bounds[name], overridden)
+				}
+			}
+		})
+	}
+}
+
+func hasOverrideEntry(override *flexdaemonsetsv1alpha1.FlexNodeOverride, name corev1.ResourceName) bool {
+	for _, o := range override.Spec.Resources {
+		if o.Resource == name {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,9 @@ package utils
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+
 	// "math" // Not strictly required for math.Max as we are using Quantity.Cmp
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource" // Required for resource.Quantity
@@ -12,6 +15,108 @@ import (
 
 var log = ctrl.Log.WithName("utils").WithName("resources")
 
+// CalculatorVersion identifies the calculation rules implemented by CalculatePodResources. It is recorded in
+// sizing audit records and must be bumped whenever a change would produce different results for the same input.
+const CalculatorVersion = "v1"
+
+// Bound records which rule of the template determined a calculated quantity.
+type Bound string
+
+const (
+	// BoundPercentage means the quantity is the template percentage of the node's allocatable.
+	BoundPercentage Bound = "percentage"
+	// BoundFloor means the percentage fell below the template minimum (or the node reports no allocatable),
+	// so the minimum was used instead.
+	BoundFloor Bound = "floor"
+	// BoundCap means the percentage exceeded the template maximum, so the maximum was used instead.
+	BoundCap Bound = "cap"
+)
+
+// CalculationResult is the outcome of sizing a pod for a node.
+type CalculationResult struct {
+	// Resources are the quantities to use as both requests and limits. Resources that calculate to zero are omitted.
+	Resources corev1.ResourceList
+	// Bounds records, for every resource in Resources, which rule of the template produced it.
+	Bounds map[corev1.ResourceName]Bound
+	// Override is the name of the FlexNodeOverride applied by ApplyNodeOverride, if any.
+	Override string
+	// Steps explains, resource by resource, how Resources were derived.
+	Steps []CalculationStep
+}
+
+// CalculationStep records how one resource was calculated, for tracing a decision after the fact.
+type CalculationStep struct {
+	Resource corev1.ResourceName `json:"resource"`
+	// Allocatable is the node's allocatable the percentage was taken of; nil if the node does not report it.
+	Allocatable *resource.Quantity `json:"allocatable,omitempty"`
+	Percentage  int32              `json:"percentage"`
+	// FromPercentage is Percentage of Allocatable; nil without allocatable.
+	FromPercentage *resource.Quantity `json:"fromPercentage,omitempty"`
+	Minimum        *resource.Quantity `json:"minimum,omitempty"`
+	Maximum        *resource.Quantity `json:"maximum,omitempty"`
+	// Result is the quantity used; nil if the resource is not requested.
+	Result *resource.Quantity `json:"result,omitempty"`
+	Bound  Bound              `json:"bound,omitempty"`
+	// Override names the FlexNodeOverride that replaced the template's result.
+	Override string `json:"override,omitempty"`
+}
+
+// resourceRule is the part of a FlexDaemonsetTemplateSpec that applies to a single resource.
+type resourceRule struct {
+	name       corev1.ResourceName
+	field      string // Template field holding the minimum, used in logs and errors.
+	maxField   string // Template field holding the maximum.
+	percentage int32
+	min        string
+	max        string
+	// milli selects milli-unit arithmetic (CPU); other resources are calculated in whole units.
+	milli bool
+}
+
+func templateResourceRules(templateSpec *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) []resourceRule {
+	return []resourceRule{
+		{name: corev1.ResourceCPU, field: "MinCPU", maxField: "MaxCPU", percentage: templateSpec.CPUPercentage,
+			min: templateSpec.MinCPU, max: templateSpec.MaxCPU, milli: true},
+		{name: corev1.ResourceMemory, field: "MinMemory", maxField: "MaxMemory", percentage: templateSpec.MemoryPercentage,
+			min: templateSpec.MinMemory, max: templateSpec.MaxMemory},
+		{name: corev1.ResourceEphemeralStorage, field: "MinStorage", maxField: "MaxStorage", percentage: templateSpec.StoragePercentage,
+			min: templateSpec.MinStorage, max: templateSpec.MaxStorage},
+	}
+}
+
+// ValidateTemplateSpec reports the first template field the calculation cannot use on any node: a minimum or
+// maximum that does not parse as a quantity, or a maximum below its minimum.
+func ValidateTemplateSpec(templateSpec *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) error {
+	for _, rule := range templateResourceRules(templateSpec) {
+		if _, _, err := rule.parseBounds(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseBounds parses the rule's minimum and maximum; either is nil when unset.
+func (rule resourceRule) parseBounds() (min, max *resource.Quantity, err error) {
+	if rule.min != "" {
+		parsed, err := resource.ParseQuantity(rule.min)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s '%s': %w", rule.field, rule.min, err)
+		}
+		min = &parsed
+	}
+	if rule.max != "" {
+		parsed, err := resource.ParseQuantity(rule.max)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s '%s': %w", rule.maxField, rule.max, err)
+		}
+		max = &parsed
+	}
+	if min != nil && max != nil && max.Cmp(*min) < 0 {
+		return nil, nil, fmt.Errorf("%s '%s' is below %s '%s'", rule.maxField, rule.max, rule.field, rule.min)
+	}
+	return min, max, nil
+}
+
 // CalculatePodResources calculates the desired resource requests and limits for a pod's containers
 // based on the FlexDaemonsetTemplate and the node's allocatable resources.
 // For now, we'll set requests and limits to be the same, as is common for critical workloads like DaemonSets.
@@ -19,152 +124,171 @@ func CalculatePodResources(
 	templateSpec *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec,
 	nodeAllocatable corev1.ResourceList,
 ) (corev1.ResourceList, error) {
+	result, err := CalculatePodResourcesWithBounds(templateSpec, nodeAllocatable)
+	if err != nil {
+		return nil, err
+	}
+	return result.Resources, nil
+}
 
-	calculatedResources := corev1.ResourceList{}
-	// var err error // This was unused. If it was intended for future use, it should be uncommented.
-	// For now, commenting out to fix build error. If any function call here can return an error, it should be handled.
-
-	// Calculate CPU
-	cpuAllocatable, ok := nodeAllocatable[corev1.ResourceCPU]
-	if !ok {
-		log.Info("Node has no allocatable CPU information. Cannot calculate CPU percentage.")
-		// Fallback to MinCPU if specified, otherwise, no CPU is requested.
-		if templateSpec.MinCPU != "" {
-			parsedMinCPU, parseErr := resource.ParseQuantity(templateSpec.MinCPU)
-			if parseErr != nil {
-				log.Error(parseErr, "Failed to parse MinCPU", "MinCPU", templateSpec.MinCPU)
-				return nil, fmt.Errorf("failed to parse MinCPU '%s': %w", templateSpec.MinCPU, parseErr)
-			}
-			if parsedMinCPU.MilliValue() > 0 { // Only add if MinCPU itself is > 0
-				calculatedResources[corev1.ResourceCPU] = parsedMinCPU
-			} else {
-				log.Info("MinCPU is specified but parses to zero or less, requesting no CPU.", "MinCPU", templateSpec.MinCPU)
-			}
-		} else {
-			log.Info("Node has no allocatable CPU and no MinCPU specified, requesting no CPU.")
-		}
-	} else {
-		// Calculate CPU based on percentage
-		cpuPercentageValue := float64(cpuAllocatable.MilliValue()) * (float64(templateSpec.CPUPercentage) / 100.0)
-		calculatedCPU := resource.NewMilliQuantity(int64(cpuPercentageValue), resource.DecimalSI)
-
-		minCPUQuantitySet := false
-		var minCPUQuantity resource.Quantity
-		if templateSpec.MinCPU != "" {
-			var parseErr error
-			minCPUQuantity, parseErr = resource.ParseQuantity(templateSpec.MinCPU)
-			if parseErr != nil {
-				log.Error(parseErr, "Failed to parse MinCPU", "MinCPU", templateSpec.MinCPU)
-				return nil, fmt.Errorf("failed to parse MinCPU '%s': %w", templateSpec.MinCPU, parseErr)
-			}
-			minCPUQuantitySet = true
+// CalculatePodResourcesWithBounds is CalculatePodResources, additionally reporting which bound of the
+// template determined each resource.
+func CalculatePodResourcesWithBounds(
+	templateSpec *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec,
+	nodeAllocatable corev1.ResourceList,
+) (*CalculationResult, error) {
+	result := &CalculationResult{
+		Resources: corev1.ResourceList{},
+		Bounds:    map[corev1.ResourceName]Bound{},
+	}
+	for _, rule := range templateResourceRules(templateSpec) {
+		step, err := calculateResource(rule, nodeAllocatable)
+		if err != nil {
+			return nil, err
 		}
-
-		// If MinCPU is specified and calculated CPU is less than MinCPU, use MinCPU.
-		if minCPUQuantitySet && calculatedCPU.Cmp(minCPUQuantity) < 0 {
-			log.Info("Calculated CPU is less than MinCPU, using MinCPU", "calculatedCPU", calculatedCPU.String(), "minCPU", minCPUQuantity.String())
-			calculatedCPU = &minCPUQuantity
+		result.Steps = append(result.Steps, step)
+		if step.Result == nil {
+			continue
 		}
+		result.Resources[rule.name] = *step.Result
+		result.Bounds[rule.name] = step.Bound
+	}
+	log.Info("Calculated pod resources", "resources", fmt.Sprintf("%v", result.Resources))
+	return result, nil
+}
 
-		// Only add CPU to resources if it's greater than 0.
-		if calculatedCPU.MilliValue() > 0 {
-			calculatedResources[corev1.ResourceCPU] = *calculatedCPU
-		} else {
-			log.Info("Calculated CPU (after considering MinCPU if any) is zero or less. Requesting no CPU.", "finalCalculatedCPU", calculatedCPU.String())
-		}
+// calculateResource applies one resource rule. The step's Result is nil if the resource should not be requested.
+func calculateResource(rule resourceRule, nodeAllocatable corev1.ResourceList) (CalculationStep, error) {
+	step := CalculationStep{Resource: rule.name, Percentage: rule.percentage}
+	minQuantity, maxQuantity, err := rule.parseBounds()
+	if err != nil {
+		log.Error(err, "Invalid template bounds", "resource", rule.name)
+		return step, err
 	}
+	step.Minimum, step.Maximum = minQuantity, maxQuantity
 
-	// Calculate Memory
-	memoryAllocatable, ok := nodeAllocatable[corev1.ResourceMemory]
+	allocatable, ok := nodeAllocatable[rule.name]
 	if !ok {
-		log.Info("Node has no allocatable Memory information. Cannot calculate Memory percentage.")
-		if templateSpec.MinMemory != "" {
-			parsedMinMemory, parseErr := resource.ParseQuantity(templateSpec.MinMemory)
-			if parseErr != nil {
-				log.Error(parseErr, "Failed to parse MinMemory", "MinMemory", templateSpec.MinMemory)
-				return nil, fmt.Errorf("failed to parse MinMemory '%s': %w", templateSpec.MinMemory, parseErr)
-			}
-			if parsedMinMemory.Value() > 0 {
-				calculatedResources[corev1.ResourceMemory] = parsedMinMemory
-			} else {
-				log.Info("MinMemory is specified but parses to zero or less, requesting no Memory.", "MinMemory", templateSpec.MinMemory)
-			}
-		} else {
-			log.Info("Node has no allocatable Memory and no MinMemory specified, requesting no Memory.")
+		// Fallback to the minimum if specified, otherwise the resource is not requested.
+		log.Info("Node has no allocatable information for resource. Cannot calculate percentage.", "resource", rule.name)
+		if minQuantity == nil {
+			log.Info("Node has no allocatable resource and no minimum specified, not requesting it.", "resource", rule.name)
+			return step, nil
 		}
-	} else {
-		memoryPercentageValue := float64(memoryAllocatable.Value()) * (float64(templateSpec.MemoryPercentage) / 100.0)
-		calculatedMemory := resource.NewQuantity(int64(memoryPercentageValue), resource.BinarySI)
-
-		minMemoryQuantitySet := false
-		var minMemoryQuantity resource.Quantity
-		if templateSpec.MinMemory != "" {
-			var parseErr error
-			minMemoryQuantity, parseErr = resource.ParseQuantity(templateSpec.MinMemory)
-			if parseErr != nil {
-				log.Error(parseErr, "Failed to parse MinMemory", "MinMemory", templateSpec.MinMemory)
-				return nil, fmt.Errorf("failed to parse MinMemory '%s': %w", templateSpec.MinMemory, parseErr)
-			}
-			minMemoryQuantitySet = true
+		if !isPositive(minQuantity, rule.milli) { // Only add if the minimum itself is > 0
+			log.Info("Minimum is specified but parses to zero or less, not requesting resource.", "field", rule.field, "value", rule.min)
+			return step, nil
 		}
+		step.Result, step.Bound = minQuantity, BoundFloor
+		return step, nil
+	}
+	step.Allocatable = &allocatable
 
-		if minMemoryQuantitySet && calculatedMemory.Cmp(minMemoryQuantity) < 0 {
-			log.Info("Calculated Memory is less than MinMemory, using MinMemory", "calculatedMemory", calculatedMemory.String(), "minMemory", minMemoryQuantity.String())
-			calculatedMemory = &minMemoryQuantity
-		}
+	// Calculate based on percentage
+	var calculated *resource.Quantity
+	if rule.milli {
+		value := float64(allocatable.MilliValue()) * (float64(rule.percentage) / 100.0)
+		calculated = resource.NewMilliQuantity(int64(value), resource.DecimalSI)
+	} else {
+		value := float64(allocatable.Value()) * (float64(rule.percentage) / 100.0)
+		calculated = resource.NewQuantity(int64(value), resource.BinarySI)
+	}
+	step.FromPercentage = calculated
+	bound := BoundPercentage
 
-		if calculatedMemory.Value() > 0 {
-			calculatedResources[corev1.ResourceMemory] = *calculatedMemory
-		} else {
-			log.Info("Calculated Memory (after considering MinMemory if any) is zero or less. Requesting no Memory.", "finalCalculatedMemory", calculatedMemory.String())
-		}
+	// If a minimum is specified and the calculated quantity is less than it, use the minimum.
+	if minQuantity != nil && calculated.Cmp(*minQuantity) < 0 {
+		log.Info("Calculated quantity is less than minimum, using minimum", "resource", rule.name, "calculated", calculated.String(), "minimum", minQuantity.String())
+		calculated = minQuantity
+		bound = BoundFloor
 	}
 
-	// Calculate Ephemeral Storage
-	storageAllocatable, ok := nodeAllocatable[corev1.ResourceEphemeralStorage]
-	if !ok {
-		log.Info("Node has no allocatable EphemeralStorage information. Cannot calculate Storage percentage.")
-		if templateSpec.MinStorage != "" {
-			parsedMinStorage, parseErr := resource.ParseQuantity(templateSpec.MinStorage)
-			if parseErr != nil {
-				log.Error(parseErr, "Failed to parse MinStorage", "MinStorage", templateSpec.MinStorage)
-				return nil, fmt.Errorf("failed to parse MinStorage '%s': %w", templateSpec.MinStorage, parseErr)
-			}
-			if parsedMinStorage.Value() > 0 {
-				calculatedResources[corev1.ResourceEphemeralStorage] = parsedMinStorage
-			} else {
-				log.Info("MinStorage is specified but parses to zero or less, requesting no Storage.", "MinStorage", templateSpec.MinStorage)
-			}
-		} else {
-			log.Info("Node has no allocatable Storage and no MinStorage specified, requesting no Storage.")
-		}
-	} else {
-		storagePercentageValue := float64(storageAllocatable.Value()) * (float64(templateSpec.StoragePercentage) / 100.0)
-		calculatedStorage := resource.NewQuantity(int64(storagePercentageValue), resource.BinarySI)
-
-		minStorageQuantitySet := false
-		var minStorageQuantity resource.Quantity
-		if templateSpec.MinStorage != "" {
-			var parseErr error
-			minStorageQuantity, parseErr = resource.ParseQuantity(templateSpec.MinStorage)
-			if parseErr != nil {
-				log.Error(parseErr, "Failed to parse MinStorage", "MinStorage", templateSpec.MinStorage)
-				return nil, fmt.Errorf("failed to parse MinStorage '%s': %w", templateSpec.MinStorage, parseErr)
-			}
-			minStorageQuantitySet = true
-		}
+	// If a maximum is specified and the calculated quantity exceeds it, use the maximum. Validation keeps the
+	// maximum at or above the minimum, so the cap never undoes the floor.
+	if maxQuantity != nil && calculated.Cmp(*maxQuantity) > 0 {
+		log.Info("Calculated quantity is more than maximum, using maximum", "resource", rule.name, "calculated", calculated.String(), "maximum", maxQuantity.String())
+		calculated = maxQuantity
+		bound = BoundCap
+	}
 
-		if minStorageQuantitySet && calculatedStorage.Cmp(minStorageQuantity) < 0 {
-			log.Info("Calculated Storage is less than MinStorage, using MinStorage", "calculatedStorage", calculatedStorage.String(), "minStorage", minStorageQuantity.String())
-			calculatedStorage = &minStorageQuantity
-		}
+	// Only request the resource if it's greater than 0.
+	if !isPositive(calculated, rule.milli) {
+		log.Info("Calculated quantity (after considering minimum if any) is zero or less. Not requesting resource.", "resource", rule.name, "final", calculated.String())
+		return step, nil
+	}
+	step.Result, step.Bound = calculated, bound
+	return step, nil
+}
 
-		if calculatedStorage.Value() > 0 {
-			calculatedResources[corev1.ResourceEphemeralStorage] = *calculatedStorage
-		} else {
-			log.Info("Calculated Storage (after considering MinStorage if any) is zero or less. Requesting no Storage.", "finalCalculatedStorage", calculatedStorage.String())
-		}
+func isPositive(q *resource.Quantity, milli bool) bool {
+	if milli {
+		return q.MilliValue() > 0
+	}
+	return q.Value() > 0
+}
+
+// Describe renders the result as "cpu=1843m (percentage), memory=64Mi (floor)" for events and annotations,
+// followed by the name of the FlexNodeOverride if one was applied.
+func (r *CalculationResult) Describe() string {
+	if len(r.Resources) == 0 {
+		return "no resources"
+	}
+	parts := make([]string, 0, len(r.Resources))
+	for _, name := range sortedResourceNames(r.Resources) {
+		quantity := r.Resources[name]
+		parts = append(parts, fmt.Sprintf("%s=%s (%s)", name, quantity.String(), r.Bounds[name]))
+	}
+	if r.Override != "" {
+		return strings.Join(parts, ", ") + " [FlexNodeOverride " + r.Override + "]"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DescribeResources renders a resource list as "cpu=500m, memory=1Gi", sorted by resource name.
+func DescribeResources(resources corev1.ResourceList) string {
+	if len(resources) == 0 {
+		return "none"
+	}
+	parts := make([]string, 0, len(resources))
+	for _, name := range sortedResourceNames(resources) {
+		quantity := resources[name]
+		parts = append(parts, fmt.Sprintf("%s=%s", name, quantity.String()))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sortedResourceNames(resources corev1.ResourceList) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// TemplateDefaults are fleet-wide values for the fields a FlexDaemonsetTemplate leaves unset.
+type TemplateDefaults struct {
+	// MinCPU, MinMemory and MinStorage are the floors used by templates that set no minimum of their own.
+	MinCPU     string `json:"minCPU,omitempty"`
+	MinMemory  string `json:"minMemory,omitempty"`
+	MinStorage string `json:"minStorage,omitempty"`
+}
+
+// Apply returns templateSpec with the defaults filled in. templateSpec itself is not modified; it is returned
+// unchanged when there is nothing to fill in.
+func (d TemplateDefaults) Apply(templateSpec *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec {
+	if d == (TemplateDefaults{}) {
+		return templateSpec
+	}
+	spec := templateSpec.DeepCopy()
+	if spec.MinCPU == "" {
+		spec.MinCPU = d.MinCPU
+	}
+	if spec.MinMemory == "" {
+		spec.MinMemory = d.MinMemory
+	}
+	if spec.MinStorage == "" {
+		spec.MinStorage = d.MinStorage
 	}
-	log.Info("Calculated pod resources", "resources", fmt.Sprintf("%v", calculatedResources))
-	return calculatedResources, nil
+	return spec
 }
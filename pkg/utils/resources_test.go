@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+)
+
+func TestCalculatePodResourcesWithBounds(t *testing.T) {
+	allocatable := corev1.ResourceList{
+		corev1.ResourceCPU:              resource.MustParse("8"),
+		corev1.ResourceMemory:           resource.MustParse("32Gi"),
+		corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+	}
+
+	tests := []struct {
+		name        string
+		mutate      func(*flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec)
+		allocatable corev1.ResourceList
+		wantCPU     string
+		wantBound   Bound
+	}{
+		{name: "percentage", wantCPU: "800m", wantBound: BoundPercentage},
+		{name: "floor", wantCPU: "1", wantBound: BoundFloor,
+			mutate: func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) { s.MinCPU = "1" }},
+		{name: "cap", wantCPU: "500m", wantBound: BoundCap,
+			mutate: func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) { s.MaxCPU = "500m" }},
+		{name: "between floor and cap", wantCPU: "800m", wantBound: BoundPercentage,
+			mutate: func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) { s.MinCPU, s.MaxCPU = "100m", "1" }},
+		{name: "floor equal to cap", wantCPU: "2", wantBound: BoundFloor,
+			mutate: func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) { s.MinCPU, s.MaxCPU = "2", "2" }},
+		{name: "no allocatable uses the floor", allocatable: corev1.ResourceList{}, wantCPU: "100m", wantBound: BoundFloor,
+			mutate: func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) { s.MinCPU, s.MaxCPU = "100m", "1" }},
+		{name: "no allocatable and no floor", allocatable: corev1.ResourceList{},
+			mutate: func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) { s.MaxCPU = "1" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec{CPUPercentage: 10, MemoryPercentage: 10, StoragePercentage: 10}
+			if tt.mutate != nil {
+				tt.mutate(spec)
+			}
+			nodeAllocatable := allocatable
+			if tt.allocatable != nil {
+				nodeAllocatable = tt.allocatable
+			}
+			result, err := CalculatePodResourcesWithBounds(spec, nodeAllocatable)
+			if err != nil {
+				t.Fatalf("CalculatePodResourcesWithBounds() error = %v", err)
+			}
+			cpu, ok := result.Resources[corev1.ResourceCPU]
+			if tt.wantCPU == "" {
+				if ok {
+					t.Fatalf("cpu = %s, want not requested", cpu.String())
+				}
+				return
+			}
+			if want := resource.MustParse(tt.wantCPU); !ok || cpu.Cmp(want) != 0 {
+				t.Errorf("cpu = %s, want %s", cpu.String(), tt.wantCPU)
+			}
+			if got := result.Bounds[corev1.ResourceCPU]; got != tt.wantBound {
+				t.Errorf("cpu bound = %q, want %q", got, tt.wantBound)
+			}
+		})
+	}
+}
+
+func TestValidateTemplateSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec)
+		wantErr string
+	}{
+		{name: "no bounds"},
+		{name: "minimum and maximum", mutate: func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) {
+			s.MinMemory, s.MaxMemory = "64Mi", "1Gi"
+		}},
+		{name: "unparsable minimum", wantErr: "MinCPU", mutate: func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) {
+			s.MinCPU = "lots"
+		}},
+		{name: "unparsable maximum", wantErr: "MaxStorage", mutate: func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) {
+			s.MaxStorage = "lots"
+		}},
+		{name: "maximum below minimum", wantErr: "MaxMemory '32Mi' is below MinMemory '64Mi'", mutate: func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) {
+			s.MinMemory, s.MaxMemory = "64Mi", "32Mi"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec{CPUPercentage: 10, MemoryPercentage: 10, StoragePercentage: 10}
+			if tt.mutate != nil {
+				tt.mutate(spec)
+			}
+			err := ValidateTemplateSpec(spec)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("ValidateTemplateSpec() error = %v, want none", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("ValidateTemplateSpec() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
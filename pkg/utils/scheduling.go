@@ -0,0 +1,116 @@
+package utils
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReplaceNodeNameNodeAffinity pins a pod to a single node by replacing the required node affinity
+// terms with a match on the node's metadata.name, mirroring what the upstream DaemonSet controller does
+// (k8s.io/kubernetes/pkg/controller/daemon/util.ReplaceDaemonSetPodNodeNameNodeAffinity).
+// Pod affinity, pod anti-affinity and preferred node affinity terms are preserved as-is.
+// The passed affinity is not modified; a new (deep-copied) Affinity is returned.
+func ReplaceNodeNameNodeAffinity(affinity *corev1.Affinity, nodeName string) *corev1.Affinity {
+	nodeSelReq := corev1.NodeSelectorRequirement{
+		Key:      metav1.ObjectNameField,
+		Operator: corev1.NodeSelectorOpIn,
+		Values:   []string{nodeName},
+	}
+
+	nodeSelector := &corev1.NodeSelector{
+		NodeSelectorTerms: []corev1.NodeSelectorTerm{
+			{
+				MatchFields: []corev1.NodeSelectorRequirement{nodeSelReq},
+			},
+		},
+	}
+
+	if affinity == nil {
+		return &corev1.Affinity{
+			NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: nodeSelector,
+			},
+		}
+	}
+
+	merged := affinity.DeepCopy()
+	if merged.NodeAffinity == nil {
+		merged.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	// The node was already selected by the DaemonSet's scheduling predicates (or explicitly targeted by the FDNP),
+	// so, like the DaemonSet controller, the original required terms are replaced rather than ANDed.
+	merged.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = nodeSelector
+	return merged
+}
+
+// DaemonPodNodeName returns the node a daemon pod is bound to: spec.nodeName once set, otherwise the single node
+// named by the required metadata.name node affinity the DaemonSet controller adds before the pod is scheduled
+// (see ReplaceNodeNameNodeAffinity). It returns "" if the pod is not pinned to one node.
+func DaemonPodNodeName(pod *corev1.Pod) string {
+	if pod.Spec.NodeName != "" {
+		return pod.Spec.NodeName
+	}
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return ""
+	}
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 {
+		return ""
+	}
+	for _, requirement := range terms[0].MatchFields {
+		if requirement.Key == metav1.ObjectNameField && requirement.Operator == corev1.NodeSelectorOpIn && len(requirement.Values) == 1 {
+			return requirement.Values[0]
+		}
+	}
+	return ""
+}
+
+// daemonPodTolerations are the tolerations the upstream DaemonSet controller adds to every daemon pod
+// so that it is not evicted or blocked by node conditions that do not affect node-local agents.
+var daemonPodTolerations = []corev1.Toleration{
+	{Key: corev1.TaintNodeNotReady, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	{Key: corev1.TaintNodeUnreachable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	{Key: corev1.TaintNodeDiskPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodeMemoryPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodePIDPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodeUnschedulable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+}
+
+// AddDaemonPodTolerations appends the default DaemonSet tolerations to the given pod spec,
+// skipping any that the spec already carries. Host-network pods additionally tolerate network-unavailable.
+func AddDaemonPodTolerations(spec *corev1.PodSpec) {
+	tolerations := daemonPodTolerations
+	if spec.HostNetwork {
+		tolerations = append(append([]corev1.Toleration{}, tolerations...), corev1.Toleration{
+			Key:      corev1.TaintNodeNetworkUnavailable,
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffectNoSchedule,
+		})
+	}
+
+	for _, toleration := range tolerations {
+		found := false
+		for i := range spec.Tolerations {
+			if spec.Tolerations[i].MatchToleration(&toleration) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			spec.Tolerations = append(spec.Tolerations, toleration)
+		}
+	}
+}
+
+// PodUnschedulableCondition returns the PodScheduled condition of the pod if the scheduler
+// reported that it could not place the pod, or nil otherwise.
+func PodUnschedulableCondition(pod *corev1.Pod) *corev1.PodCondition {
+	for i := range pod.Status.Conditions {
+		cond := &pod.Status.Conditions[i]
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse && cond.Reason == corev1.PodReasonUnschedulable {
+			return cond
+		}
+	}
+	return nil
+}
@@ -5,20 +5,44 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1" // Added
 	corev1 "k8s.io/api/core/v1"
 	// metav1 "k8s.io/apimachinery/pkg/apis/meta/v1" // Not strictly needed if using appsv1.SchemeGroupVersion.String()
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
-	// flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1" // Removed
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/audit"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/metrics"
 	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
 )
 
 const (
 	PodApplyTemplateAnnotation = "flexdaemonsets.xai/apply-template" // Annotation to be placed on Pod
+
+	// PodMutatorName is the event source of the webhook.
+	PodMutatorName = "FlexDaemonSetPodMutator"
+
+	ReasonPodAnnotated     = "PodAnnotated"
+	ReasonPodDryRun        = "PodDryRun"
+	ReasonPodNotSized      = "PodNotSized"
+	ReasonPodFallback      = "PodFallbackResources"
+	ReasonPodDeniedFailure = "PodDenied"
+
+	// DefaultLookupTimeout bounds the reads made for one pod, leaving room within the 5s timeoutSeconds of
+	// manifests/webhook.yaml to answer with the failure policy.
+	DefaultLookupTimeout = 3 * time.Second
+
+	// dryRunAuditAnnotation is the key of the audit annotation the API server records for dry-run admissions.
+	dryRunAuditAnnotation = "dry-run-decision"
+	// failurePolicyAuditAnnotation records the failure policy applied to a pod that could not be marked for sizing.
+	failurePolicyAuditAnnotation = "failure-policy"
 )
 
 var log = ctrl.Log.WithName("webhook").WithName("PodMutator")
@@ -27,10 +51,50 @@ var log = ctrl.Log.WithName("webhook").WithName("PodMutator")
 type PodMutator struct {
 	Client  client.Client
 	Decoder admission.Decoder // Correct interface type for v0.18.0 (as determined previously)
+	// Recorder emits events on the owning DaemonSet. Pods under admission do not exist yet and cannot carry events.
+	Recorder record.EventRecorder
+	// Namespaces, if set, are the only namespaces the manager watches. Pods elsewhere are passed through: their
+	// DaemonSets are not in the cache and no controller would size them.
+	Namespaces []string
+	// DryRun records the sizing decision on every pod instead of marking it for sizing, as if every template
+	// were in dry-run mode.
+	DryRun bool
+	// TemplateDefaults fill in the fields templates leave unset when a dry-run decision is calculated.
+	TemplateDefaults utils.TemplateDefaults
+	// FailurePolicy applies to pods that cannot be marked for sizing when their template does not set one, or
+	// cannot be read. Defaults to Allow.
+	FailurePolicy flexdaemonsetsv1alpha1.FailurePolicy
+	// LookupTimeout bounds the reads made for one pod. Defaults to DefaultLookupTimeout.
+	LookupTimeout time.Duration
 }
 
-// Handle is the main entry point for the mutating webhook.
+// Handle is the main entry point for the mutating webhook. It records the outcome and latency of every decision.
 func (m *PodMutator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	start := time.Now()
+	resp := m.handle(ctx, req)
+	metrics.ObserveAdmission(admissionOutcome(resp), start)
+	return resp
+}
+
+// admissionOutcome classifies a response for the admission metrics.
+func admissionOutcome(resp admission.Response) string {
+	switch {
+	case !resp.Allowed && resp.AuditAnnotations[failurePolicyAuditAnnotation] != "":
+		return metrics.AdmissionDenied
+	case !resp.Allowed:
+		return metrics.AdmissionErrored
+	case resp.AuditAnnotations[dryRunAuditAnnotation] != "":
+		return metrics.AdmissionDryRun
+	case resp.AuditAnnotations[failurePolicyAuditAnnotation] == string(flexdaemonsetsv1alpha1.FailurePolicyFallback) && len(resp.Patches) > 0:
+		return metrics.AdmissionFallback
+	case len(resp.Patches) > 0:
+		return metrics.AdmissionMutated
+	default:
+		return metrics.AdmissionSkipped
+	}
+}
+
+func (m *PodMutator) handle(ctx context.Context, req admission.Request) admission.Response {
 	pod := &corev1.Pod{}
 
 	if m.Decoder == nil {
@@ -70,12 +134,24 @@ func (m *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 		return admission.Allowed("Pod is not owned by a DaemonSet.")
 	}
 
+	if len(m.Namespaces) > 0 && !slices.Contains(m.Namespaces, req.Namespace) {
+		requestLogger.Info("Namespace is not watched by the manager, skipping.")
+		return admission.Allowed("Namespace is not watched by FlexDaemonsets.")
+	}
+
+	// The reads below come from the manager's cache. They are bounded so that a cache still syncing cannot hold
+	// the request past the API server's timeout, which would apply the webhook configuration's failurePolicy
+	// instead of ours.
+	ctx, cancel := context.WithTimeout(ctx, m.lookupTimeout())
+	defer cancel()
+
 	// Fetch the owning DaemonSet
 	daemonSet := &appsv1.DaemonSet{}
 	err = m.Client.Get(ctx, types.NamespacedName{Name: daemonSetName, Namespace: req.Namespace}, daemonSet)
 	if err != nil {
 		requestLogger.Error(err, "Failed to get owning DaemonSet", "daemonSetName", daemonSetName, "namespace", req.Namespace)
-		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("failed to get owning DaemonSet %s/%s: %w", req.Namespace, daemonSetName, err))
+		return m.onFailure(req, pod, nil, nil, metrics.WebhookFailureDaemonSetUnavailable,
+			fmt.Sprintf("owning DaemonSet %s/%s could not be read: %v", req.Namespace, daemonSetName, err))
 	}
 	requestLogger.Info("Successfully fetched owning DaemonSet", "daemonSetName", daemonSetName)
 
@@ -85,8 +161,41 @@ func (m *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 		requestLogger.Info("Owning DaemonSet does not have the required annotation or annotation is empty.", "daemonSetName", daemonSetName, "annotation", utils.FlexDaemonsetTemplateAnnotation)
 		return admission.Allowed("Owning DaemonSet is not annotated for flex resource allocation.")
 	}
+	if _, releasing := daemonSet.Annotations[utils.ReleaseAnnotation]; releasing {
+		requestLogger.Info("Owning DaemonSet is being released from flex resource allocation.", "daemonSetName", daemonSetName)
+		return admission.Allowed("Owning DaemonSet is being released from flex resource allocation.")
+	}
 	requestLogger.Info("Found template annotation on DaemonSet", "templateName", templateNameFromDSAnnotation)
 
+	// Paused DaemonSets freeze sizing: the pod is admitted with the DaemonSet template's resources.
+	if reason := utils.PauseReason(daemonSet, nil); reason != "" {
+		requestLogger.Info("Sizing is paused, passing pod through", "reason", reason)
+		return admission.Allowed("Flex resource allocation is paused: " + reason)
+	}
+	template := &flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{}
+	if err := m.Client.Get(ctx, types.NamespacedName{Name: templateNameFromDSAnnotation}, template); err != nil {
+		requestLogger.Info("Could not read FlexDaemonsetTemplate", "templateName", templateNameFromDSAnnotation, "error", err.Error())
+		if errors.IsNotFound(err) {
+			return m.onFailure(req, pod, daemonSet, nil, metrics.WebhookFailureTemplateNotFound,
+				fmt.Sprintf("FlexDaemonsetTemplate %s does not exist", templateNameFromDSAnnotation))
+		}
+		return m.onFailure(req, pod, daemonSet, nil, metrics.WebhookFailureTemplateUnavailable,
+			fmt.Sprintf("FlexDaemonsetTemplate %s could not be read: %v", templateNameFromDSAnnotation, err))
+	}
+	if reason := utils.PauseReason(daemonSet, template); reason != "" {
+		requestLogger.Info("Sizing is paused, passing pod through", "reason", reason)
+		return admission.Allowed("Flex resource allocation is paused: " + reason)
+	}
+	if reason := utils.DryRunReason(m.DryRun, template); reason != "" {
+		return m.dryRun(ctx, req, pod, daemonSet, template, reason)
+	}
+	// Only errors that hold on every node are caught here; the node is not read, so a node missing from the cache
+	// does not fail the pod.
+	if err := utils.ValidateTemplateSpec(m.TemplateDefaults.Apply(&template.Spec)); err != nil {
+		return m.onFailure(req, pod, daemonSet, template, metrics.WebhookFailureTemplateInvalid,
+			fmt.Sprintf("FlexDaemonsetTemplate %s cannot be applied: %v", templateNameFromDSAnnotation, err))
+	}
+
 	// Mutate Pod to Add Annotation
 	mutatedPod := pod.DeepCopy()
 	if mutatedPod.Annotations == nil {
@@ -94,6 +203,9 @@ func (m *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 	}
 	mutatedPod.Annotations[PodApplyTemplateAnnotation] = templateNameFromDSAnnotation
 	requestLogger.Info("Annotating Pod for FlexDaemonset controller processing", "podAnnotation", PodApplyTemplateAnnotation, "templateName", templateNameFromDSAnnotation)
+	if m.Recorder != nil {
+		m.Recorder.Eventf(daemonSet, corev1.EventTypeNormal, ReasonPodAnnotated, "Pod %s will be sized by template %s once scheduled", podDisplayName(pod), templateNameFromDSAnnotation)
+	}
 
 	// Create and Return JSON Patch
 	marshaledPod, err := json.Marshal(mutatedPod)
@@ -105,4 +217,159 @@ func (m *PodMutator) Handle(ctx context.Context, req admission.Request) admissio
 	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
 }
 
+// dryRun admits the pod with its resources unchanged. The decision the pod controller would make is recorded in
+// audit.DryRunAnnotation, as an admission warning and as an audit annotation. DaemonSet pods are pinned to their
+// node by node affinity before they are created, so the node is known at admission.
+func (m *PodMutator) dryRun(ctx context.Context, req admission.Request, pod *corev1.Pod, daemonSet *appsv1.DaemonSet, template *flexdaemonsetsv1alpha1.FlexDaemonsetTemplate, reason string) admission.Response {
+	requestLogger := log.WithValues("podNamespace", req.Namespace, "podName", podDisplayName(pod), "reason", reason)
+	templateName := daemonSet.Annotations[utils.FlexDaemonsetTemplateAnnotation]
+	nodeName := utils.DaemonPodNodeName(pod)
+	if nodeName == "" {
+		return admission.Allowed("").WithWarnings("FlexDaemonsets dry-run: the pod is not pinned to a node; no decision recorded")
+	}
+	node := &corev1.Node{}
+	if err := m.Client.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+		requestLogger.Info("Could not read node for dry-run decision", "nodeName", nodeName, "error", err.Error())
+		return admission.Allowed("").WithWarnings(fmt.Sprintf("FlexDaemonsets dry-run: node %s could not be read; no decision recorded", nodeName))
+	}
+
+	allocatable, allocatableErrs := utils.EffectiveAllocatable(node)
+	for _, err := range allocatableErrs {
+		requestLogger.Info("Ignoring invalid allocatable adjustment", "nodeName", nodeName, "error", err.Error())
+	}
+	calculation, err := utils.CalculatePodResourcesWithBounds(m.TemplateDefaults.Apply(&template.Spec), allocatable)
+	if err != nil {
+		return admission.Allowed("").WithWarnings(fmt.Sprintf("FlexDaemonsets dry-run: template %s cannot be applied on node %s: %v", templateName, nodeName, err))
+	}
+	var overrides flexdaemonsetsv1alpha1.FlexNodeOverrideList
+	if err := m.Client.List(ctx, &overrides); err != nil {
+		requestLogger.Info("Could not list FlexNodeOverrides, recording the template's decision", "error", err.Error())
+	} else {
+		_, skipped := utils.ApplyNodeOverrides(calculation, overrides.Items, node, allocatable, daemonSet, templateName, time.Now())
+		for _, s := range skipped {
+			requestLogger.Info("Skipping invalid FlexNodeOverride", "override", s.Override.Name, "error", s.Err.Error())
+		}
+	}
+
+	decision := audit.NewDecision(pod, node, templateName, template.Generation, calculation)
+	encodedDecision, err := decision.Encode()
+	if err != nil {
+		requestLogger.Error(err, "Failed to encode dry-run decision")
+		return admission.Allowed("").WithWarnings("FlexDaemonsets dry-run: " + err.Error())
+	}
+	mutatedPod := pod.DeepCopy()
+	if mutatedPod.Annotations == nil {
+		mutatedPod.Annotations = make(map[string]string)
+	}
+	mutatedPod.Annotations[audit.DryRunAnnotation] = encodedDecision
+	marshaledPod, err := json.Marshal(mutatedPod)
+	if err != nil {
+		requestLogger.Error(err, "Failed to marshal mutated pod for patch")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	description := calculation.Describe()
+	requestLogger.Info("Recorded dry-run sizing decision", "nodeName", nodeName, "templateName", templateName, "resources", description)
+	metrics.DryRunDecisions.WithLabelValues(templateName, metrics.DryRunComponentWebhook).Inc()
+	if m.Recorder != nil {
+		m.Recorder.Eventf(daemonSet, corev1.EventTypeNormal, ReasonPodDryRun, "Pod %s would be sized by template %s on node %s: %s (%s)", podDisplayName(pod), templateName, nodeName, description, reason)
+	}
+	resp := admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod).
+		WithWarnings(fmt.Sprintf("FlexDaemonsets dry-run (%s): template %s would set every container's requests and limits on node %s to %s", reason, templateName, nodeName, description))
+	resp.AuditAnnotations = map[string]string{dryRunAuditAnnotation: description}
+	return resp
+}
+
+// onFailure answers for a pod that cannot be marked for sizing, following the template's failure policy if the
+// template (which may be nil, as may the DaemonSet) was read, else the manager's. A DaemonSet that could not be
+// read has no annotations to look up, so the manager's policy applies. Every policy warns the client and is
+// counted in metrics.WebhookFailures.
+func (m *PodMutator) onFailure(req admission.Request, pod *corev1.Pod, daemonSet *appsv1.DaemonSet, template *flexdaemonsetsv1alpha1.FlexDaemonsetTemplate, reason, message string) admission.Response {
+	policy := m.failurePolicy(template)
+	var fallback *corev1.ResourceRequirements
+	if template != nil {
+		fallback = template.Spec.FallbackResources
+	}
+	if policy == flexdaemonsetsv1alpha1.FailurePolicyFallback && fallback == nil {
+		message += "; no fallbackResources are set, so the pod keeps the DaemonSet template's resources"
+		policy = flexdaemonsetsv1alpha1.FailurePolicyAllow
+	}
+	if m.DryRun && policy != flexdaemonsetsv1alpha1.FailurePolicyAllow {
+		message += fmt.Sprintf("; the %s failure policy is not applied in dry-run mode", policy)
+		policy = flexdaemonsetsv1alpha1.FailurePolicyAllow
+	}
+	requestLogger := log.WithValues("podNamespace", req.Namespace, "podName", podDisplayName(pod), "reason", reason, "failurePolicy", policy)
+	metrics.WebhookFailures.WithLabelValues(reason, string(policy)).Inc()
+
+	var resp admission.Response
+	switch policy {
+	case flexdaemonsetsv1alpha1.FailurePolicyDeny:
+		requestLogger.Info("Denying pod that cannot be marked for sizing", "message", message)
+		m.eventf(daemonSet, corev1.EventTypeWarning, ReasonPodDeniedFailure, "Pod %s denied: %s", podDisplayName(pod), message)
+		resp = admission.Denied("FlexDaemonsets: " + message).
+			WithWarnings(fmt.Sprintf("FlexDaemonsets: %s; the pod is denied by the Deny failure policy", message))
+	case flexdaemonsetsv1alpha1.FailurePolicyFallback:
+		mutatedPod := pod.DeepCopy()
+		if mutatedPod.Annotations == nil {
+			mutatedPod.Annotations = make(map[string]string)
+		}
+		mutatedPod.Annotations[utils.FallbackAnnotation] = message
+		for i := range mutatedPod.Spec.InitContainers {
+			mutatedPod.Spec.InitContainers[i].Resources = *fallback.DeepCopy()
+		}
+		for i := range mutatedPod.Spec.Containers {
+			mutatedPod.Spec.Containers[i].Resources = *fallback.DeepCopy()
+		}
+		marshaledPod, err := json.Marshal(mutatedPod)
+		if err != nil {
+			requestLogger.Error(err, "Failed to marshal mutated pod for patch")
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		requestLogger.Info("Applying fallback resources to pod that cannot be marked for sizing", "message", message)
+		m.eventf(daemonSet, corev1.EventTypeWarning, ReasonPodFallback, "Pod %s given the fallback resources of template %s: %s", podDisplayName(pod), template.Name, message)
+		resp = admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod).
+			WithWarnings(fmt.Sprintf("FlexDaemonsets: %s; every container gets the fallback resources of template %s", message, template.Name))
+	default:
+		requestLogger.Info("Admitting pod that cannot be marked for sizing unchanged", "message", message)
+		m.eventf(daemonSet, corev1.EventTypeWarning, ReasonPodNotSized, "Pod %s admitted without flex sizing: %s", podDisplayName(pod), message)
+		resp = admission.Allowed("").WithWarnings(fmt.Sprintf("FlexDaemonsets: %s; the pod is admitted with the DaemonSet template's resources", message))
+	}
+	resp.AuditAnnotations = map[string]string{failurePolicyAuditAnnotation: string(policy)}
+	return resp
+}
+
+// failurePolicy returns the template's failure policy if it sets one, else the manager's, defaulting to Allow.
+func (m *PodMutator) failurePolicy(template *flexdaemonsetsv1alpha1.FlexDaemonsetTemplate) flexdaemonsetsv1alpha1.FailurePolicy {
+	if template != nil && template.Spec.FailurePolicy != "" {
+		return template.Spec.FailurePolicy
+	}
+	if m.FailurePolicy != "" {
+		return m.FailurePolicy
+	}
+	return flexdaemonsetsv1alpha1.FailurePolicyAllow
+}
+
+func (m *PodMutator) lookupTimeout() time.Duration {
+	if m.LookupTimeout > 0 {
+		return m.LookupTimeout
+	}
+	return DefaultLookupTimeout
+}
+
+// eventf records an event on the DaemonSet, if it was read and a recorder is set.
+func (m *PodMutator) eventf(daemonSet *appsv1.DaemonSet, eventType, reason, messageFmt string, args ...interface{}) {
+	if m.Recorder == nil || daemonSet == nil {
+		return
+	}
+	m.Recorder.Eventf(daemonSet, eventType, reason, messageFmt, args...)
+}
+
+// podDisplayName returns the pod's name, or its generateName prefix if the name has not been assigned yet.
+func podDisplayName(pod *corev1.Pod) string {
+	if pod.Name != "" {
+		return pod.Name
+	}
+	return pod.GenerateName + "<generated>"
+}
+
 var _ admission.Handler = &PodMutator{}
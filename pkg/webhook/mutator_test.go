@@ -0,0 +1,258 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	flexdaemonsetsv1alpha1 "github.com/prakarsh-dt/FlexDaemonsets/pkg/apis/flexdaemonsets/v1alpha1"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/metrics"
+	"github.com/prakarsh-dt/FlexDaemonsets/pkg/utils"
+)
+
+const (
+	testNamespace = "monitoring"
+	testDaemonSet = "node-exporter"
+	testTemplate  = "small"
+)
+
+func testScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := flexdaemonsetsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func testDaemonSetObject() *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{
+		Name:        testDaemonSet,
+		Namespace:   testNamespace,
+		Annotations: map[string]string{utils.FlexDaemonsetTemplateAnnotation: testTemplate},
+	}}
+}
+
+func testTemplateObject(mutate func(*flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec)) *flexdaemonsetsv1alpha1.FlexDaemonsetTemplate {
+	template := &flexdaemonsetsv1alpha1.FlexDaemonsetTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: testTemplate},
+		Spec:       flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec{CPUPercentage: 10, MemoryPercentage: 10, StoragePercentage: 10},
+	}
+	if mutate != nil {
+		mutate(&template.Spec)
+	}
+	return template
+}
+
+// podRequest returns the admission request creating a pod of testDaemonSet, or of no controller if owned is unset.
+func podRequest(t *testing.T, owned bool) admission.Request {
+	t.Helper()
+	pod := &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{GenerateName: testDaemonSet + "-", Namespace: testNamespace},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "exporter", Image: "exporter:v1"}}},
+	}
+	if owned {
+		pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "DaemonSet", Name: testDaemonSet}}
+	}
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		UID:       "req-uid",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Namespace: testNamespace,
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}}
+}
+
+// failDaemonSetReads makes every DaemonSet read fail as if the cache could not answer in time.
+var failDaemonSetReads = interceptor.Funcs{
+	Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+		if _, ok := obj.(*appsv1.DaemonSet); ok {
+			return errors.NewTimeoutError("cache not synced", 1)
+		}
+		return c.Get(ctx, key, obj, opts...)
+	},
+}
+
+func TestHandleFailurePolicies(t *testing.T) {
+	fallbackResources := &corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+	}
+	invalidMinimum := func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) { s.MinCPU = "lots" }
+
+	tests := []struct {
+		name          string
+		objs          []client.Object
+		funcs         *interceptor.Funcs
+		notOwned      bool
+		globalPolicy  flexdaemonsetsv1alpha1.FailurePolicy
+		dryRun        bool
+		wantOutcome   string
+		wantPolicy    flexdaemonsetsv1alpha1.FailurePolicy
+		wantFallback  bool
+		wantNoWarning bool
+	}{
+		{
+			name:          "not a DaemonSet pod",
+			notOwned:      true,
+			globalPolicy:  flexdaemonsetsv1alpha1.FailurePolicyDeny,
+			wantOutcome:   metrics.AdmissionSkipped,
+			wantNoWarning: true,
+		},
+		{
+			name:          "valid template marks the pod",
+			objs:          []client.Object{testDaemonSetObject(), testTemplateObject(nil)},
+			globalPolicy:  flexdaemonsetsv1alpha1.FailurePolicyDeny,
+			wantOutcome:   metrics.AdmissionMutated,
+			wantNoWarning: true,
+		},
+		{
+			name:        "unreadable DaemonSet defaults to Allow",
+			funcs:       &failDaemonSetReads,
+			wantOutcome: metrics.AdmissionSkipped,
+			wantPolicy:  flexdaemonsetsv1alpha1.FailurePolicyAllow,
+		},
+		{
+			name:         "unreadable DaemonSet follows the global Deny",
+			funcs:        &failDaemonSetReads,
+			globalPolicy: flexdaemonsetsv1alpha1.FailurePolicyDeny,
+			wantOutcome:  metrics.AdmissionDenied,
+			wantPolicy:   flexdaemonsetsv1alpha1.FailurePolicyDeny,
+		},
+		{
+			name:         "unreadable DaemonSet has no fallback resources",
+			funcs:        &failDaemonSetReads,
+			globalPolicy: flexdaemonsetsv1alpha1.FailurePolicyFallback,
+			wantOutcome:  metrics.AdmissionSkipped,
+			wantPolicy:   flexdaemonsetsv1alpha1.FailurePolicyAllow,
+		},
+		{
+			name:         "missing template follows the global Deny",
+			objs:         []client.Object{testDaemonSetObject()},
+			globalPolicy: flexdaemonsetsv1alpha1.FailurePolicyDeny,
+			wantOutcome:  metrics.AdmissionDenied,
+			wantPolicy:   flexdaemonsetsv1alpha1.FailurePolicyDeny,
+		},
+		{
+			name: "invalid template with its own Fallback",
+			objs: []client.Object{testDaemonSetObject(), testTemplateObject(func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) {
+				invalidMinimum(s)
+				s.FailurePolicy, s.FallbackResources = flexdaemonsetsv1alpha1.FailurePolicyFallback, fallbackResources
+			})},
+			globalPolicy: flexdaemonsetsv1alpha1.FailurePolicyDeny,
+			wantOutcome:  metrics.AdmissionFallback,
+			wantPolicy:   flexdaemonsetsv1alpha1.FailurePolicyFallback,
+			wantFallback: true,
+		},
+		{
+			name: "invalid template Deny overrides the global Allow",
+			objs: []client.Object{testDaemonSetObject(), testTemplateObject(func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) {
+				invalidMinimum(s)
+				s.FailurePolicy = flexdaemonsetsv1alpha1.FailurePolicyDeny
+			})},
+			globalPolicy: flexdaemonsetsv1alpha1.FailurePolicyAllow,
+			wantOutcome:  metrics.AdmissionDenied,
+			wantPolicy:   flexdaemonsetsv1alpha1.FailurePolicyDeny,
+		},
+		{
+			name:         "invalid template Fallback without fallback resources",
+			objs:         []client.Object{testDaemonSetObject(), testTemplateObject(invalidMinimum)},
+			globalPolicy: flexdaemonsetsv1alpha1.FailurePolicyFallback,
+			wantOutcome:  metrics.AdmissionSkipped,
+			wantPolicy:   flexdaemonsetsv1alpha1.FailurePolicyAllow,
+		},
+		{
+			name:         "dry-run never denies",
+			funcs:        &failDaemonSetReads,
+			globalPolicy: flexdaemonsetsv1alpha1.FailurePolicyDeny,
+			dryRun:       true,
+			wantOutcome:  metrics.AdmissionSkipped,
+			wantPolicy:   flexdaemonsetsv1alpha1.FailurePolicyAllow,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := testScheme(t)
+			builder := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.objs...)
+			if tt.funcs != nil {
+				builder = builder.WithInterceptorFuncs(*tt.funcs)
+			}
+			m := &PodMutator{
+				Client:        builder.Build(),
+				Decoder:       admission.NewDecoder(scheme),
+				FailurePolicy: tt.globalPolicy,
+				DryRun:        tt.dryRun,
+			}
+
+			resp := m.Handle(context.Background(), podRequest(t, !tt.notOwned))
+			if got := admissionOutcome(resp); got != tt.wantOutcome {
+				t.Errorf("outcome = %s, want %s (%+v)", got, tt.wantOutcome, resp.Result)
+			}
+			if got := resp.AuditAnnotations[failurePolicyAuditAnnotation]; got != string(tt.wantPolicy) {
+				t.Errorf("failure-policy audit annotation = %q, want %q", got, tt.wantPolicy)
+			}
+			if tt.wantNoWarning != (len(resp.Warnings) == 0) {
+				t.Errorf("warnings = %v", resp.Warnings)
+			}
+			fallbackPatched := false
+			for _, patch := range resp.Patches {
+				if strings.HasPrefix(patch.Path, "/spec/containers/0/resources") {
+					fallbackPatched = true
+				}
+			}
+			if fallbackPatched != tt.wantFallback {
+				t.Errorf("fallback resources patched = %v, want %v (patches %v)", fallbackPatched, tt.wantFallback, resp.Patches)
+			}
+		})
+	}
+}
+
+func TestFailurePolicyPrecedence(t *testing.T) {
+	tests := []struct {
+		global   flexdaemonsetsv1alpha1.FailurePolicy
+		template flexdaemonsetsv1alpha1.FailurePolicy
+		want     flexdaemonsetsv1alpha1.FailurePolicy
+	}{
+		{want: flexdaemonsetsv1alpha1.FailurePolicyAllow},
+		{global: flexdaemonsetsv1alpha1.FailurePolicyDeny, want: flexdaemonsetsv1alpha1.FailurePolicyDeny},
+		{global: flexdaemonsetsv1alpha1.FailurePolicyDeny, template: flexdaemonsetsv1alpha1.FailurePolicyFallback, want: flexdaemonsetsv1alpha1.FailurePolicyFallback},
+		{template: flexdaemonsetsv1alpha1.FailurePolicyDeny, want: flexdaemonsetsv1alpha1.FailurePolicyDeny},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("global=%s,template=%s", tt.global, tt.template), func(t *testing.T) {
+			m := &PodMutator{FailurePolicy: tt.global}
+			template := testTemplateObject(func(s *flexdaemonsetsv1alpha1.FlexDaemonsetTemplateSpec) { s.FailurePolicy = tt.template })
+			if got := m.failurePolicy(template); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if tt.template == "" {
+				if got := m.failurePolicy(nil); got != tt.want {
+					t.Errorf("without template: got %s, want %s", got, tt.want)
+				}
+			}
+		})
+	}
+}